@@ -4,13 +4,14 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"order_service/internal/auth"
 	"order_service/internal/cache"
 	"order_service/internal/config"
-	"order_service/internal/database"
+	"order_service/internal/encryption"
 	"order_service/internal/handler"
 	"order_service/internal/middleware"
+	"order_service/internal/pii"
 	"order_service/internal/queue"
-	"order_service/internal/repository"
 	"order_service/internal/service"
 	"os"
 	"os/signal"
@@ -25,37 +26,122 @@ func main() {
 	// Загрузка конфига
 	cfg := config.MustLoad()
 
-	// Инициализация подключения к базе данных
-	db, err := database.InitDB(cfg)
+	// Ключи шифрования персональных данных (nil, если шифрование выключено)
+	keyring, err := encryption.LoadKeyring(cfg)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Failed to load encryption keys: %v", err)
 	}
-	defer db.Close()
 
-	// Инициализация кэша
-	c := cache.NewCache(cfg)
-	err = c.Ping()
+	// Токены вызывающих: без них все запросы анонимны и защищенные эндпоинты закрыты
+	tokens, err := auth.LoadTokens(cfg)
 	if err != nil {
-		log.Fatalf("Failed to connect to cache: %v", err)
+		log.Fatalf("Failed to load auth tokens: %v", err)
 	}
-	defer c.Close()
+	if len(tokens) == 0 {
+		log.Println("No auth tokens configured, privileged endpoints will reject all requests")
+	}
+
+	// Хранилище заказов из storage.driver: Postgres, а для локальной разработки SQLite или память.
+	// Фоновые задачи останавливаются до закрытия соединений
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	store, err := openStorage(bgCtx, cfg, keyring)
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.close()
+	defer stopBackground() // фоновые задачи хранилища и кэша
+
+	// Инициализация кэша. Без Redis сервис стартует в деградированном режиме и читает из БД,
+	// а выключатель в фоне ждет восстановления Redis
+	redisCache, err := cache.NewCache(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure cache: %v", err)
+	}
+	c, err := cache.NewBreakerCache(redisCache, cfg.Cache.Breaker)
+	if err != nil {
+		log.Fatalf("Failed to configure cache: %v", err)
+	}
+	if err := redisCache.Ping(); err != nil {
+		log.Printf("Cache is unavailable, starting in degraded mode: %v", err)
+		c.Trip(err)
+	}
+	go c.Run(bgCtx)
+
+	// Локальный уровень кэша в памяти реплики с инвалидацией через Redis pub/sub.
+	// Кэш в памяти уже локальный, второй уровень ему не нужен
+	var orderCache cache.Cache = c
+	if cfg.Cache.Local.Enabled && cfg.Cache.Mode != cache.ModeMemory {
+		tiered, err := cache.NewTieredCache(c, cfg)
+		if err != nil {
+			log.Fatalf("Failed to configure local cache: %v", err)
+		}
+		go tiered.Run(bgCtx)
+		orderCache = tiered
+	}
+	defer orderCache.Close()
 
-	// Инициализация слоев
-	repo := repository.NewOrderRepository(db)
-	svc := service.NewOrderService(repo, cfg, c)
-	h := handler.NewOrderHandler(svc, cfg)
+	// Правила маскирования персональных данных
+	masker, err := pii.NewMasker(cfg.Pii.Rules)
+	if err != nil {
+		log.Fatalf("Failed to load PII rules: %v", err)
+	}
+
+	// Брокер сообщений из broker.driver: Kafka, NATS JetStream или канал в памяти процесса
+	broker, err := queue.NewBroker(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to broker: %v", err)
+	}
+	defer broker.Close()
+	publisher, err := broker.NewPublisher()
+	if err != nil {
+		log.Fatalf("Failed to create publisher: %v", err)
+	}
+	defer publisher.Close()
+
+	svc := service.NewOrderService(store.orders, store.audit, cfg, orderCache)
+	h := handler.NewOrderHandler(svc, cfg, masker, publisher)
+	health := handler.NewHealthHandler(store.health, c)
 
 	// Настройка маршрутизатора chi
 	r := chi.NewRouter()
 
-	// Подключаем логгер
-	r.Use(middleware.RequestLogger)
+	// Подключаем сжатие, логгер, аутентификацию и контекст аудита.
+	// Сжатие — внешний слой, чтобы логгер видел тела ответов до сжатия
+	r.Use(middleware.Compress(cfg.HttpServer.CompressMinSize))
+	r.Use(middleware.RequestLogger(masker))
+	r.Use(auth.Middleware(tokens))
+	r.Use(middleware.AuditContext)
+
+	// Проверки здоровья
+	r.Get("/healthz", health.Live) // GET /healthz -> процесс жив
+	r.Get("/readyz", health.Ready) // GET /readyz -> готовность: БД обязательна, кэш — нет
 
 	// Работа с заказами
 	r.Route("/order", func(r chi.Router) {
-		r.Get("/{orderID}", h.GetOrderByID)  // GET /order/{id} -> получить заказ по ID
-		r.Get("/generate", h.GenerateOrders) // GET /order/generate?count=N -> сгенерировать N заказов
-		r.Post("/", h.SendOrderToKafka)      // POST /order -> отправить заказ в Kafka
+		r.Get("/{orderID}", h.GetOrderByID)        // GET /order/{id} -> получить заказ по ID
+		r.Patch("/{orderID}", h.UpdateOrder)       // PATCH /order/{id} -> изменить заказ (If-Match обязателен)
+		r.Get("/generate", h.GenerateOrders)       // GET /order/generate?count=N -> сгенерировать N заказов
+		r.Post("/", h.SendOrderToKafka)            // POST /order -> отправить заказ в брокер
+		r.Delete("/{orderID}", h.DeleteOrder)      // DELETE /order/{id} -> мягко удалить заказ
+		r.Post("/{orderID}/erase", h.EraseOrder)   // POST /order/{id}/erase -> стереть персональные данные
+		r.Get("/{orderID}/audit", h.GetOrderAudit) // GET /order/{id}/audit -> журнал аудита заказа
+	})
+
+	// Администрирование кэша
+	r.Route("/admin/cache", func(r chi.Router) {
+		r.Post("/orders/{orderID}/invalidate", h.InvalidateCache) // POST -> убрать заказ из кэша всех реплик
+		r.Post("/flush", h.FlushCache)                            // POST -> сбросить кэш заказов
+	})
+
+	// Работа с покупателями
+	r.Route("/customers", func(r chi.Router) {
+		r.Post("/{customerID}/erase", h.EraseCustomer) // POST /customers/{id}/erase -> стереть данные во всех заказах
+	})
+
+	// Работа с наборами заказов
+	r.Route("/orders", func(r chi.Router) {
+		r.Get("/", h.ListOrders)         // GET /orders?customer_id=...&limit=N -> листинг заказов
+		r.Get("/export", h.ExportOrders) // GET /orders/export?format=csv|ndjson|parquet -> выгрузка заказов
 	})
 
 	// Раздача статических файлов (фронтенд)
@@ -66,10 +152,10 @@ func main() {
 		http.Error(w, "Not Found", http.StatusNotFound)
 	})
 
-	// Инициализация кафки
-	cns, err := queue.NewKafkaConsumer(svc, cfg)
+	// Чтение заказов из брокера
+	cns, err := queue.NewConsumer(svc, cfg, broker)
 	if err != nil {
-		log.Fatalf("Failed to initialize kafka: %v", err)
+		log.Fatalf("Failed to initialize consumer: %v", err)
 	}
 	cns.Start()
 	defer cns.Stop()
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"order_service/internal/cache"
+	"order_service/internal/config"
+	"order_service/internal/database"
+	"order_service/internal/encryption"
+	"order_service/internal/queue"
+	"order_service/internal/repository"
+	"order_service/internal/service"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// app лениво поднимает зависимости: команда открывает только те соединения, которые ей нужны.
+// Ошибки подключения возвращаются команде, чтобы main закрыл уже открытые соединения.
+type app struct {
+	cfg      *config.Config
+	db       *pgxpool.Pool
+	shards   []*pgxpool.Pool
+	sqlite   *sql.DB
+	cache    cache.Cache
+	broker   queue.Broker
+	producer queue.Publisher
+}
+
+func newApp() *app {
+	return &app{}
+}
+
+func (a *app) config() (*config.Config, error) {
+	if a.cfg == nil {
+		cfg, err := config.Load()
+		if err != nil {
+			return nil, err
+		}
+		a.cfg = cfg
+	}
+	return a.cfg, nil
+}
+
+func (a *app) database() (*pgxpool.Pool, error) {
+	if a.db == nil {
+		cfg, err := a.config()
+		if err != nil {
+			return nil, err
+		}
+		db, err := database.InitDB(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+		a.db = db
+	}
+	return a.db, nil
+}
+
+// databases возвращает основную БД и шарды 1..N из data_base.shards.
+func (a *app) databases() ([]*pgxpool.Pool, error) {
+	db, err := a.database()
+	if err != nil {
+		return nil, err
+	}
+	if a.shards == nil {
+		shards, err := database.InitShards(a.cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to database shards: %w", err)
+		}
+		a.shards = shards
+	}
+	return append([]*pgxpool.Pool{db}, a.shards...), nil
+}
+
+// sqliteDB открывает файл storage.path, если storage.driver — sqlite.
+func (a *app) sqliteDB() (*sql.DB, error) {
+	if a.sqlite == nil {
+		cfg, err := a.config()
+		if err != nil {
+			return nil, err
+		}
+		db, err := database.OpenSQLite(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open sqlite storage: %w", err)
+		}
+		a.sqlite = db
+	}
+	return a.sqlite, nil
+}
+
+// storageDriver возвращает storage.driver. Хранилище в памяти живет только внутри сервиса,
+// orderctl до него не достать.
+func (a *app) storageDriver() (string, error) {
+	cfg, err := a.config()
+	if err != nil {
+		return "", err
+	}
+	if cfg.Storage.Driver == database.DriverMemory {
+		return "", errors.New("storage driver memory is not shared with the service, orderctl needs postgres or sqlite")
+	}
+	return cfg.Storage.Driver, nil
+}
+
+func (a *app) keyring() (*encryption.Keyring, error) {
+	cfg, err := a.config()
+	if err != nil {
+		return nil, err
+	}
+	keyring, err := encryption.LoadKeyring(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load encryption keys: %w", err)
+	}
+	return keyring, nil
+}
+
+func (a *app) repo() (repository.OrderRepository, error) {
+	driver, err := a.storageDriver()
+	if err != nil {
+		return nil, err
+	}
+	if driver == database.DriverSQLite {
+		db, err := a.sqliteDB()
+		if err != nil {
+			return nil, err
+		}
+		return repository.NewSQLiteOrderRepository(db), nil
+	}
+	return a.postgresRepo()
+}
+
+// postgresRepo — репозиторий заказов Postgres с учетом шардов.
+func (a *app) postgresRepo() (repository.OrderRepository, error) {
+	dbs, err := a.databases()
+	if err != nil {
+		return nil, err
+	}
+	if len(dbs) > 1 {
+		return a.shardedRepo()
+	}
+	keyring, err := a.keyring()
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewOrderRepository(dbs[0], keyring), nil
+}
+
+func (a *app) shardedRepo() (*repository.ShardedRepository, error) {
+	dbs, err := a.databases()
+	if err != nil {
+		return nil, err
+	}
+	keyring, err := a.keyring()
+	if err != nil {
+		return nil, err
+	}
+	repo, err := repository.NewShardedRepository(dbs, keyring, a.cfg.Database.Shards.Lookup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure database shards: %w", err)
+	}
+	return repo, nil
+}
+
+func (a *app) auditLog() (repository.AuditRepository, error) {
+	driver, err := a.storageDriver()
+	if err != nil {
+		return nil, err
+	}
+	if driver == database.DriverSQLite {
+		db, err := a.sqliteDB()
+		if err != nil {
+			return nil, err
+		}
+		return repository.NewSQLiteAuditRepository(db), nil
+	}
+	return a.postgresAuditLog()
+}
+
+// postgresAuditLog — журнал аудита Postgres с учетом шардов.
+func (a *app) postgresAuditLog() (repository.AuditRepository, error) {
+	dbs, err := a.databases()
+	if err != nil {
+		return nil, err
+	}
+	if len(dbs) > 1 {
+		return repository.NewShardedAuditRepository(dbs), nil
+	}
+	return repository.NewAuditRepository(dbs[0]), nil
+}
+
+// orderCache — кэш за выключателем, как в сервисе: без Redis команды работают с БД, а удаления
+// из кэша, которые не удалось сделать, остаются до TTL записей.
+func (a *app) orderCache() (cache.Cache, error) {
+	if a.cache == nil {
+		cfg, err := a.config()
+		if err != nil {
+			return nil, err
+		}
+		c, err := cache.NewCache(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure cache: %w", err)
+		}
+		breaker, err := cache.NewBreakerCache(c, cfg.Cache.Breaker)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure cache: %w", err)
+		}
+		if err := c.Ping(); err != nil {
+			log.Printf("Cache is unavailable, working without it: %v", err)
+			breaker.Trip(err)
+		}
+		a.cache = breaker
+	}
+	return a.cache, nil
+}
+
+func (a *app) service() (service.OrderService, error) {
+	repo, err := a.repo()
+	if err != nil {
+		return nil, err
+	}
+	auditLog, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	c, err := a.orderCache()
+	if err != nil {
+		return nil, err
+	}
+	return service.NewOrderService(repo, auditLog, a.cfg, c), nil
+}
+
+// messageBroker подключается к брокеру из broker.driver. Канал в памяти живет только внутри сервиса,
+// orderctl до него не достать.
+func (a *app) messageBroker() (queue.Broker, error) {
+	if a.broker == nil {
+		cfg, err := a.config()
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Broker.Driver == queue.BrokerChannel {
+			return nil, errors.New("broker channel is not shared with the service, orderctl needs kafka or nats")
+		}
+		b, err := queue.NewBroker(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to broker: %w", err)
+		}
+		a.broker = b
+	}
+	return a.broker, nil
+}
+
+func (a *app) orderProducer() (queue.Publisher, error) {
+	if a.producer == nil {
+		b, err := a.messageBroker()
+		if err != nil {
+			return nil, err
+		}
+		p, err := b.NewPublisher()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create publisher: %w", err)
+		}
+		a.producer = p
+	}
+	return a.producer, nil
+}
+
+// close закрывает все открытые соединения.
+func (a *app) close() {
+	if a.producer != nil {
+		a.producer.Close()
+	}
+	if a.broker != nil {
+		a.broker.Close()
+	}
+	if a.cache != nil {
+		a.cache.Close()
+	}
+	if a.db != nil {
+		a.db.Close()
+	}
+	for _, shard := range a.shards {
+		shard.Close()
+	}
+	if a.sqlite != nil {
+		a.sqlite.Close()
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"order_service/internal/repository"
+)
+
+// runArchive выгружает в dir секции заказов старше retention и удаляет их из БД.
+// Каждый месяц пишется в orders-YYYY-MM.ndjson.gz (при шардировании — orders-YYYY-MM.shardN.ndjson.gz);
+// файл появляется под итоговым именем только целиком записанным, иначе секции месяца остаются в БД.
+// Устаревшие заказы из секций *_default не архивируются: о них выводится предупреждение.
+func runArchive(ctx context.Context, a *app, args []string) error {
+	cfg, err := a.config()
+	if err != nil {
+		return err
+	}
+	partitionsCfg := cfg.Database.Partitions
+	fs := flag.NewFlagSet("archive", flag.ContinueOnError)
+	retention := fs.Duration("retention", partitionsCfg.Retention, "хранить в БД заказы не старше")
+	dir := fs.String("dir", partitionsCfg.ArchiveDir, "каталог архивов")
+	dryRun := fs.Bool("dry-run", false, "только показать устаревшие месяцы")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+
+	dbs, err := a.databases()
+	if err != nil {
+		return err
+	}
+	for i, db := range dbs {
+		partitions, err := repository.NewPartitionManager(db, partitionsCfg)
+		if err != nil {
+			return err
+		}
+		shard := ""
+		if len(dbs) > 1 {
+			shard = fmt.Sprintf("shard%d", i)
+		}
+		if err := archiveDatabase(ctx, partitions, shard, *retention, *dir, *dryRun); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveDatabase архивирует устаревшие месяцы одной БД. shard — метка шарда в именах архивов
+// и сообщениях, пустая без шардирования.
+func archiveDatabase(ctx context.Context, partitions *repository.PartitionManager, shard string,
+	retention time.Duration, dir string, dryRun bool) error {
+	now := time.Now()
+	months, err := partitions.Expired(ctx, now, retention)
+	if err != nil {
+		return err
+	}
+	label := func(month time.Time) string {
+		if shard == "" {
+			return month.Format("2006-01")
+		}
+		return month.Format("2006-01") + "." + shard
+	}
+	stale, err := partitions.ExpiredInDefault(ctx, now, retention)
+	if err != nil {
+		return err
+	}
+	if stale > 0 {
+		where := "orders_default"
+		if shard != "" {
+			where += " of " + shard
+		}
+		log.Printf("%d orders older than %s are in %s and are not archived; "+
+			"move them to monthly partitions manually", stale, retention, where)
+	}
+	if len(months) == 0 {
+		if shard != "" {
+			log.Printf("No partitions older than %s in %s", retention, shard)
+		} else {
+			log.Printf("No partitions older than %s", retention)
+		}
+		return nil
+	}
+	if dryRun {
+		for _, month := range months {
+			fmt.Println(label(month))
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	for _, month := range months {
+		name := fmt.Sprintf("orders-%s.ndjson.gz", label(month))
+		path := filepath.Join(dir, name)
+		w, err := newArchiveFile(path)
+		if err != nil {
+			return err
+		}
+		n, err := partitions.Archive(ctx, month, name, w)
+		if err != nil {
+			w.abort()
+			return fmt.Errorf("archive %s: %w", label(month), err)
+		}
+		log.Printf("Archived %d orders of %s to %s", n, label(month), path)
+	}
+	return nil
+}
+
+// archiveFile пишет сжатый архив во временный файл и переименовывает его в итоговый при Close.
+type archiveFile struct {
+	*gzip.Writer
+	f    *os.File
+	path string
+}
+
+func newArchiveFile(path string) (*archiveFile, error) {
+	f, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	return &archiveFile{Writer: gzip.NewWriter(f), f: f, path: path}, nil
+}
+
+func (w *archiveFile) Close() error {
+	if err := w.Writer.Close(); err != nil {
+		return err
+	}
+	if err := w.f.Sync(); err != nil {
+		return err
+	}
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(w.f.Name(), w.path)
+}
+
+// abort удаляет недописанный архив. После успешного Close временного файла уже нет.
+func (w *archiveFile) abort() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
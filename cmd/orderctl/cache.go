@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"order_service/internal/cache"
+)
+
+func runCache(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	c, err := a.orderCache()
+	if err != nil {
+		return err
+	}
+	// Команды кэша без Redis не имеют смысла: при недоступном Redis выключатель возвращает ошибку
+	switch args[0] {
+	case "invalidate":
+		if len(args) != 2 {
+			return errUsage
+		}
+		if err := c.DeleteOrder(ctx, args[1]); err != nil {
+			return err
+		}
+		// Реплики сервиса удалят заказ и из локальных уровней
+		if err := c.Invalidate(ctx, args[1]); err != nil {
+			return err
+		}
+		log.Printf("Cache entry %s invalidated", args[1])
+	case "flush":
+		if err := c.Flush(ctx); err != nil {
+			return err
+		}
+		if err := c.Invalidate(ctx, cache.InvalidateAll); err != nil {
+			return err
+		}
+		log.Println("Cache flushed")
+	default:
+		return errUsage
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"order_service/internal/queue"
+)
+
+func runReplayDLQ(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("replay-dlq", flag.ExitOnError)
+	idle := fs.Duration("idle", 5*time.Second, "остановиться, если новых сообщений нет дольше этого времени")
+	fs.Parse(args)
+
+	cfg, err := a.config()
+	if err != nil {
+		return err
+	}
+	broker, err := a.messageBroker()
+	if err != nil {
+		return err
+	}
+	producer, err := a.orderProducer()
+	if err != nil {
+		return err
+	}
+	n, err := queue.ReplayDLQ(ctx, cfg, broker, producer, *idle)
+	log.Printf("Replayed %d messages from %s", n, cfg.Kafka.DlqTopic)
+	return err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"order_service/internal/repository"
+)
+
+func runEncrypt(ctx context.Context, a *app, args []string) error {
+	return runEncryptionBatches(ctx, a, "encrypt", args, repository.DeliveryEncryptor.EncryptBatch)
+}
+
+func runRekey(ctx context.Context, a *app, args []string) error {
+	return runEncryptionBatches(ctx, a, "rekey", args, repository.DeliveryEncryptor.RekeyBatch)
+}
+
+// runEncryptionBatches гоняет пакетную операцию в основной БД и каждом шарде, пока она
+// не перестанет находить строки.
+func runEncryptionBatches(ctx context.Context, a *app, name string, args []string,
+	batchFn func(repository.DeliveryEncryptor, context.Context, int) (int, error)) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	batch := fs.Int("batch", 500, "строк в одной транзакции")
+	fs.Parse(args)
+
+	dbs, err := a.databases()
+	if err != nil {
+		return err
+	}
+	keyring, err := a.keyring()
+	if err != nil {
+		return err
+	}
+	total := 0
+	for _, db := range dbs {
+		enc, err := repository.NewDeliveryEncryptor(db, keyring)
+		if err != nil {
+			return err
+		}
+		for {
+			n, err := batchFn(enc, ctx, *batch)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				break
+			}
+			total += n
+			log.Printf("%s: %d rows processed", name, total)
+		}
+	}
+	log.Printf("%s: done, %d rows", name, total)
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"order_service/internal/loadgen"
+)
+
+func runLoadgen(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("loadgen", flag.ExitOnError)
+	var cfg loadgen.Config
+	fs.Float64Var(&cfg.Rate, "rate", 100, "целевая скорость, заказов в секунду")
+	fs.DurationVar(&cfg.Duration, "duration", time.Minute, "длительность отправки")
+	fs.DurationVar(&cfg.RampUp, "ramp-up", 10*time.Second, "время разгона до целевой скорости")
+	fs.DurationVar(&cfg.Timeout, "timeout", 30*time.Second, "сколько ждать появления заказа")
+	fs.DurationVar(&cfg.PollInterval, "poll", 50*time.Millisecond, "интервал опроса заказа")
+	fs.IntVar(&cfg.Concurrency, "concurrency", 1000, "максимум заказов в полете")
+	output := outputFlag(fs)
+	gen := generatorFlags(fs)
+	fs.Parse(args)
+
+	g, err := gen()
+	if err != nil {
+		return err
+	}
+	producer, err := a.orderProducer()
+	if err != nil {
+		return err
+	}
+	repo, err := a.repo()
+	if err != nil {
+		return err
+	}
+	runner, err := loadgen.NewRunner(producer, repo, g, cfg)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Load test: %.0f orders/sec for %s (ramp-up %s), seed %d, base time %s",
+		cfg.Rate, cfg.Duration, cfg.RampUp, g.Seed(), g.BaseTime().Format(time.RFC3339Nano))
+	report, err := runner.Run(ctx)
+	if report != nil {
+		if perr := printReport(*output, report); perr != nil {
+			return perr
+		}
+	}
+	return err
+}
+
+func printReport(format string, r *loadgen.Report) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case "table":
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "sent\t%d\n", r.Sent)
+		fmt.Fprintf(tw, "publish errors\t%d\n", r.PublishErr)
+		fmt.Fprintf(tw, "completed\t%d\n", r.Completed)
+		fmt.Fprintf(tw, "timed out\t%d\n", r.TimedOut)
+		fmt.Fprintf(tw, "elapsed\t%s\n", r.Elapsed.Round(time.Millisecond))
+		fmt.Fprintf(tw, "throughput\t%.1f orders/sec\n", r.Throughput)
+		fmt.Fprintf(tw, "p50\t%s\n", r.P50)
+		fmt.Fprintf(tw, "p95\t%s\n", r.P95)
+		fmt.Fprintf(tw, "p99\t%s\n", r.P99)
+		fmt.Fprintf(tw, "max\t%s\n", r.Max)
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown output format: %s", format)
+}
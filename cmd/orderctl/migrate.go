@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"order_service/internal/database"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// runMigrate применяет или откатывает встроенные миграции в основной БД и во всех шардах.
+// Подключение открывается без проверки схемы: именно эта команда ее и чинит.
+func runMigrate(ctx context.Context, a *app, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	switch args[0] {
+	case database.MigrateUp, database.MigrateDown, database.MigrateStatus, database.MigrateRedo:
+	default:
+		return errUsage
+	}
+
+	cfg, err := a.config()
+	if err != nil {
+		return err
+	}
+	db, err := database.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	shards, err := database.OpenShards(cfg)
+	if err != nil {
+		return err
+	}
+	for _, shard := range shards {
+		defer shard.Close()
+	}
+
+	if len(shards) == 0 {
+		return database.Migrate(ctx, db, args[0], os.Stdout)
+	}
+	for i, pool := range append([]*pgxpool.Pool{db}, shards...) {
+		fmt.Printf("shard %d:\n", i)
+		if err := database.Migrate(ctx, pool, args[0], os.Stdout); err != nil {
+			return fmt.Errorf("shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
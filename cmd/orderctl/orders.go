@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"order_service/internal/audit"
+	"order_service/internal/domain"
+	"order_service/internal/export"
+	"order_service/internal/message"
+)
+
+// filterFlags регистрирует флаги фильтра заказов и возвращает функцию их разбора.
+func filterFlags(fs *flag.FlagSet) func() (domain.OrderFilter, error) {
+	var filter domain.OrderFilter
+	fs.StringVar(&filter.CustomerID, "customer", "", "фильтр по customer_id")
+	fs.StringVar(&filter.TrackNumber, "track", "", "фильтр по track_number")
+	fs.StringVar(&filter.DeliveryService, "delivery-service", "", "фильтр по delivery_service")
+	fs.StringVar(&filter.Locale, "locale", "", "фильтр по locale")
+	fs.StringVar(&filter.Email, "email", "", "фильтр по email покупателя")
+	fs.StringVar(&filter.Phone, "phone", "", "фильтр по телефону покупателя")
+	fs.IntVar(&filter.Limit, "limit", 0, "максимальное число заказов")
+	from := fs.String("from", "", "date_created >= (YYYY-MM-DD или RFC3339)")
+	to := fs.String("to", "", "date_created < (YYYY-MM-DD или RFC3339)")
+
+	return func() (domain.OrderFilter, error) {
+		var err error
+		if filter.DateFrom, err = parseDate(*from); err != nil {
+			return filter, fmt.Errorf("invalid -from: %w", err)
+		}
+		if filter.DateTo, err = parseDate(*to); err != nil {
+			return filter, fmt.Errorf("invalid -to: %w", err)
+		}
+		return filter, nil
+	}
+}
+
+func parseDate(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.Parse(time.DateOnly, s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func runGet(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	output := outputFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+
+	repo, err := a.repo()
+	if err != nil {
+		return err
+	}
+	auditLog, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	order, err := repo.GetByID(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := auditLog.Record(ctx, audit.NewEvent(ctx, domain.AuditOrderReadPII, order.OrderUID, nil)); err != nil {
+		return err
+	}
+	return printOrder(os.Stdout, *output, order)
+}
+
+func runList(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	output := outputFlag(fs)
+	parseFilter := filterFlags(fs)
+	fs.Parse(args)
+
+	filter, err := parseFilter()
+	if err != nil {
+		return err
+	}
+	repo, err := a.repo()
+	if err != nil {
+		return err
+	}
+	if err := recordSelection(ctx, a, domain.AuditOrdersList, filter); err != nil {
+		return err
+	}
+	orders, err := repo.List(ctx, filter)
+	if err != nil {
+		return err
+	}
+	return printOrders(os.Stdout, *output, orders)
+}
+
+func runExport(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	formatName := fs.String("format", "ndjson", "формат: csv, ndjson или parquet")
+	out := fs.String("out", "", "файл для выгрузки (по умолчанию stdout)")
+	parseFilter := filterFlags(fs)
+	fs.Parse(args)
+
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+	filter, err := parseFilter()
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	bw := bufio.NewWriter(w)
+	ew, err := export.NewWriter(format, bw)
+	if err != nil {
+		return err
+	}
+
+	repo, err := a.repo()
+	if err != nil {
+		return err
+	}
+	if err := recordSelection(ctx, a, domain.AuditOrdersExport, filter); err != nil {
+		return err
+	}
+	n := 0
+	err = repo.Export(ctx, filter, func(order *domain.Order) error {
+		n++
+		return ew.Write(order)
+	})
+	if err != nil {
+		return err
+	}
+	if err := ew.Close(); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	log.Printf("Exported %d orders", n)
+	return nil
+}
+
+func runImport(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	mode := fs.String("mode", "publish", "publish — отправить в Kafka, insert — записать напрямую в БД")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+
+	var handle func(line []byte) error
+	switch *mode {
+	case "publish":
+		producer, err := a.orderProducer()
+		if err != nil {
+			return err
+		}
+		// Строка проверяется по схеме сообщения здесь, а не в консюмере: ошибка видна с номером строки
+		handle = func(line []byte) error {
+			order, _, err := message.Decode(line)
+			if err != nil {
+				return err
+			}
+			value, err := message.Encode(order, audit.SourceCLI)
+			if err != nil {
+				return err
+			}
+			return producer.Publish(ctx, []byte(order.OrderUID), value)
+		}
+	case "insert":
+		// Через сервис, чтобы сработала та же валидация, что и у консюмера
+		svc, err := a.service()
+		if err != nil {
+			return err
+		}
+		handle = func(line []byte) error {
+			return svc.HandleOrder(ctx, line)
+		}
+	default:
+		return fmt.Errorf("unknown import mode: %s", *mode)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	imported, failed, lineNo := 0, 0, 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if err := handle(line); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("line %d: %v", lineNo, err)
+			failed++
+			continue
+		}
+		imported++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	log.Printf("Imported %d orders, failed %d", imported, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d orders failed to import", failed)
+	}
+	return nil
+}
+
+func runGenerate(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	count := fs.Int("count", 1, "количество заказов")
+	publish := fs.Bool("publish", false, "отправить заказы в Kafka вместо вывода")
+	output := fs.String("o", "json", "формат вывода: table или json")
+	gen := generatorFlags(fs)
+	fs.Parse(args)
+	if *count < 1 {
+		return errors.New("count must be positive")
+	}
+
+	g, err := gen()
+	if err != nil {
+		return err
+	}
+	orders := g.Generate(*count)
+	log.Printf("Generator seed: %d, base time: %s", g.Seed(), g.BaseTime().Format(time.RFC3339Nano))
+
+	if !*publish {
+		return printOrders(os.Stdout, *output, orders)
+	}
+
+	producer, err := a.orderProducer()
+	if err != nil {
+		return err
+	}
+	for i := range orders {
+		order := &orders[i]
+		value, err := message.Encode(order, audit.SourceCLI)
+		if err != nil {
+			return err
+		}
+		if err := producer.Publish(ctx, []byte(order.OrderUID), value); err != nil {
+			return err
+		}
+	}
+	log.Printf("Published %d orders", len(orders))
+	return nil
+}
+
+// generatorFlags регистрирует флаги генератора заказов и возвращает функцию, создающую генератор.
+func generatorFlags(fs *flag.FlagSet) func() (*domain.Generator, error) {
+	var opts domain.GeneratorOptions
+	fs.Int64Var(&opts.Seed, "seed", 0, "сид генератора (0 — случайный)")
+	baseTime := fs.String("base-time", "", "точка отсчета дат заказов в RFC 3339; вместе с -seed воспроизводит вывод")
+	fs.StringVar(&opts.Locale, "locale", "", "локаль: en или ru")
+	fs.Float64Var(&opts.InvalidRate, "invalid-rate", 0, "доля невалидных заказов")
+	fs.Float64Var(&opts.DuplicateRate, "duplicate-rate", 0, "доля заказов с повторным UID")
+	items := fs.String("items", "", "распределение числа товаров, например 1:60,2:25,3:15")
+	currencies := fs.String("currencies", "", "валюты через запятую")
+	providers := fs.String("providers", "", "платежные провайдеры через запятую")
+
+	return func() (*domain.Generator, error) {
+		if *baseTime != "" {
+			t, err := time.Parse(time.RFC3339Nano, *baseTime)
+			if err != nil {
+				return nil, fmt.Errorf("invalid base time: %w", err)
+			}
+			opts.BaseTime = t
+		}
+		if *items != "" {
+			weights, err := domain.ParseItemWeights(*items)
+			if err != nil {
+				return nil, err
+			}
+			opts.ItemWeights = weights
+		}
+		opts.Currencies = splitList(*currencies)
+		opts.Providers = splitList(*providers)
+		return domain.NewGenerator(opts)
+	}
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// recordSelection записывает в журнал аудита выборку заказов с персональными данными:
+// листинг или выгрузку, в зависимости от action.
+func recordSelection(ctx context.Context, a *app, action string, filter domain.OrderFilter) error {
+	details, err := json.Marshal(audit.FilterDetails(filter))
+	if err != nil {
+		return err
+	}
+	auditLog, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	return auditLog.Record(ctx, audit.NewEvent(ctx, action, "", details))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"order_service/internal/domain"
+)
+
+// outputFlag регистрирует флаг формата вывода -o.
+func outputFlag(fs *flag.FlagSet) *string {
+	return fs.String("o", "table", "формат вывода: table или json")
+}
+
+// printOrders печатает список заказов таблицей или JSON-массивом.
+func printOrders(w io.Writer, format string, orders []domain.Order) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(orders)
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ORDER_UID\tTRACK_NUMBER\tCUSTOMER\tSERVICE\tCREATED\tAMOUNT\tITEMS")
+		for _, o := range orders {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d %s\t%d\n",
+				o.OrderUID, o.TrackNumber, o.CustomerID, o.DeliveryService,
+				o.DateCreated.Format(time.DateTime), o.Payment.Amount, o.Payment.Currency, len(o.Items))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown output format: %s", format)
+}
+
+// printOrder печатает один заказ: таблицей поле-значение или JSON-объектом.
+func printOrder(w io.Writer, format string, o *domain.Order) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(o)
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		rows := [][2]string{
+			{"order_uid", o.OrderUID},
+			{"track_number", o.TrackNumber},
+			{"entry", o.Entry},
+			{"locale", o.Locale},
+			{"customer_id", o.CustomerID},
+			{"delivery_service", o.DeliveryService},
+			{"shardkey", o.Shardkey},
+			{"sm_id", strconv.Itoa(o.SmID)},
+			{"date_created", o.DateCreated.Format(time.RFC3339)},
+			{"oof_shard", o.OofShard},
+			{"delivery", fmt.Sprintf("%s, %s, %s, %s %s", o.Delivery.Name, o.Delivery.Phone, o.Delivery.Email, o.Delivery.City, o.Delivery.Address)},
+			{"payment", fmt.Sprintf("%d %s via %s/%s (tx %s)", o.Payment.Amount, o.Payment.Currency, o.Payment.Provider, o.Payment.Bank, o.Payment.Transaction)},
+		}
+		for _, row := range rows {
+			fmt.Fprintf(tw, "%s\t%s\n", row[0], row[1])
+		}
+		for i, item := range o.Items {
+			fmt.Fprintf(tw, "item[%d]\t%s (%s), total %d, status %d\n", i, item.Name, item.Brand, item.TotalPrice, item.Status)
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown output format: %s", format)
+}
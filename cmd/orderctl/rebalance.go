@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+)
+
+// runRebalance переносит заказы в шарды, которые им назначает shardkey при текущем числе шардов.
+// Запускать после добавления шардов в data_base.shards.addrs; повторный запуск безопасен.
+func runRebalance(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("rebalance", flag.ContinueOnError)
+	batch := fs.Int("batch", 500, "заказов за один просмотр шарда")
+	dryRun := fs.Bool("dry-run", false, "только показать заказы, которые нужно перенести")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || *batch <= 0 {
+		return errUsage
+	}
+	dbs, err := a.databases()
+	if err != nil {
+		return err
+	}
+	if len(dbs) < 2 {
+		return fmt.Errorf("no shards configured in data_base.shards.addrs")
+	}
+	repo, err := a.shardedRepo()
+	if err != nil {
+		return err
+	}
+
+	moved, err := repo.Rebalance(ctx, *batch, *dryRun, func(orderUID string, from, to int) {
+		if *dryRun {
+			fmt.Printf("%s\t%d -> %d\n", orderUID, from, to)
+		}
+	})
+	if *dryRun {
+		log.Printf("rebalance: %d orders to move", moved)
+	} else {
+		log.Printf("rebalance: %d orders moved", moved)
+	}
+	return err
+}
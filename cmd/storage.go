@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"order_service/internal/config"
+	"order_service/internal/database"
+	"order_service/internal/encryption"
+	"order_service/internal/handler"
+	"order_service/internal/repository"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// storage — хранилище заказов, выбранное storage.driver, и соединения, которые нужно закрыть.
+type storage struct {
+	orders  repository.OrderRepository
+	audit   repository.AuditRepository
+	health  handler.Pinger
+	closers []func()
+}
+
+// pingFunc приводит функцию проверки к handler.Pinger.
+type pingFunc func(ctx context.Context) error
+
+func (f pingFunc) Ping(ctx context.Context) error {
+	return f(ctx)
+}
+
+func (s *storage) close() {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i]()
+	}
+}
+
+// openStorage открывает хранилище storage.driver. Фоновые задачи Postgres (секции, проверка реплик)
+// работают, пока не отменен bgCtx. sqlite и memory в env prod запрещены: они не шифруют
+// персональные данные и не переживают потерю пода.
+func openStorage(bgCtx context.Context, cfg *config.Config, keyring *encryption.Keyring) (*storage, error) {
+	driver := cfg.Storage.Driver
+	if driver != database.DriverPostgres {
+		if cfg.Env == "prod" {
+			return nil, fmt.Errorf("storage driver %q is for local development only", driver)
+		}
+		if keyring != nil {
+			log.Printf("Storage driver %s keeps personal data unencrypted", driver)
+		}
+	}
+
+	switch driver {
+	case database.DriverPostgres:
+		return openPostgres(bgCtx, cfg, keyring)
+	case database.DriverSQLite:
+		db, err := database.OpenSQLite(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &storage{
+			orders:  repository.NewSQLiteOrderRepository(db),
+			audit:   repository.NewSQLiteAuditRepository(db),
+			health:  pingFunc(db.PingContext),
+			closers: []func(){func() { db.Close() }},
+		}, nil
+	case database.DriverMemory:
+		log.Println("Using in-memory storage: orders are lost on restart")
+		db := repository.NewMemoryDB()
+		return &storage{
+			orders: repository.NewMemoryOrderRepository(db),
+			audit:  repository.NewMemoryAuditRepository(db),
+			health: pingFunc(func(context.Context) error { return nil }),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q, expected postgres, sqlite or memory", driver)
+	}
+}
+
+// openPostgres подключает основную БД, шарды и реплики для чтения.
+func openPostgres(bgCtx context.Context, cfg *config.Config, keyring *encryption.Keyring) (*storage, error) {
+	db, err := database.InitDB(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	s := &storage{
+		orders:  repository.NewOrderRepository(db, keyring),
+		audit:   repository.NewAuditRepository(db),
+		health:  db,
+		closers: []func(){db.Close},
+	}
+
+	// Шарды 1..N из data_base.shards; основная БД — шард 0
+	shardDBs, err := database.InitShards(cfg)
+	if err != nil {
+		s.close()
+		return nil, fmt.Errorf("failed to connect to database shards: %w", err)
+	}
+	for _, shardDB := range shardDBs {
+		s.closers = append(s.closers, shardDB.Close)
+	}
+	dbs := append([]*pgxpool.Pool{db}, shardDBs...)
+
+	// Помесячные секции заказов создаются заранее в каждом шарде; архивирует старые orderctl archive
+	for _, shardDB := range dbs {
+		partitions, err := repository.NewPartitionManager(shardDB, cfg.Database.Partitions)
+		if err != nil {
+			s.close()
+			return nil, fmt.Errorf("failed to configure partitions: %w", err)
+		}
+		go partitions.Run(bgCtx)
+	}
+
+	// Шарды: заказ пишется в шард по shardkey, журнал аудита собирается со всех шардов
+	if len(dbs) > 1 {
+		if len(cfg.Database.Replicas.Addrs) > 0 {
+			s.close()
+			return nil, fmt.Errorf("read replicas are not supported together with database shards")
+		}
+		sharded, err := repository.NewShardedRepository(dbs, keyring, cfg.Database.Shards.Lookup)
+		if err != nil {
+			s.close()
+			return nil, fmt.Errorf("failed to configure database shards: %w", err)
+		}
+		s.orders = sharded
+		s.audit = repository.NewShardedAuditRepository(dbs)
+	}
+
+	// Реплики для чтения: запись и недавно измененные заказы остаются на основной БД
+	if len(cfg.Database.Replicas.Addrs) > 0 {
+		replicaDBs, err := database.OpenReplicas(cfg)
+		if err != nil {
+			s.close()
+			return nil, fmt.Errorf("failed to configure read replicas: %w", err)
+		}
+		for _, replicaDB := range replicaDBs {
+			s.closers = append(s.closers, replicaDB.Close)
+		}
+		replicated, err := repository.NewReplicatedRepository(s.orders, replicaDBs, keyring, cfg.Database.Replicas)
+		if err != nil {
+			s.close()
+			return nil, fmt.Errorf("failed to configure read replicas: %w", err)
+		}
+		go replicated.Run(bgCtx)
+		s.orders = replicated
+	}
+	return s, nil
+}
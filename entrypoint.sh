@@ -1,8 +1,8 @@
 #!/bin/sh
 set -e  # Выход при ошибках
 
-echo "Running migrations with DB_URL: $DB_URL"
-goose -dir migrations postgres "$DB_URL" up
+echo "Running migrations..."
+orderctl migrate up
 
 echo "Starting application..."
 exec ./main
\ No newline at end of file
@@ -0,0 +1,225 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"order_service/internal/domain"
+)
+
+// Источники операций.
+const (
+	SourceHTTP    = "http"
+	SourceKafka   = "kafka"
+	SourceNATS    = "nats"
+	SourceChannel = "channel"
+	SourceCLI     = "cli"
+	SourceLoadgen = "loadgen"
+)
+
+// Размеры колонок журнала и отметок об удалении и стирании, в символах.
+const (
+	MaxActorLen     = 100 // audit_events.actor, orders.deleted_by, erasures.erased_by
+	MaxRequestIDLen = 100 // audit_events.request_id
+)
+
+// Truncate обрезает строку до max символов, не разрезая многобайтовые символы.
+func Truncate(s string, max int) string {
+	n := 0
+	for i := range s {
+		if n == max {
+			return s[:i]
+		}
+		n++
+	}
+	return s
+}
+
+// Meta — кто и в рамках какого запроса выполняет операцию.
+type Meta struct {
+	Actor     string
+	Source    string
+	RequestID string
+}
+
+type metaKey struct{}
+
+// WithMeta кладет сведения об инициаторе в контекст. Инициатор и идентификатор запроса
+// обрезаются до размеров колонок журнала.
+func WithMeta(ctx context.Context, m Meta) context.Context {
+	m.Actor = Truncate(m.Actor, MaxActorLen)
+	m.RequestID = Truncate(m.RequestID, MaxRequestIDLen)
+	return context.WithValue(ctx, metaKey{}, m)
+}
+
+// FromContext достает сведения об инициаторе. Без них операция записывается как анонимная.
+func FromContext(ctx context.Context) Meta {
+	m, _ := ctx.Value(metaKey{}).(Meta)
+	if m.Actor == "" {
+		m.Actor = "anonymous"
+	}
+	if m.Source == "" {
+		m.Source = "unknown"
+	}
+	return m
+}
+
+// NewEvent заполняет событие сведениями об инициаторе из контекста.
+func NewEvent(ctx context.Context, action, orderUID string, changes json.RawMessage) domain.AuditEvent {
+	m := FromContext(ctx)
+	return domain.AuditEvent{
+		Actor:     m.Actor,
+		Source:    m.Source,
+		Action:    action,
+		OrderUID:  orderUID,
+		Changes:   changes,
+		RequestID: m.RequestID,
+	}
+}
+
+// redactedValue подставляется в diff вместо персональных данных:
+// журнал хранится бессрочно и не должен обходить шифрование и GDPR-стирание.
+const redactedValue = "[redacted]"
+
+// piiPaths — пути к полям заказа, размеченным тегом pii.
+var piiPaths = collectPIIPaths(reflect.TypeOf(domain.Order{}), "")
+
+func collectPIIPaths(t reflect.Type, prefix string) map[string]bool {
+	paths := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if f.Tag.Get("pii") != "" {
+			paths[prefix+name] = true
+		}
+		if f.Type.Kind() == reflect.Struct && f.Type.PkgPath() == t.PkgPath() {
+			for p := range collectPIIPaths(f.Type, prefix+name+".") {
+				paths[p] = true
+			}
+		}
+	}
+	return paths
+}
+
+// FilterDetails описывает фильтр выгрузки для журнала. Email и телефон сами являются
+// персональными данными, поэтому фиксируется только факт фильтрации по ним.
+func FilterDetails(f domain.OrderFilter) map[string]any {
+	m := map[string]any{}
+	set := func(key, value string) {
+		if value != "" {
+			m[key] = value
+		}
+	}
+	set("customer_id", f.CustomerID)
+	set("track_number", f.TrackNumber)
+	set("delivery_service", f.DeliveryService)
+	set("locale", f.Locale)
+	if f.Email != "" {
+		m["email"] = redactedValue
+	}
+	if f.Phone != "" {
+		m["phone"] = redactedValue
+	}
+	if f.DateFrom != nil {
+		m["date_from"] = f.DateFrom
+	}
+	if f.DateTo != nil {
+		m["date_to"] = f.DateTo
+	}
+	if f.Limit > 0 {
+		m["limit"] = f.Limit
+	}
+	return m
+}
+
+// Change — изменение одного поля в diff.
+type Change struct {
+	Old any `json:"old,omitempty"`
+	New any `json:"new,omitempty"`
+}
+
+// Diff строит JSON-diff двух значений вида {"delivery.city": {"old": ..., "new": ...}}.
+// before или after может быть nil (создание, удаление). Значения персональных полей не пишутся.
+func Diff(before, after any) (json.RawMessage, error) {
+	old, err := flatten(before)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := flatten(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := map[string]Change{}
+	for k := range old {
+		if _, ok := cur[k]; !ok {
+			cur[k] = nil
+		}
+	}
+	for k, n := range cur {
+		o := old[k]
+		if reflect.DeepEqual(o, n) {
+			continue
+		}
+		if piiPaths[k] {
+			o, n = redact(o), redact(n)
+		}
+		diff[k] = Change{Old: o, New: n}
+	}
+	// json.Marshal сортирует ключи, поэтому diff детерминирован
+	return json.Marshal(diff)
+}
+
+func redact(v any) any {
+	if v == nil {
+		return nil
+	}
+	return redactedValue
+}
+
+// flatten раскладывает значение по путям: delivery.city, items[0].price.
+func flatten(v any) (map[string]any, error) {
+	out := map[string]any{}
+	if v == nil {
+		return out, nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return out, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("audit diff marshal error: %w", err)
+	}
+	var tree any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("audit diff unmarshal error: %w", err)
+	}
+	walk(tree, "", out)
+	return out, nil
+}
+
+func walk(v any, path string, out map[string]any) {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, child := range val {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			walk(child, p, out)
+		}
+	case []any:
+		for i, child := range val {
+			walk(child, fmt.Sprintf("%s[%d]", path, i), out)
+		}
+	default:
+		out[path] = val
+	}
+}
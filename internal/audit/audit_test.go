@@ -0,0 +1,187 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"order_service/internal/domain"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{"admin", 10, "admin"},
+		{"admin", 5, "admin"},
+		{"admin", 3, "adm"},
+		{"оператор", 4, "опер"},
+		{"", 3, ""},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.in, tt.max); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestWithMetaTruncatesToColumnSize(t *testing.T) {
+	long := strings.Repeat("я", MaxActorLen+20)
+	m := FromContext(WithMeta(context.Background(), Meta{Actor: long, RequestID: long}))
+	if n := utf8.RuneCountInString(m.Actor); n != MaxActorLen || !utf8.ValidString(m.Actor) {
+		t.Errorf("actor: %d runes, valid UTF-8 %v", n, utf8.ValidString(m.Actor))
+	}
+	if n := utf8.RuneCountInString(m.RequestID); n != MaxRequestIDLen {
+		t.Errorf("request id: %d runes, want %d", n, MaxRequestIDLen)
+	}
+}
+
+func diffOrder() *domain.Order {
+	return &domain.Order{
+		OrderUID: "b563feb7b2b84b6test",
+		Delivery: domain.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: domain.Payment{Transaction: "b563feb7b2b84b6test", Currency: "USD", Amount: 1817},
+		Items: []domain.Item{
+			{ChrtID: 9934930, Price: 453, Name: "Mascaras"},
+			{ChrtID: 9934931, Price: 100, Name: "Brush"},
+		},
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		before func() *domain.Order
+		change func(o *domain.Order)
+		want   string
+	}{
+		{
+			name:   "unchanged",
+			before: diffOrder,
+			change: func(o *domain.Order) {},
+			want:   `{}`,
+		},
+		{
+			name:   "pii fields redacted",
+			before: diffOrder,
+			change: func(o *domain.Order) {
+				o.Delivery.Name = "Ivan Ivanov"
+				o.Delivery.Phone = "+79000000000"
+				o.Delivery.Email = "ivan@example.com"
+			},
+			want: `{"delivery.email":{"old":"[redacted]","new":"[redacted]"},` +
+				`"delivery.name":{"old":"[redacted]","new":"[redacted]"},` +
+				`"delivery.phone":{"old":"[redacted]","new":"[redacted]"}}`,
+		},
+		{
+			name:   "pii field cleared",
+			before: diffOrder,
+			change: func(o *domain.Order) { o.Delivery.Email = "" },
+			want:   `{"delivery.email":{"old":"[redacted]","new":"[redacted]"}}`,
+		},
+		{
+			name:   "plain nested fields keep values",
+			before: diffOrder,
+			change: func(o *domain.Order) {
+				o.Delivery.City = "Haifa"
+				o.Payment.Amount = 1900
+				o.Payment.Transaction = "other"
+				o.Items[1].Price = 120
+			},
+			want: `{"delivery.city":{"old":"Kiryat Mozkin","new":"Haifa"},` +
+				`"items[1].price":{"old":100,"new":120},` +
+				`"payment.amount":{"old":1817,"new":1900},` +
+				`"payment.transaction":{"old":"[redacted]","new":"[redacted]"}}`,
+		},
+		{
+			name:   "item removed",
+			before: diffOrder,
+			change: func(o *domain.Order) { o.Items = o.Items[:1] },
+			want: `{"items[1].brand":{"old":""},"items[1].chrt_id":{"old":9934931},"items[1].name":{"old":"Brush"},` +
+				`"items[1].nm_id":{"old":0},"items[1].price":{"old":100},"items[1].rid":{"old":""},` +
+				`"items[1].sale":{"old":0},"items[1].size":{"old":""},"items[1].status":{"old":0},` +
+				`"items[1].total_price":{"old":0},"items[1].track_number":{"old":""}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after := tt.before(), tt.before()
+			tt.change(after)
+			got, err := Diff(before, after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("Diff:\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// При создании и удалении в diff попадает весь заказ, но персональные данные — только как отметка.
+func TestDiffCreateAndDelete(t *testing.T) {
+	order := diffOrder()
+	tests := []struct {
+		name          string
+		before, after any
+		side          func(c Change) any
+	}{
+		{"create", nil, order, func(c Change) any { return c.New }},
+		{"create from nil pointer", (*domain.Order)(nil), order, func(c Change) any { return c.New }},
+		{"delete", order, nil, func(c Change) any { return c.Old }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Diff(tt.before, tt.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var diff map[string]Change
+			if err := json.Unmarshal(data, &diff); err != nil {
+				t.Fatal(err)
+			}
+			for path := range piiPaths {
+				if v := tt.side(diff[path]); v != redactedValue {
+					t.Errorf("%s = %v, want %s", path, v, redactedValue)
+				}
+			}
+			for path, want := range map[string]any{
+				"order_uid":      order.OrderUID,
+				"delivery.city":  order.Delivery.City,
+				"items[0].name":  order.Items[0].Name,
+				"items[1].price": float64(order.Items[1].Price),
+			} {
+				if v := tt.side(diff[path]); v != want {
+					t.Errorf("%s = %v, want %v", path, v, want)
+				}
+			}
+			for _, pii := range []string{order.Delivery.Name, order.Delivery.Phone, order.Delivery.Email} {
+				if strings.Contains(string(data), pii) {
+					t.Fatalf("personal data %q leaked into diff %s", pii, data)
+				}
+			}
+		})
+	}
+}
+
+func TestPIIPaths(t *testing.T) {
+	want := []string{
+		"delivery.address", "delivery.email", "delivery.name", "delivery.phone", "delivery.zip",
+		"payment.request_id", "payment.transaction",
+	}
+	if len(piiPaths) != len(want) {
+		t.Fatalf("pii paths %v, want %v", piiPaths, want)
+	}
+	for _, p := range want {
+		if !piiPaths[p] {
+			t.Errorf("%s is not marked as pii", p)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"order_service/internal/audit"
+	"order_service/internal/config"
+)
+
+// Разрешения, которые можно выдать токену в конфиге.
+const (
+	PermPIIRead     = "pii:read"      // чтение персональных данных без маскирования
+	PermAuditRead   = "audit:read"    // чтение журнала аудита
+	PermCacheAdmin  = "cache:admin"   // инвалидация и сброс кэша
+	PermOrderWrite  = "orders:write"  // изменение заказа
+	PermOrderDelete = "orders:delete" // мягкое удаление заказа
+	PermOrderErase  = "orders:erase"  // GDPR-стирание персональных данных
+)
+
+// Principal — аутентифицированный вызывающий.
+type Principal struct {
+	Actor       string
+	Permissions []string
+}
+
+// Anonymous — вызывающий без токена: без имени и без разрешений.
+var Anonymous = Principal{}
+
+// IsAnonymous сообщает, что вызывающий не предъявил токен.
+func (p Principal) IsAnonymous() bool {
+	return p.Actor == "" && len(p.Permissions) == 0
+}
+
+// Has проверяет наличие разрешения.
+func (p Principal) Has(permission string) bool {
+	for _, perm := range p.Permissions {
+		if perm == permission {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal кладет вызывающего в контекст.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext достает вызывающего из контекста; без него — Anonymous.
+func FromContext(ctx context.Context) Principal {
+	if p, ok := ctx.Value(principalKey{}).(Principal); ok {
+		return p
+	}
+	return Anonymous
+}
+
+// tokenEntry — токен из конфига, хранится как хэш, чтобы сравнение шло за постоянное время.
+type tokenEntry struct {
+	hash      [sha256.Size]byte
+	principal Principal
+}
+
+// LoadTokens собирает токены из конфига и файла токенов.
+func LoadTokens(cfg *config.Config) ([]config.AuthToken, error) {
+	tokens := append([]config.AuthToken(nil), cfg.Auth.Tokens...)
+	if cfg.Auth.TokensFile != "" {
+		fileTokens, err := readTokensFile(cfg.Auth.TokensFile)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, fileTokens...)
+	}
+	for _, t := range tokens {
+		if t.Token == "" {
+			return nil, fmt.Errorf("empty token for actor %q", t.Actor)
+		}
+	}
+	return tokens, nil
+}
+
+// readTokensFile читает файл токенов: по строке "<actor> <разрешения через запятую> <token>",
+// пустые строки и # игнорируются. В именах разрешений есть двоеточия, поэтому поля разделены пробелами.
+func readTokensFile(path string) ([]config.AuthToken, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tokens file: %w", err)
+	}
+	defer f.Close()
+
+	var tokens []config.AuthToken
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, errors.New("invalid tokens file line, expected <actor> <permissions> <token>")
+		}
+		t := config.AuthToken{Actor: fields[0], Token: fields[2]}
+		for _, perm := range strings.Split(fields[1], ",") {
+			if perm = strings.TrimSpace(perm); perm != "" {
+				t.Permissions = append(t.Permissions, perm)
+			}
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, scanner.Err()
+}
+
+// Middleware определяет вызывающего по заголовку Authorization: Bearer <token>.
+// Запросы без токена проходят как Anonymous, с неизвестным токеном — получают 401.
+func Middleware(tokens []config.AuthToken) func(http.Handler) http.Handler {
+	entries := make([]tokenEntry, 0, len(tokens))
+	for _, t := range tokens {
+		entries = append(entries, tokenEntry{
+			hash:      sha256.Sum256([]byte(t.Token)),
+			principal: Principal{Actor: audit.Truncate(t.Actor, audit.MaxActorLen), Permissions: t.Permissions},
+		})
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok {
+				http.Error(w, `{"error": "Invalid authorization header"}`, http.StatusUnauthorized)
+				return
+			}
+			hash := sha256.Sum256([]byte(token))
+			for _, e := range entries {
+				if subtle.ConstantTimeCompare(hash[:], e.hash[:]) == 1 {
+					next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), e.principal)))
+					return
+				}
+			}
+			http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"order_service/internal/config"
+)
+
+func TestLoadTokensFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	data := "# операторы\n\nadmin pii:read,audit:read s3cr3t:with:colons\nsupport audit:read  t2\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Auth: config.Auth{
+		Tokens:     []config.AuthToken{{Token: "t0", Actor: "ci", Permissions: []string{PermCacheAdmin}}},
+		TokensFile: path,
+	}}
+
+	tokens, err := LoadTokens(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []config.AuthToken{
+		{Token: "t0", Actor: "ci", Permissions: []string{PermCacheAdmin}},
+		{Token: "s3cr3t:with:colons", Actor: "admin", Permissions: []string{PermPIIRead, PermAuditRead}},
+		{Token: "t2", Actor: "support", Permissions: []string{PermAuditRead}},
+	}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Fatalf("tokens = %+v, want %+v", tokens, want)
+	}
+}
+
+func TestLoadTokensRejectsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]config.Auth{
+		"missing file": {TokensFile: filepath.Join(dir, "missing")},
+		"short line":   {TokensFile: writeFile(t, dir, "short", "admin t1\n")},
+		"empty token":  {Tokens: []config.AuthToken{{Actor: "admin"}}},
+	}
+	for name, a := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadTokens(&config.Config{Auth: a}); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var got Principal
+	h := Middleware([]config.AuthToken{{Token: "t1", Actor: "admin", Permissions: []string{PermPIIRead}}})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = FromContext(r.Context()) }))
+
+	tests := []struct {
+		header     string
+		wantStatus int
+		wantActor  string
+	}{
+		{"", http.StatusOK, ""},
+		{"Bearer t1", http.StatusOK, "admin"},
+		{"Bearer wrong", http.StatusUnauthorized, ""},
+		{"Basic t1", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		got = Principal{}
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.wantStatus || got.Actor != tt.wantActor {
+			t.Errorf("%q: status %d actor %q, want %d %q", tt.header, w.Code, got.Actor, tt.wantStatus, tt.wantActor)
+		}
+	}
+}
+
+func writeFile(t *testing.T, dir, name, data string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,325 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"order_service/internal/config"
+	"order_service/internal/domain"
+)
+
+// ErrUnavailable возвращается без обращения к Redis, пока выключатель разомкнут.
+var ErrUnavailable = errors.New("cache unavailable")
+
+// Состояния выключателя.
+const (
+	StateClosed = "closed" // Redis работает, запросы идут в него
+	StateOpen   = "open"   // Redis недоступен, запросы сразу получают ErrUnavailable
+)
+
+// maxPendingEvictions — сколько заказов запоминается для удаления из кэша после восстановления.
+// При переполнении после восстановления сбрасывается весь кэш заказов.
+const maxPendingEvictions = 10000
+
+// BreakerCache — кэш с автоматическим выключателем. После FailureThreshold ошибок подряд
+// Redis перестает вызываться, и запросы идут сразу в БД без ожидания таймаутов.
+// Фоновая проверка раз в ProbeInterval пингует Redis и замыкает выключатель.
+//
+// Пока выключатель разомкнут, записи и удаления в кэше теряются, и после восстановления
+// там могли бы остаться устаревшие заказы. Поэтому UID измененных заказов запоминаются
+// и удаляются из кэша перед замыканием. Удаления, не прошедшие при замкнутом выключателе,
+// откладываются так же и применяются в фоне после следующего успешного обращения к Redis.
+type BreakerCache struct {
+	inner     Cache
+	threshold int
+	interval  time.Duration
+
+	mu       sync.Mutex
+	open     bool
+	failures int
+	pending  map[string]struct{}
+	overflow bool
+	draining bool // отложенные удаления применяются в фоне при замкнутом выключателе
+}
+
+// NewBreakerCache оборачивает кэш выключателем с настройками из конфига. Нулевой порог
+// размыкал бы выключатель на первой ошибке, а с нулевым интервалом не запустится Run.
+func NewBreakerCache(inner Cache, cfg config.CacheBreaker) (*BreakerCache, error) {
+	if cfg.FailureThreshold < 1 {
+		return nil, fmt.Errorf("cache.breaker.failure_threshold must be positive, got %d", cfg.FailureThreshold)
+	}
+	if cfg.ProbeInterval <= 0 {
+		return nil, fmt.Errorf("cache.breaker.probe_interval must be positive, got %s", cfg.ProbeInterval)
+	}
+	return &BreakerCache{
+		inner:     inner,
+		threshold: cfg.FailureThreshold,
+		interval:  cfg.ProbeInterval,
+		pending:   map[string]struct{}{},
+	}, nil
+}
+
+// Run проверяет доступность Redis, пока выключатель разомкнут. Завершается с отменой ctx.
+func (b *BreakerCache) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if b.State() == StateOpen {
+				b.probe(ctx)
+			}
+		}
+	}
+}
+
+// Trip размыкает выключатель, например когда Redis недоступен уже при старте.
+func (b *BreakerCache) Trip(reason error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tripLocked(reason)
+}
+
+// State возвращает текущее состояние для проверок здоровья.
+func (b *BreakerCache) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.open {
+		return StateOpen
+	}
+	return StateClosed
+}
+
+func (b *BreakerCache) tripLocked(reason error) {
+	if b.open {
+		return
+	}
+	b.open = true
+	log.Printf("Cache circuit breaker opened, serving from database only: %v", reason)
+}
+
+// probe пингует Redis, применяет отложенные удаления и замыкает выключатель. Замыкание
+// происходит под той же блокировкой, под которой проверяется, что отложенных удалений не осталось.
+func (b *BreakerCache) probe(ctx context.Context) {
+	if err := b.inner.Ping(); err != nil {
+		return
+	}
+
+	applied := 0
+	for {
+		b.mu.Lock()
+		pending, overflow := b.pending, b.overflow
+		if len(pending) == 0 && !overflow {
+			b.open, b.failures = false, 0
+			b.mu.Unlock()
+			log.Printf("Cache circuit breaker closed, Redis is available again (%d deferred evictions applied)", applied)
+			return
+		}
+		b.pending, b.overflow = map[string]struct{}{}, false
+		b.mu.Unlock()
+
+		if err := b.applyPending(ctx, pending, overflow); err != nil {
+			// Вернем отложенное и попробуем на следующей проверке
+			b.restorePending(pending, overflow)
+			return
+		}
+		applied += len(pending)
+	}
+}
+
+// drain применяет удаления, отложенные при замкнутом выключателе. Если выключатель
+// разомкнулся, удаления остаются для probe.
+func (b *BreakerCache) drain(ctx context.Context) {
+	defer func() {
+		b.mu.Lock()
+		b.draining = false
+		b.mu.Unlock()
+	}()
+	for {
+		b.mu.Lock()
+		pending, overflow := b.pending, b.overflow
+		if b.open || (len(pending) == 0 && !overflow) {
+			b.mu.Unlock()
+			return
+		}
+		b.pending, b.overflow = map[string]struct{}{}, false
+		b.mu.Unlock()
+
+		if err := b.applyPending(ctx, pending, overflow); err != nil {
+			b.restorePending(pending, overflow)
+			b.record(err)
+			return
+		}
+		log.Printf("Applied %d deferred cache evictions", len(pending))
+	}
+}
+
+// applyPending удаляет отложенные заказы из кэша, а при переполнении сбрасывает его целиком.
+func (b *BreakerCache) applyPending(ctx context.Context, pending map[string]struct{}, overflow bool) error {
+	if overflow {
+		return b.inner.Flush(ctx)
+	}
+	for uid := range pending {
+		if err := b.inner.DeleteOrder(ctx, uid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restorePending возвращает непримененные удаления в очередь.
+func (b *BreakerCache) restorePending(pending map[string]struct{}, overflow bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.overflow = b.overflow || overflow
+	for uid := range pending {
+		b.deferEvictionLocked(uid)
+	}
+}
+
+// allow сообщает, можно ли обращаться к Redis.
+func (b *BreakerCache) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.open
+}
+
+// record учитывает результат обращения. Промах кэша ошибкой Redis не считается.
+// Успешное обращение при отложенных удалениях запускает их применение в фоне.
+func (b *BreakerCache) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil || errors.Is(err, ErrNotFound) {
+		b.failures = 0
+		if !b.open && !b.draining && (len(b.pending) > 0 || b.overflow) {
+			b.draining = true
+			go b.drain(context.Background())
+		}
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		return // Клиент ушел, Redis тут ни при чем
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.tripLocked(err)
+	}
+}
+
+func (b *BreakerCache) deferEviction(orderUID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.deferEvictionLocked(orderUID)
+}
+
+// deferIfOpen атомарно с проверкой состояния откладывает удаление заказа, если выключатель
+// разомкнут. Иначе удаление могло бы попасть в очередь уже после замыкания и потеряться.
+func (b *BreakerCache) deferIfOpen(orderUID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.open {
+		b.deferEvictionLocked(orderUID)
+	}
+	return b.open
+}
+
+func (b *BreakerCache) deferEvictionLocked(orderUID string) {
+	if b.overflow {
+		return
+	}
+	if len(b.pending) >= maxPendingEvictions {
+		b.overflow, b.pending = true, map[string]struct{}{}
+		return
+	}
+	b.pending[orderUID] = struct{}{}
+}
+
+func (b *BreakerCache) SetOrder(ctx context.Context, orderUID string, order *domain.Order) error {
+	if b.deferIfOpen(orderUID) {
+		return ErrUnavailable
+	}
+	err := b.inner.SetOrder(ctx, orderUID, order)
+	b.record(err)
+	if err != nil {
+		b.deferEviction(orderUID)
+	}
+	return err
+}
+
+func (b *BreakerCache) GetOrder(ctx context.Context, orderUID string) (*domain.Order, error) {
+	if !b.allow() {
+		return nil, ErrUnavailable
+	}
+	order, err := b.inner.GetOrder(ctx, orderUID)
+	b.record(err)
+	return order, err
+}
+
+func (b *BreakerCache) GetOrderMeta(ctx context.Context, orderUID string) (*domain.OrderMeta, error) {
+	if !b.allow() {
+		return nil, ErrUnavailable
+	}
+	meta, err := b.inner.GetOrderMeta(ctx, orderUID)
+	b.record(err)
+	return meta, err
+}
+
+// DeleteOrder при недоступном Redis откладывает удаление до восстановления.
+func (b *BreakerCache) DeleteOrder(ctx context.Context, orderUID string) error {
+	if b.deferIfOpen(orderUID) {
+		return ErrUnavailable
+	}
+	err := b.inner.DeleteOrder(ctx, orderUID)
+	b.record(err)
+	if err != nil {
+		b.deferEviction(orderUID)
+	}
+	return err
+}
+
+// Tombstone при недоступном Redis, как и DeleteOrder, откладывает удаление до восстановления.
+func (b *BreakerCache) Tombstone(ctx context.Context, orderUID string, version int64) error {
+	if b.deferIfOpen(orderUID) {
+		return ErrUnavailable
+	}
+	err := b.inner.Tombstone(ctx, orderUID, version)
+	b.record(err)
+	if err != nil {
+		b.deferEviction(orderUID)
+	}
+	return err
+}
+
+// Invalidate при недоступном Redis не рассылается: локальные уровни других реплик
+// устареют не дольше своего TTL.
+func (b *BreakerCache) Invalidate(ctx context.Context, orderUID string) error {
+	if !b.allow() {
+		return ErrUnavailable
+	}
+	err := b.inner.Invalidate(ctx, orderUID)
+	b.record(err)
+	return err
+}
+
+func (b *BreakerCache) Flush(ctx context.Context) error {
+	if !b.allow() {
+		return ErrUnavailable
+	}
+	err := b.inner.Flush(ctx)
+	b.record(err)
+	return err
+}
+
+func (b *BreakerCache) Ping() error {
+	return b.inner.Ping()
+}
+
+func (b *BreakerCache) Close() error {
+	return b.inner.Close()
+}
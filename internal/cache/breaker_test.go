@@ -0,0 +1,226 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"order_service/internal/config"
+	"order_service/internal/domain"
+)
+
+var errRedisDown = errors.New("redis down")
+
+// fakeCache — кэш, который падает по команде и запоминает удаления.
+type fakeCache struct {
+	mu      sync.Mutex
+	fail    bool
+	deleted map[string]int
+	flushes int
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{deleted: map[string]int{}}
+}
+
+func (c *fakeCache) setFail(fail bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fail = fail
+}
+
+func (c *fakeCache) err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fail {
+		return errRedisDown
+	}
+	return nil
+}
+
+func (c *fakeCache) deletes(uid string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deleted[uid]
+}
+
+func (c *fakeCache) SetOrder(ctx context.Context, orderUID string, order *domain.Order) error {
+	return c.err()
+}
+
+func (c *fakeCache) GetOrder(ctx context.Context, orderUID string) (*domain.Order, error) {
+	if err := c.err(); err != nil {
+		return nil, err
+	}
+	return nil, ErrNotFound
+}
+
+func (c *fakeCache) GetOrderMeta(ctx context.Context, orderUID string) (*domain.OrderMeta, error) {
+	if err := c.err(); err != nil {
+		return nil, err
+	}
+	return nil, ErrNotFound
+}
+
+func (c *fakeCache) DeleteOrder(ctx context.Context, orderUID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fail {
+		return errRedisDown
+	}
+	c.deleted[orderUID]++
+	return nil
+}
+
+func (c *fakeCache) Tombstone(ctx context.Context, orderUID string, version int64) error {
+	return c.DeleteOrder(ctx, orderUID)
+}
+
+func (c *fakeCache) Invalidate(ctx context.Context, orderUID string) error {
+	return c.err()
+}
+
+func (c *fakeCache) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fail {
+		return errRedisDown
+	}
+	c.flushes++
+	return nil
+}
+
+func (c *fakeCache) Ping() error  { return c.err() }
+func (c *fakeCache) Close() error { return nil }
+
+// eventually ждет выполнения условия, проверяемого в фоне.
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func newTestBreaker(t *testing.T, inner Cache, cfg config.CacheBreaker) *BreakerCache {
+	t.Helper()
+	b, err := NewBreakerCache(inner, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestNewBreakerCacheRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.CacheBreaker
+	}{
+		{"zero threshold", config.CacheBreaker{FailureThreshold: 0, ProbeInterval: time.Second}},
+		{"negative threshold", config.CacheBreaker{FailureThreshold: -1, ProbeInterval: time.Second}},
+		{"zero probe interval", config.CacheBreaker{FailureThreshold: 5, ProbeInterval: 0}},
+		{"negative probe interval", config.CacheBreaker{FailureThreshold: 5, ProbeInterval: -time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewBreakerCache(newFakeCache(), tt.cfg); err == nil {
+				t.Fatal("NewBreakerCache accepted invalid config")
+			}
+		})
+	}
+}
+
+func TestBreakerRetriesFailedEvictionWhileClosed(t *testing.T) {
+	ctx := context.Background()
+	inner := newFakeCache()
+	b := newTestBreaker(t, inner, config.CacheBreaker{FailureThreshold: 5, ProbeInterval: time.Hour})
+
+	inner.setFail(true)
+	if err := b.DeleteOrder(ctx, "o1"); !errors.Is(err, errRedisDown) {
+		t.Fatalf("DeleteOrder: err = %v", err)
+	}
+	if b.State() != StateClosed {
+		t.Fatal("breaker opened after a single failure")
+	}
+
+	// Следующее успешное обращение применяет отложенное удаление, хотя выключатель не размыкался
+	inner.setFail(false)
+	if _, err := b.GetOrder(ctx, "o2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetOrder: err = %v", err)
+	}
+	eventually(t, func() bool { return inner.deletes("o1") == 1 })
+	eventually(t, func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return !b.draining && len(b.pending) == 0
+	})
+}
+
+func TestBreakerKeepsEvictionWhenRetryFails(t *testing.T) {
+	ctx := context.Background()
+	inner := newFakeCache()
+	b := newTestBreaker(t, inner, config.CacheBreaker{FailureThreshold: 5, ProbeInterval: time.Hour})
+
+	inner.setFail(true)
+	b.SetOrder(ctx, "o1", &domain.Order{OrderUID: "o1"})
+	inner.setFail(false)
+	b.Invalidate(ctx, "o2")
+	eventually(t, func() bool { return inner.deletes("o1") == 1 })
+
+	// Повторная ошибка возвращает удаление в очередь
+	inner.setFail(true)
+	b.Tombstone(ctx, "o3", 2)
+	b.mu.Lock()
+	b.draining = true // фоновое применение еще не началось
+	b.mu.Unlock()
+	inner.setFail(false)
+	b.drain(ctx)
+	if inner.deletes("o3") != 1 {
+		t.Fatalf("o3 deleted %d times, want 1", inner.deletes("o3"))
+	}
+}
+
+func TestBreakerProbeAppliesPendingBeforeClosing(t *testing.T) {
+	ctx := context.Background()
+	inner := newFakeCache()
+	b := newTestBreaker(t, inner, config.CacheBreaker{FailureThreshold: 1, ProbeInterval: time.Hour})
+
+	inner.setFail(true)
+	b.GetOrder(ctx, "o1")
+	if b.State() != StateOpen {
+		t.Fatal("breaker did not open at threshold")
+	}
+	if err := b.DeleteOrder(ctx, "o1"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("DeleteOrder while open: err = %v", err)
+	}
+
+	b.probe(ctx)
+	if b.State() != StateOpen || inner.deletes("o1") != 0 {
+		t.Fatal("probe closed the breaker while Redis is down")
+	}
+
+	inner.setFail(false)
+	b.probe(ctx)
+	if b.State() != StateClosed || inner.deletes("o1") != 1 {
+		t.Fatalf("after probe: state %s, deletes %d", b.State(), inner.deletes("o1"))
+	}
+}
+
+func TestBreakerOverflowFlushes(t *testing.T) {
+	ctx := context.Background()
+	inner := newFakeCache()
+	b := newTestBreaker(t, inner, config.CacheBreaker{FailureThreshold: 1, ProbeInterval: time.Hour})
+	b.Trip(errRedisDown)
+	for i := 0; i <= maxPendingEvictions; i++ {
+		b.DeleteOrder(ctx, string(rune('a'+i%26))+time.Duration(i).String())
+	}
+	b.probe(ctx)
+	if b.State() != StateClosed || inner.flushes != 1 {
+		t.Fatalf("after probe: state %s, flushes %d", b.State(), inner.flushes)
+	}
+}
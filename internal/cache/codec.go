@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"order_service/internal/domain"
+	"order_service/internal/orderpb"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// SchemaVersion входит в ключ кэша (order:v2:{uid}). Увеличивается при несовместимом изменении
+// domain.Order: новые экземпляры сервиса не читают записи старой схемы, а старые истекают по TTL.
+const SchemaVersion = 2
+
+// Codec сериализует заказ для хранения в кэше.
+type Codec interface {
+	Name() string
+	Marshal(order *domain.Order) ([]byte, error)
+	Unmarshal(data []byte, order *domain.Order) error
+}
+
+// Compression сжимает сериализованный заказ.
+type Compression interface {
+	Name() string
+	Compress(src []byte) []byte
+	Decompress(src []byte) ([]byte, error)
+}
+
+// Идентификаторы записываются в первый байт значения, поэтому их нельзя менять.
+// Запись читается по своему заголовку, и смена формата в конфиге не ломает уже закэшированное.
+var (
+	codecs = map[byte]Codec{
+		1: jsonCodec{},
+		2: msgpackCodec{},
+		3: protobufCodec{},
+	}
+	compressions = map[byte]Compression{
+		0: noCompression{},
+		1: snappyCompression{},
+		2: zstdCompression{},
+	}
+)
+
+// Format — кодек и сжатие записей кэша.
+type Format struct {
+	codecID       byte
+	compressionID byte
+}
+
+// NewFormat находит кодек и сжатие по именам из конфига.
+func NewFormat(codec, compression string) (Format, error) {
+	var f Format
+	var ok bool
+	if f.codecID, ok = findID(codecs, codec); !ok {
+		return f, fmt.Errorf("unknown cache codec %q, expected one of %v", codec, names(codecs))
+	}
+	if f.compressionID, ok = findID(compressions, compression); !ok {
+		return f, fmt.Errorf("unknown cache compression %q, expected one of %v", compression, names(compressions))
+	}
+	return f, nil
+}
+
+// allFormats возвращает все сочетания кодеков и сжатия — для тестов и бенчмарков.
+func allFormats() []Format {
+	var formats []Format
+	for _, codec := range names(codecs) {
+		for _, compression := range names(compressions) {
+			f, _ := NewFormat(codec, compression)
+			formats = append(formats, f)
+		}
+	}
+	return formats
+}
+
+func (f Format) String() string {
+	return codecs[f.codecID].Name() + "+" + compressions[f.compressionID].Name()
+}
+
+// Encode сериализует заказ. Первый байт — заголовок: кодек в старших четырех битах, сжатие в младших.
+func (f Format) Encode(order *domain.Order) ([]byte, error) {
+	data, err := codecs[f.codecID].Marshal(order)
+	if err != nil {
+		return nil, err
+	}
+	data = compressions[f.compressionID].Compress(data)
+	return append([]byte{f.codecID<<4 | f.compressionID}, data...), nil
+}
+
+// errUnknownFormat — запись сделана форматом, которого этот экземпляр не знает; считается промахом.
+var errUnknownFormat = errors.New("unknown cache entry format")
+
+// Decode разбирает запись по ее заголовку, а не по текущему формату.
+func (f Format) Decode(data []byte, order *domain.Order) error {
+	if len(data) == 0 {
+		return errUnknownFormat
+	}
+	codec, ok1 := codecs[data[0]>>4]
+	compression, ok2 := compressions[data[0]&0x0f]
+	if !ok1 || !ok2 {
+		return errUnknownFormat
+	}
+	payload, err := compression.Decompress(data[1:])
+	if err != nil {
+		return fmt.Errorf("%s decompress: %w", compression.Name(), err)
+	}
+	return codec.Unmarshal(payload, order)
+}
+
+func findID[T interface{ Name() string }](m map[byte]T, name string) (byte, bool) {
+	for id, v := range m {
+		if v.Name() == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+func names[T interface{ Name() string }](m map[byte]T) []string {
+	out := make([]string, 0, len(m))
+	for _, v := range m {
+		out = append(out, v.Name())
+	}
+	sort.Strings(out)
+	return out
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+func (jsonCodec) Marshal(order *domain.Order) ([]byte, error) {
+	return json.Marshal(order)
+}
+func (jsonCodec) Unmarshal(data []byte, order *domain.Order) error {
+	return json.Unmarshal(data, order)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return "msgpack" }
+
+// Marshal использует json-теги, чтобы имена полей не расходились с JSON.
+func (msgpackCodec) Marshal(order *domain.Order) ([]byte, error) {
+	enc := msgpack.GetEncoder()
+	defer msgpack.PutEncoder(enc)
+	var buf bytes.Buffer
+	enc.Reset(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(order); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+func (msgpackCodec) Unmarshal(data []byte, order *domain.Order) error {
+	dec := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(dec)
+	dec.Reset(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	if err := dec.Decode(order); err != nil {
+		return err
+	}
+	// msgpack разбирает время в локальном поясе, остальные кодеки — как записано (UTC)
+	order.DateCreated, order.UpdatedAt = order.DateCreated.UTC(), order.UpdatedAt.UTC()
+	return nil
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string { return "protobuf" }
+func (protobufCodec) Marshal(order *domain.Order) ([]byte, error) {
+	return orderpb.MarshalOrder(order), nil
+}
+func (protobufCodec) Unmarshal(data []byte, order *domain.Order) error {
+	return orderpb.UnmarshalOrder(data, order)
+}
+
+type noCompression struct{}
+
+func (noCompression) Name() string                          { return "none" }
+func (noCompression) Compress(src []byte) []byte            { return src }
+func (noCompression) Decompress(src []byte) ([]byte, error) { return src, nil }
+
+type snappyCompression struct{}
+
+func (snappyCompression) Name() string               { return "snappy" }
+func (snappyCompression) Compress(src []byte) []byte { return snappy.Encode(nil, src) }
+func (snappyCompression) Decompress(src []byte) ([]byte, error) {
+	return snappy.Decode(nil, src)
+}
+
+// Кодировщик и декодер zstd потокобезопасны для EncodeAll/DecodeAll и переиспользуются.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+type zstdCompression struct{}
+
+func (zstdCompression) Name() string               { return "zstd" }
+func (zstdCompression) Compress(src []byte) []byte { return zstdEncoder.EncodeAll(src, nil) }
+func (zstdCompression) Decompress(src []byte) ([]byte, error) {
+	return zstdDecoder.DecodeAll(src, nil)
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+
+	"order_service/internal/domain"
+)
+
+// benchOrders — сколько заказов генерируется для бенчмарков форматов.
+const benchOrders = 1000
+
+func generateOrders(tb testing.TB, n int) []domain.Order {
+	tb.Helper()
+	opts := domain.DefaultGeneratorOptions()
+	opts.Seed = 1
+	gen, err := domain.NewGenerator(opts)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return gen.Generate(n)
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	orders := generateOrders(t, 20)
+	for _, format := range allFormats() {
+		t.Run(format.String(), func(t *testing.T) {
+			for i := range orders {
+				data, err := format.Encode(&orders[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got domain.Order
+				if err := format.Decode(data, &got); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, orders[i]) {
+					t.Fatalf("order %s differs after round trip", orders[i].OrderUID)
+				}
+			}
+		})
+	}
+}
+
+// Запись читается по своему заголовку: смена формата в конфиге не ломает закэшированное.
+func TestFormatDecodesOtherFormats(t *testing.T) {
+	order := generateOrders(t, 1)[0]
+	current, err := NewFormat("json", "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range allFormats() {
+		data, err := format.Encode(&order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got domain.Order
+		if err := current.Decode(data, &got); err != nil {
+			t.Errorf("%s: %v", format, err)
+		}
+	}
+	if err := current.Decode([]byte{0xff, 0x00}, new(domain.Order)); err != errUnknownFormat {
+		t.Errorf("unknown header: err = %v, want errUnknownFormat", err)
+	}
+	if err := current.Decode(nil, new(domain.Order)); err != errUnknownFormat {
+		t.Errorf("empty entry: err = %v, want errUnknownFormat", err)
+	}
+}
+
+func TestNewFormatRejectsUnknown(t *testing.T) {
+	if _, err := NewFormat("xml", "none"); err == nil {
+		t.Error("unknown codec: expected error")
+	}
+	if _, err := NewFormat("json", "gzip"); err == nil {
+		t.Error("unknown compression: expected error")
+	}
+}
+
+// BenchmarkFormat_Encode сравнивает форматы по времени кодирования и размеру записи
+// (метрика bytes/entry — средний размер на сгенерированных заказах).
+func BenchmarkFormat_Encode(b *testing.B) {
+	orders := generateOrders(b, benchOrders)
+	for _, format := range allFormats() {
+		b.Run(format.String(), func(b *testing.B) {
+			size := 0
+			for i := range orders {
+				data, err := format.Encode(&orders[i])
+				if err != nil {
+					b.Fatal(err)
+				}
+				size += len(data)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := format.Encode(&orders[i%len(orders)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(size)/float64(len(orders)), "bytes/entry")
+		})
+	}
+}
+
+func BenchmarkFormat_Decode(b *testing.B) {
+	orders := generateOrders(b, benchOrders)
+	for _, format := range allFormats() {
+		b.Run(format.String(), func(b *testing.B) {
+			encoded := make([][]byte, len(orders))
+			for i := range orders {
+				data, err := format.Encode(&orders[i])
+				if err != nil {
+					b.Fatal(err)
+				}
+				encoded[i] = data
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			var order domain.Order
+			for i := 0; i < b.N; i++ {
+				if err := format.Decode(encoded[i%len(encoded)], &order); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"order_service/internal/domain"
+)
+
+// localTier — ограниченный по размеру LRU-кэш заказов в памяти процесса с коротким TTL.
+// TTL ограничивает устаревание на случай потерянных сообщений об инвалидации.
+type localTier struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type localEntry struct {
+	uid     string
+	order   domain.Order
+	expires time.Time
+	// deleted — отметка об удалении: заказ не отдается, в order только версия
+	deleted bool
+}
+
+func newLocalTier(size int, ttl time.Duration) *localTier {
+	return &localTier{size: size, ttl: ttl, ll: list.New(), items: map[string]*list.Element{}}
+}
+
+// get возвращает копию заказа вместе с товарами, чтобы вызывающий не мог изменить
+// закэшированное значение.
+func (l *localTier) get(uid string) (*domain.Order, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.items[uid]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*localEntry)
+	if time.Now().After(e.expires) {
+		l.removeLocked(el)
+		return nil, false
+	}
+	if e.deleted {
+		return nil, false
+	}
+	l.ll.MoveToFront(el)
+	order := e.order
+	order.Items = append([]domain.Item(nil), e.order.Items...)
+	return &order, true
+}
+
+// set не заменяет более новую версию заказа более старой.
+func (l *localTier) set(order *domain.Order) {
+	l.put(order.OrderUID, *order, false, l.ttl)
+}
+
+// tombstone запоминает, что заказ удален в версии version. Отметка живет не дольше TTL уровня.
+func (l *localTier) tombstone(uid string, version int64) {
+	l.put(uid, domain.Order{OrderUID: uid, Version: version}, true, min(l.ttl, TombstoneTTL))
+}
+
+// put хранит собственную копию товаров: срез вызывающего может измениться после записи.
+func (l *localTier) put(uid string, order domain.Order, deleted bool, ttl time.Duration) {
+	order.Items = append([]domain.Item(nil), order.Items...)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	expires := time.Now().Add(ttl)
+	if el, ok := l.items[uid]; ok {
+		e := el.Value.(*localEntry)
+		if e.order.Version > order.Version && time.Now().Before(e.expires) {
+			return
+		}
+		e.order, e.expires, e.deleted = order, expires, deleted
+		l.ll.MoveToFront(el)
+		return
+	}
+	l.items[uid] = l.ll.PushFront(&localEntry{uid: uid, order: order, expires: expires, deleted: deleted})
+	for l.ll.Len() > l.size {
+		l.removeLocked(l.ll.Back())
+	}
+}
+
+func (l *localTier) delete(uid string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[uid]; ok {
+		l.removeLocked(el)
+	}
+}
+
+func (l *localTier) purge() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ll.Init()
+	l.items = map[string]*list.Element{}
+}
+
+func (l *localTier) removeLocked(el *list.Element) {
+	l.ll.Remove(el)
+	delete(l.items, el.Value.(*localEntry).uid)
+}
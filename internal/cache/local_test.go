@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"order_service/internal/domain"
+)
+
+func TestLocalTierCopiesItems(t *testing.T) {
+	l := newLocalTier(10, time.Hour)
+	order := &domain.Order{OrderUID: "o1", Version: 1, Items: []domain.Item{{ChrtID: 1, Price: 100}, {ChrtID: 2, Price: 200}}}
+	l.set(order)
+
+	// Изменение среза после записи не задевает кэш
+	order.Items[0].Price = 1
+
+	got, ok := l.get("o1")
+	if !ok {
+		t.Fatal("order not cached")
+	}
+	if got.Items[0].Price != 100 {
+		t.Fatalf("cached price %d changed through the caller's slice", got.Items[0].Price)
+	}
+
+	// Как и изменение того, что вернул get
+	got.Items[1].Price = 2
+	got.Items = append(got.Items[:1], domain.Item{ChrtID: 3})
+
+	again, ok := l.get("o1")
+	if !ok {
+		t.Fatal("order not cached")
+	}
+	if len(again.Items) != 2 || again.Items[1].ChrtID != 2 || again.Items[1].Price != 200 {
+		t.Fatalf("cached items %+v changed through a returned copy", again.Items)
+	}
+}
+
+func TestLocalTierKeepsNewerVersion(t *testing.T) {
+	l := newLocalTier(10, time.Hour)
+	l.set(&domain.Order{OrderUID: "o1", Version: 2, TrackNumber: "new"})
+	l.set(&domain.Order{OrderUID: "o1", Version: 1, TrackNumber: "old"})
+	if got, _ := l.get("o1"); got == nil || got.TrackNumber != "new" {
+		t.Fatalf("got %+v, want version 2", got)
+	}
+}
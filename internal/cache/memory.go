@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"order_service/internal/config"
+	"order_service/internal/domain"
+)
+
+// memoryForever — срок жизни записи кэша в памяти при ttl 0: в Redis такие ключи не истекают.
+const memoryForever = 100 * 365 * 24 * time.Hour
+
+// memoryCache — кэш в памяти процесса для локальной разработки без Redis. Других реплик
+// у него нет, поэтому Invalidate ничего не делает.
+type memoryCache struct {
+	local *localTier
+}
+
+// NewMemoryCache создает кэш в памяти на cache.local.size заказов с TTL cache.ttl.
+func NewMemoryCache(cfg config.Cache) Cache {
+	ttl := cfg.Ttl
+	if ttl <= 0 {
+		ttl = memoryForever
+	}
+	return &memoryCache{local: newLocalTier(cfg.Local.Size, ttl)}
+}
+
+func (c *memoryCache) SetOrder(ctx context.Context, orderUID string, order *domain.Order) error {
+	c.local.set(order) // локальный уровень сам копирует товары
+	return nil
+}
+
+func (c *memoryCache) GetOrder(ctx context.Context, orderUID string) (*domain.Order, error) {
+	order, ok := c.local.get(orderUID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return order, nil
+}
+
+func (c *memoryCache) GetOrderMeta(ctx context.Context, orderUID string) (*domain.OrderMeta, error) {
+	order, ok := c.local.get(orderUID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &domain.OrderMeta{Version: order.Version, UpdatedAt: order.UpdatedAt}, nil
+}
+
+func (c *memoryCache) DeleteOrder(ctx context.Context, orderUID string) error {
+	c.local.delete(orderUID)
+	return nil
+}
+
+func (c *memoryCache) Tombstone(ctx context.Context, orderUID string, version int64) error {
+	c.local.tombstone(orderUID, version)
+	return nil
+}
+
+func (c *memoryCache) Invalidate(ctx context.Context, orderUID string) error {
+	return nil
+}
+
+func (c *memoryCache) Flush(ctx context.Context) error {
+	c.local.purge()
+	return nil
+}
+
+func (c *memoryCache) Ping() error {
+	return nil
+}
+
+func (c *memoryCache) Close() error {
+	return nil
+}
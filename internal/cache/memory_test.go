@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order_service/internal/config"
+	"order_service/internal/domain"
+)
+
+func TestMemoryCacheTombstoneRejectsStaleSet(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(config.Cache{Ttl: time.Hour, Local: config.CacheLocal{Size: 10}})
+
+	stale := &domain.Order{OrderUID: "o1", Version: 3}
+	if err := c.SetOrder(ctx, "o1", stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Tombstone(ctx, "o1", 4); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetOrder(ctx, "o1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetOrder after tombstone: err = %v, want ErrNotFound", err)
+	}
+
+	// Чтение, начатое до удаления, пытается вернуть заказ в кэш
+	if err := c.SetOrder(ctx, "o1", stale); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetOrder(ctx, "o1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetOrder after stale set: err = %v, want ErrNotFound", err)
+	}
+	if _, err := c.GetOrderMeta(ctx, "o1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetOrderMeta after stale set: err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"order_service/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Режимы подключения к Redis.
+const (
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel"
+	ModeCluster    = "cluster"
+	// ModeMemory — кэш в памяти процесса вместо Redis, см. NewMemoryCache
+	ModeMemory = "memory"
+)
+
+// newClient создает клиент Redis для режима из конфига. Все три клиента реализуют
+// redis.UniversalClient, поэтому остальной код кэша от режима не зависит.
+func newClient(cfg config.Cache) (redis.UniversalClient, error) {
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:            cfg.Addrs,
+		MasterName:       cfg.MasterName,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		SentinelUsername: cfg.SentinelUsername,
+		SentinelPassword: cfg.SentinelPassword,
+		DB:               cfg.DB,
+		TLSConfig:        tlsConfig,
+		PoolSize:         cfg.Pool.Size,
+		MinIdleConns:     cfg.Pool.MinIdle,
+		DialTimeout:      cfg.Pool.DialTimeout,
+		ReadTimeout:      cfg.Pool.ReadTimeout,
+		WriteTimeout:     cfg.Pool.WriteTimeout,
+		PoolTimeout:      cfg.Pool.PoolTimeout,
+	}
+
+	switch cfg.Mode {
+	case ModeStandalone, "":
+		if len(opts.Addrs) == 0 {
+			opts.Addrs = []string{cfg.Adress}
+		}
+		if len(opts.Addrs) != 1 {
+			return nil, errors.New("cache: standalone mode expects a single address")
+		}
+		return redis.NewClient(opts.Simple()), nil
+	case ModeSentinel:
+		if len(opts.Addrs) == 0 || opts.MasterName == "" {
+			return nil, errors.New("cache: sentinel mode requires addrs and master_name")
+		}
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case ModeCluster:
+		if len(opts.Addrs) == 0 {
+			return nil, errors.New("cache: cluster mode requires addrs")
+		}
+		if opts.DB != 0 {
+			return nil, errors.New("cache: cluster mode supports only db 0")
+		}
+		return redis.NewClusterClient(opts.Cluster()), nil
+	default:
+		return nil, fmt.Errorf("cache: unknown mode %q, expected standalone, sentinel or cluster", cfg.Mode)
+	}
+}
+
+// newTLSConfig собирает TLS-конфигурацию; nil — TLS выключен.
+func newTLSConfig(cfg config.CacheTLS) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cache: read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("cache: no certificates in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cache: load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order_service/internal/config"
+	"order_service/internal/domain"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestNewClientModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Cache
+		want    string // тип клиента: client или cluster
+		wantErr bool
+	}{
+		{name: "default mode uses adress", cfg: config.Cache{Adress: "redis:6379"}, want: "client"},
+		{name: "standalone with addrs", cfg: config.Cache{Mode: ModeStandalone, Addrs: []string{"redis:6379"}}, want: "client"},
+		{name: "standalone with two addrs", cfg: config.Cache{Mode: ModeStandalone, Addrs: []string{"a:6379", "b:6379"}}, wantErr: true},
+		{name: "sentinel", cfg: config.Cache{Mode: ModeSentinel, Addrs: []string{"s:26379"}, MasterName: "mymaster"}, want: "client"},
+		{name: "sentinel without master", cfg: config.Cache{Mode: ModeSentinel, Addrs: []string{"s:26379"}}, wantErr: true},
+		{name: "sentinel without addrs", cfg: config.Cache{Mode: ModeSentinel, MasterName: "mymaster"}, wantErr: true},
+		{name: "cluster", cfg: config.Cache{Mode: ModeCluster, Addrs: []string{"a:7000", "b:7001"}}, want: "cluster"},
+		{name: "cluster without addrs", cfg: config.Cache{Mode: ModeCluster}, wantErr: true},
+		{name: "cluster with db", cfg: config.Cache{Mode: ModeCluster, Addrs: []string{"a:7000"}, DB: 1}, wantErr: true},
+		{name: "unknown mode", cfg: config.Cache{Mode: "proxy", Adress: "redis:6379"}, wantErr: true},
+		{name: "missing CA file", cfg: config.Cache{Adress: "redis:6379", TLS: config.CacheTLS{Enabled: true, CAFile: "/nonexistent/ca.pem"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, err := newClient(tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					rc.Close()
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			got := "client"
+			if _, ok := rc.(*redis.ClusterClient); ok {
+				got = "cluster"
+			}
+			if got != tt.want {
+				t.Errorf("client = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	tlsConfig, err := newTLSConfig(config.CacheTLS{})
+	if err != nil || tlsConfig != nil {
+		t.Fatalf("disabled TLS: %v, %v", tlsConfig, err)
+	}
+	tlsConfig, err = newTLSConfig(config.CacheTLS{Enabled: true, ServerName: "redis.internal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.ServerName != "redis.internal" || tlsConfig.RootCAs != nil {
+		t.Errorf("tls config = %+v", tlsConfig)
+	}
+}
+
+// newTestCache создает кэш поверх miniredis в заданном режиме.
+func newTestCache(t *testing.T, mode string) (*cache, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rc, err := newClient(config.Cache{Mode: mode, Addrs: []string{mr.Addr()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rc.Close() })
+	format, err := NewFormat("json", "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &cache{rc: rc, ttl: time.Hour, prefix: "order", format: format}, mr
+}
+
+func TestFlushRemovesOnlyPrefixedKeys(t *testing.T) {
+	for _, mode := range []string{ModeStandalone, ModeCluster} {
+		t.Run(mode, func(t *testing.T) {
+			ctx := context.Background()
+			c, mr := newTestCache(t, mode)
+			if mode == ModeCluster {
+				if _, ok := c.rc.(*redis.ClusterClient); !ok {
+					t.Fatalf("client is %T", c.rc)
+				}
+			}
+
+			if err := c.SetOrder(ctx, "o1", &domain.Order{OrderUID: "o1", Version: 1}); err != nil {
+				t.Fatal(err)
+			}
+			mr.Set("order:v0:o2", "old schema")
+			mr.Set("session:1", "not a cache key")
+
+			if err := c.Flush(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if keys := mr.Keys(); len(keys) != 1 || keys[0] != "session:1" {
+				t.Fatalf("keys after flush = %v", keys)
+			}
+			if _, err := c.GetOrder(ctx, "o1"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("GetOrder after flush: err = %v", err)
+			}
+		})
+	}
+}
@@ -2,12 +2,12 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"order_service/internal/config"
 	"order_service/internal/domain"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -15,38 +15,140 @@ import (
 
 var ErrNotFound = errors.New("cache miss")
 
+// TombstoneTTL — сколько хранится отметка об удалении заказа. Ее хватает, чтобы закончились
+// чтения, начатые до удаления.
+const TombstoneTTL = time.Minute
+
 type Cache interface {
+	// SetOrder кладет заказ в кэш, если там нет более новой версии (order.Version).
 	SetOrder(ctx context.Context, orderUID string, order *domain.Order) error
 	GetOrder(ctx context.Context, orderUID string) (*domain.Order, error)
+	// GetOrderMeta читает версию и время изменения заказа, не трогая сам заказ.
+	GetOrderMeta(ctx context.Context, orderUID string) (*domain.OrderMeta, error)
+	DeleteOrder(ctx context.Context, orderUID string) error
+	// Tombstone удаляет заказ, удаленный или стертый в версии version, и на TombstoneTTL запоминает
+	// эту версию: SetOrder более старых версий, например от чтения, начатого до изменения, заказ не вернет.
+	Tombstone(ctx context.Context, orderUID string, version int64) error
+	// Invalidate сообщает всем репликам, что заказ изменился: они удаляют его из локального
+	// уровня кэша. Запись в Redis не трогается. InvalidateAll — сброс локальных уровней целиком.
+	Invalidate(ctx context.Context, orderUID string) error
+	Flush(ctx context.Context) error
 	Ping() error
 	Close() error
 }
 
 type cache struct {
-	rc  *redis.Client
-	ttl time.Duration
+	rc     redis.UniversalClient
+	ttl    time.Duration
+	prefix string
+	format Format
 }
 
-func NewCache(config *config.Config) Cache {
-	return &cache{
-		rc: redis.NewClient(&redis.Options{
-			Addr: config.Cache.Adress,
-		}),
-		ttl: config.Cache.Ttl,
+// NewCache создает клиент кэша. Неизвестный кодек или сжатие в конфиге — ошибка конфигурации.
+// В режиме memory возвращает кэш в памяти процесса.
+func NewCache(config *config.Config) (Cache, error) {
+	if config.Cache.Mode == ModeMemory {
+		return NewMemoryCache(config.Cache), nil
+	}
+	format, err := NewFormat(config.Cache.Codec, config.Cache.Compression)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := newClient(config.Cache)
+	if err != nil {
+		return nil, err
 	}
+	return &cache{
+		rc:     rc,
+		ttl:    config.Cache.Ttl,
+		prefix: config.Cache.KeyPrefix,
+		format: format,
+	}, nil
 }
 
+// channel возвращает канал pub/sub для сообщений об инвалидации.
+func channel(prefix string) string {
+	return prefix + ":invalidate"
+}
+
+// key возвращает ключ заказа: <prefix>:v<SchemaVersion>:<uid>.
+func (c *cache) key(orderUID string) string {
+	return fmt.Sprintf("%s:v%d:%s", c.prefix, SchemaVersion, orderUID)
+}
+
+// setIfNewer атомарно сравнивает версию в кэше с записываемой. Запись хранится хэшем
+// {v: версия, mod: время изменения, data: заказ}. Так чтение, начатое до изменения заказа, не перезапишет
+// в кэше свежую версию устаревшей. Ключ другого типа заменяется.
+var setIfNewer = redis.NewScript(`
+if redis.call('TYPE', KEYS[1]).ok == 'hash' then
+	local cur = redis.call('HGET', KEYS[1], 'v')
+	if cur and tonumber(cur) > tonumber(ARGV[1]) then
+		return 0
+	end
+else
+	redis.call('DEL', KEYS[1])
+end
+redis.call('HSET', KEYS[1], 'v', ARGV[1], 'mod', ARGV[2], 'data', ARGV[3])
+if tonumber(ARGV[4]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[4])
+end
+return 1
+`)
+
 func (c *cache) SetOrder(ctx context.Context, orderUID string, order *domain.Order) error {
-	orderBytes, err := json.Marshal(order)
+	orderBytes, err := c.format.Encode(order)
 	if err != nil {
 		return fmt.Errorf("ошибка сериализации заказа orderUID=%s, err=%v", orderUID, err)
 	}
 
-	return c.rc.Set(ctx, orderUID, orderBytes, c.ttl).Err()
+	return setIfNewer.Run(ctx, c.rc, []string{c.key(orderUID)},
+		order.Version, order.UpdatedAt.UnixMicro(), orderBytes, c.ttl.Milliseconds()).Err()
+}
+
+// tombstone заменяет запись хэшем только с версией: GetOrder и GetOrderMeta видят промах,
+// а setIfNewer не запишет версию старше. Более новая запись не трогается.
+var tombstone = redis.NewScript(`
+if redis.call('TYPE', KEYS[1]).ok == 'hash' then
+	local cur = redis.call('HGET', KEYS[1], 'v')
+	if cur and tonumber(cur) > tonumber(ARGV[1]) then
+		return 0
+	end
+end
+redis.call('DEL', KEYS[1])
+redis.call('HSET', KEYS[1], 'v', ARGV[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+func (c *cache) Tombstone(ctx context.Context, orderUID string, version int64) error {
+	return tombstone.Run(ctx, c.rc, []string{c.key(orderUID)}, version, TombstoneTTL.Milliseconds()).Err()
+}
+
+func (c *cache) GetOrderMeta(ctx context.Context, orderUID string) (*domain.OrderMeta, error) {
+	vals, err := c.rc.HMGet(ctx, c.key(orderUID), "v", "mod").Result()
+	if err != nil {
+		return nil, err
+	}
+	version, ok1 := vals[0].(string)
+	mod, ok2 := vals[1].(string)
+	if !ok1 || !ok2 {
+		return nil, ErrNotFound
+	}
+
+	meta := &domain.OrderMeta{}
+	if meta.Version, err = strconv.ParseInt(version, 10, 64); err != nil {
+		return nil, fmt.Errorf("ошибка разбора версии заказа orderUID=%s, err=%v", orderUID, err)
+	}
+	micros, err := strconv.ParseInt(mod, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора времени изменения заказа orderUID=%s, err=%v", orderUID, err)
+	}
+	meta.UpdatedAt = time.UnixMicro(micros).UTC()
+	return meta, nil
 }
 
 func (c *cache) GetOrder(ctx context.Context, orderUID string) (*domain.Order, error) {
-	orderJSON, err := c.rc.Get(ctx, orderUID).Result()
+	data, err := c.rc.HGet(ctx, c.key(orderUID), "data").Bytes()
 	if errors.Is(err, redis.Nil) {
 		return nil, ErrNotFound
 	}
@@ -55,7 +157,10 @@ func (c *cache) GetOrder(ctx context.Context, orderUID string) (*domain.Order, e
 	}
 
 	var order domain.Order
-	err = json.Unmarshal([]byte(orderJSON), &order)
+	err = c.format.Decode(data, &order)
+	if errors.Is(err, errUnknownFormat) {
+		return nil, ErrNotFound // Запись более нового формата: перечитаем из БД
+	}
 	if err != nil {
 		return nil, fmt.Errorf("ошибка десериализации заказа orderUID=%s, err=%v", orderUID, err)
 	}
@@ -63,6 +168,61 @@ func (c *cache) GetOrder(ctx context.Context, orderUID string) (*domain.Order, e
 	return &order, nil
 }
 
+func (c *cache) DeleteOrder(ctx context.Context, orderUID string) error {
+	return c.rc.Del(ctx, c.key(orderUID)).Err()
+}
+
+func (c *cache) Invalidate(ctx context.Context, orderUID string) error {
+	return c.rc.Publish(ctx, channel(c.prefix), orderUID).Err()
+}
+
+// flushBatch — сколько ключей удаляется за один конвейер.
+const flushBatch = 500
+
+// Flush удаляет все ключи заказов с префиксом кэша, включая записи прежних версий схемы.
+// Остальные ключи БД Redis не затрагиваются. В кластере обходится каждый мастер.
+func (c *cache) Flush(ctx context.Context) error {
+	if cluster, ok := c.rc.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return unlinkByPattern(ctx, node, c.prefix+":*")
+		})
+	}
+	return unlinkByPattern(ctx, c.rc, c.prefix+":*")
+}
+
+// unlinkByPattern удаляет ключи узла по шаблону. Ключи удаляются по одному в конвейере:
+// многоключевой UNLINK в кластере упал бы на ключах из разных слотов.
+func unlinkByPattern(ctx context.Context, rc redis.Cmdable, pattern string) error {
+	iter := rc.Scan(ctx, 0, pattern, flushBatch).Iterator()
+	batch := make([]string, 0, flushBatch)
+	unlink := func() error {
+		_, err := rc.Pipelined(ctx, func(p redis.Pipeliner) error {
+			for _, key := range batch {
+				p.Unlink(ctx, key)
+			}
+			return nil
+		})
+		batch = batch[:0]
+		return err
+	}
+
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == flushBatch {
+			if err := unlink(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return unlink()
+	}
+	return nil
+}
+
 func (c *cache) Ping() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order_service/internal/domain"
+)
+
+func TestRedisSetOrderKeepsNewerVersion(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestCache(t, ModeStandalone)
+
+	if err := c.SetOrder(ctx, "o1", &domain.Order{OrderUID: "o1", Version: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetOrder(ctx, "o1", &domain.Order{OrderUID: "o1", Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.GetOrder(ctx, "o1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != 2 {
+		t.Fatalf("version = %d, want 2", got.Version)
+	}
+	if ttl := mr.TTL(c.key("o1")); ttl != time.Hour {
+		t.Errorf("ttl = %s, want 1h", ttl)
+	}
+}
+
+func TestRedisTombstoneRejectsStaleSet(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestCache(t, ModeStandalone)
+
+	stale := &domain.Order{OrderUID: "o1", Version: 3}
+	if err := c.SetOrder(ctx, "o1", stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Tombstone(ctx, "o1", 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetOrder(ctx, "o1", stale); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetOrder(ctx, "o1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetOrder after tombstone: err = %v, want ErrNotFound", err)
+	}
+	if _, err := c.GetOrderMeta(ctx, "o1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetOrderMeta after tombstone: err = %v, want ErrNotFound", err)
+	}
+	if ttl := mr.TTL(c.key("o1")); ttl != TombstoneTTL {
+		t.Errorf("tombstone ttl = %s, want %s", ttl, TombstoneTTL)
+	}
+
+	// Заказ, созданный заново в более новой версии, снова кэшируется
+	mr.FastForward(TombstoneTTL)
+	if err := c.SetOrder(ctx, "o1", &domain.Order{OrderUID: "o1", Version: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetOrder(ctx, "o1"); err != nil {
+		t.Fatalf("GetOrder after tombstone expired: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"order_service/internal/config"
+	"order_service/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// InvalidateAll — сообщение об инвалидации, после которого реплики сбрасывают локальный уровень целиком.
+const InvalidateAll = "*"
+
+// TieredCache добавляет перед Redis локальный уровень в памяти процесса. Изменения заказов
+// рассылаются через Redis pub/sub, и каждая реплика удаляет у себя устаревшую копию.
+type TieredCache struct {
+	inner  Cache
+	local  *localTier
+	sub    redis.UniversalClient
+	prefix string
+}
+
+// NewTieredCache оборачивает кэш локальным уровнем. Для подписки на инвалидации
+// создается отдельное соединение: подписанное соединение Redis не выполняет других команд.
+func NewTieredCache(inner Cache, cfg *config.Config) (*TieredCache, error) {
+	sub, err := newClient(cfg.Cache)
+	if err != nil {
+		return nil, err
+	}
+	return &TieredCache{
+		inner:  inner,
+		local:  newLocalTier(cfg.Cache.Local.Size, cfg.Cache.Local.Ttl),
+		sub:    sub,
+		prefix: cfg.Cache.KeyPrefix,
+	}, nil
+}
+
+// Run слушает инвалидации, пока не отменен ctx. Пока подписки нет, сообщения теряются,
+// поэтому при каждой (пере)подписке локальный уровень сбрасывается.
+func (t *TieredCache) Run(ctx context.Context) {
+	ps := t.sub.Subscribe(ctx, channel(t.prefix))
+	defer ps.Close()
+
+	for {
+		msg, err := ps.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			t.local.purge()
+			// go-redis переподключится на следующем Receive
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			if m.Kind == "subscribe" {
+				t.local.purge()
+			}
+		case *redis.Message:
+			if m.Payload == InvalidateAll {
+				t.local.purge()
+			} else {
+				t.local.delete(m.Payload)
+			}
+		}
+	}
+}
+
+func (t *TieredCache) GetOrder(ctx context.Context, orderUID string) (*domain.Order, error) {
+	if order, ok := t.local.get(orderUID); ok {
+		return order, nil
+	}
+	order, err := t.inner.GetOrder(ctx, orderUID)
+	if err != nil {
+		return nil, err
+	}
+	t.local.set(order)
+	return order, nil
+}
+
+func (t *TieredCache) GetOrderMeta(ctx context.Context, orderUID string) (*domain.OrderMeta, error) {
+	if order, ok := t.local.get(orderUID); ok {
+		return &domain.OrderMeta{Version: order.Version, UpdatedAt: order.UpdatedAt}, nil
+	}
+	return t.inner.GetOrderMeta(ctx, orderUID)
+}
+
+func (t *TieredCache) SetOrder(ctx context.Context, orderUID string, order *domain.Order) error {
+	t.local.set(order)
+	return t.inner.SetOrder(ctx, orderUID, order)
+}
+
+// DeleteOrder удаляет заказ из Redis и из локальных уровней всех реплик.
+func (t *TieredCache) DeleteOrder(ctx context.Context, orderUID string) error {
+	t.local.delete(orderUID)
+	err := t.inner.DeleteOrder(ctx, orderUID)
+	return errors.Join(err, t.inner.Invalidate(ctx, orderUID))
+}
+
+// Tombstone оставляет отметку об удалении в Redis и в локальном уровне, остальные реплики
+// удаляют заказ из своих локальных уровней.
+func (t *TieredCache) Tombstone(ctx context.Context, orderUID string, version int64) error {
+	t.local.tombstone(orderUID, version)
+	err := t.inner.Tombstone(ctx, orderUID, version)
+	return errors.Join(err, t.inner.Invalidate(ctx, orderUID))
+}
+
+func (t *TieredCache) Invalidate(ctx context.Context, orderUID string) error {
+	if orderUID == InvalidateAll {
+		t.local.purge()
+	} else {
+		t.local.delete(orderUID)
+	}
+	return t.inner.Invalidate(ctx, orderUID)
+}
+
+// Flush сбрасывает пространство ключей заказов в Redis и локальные уровни всех реплик.
+func (t *TieredCache) Flush(ctx context.Context) error {
+	t.local.purge()
+	err := t.inner.Flush(ctx)
+	return errors.Join(err, t.inner.Invalidate(ctx, InvalidateAll))
+}
+
+func (t *TieredCache) Ping() error {
+	return t.inner.Ping()
+}
+
+func (t *TieredCache) Close() error {
+	if err := t.sub.Close(); err != nil {
+		log.Printf("Failed to close cache subscriber: %v", err)
+	}
+	return t.inner.Close()
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"time"
@@ -10,23 +12,176 @@ import (
 
 type Config struct {
 	Env        string `yaml:"env" env-default:"local"`
+	Storage    `yaml:"storage"`
 	Database   `yaml:"data_base"`
 	Cache      `yaml:"cache"`
+	Broker     `yaml:"broker"`
 	Kafka      `yaml:"kafka"`
 	HttpServer `yaml:"http_server"`
+	Auth       `yaml:"auth"`
+	Pii        `yaml:"pii"`
+	Encryption `yaml:"encryption"`
 }
 
+// Storage — хранилище заказов. Driver: postgres (data_base); sqlite — файл path без внешних
+// сервисов; memory — память процесса, данные пропадают при остановке. sqlite и memory — для
+// локальной разработки: в env prod сервис с ними не стартует, шифрование персональных данных
+// в них не применяется.
+type Storage struct {
+	Driver string `yaml:"driver" env-default:"postgres"`
+	Path   string `yaml:"path" env-default:"orders.db"`
+}
+
+// Database — подключение к PostgreSQL. SslMode: disable, allow, prefer, require, verify-ca,
+// verify-full; прежние значения false/true читаются как disable/require.
 type Database struct {
-	Adress   string `yaml:"adress" env-default:"localhost:5432"`
-	User     string `yaml:"user" env-default:"user"`
-	Password string `yaml:"password" env-default:"password"`
-	Name     string `yaml:"name" env-default:"orders_db"`
-	SslMode  bool   `yaml:"ssl_mode" env-default:"false"`
+	Adress     string             `yaml:"adress" env-default:"localhost:5432"`
+	User       string             `yaml:"user" env-default:"user"`
+	Password   string             `yaml:"password" env-default:"password"`
+	Name       string             `yaml:"name" env-default:"orders_db"`
+	SslMode    string             `yaml:"ssl_mode" env-default:"disable"`
+	TLS        DatabaseTLS        `yaml:"tls"`
+	Pool       DatabasePool       `yaml:"pool"`
+	Retry      DatabaseRetry      `yaml:"retry"`
+	Replicas   DatabaseReplicas   `yaml:"replicas"`
+	Partitions DatabasePartitions `yaml:"partitions"`
+	Shards     DatabaseShards     `yaml:"shards"`
+	// ConnectTimeout ограничивает одну попытку подключения
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env-default:"5s"`
+	// AutoMigrate применяет встроенные миграции при старте. Реплики сериализуются
+	// advisory-блокировкой, так что флаг можно включать на всех.
+	AutoMigrate bool `yaml:"auto_migrate" env-default:"false"`
+}
+
+// DatabaseTLS — файлы для sslmode verify-ca/verify-full и клиентского сертификата.
+type DatabaseTLS struct {
+	CAFile   string `yaml:"ca_file"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// DatabasePool — настройки пула соединений pgxpool. min_idle соединений пул держит открытыми
+// всегда. StatementCache — подготовленные запросы кэшируются на соединении; за PgBouncer
+// в режиме transaction его нужно выключить.
+type DatabasePool struct {
+	MaxOpen        int           `yaml:"max_open" env-default:"20"`
+	MinIdle        int           `yaml:"min_idle" env-default:"2"`
+	MaxLifetime    time.Duration `yaml:"max_lifetime" env-default:"30m"`
+	MaxIdleTime    time.Duration `yaml:"max_idle_time" env-default:"5m"`
+	StatementCache bool          `yaml:"statement_cache" env-default:"true"`
+}
+
+// DatabaseRetry — повтор первого подключения с экспоненциальной паузой, пока Postgres
+// поднимается. Через timeout старт завершается ошибкой.
+type DatabaseRetry struct {
+	Timeout        time.Duration `yaml:"timeout" env-default:"1m"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" env-default:"500ms"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" env-default:"10s"`
+}
+
+// DatabaseReplicas — реплики для чтения. Addrs — адреса host:port с учетными данными
+// основной БД или полные DSN. Реплика без ответа на проверку раз в health_interval
+// исключается из ротации. После записи заказ pin_window читается с основной БД,
+// чтобы автор изменения увидел его даже при отставании реплик; 0 — не закреплять.
+type DatabaseReplicas struct {
+	Addrs          []string      `yaml:"addrs"`
+	HealthInterval time.Duration `yaml:"health_interval" env-default:"5s"`
+	PinWindow      time.Duration `yaml:"pin_window" env-default:"5s"`
 }
 
+// DatabasePartitions — помесячные секции заказов. Сервис раз в check_interval создает секции
+// на ahead месяцев вперед. orderctl archive выгружает в archive_dir и удаляет секции
+// целиком старше retention; 0 — не архивировать.
+type DatabasePartitions struct {
+	Ahead         int           `yaml:"ahead" env-default:"3"`
+	CheckInterval time.Duration `yaml:"check_interval" env-default:"12h"`
+	Retention     time.Duration `yaml:"retention" env-default:"8760h"`
+	ArchiveDir    string        `yaml:"archive_dir" env-default:"archive"`
+}
+
+// DatabaseShards — шардирование заказов по shardkey. Основная БД — шард 0, Addrs — шарды
+// 1..N (адреса host:port с учетными данными основной БД или полные DSN); пусто — без шардирования.
+// Lookup — как GetByID находит шард по UID: directory (таблица order_shards в основной БД)
+// или fanout (запрос ко всем шардам). Реплики для чтения вместе с шардами не поддерживаются.
+type DatabaseShards struct {
+	Addrs  []string `yaml:"addrs"`
+	Lookup string   `yaml:"lookup" env-default:"directory"`
+}
+
+// Cache — подключение к Redis. Mode: standalone (adress), sentinel (addrs узлов Sentinel
+// и master_name) или cluster (addrs начальных узлов кластера). Mode memory — кэш в памяти
+// процесса без Redis для локальной разработки размером local.size.
 type Cache struct {
-	Adress string        `yaml:"adress" env-default:"redis:6379"`
-	Ttl    time.Duration `yaml:"ttl" env-default:"10m"`
+	Mode        string        `yaml:"mode" env-default:"standalone"`
+	Adress      string        `yaml:"adress" env-default:"redis:6379"`
+	Addrs       []string      `yaml:"addrs"`
+	MasterName  string        `yaml:"master_name"`
+	Username    string        `yaml:"username"`
+	Password    string        `yaml:"password"`
+	DB          int           `yaml:"db" env-default:"0"` // в режиме cluster не поддерживается
+	TLS         CacheTLS      `yaml:"tls"`
+	Pool        CachePool     `yaml:"pool"`
+	Breaker     CacheBreaker  `yaml:"breaker"`
+	Local       CacheLocal    `yaml:"local"`
+	Ttl         time.Duration `yaml:"ttl" env-default:"10m"`
+	KeyPrefix   string        `yaml:"key_prefix" env-default:"order"` // ключи: <prefix>:v<схема>:<uid>
+	Codec       string        `yaml:"codec" env-default:"json"`       // json, msgpack, protobuf
+	Compression string        `yaml:"compression" env-default:"none"` // none, snappy, zstd
+
+	// Учетные данные Sentinel, если они отличаются от учетных данных Redis
+	SentinelUsername string `yaml:"sentinel_username"`
+	SentinelPassword string `yaml:"sentinel_password"`
+}
+
+type CacheTLS struct {
+	Enabled            bool   `yaml:"enabled" env-default:"false"`
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"` // клиентский сертификат для mTLS
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" env-default:"false"`
+}
+
+// CacheBreaker — автоматический выключатель кэша: после failure_threshold ошибок подряд
+// сервис работает только с БД и раз в probe_interval проверяет, вернулся ли Redis.
+type CacheBreaker struct {
+	FailureThreshold int           `yaml:"failure_threshold" env-default:"5"`
+	ProbeInterval    time.Duration `yaml:"probe_interval" env-default:"5s"`
+}
+
+// CacheLocal — локальный уровень кэша в памяти реплики перед Redis. Копии удаляются
+// по сообщениям об инвалидации из Redis pub/sub, а TTL страхует от потерянных сообщений.
+type CacheLocal struct {
+	Enabled bool          `yaml:"enabled" env-default:"false"`
+	Size    int           `yaml:"size" env-default:"10000"`
+	Ttl     time.Duration `yaml:"ttl" env-default:"30s"`
+}
+
+// CachePool — размер пула соединений и таймауты. Нулевые значения — умолчания go-redis.
+type CachePool struct {
+	Size         int           `yaml:"size"`
+	MinIdle      int           `yaml:"min_idle"`
+	DialTimeout  time.Duration `yaml:"dial_timeout" env-default:"2s"`
+	ReadTimeout  time.Duration `yaml:"read_timeout" env-default:"500ms"`
+	WriteTimeout time.Duration `yaml:"write_timeout" env-default:"500ms"`
+	PoolTimeout  time.Duration `yaml:"pool_timeout"`
+}
+
+// Broker — брокер сообщений. Driver: kafka (секция kafka); nats — NATS JetStream (nats);
+// channel — канал в памяти процесса без внешних сервисов для тестов и локальной разработки,
+// в env prod сервис с ним не стартует. Топики, группа и offset_reset любого брокера — из секции kafka.
+type Broker struct {
+	Driver string     `yaml:"driver" env-default:"kafka"`
+	Nats   BrokerNats `yaml:"nats"`
+}
+
+// BrokerNats — NATS JetStream. Топики — субъекты потока Stream, группа — долговременный консюмер.
+// Неподтвержденное сообщение доставляется снова, всего не более MaxDeliver раз.
+type BrokerNats struct {
+	Adress     string        `yaml:"adress" env-default:"localhost:4222"`
+	Stream     string        `yaml:"stream" env-default:"ORDERS"`
+	AckWait    time.Duration `yaml:"ack_wait" env-default:"30s"`
+	MaxDeliver int           `yaml:"max_deliver" env-default:"5"`
 }
 
 type Kafka struct {
@@ -34,29 +189,74 @@ type Kafka struct {
 	GroupId     string `yaml:"group_id" env-default:"order-consumer-group"`
 	OffsetReset string `yaml:"offset_reset" env-default:"earliest"`
 	Topic       string `yaml:"topic" env-default:"orders"`
+	DlqTopic    string `yaml:"dlq_topic" env-default:"orders-dlq"`
 }
 
 type HttpServer struct {
 	Adress      string        `yaml:"adress" env-default:"localhost:8081"`
 	Timeout     time.Duration `yaml:"timeout" env-default:"4s"`
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
+	// CacheControl — значение заголовка Cache-Control для GET /order/{id}
+	CacheControl string `yaml:"cache_control" env-default:"private, no-cache"`
+	// CompressMinSize — ответы короче этого размера (в байтах) не сжимаются
+	CompressMinSize int `yaml:"compress_min_size" env-default:"1024"`
 }
 
-func MustLoad() *Config {
+// Auth — токены вызывающих. Токены — секреты: в конфиге, который попадает в образ, их быть
+// не должно, они читаются из tokens_file, смонтированного как секрет, строками
+// "<actor> <разрешения через запятую> <token>".
+type Auth struct {
+	Tokens     []AuthToken `yaml:"tokens"`
+	TokensFile string      `yaml:"tokens_file"`
+}
+
+type AuthToken struct {
+	Token       string   `yaml:"token"`
+	Actor       string   `yaml:"actor"`
+	Permissions []string `yaml:"permissions"`
+}
+
+// Pii — правила маскирования персональных данных по видам полей (phone, email, name, ...).
+// Правило: keep, redact, email или partial:<начало>:<конец>.
+type Pii struct {
+	Rules map[string]string `yaml:"rules"`
+}
+
+// Encryption — шифрование персональных данных доставки в БД.
+// Ключи задаются в base64 (32 байта) прямо в конфиге или в файле keys_file строками "<id>:<base64>".
+type Encryption struct {
+	Enabled     bool              `yaml:"enabled" env-default:"false"`
+	ActiveKeyID string            `yaml:"active_key_id"`
+	Keys        map[string]string `yaml:"keys"`
+	KeysFile    string            `yaml:"keys_file"`
+	IndexKey    string            `yaml:"index_key"`
+}
+
+// Load читает конфиг из файла CONFIG_PATH, недостающие значения берутся из env-default.
+func Load() (*Config, error) {
 	configPath := os.Getenv("CONFIG_PATH")
 	if configPath == "" {
-		log.Fatal("CONFIG_PATH is not set")
+		return nil, errors.New("CONFIG_PATH is not set")
 	}
 
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		log.Fatalf("config file does not exist: %s", configPath)
+		return nil, fmt.Errorf("config file does not exist: %s", configPath)
 	}
 
 	var cfg Config
 
 	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
-		log.Fatalf("cannot read config: %s", err)
+		return nil, fmt.Errorf("cannot read config: %w", err)
 	}
 
-	return &cfg
+	return &cfg, nil
+}
+
+// MustLoad — Load, завершающий процесс при ошибке.
+func MustLoad() *Config {
+	cfg, err := Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cfg
 }
@@ -1,41 +1,224 @@
 package database
 
 import (
-	"database/sql"
+	"context"
+	"fmt"
 	"log"
+	"net/url"
 	"order_service/internal/config"
+	"strconv"
+	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// Подключаем к PostgreSql
-func InitDB(cfg *config.Config) (*sql.DB, error) {
-	var sslMode string
-	if cfg.Database.SslMode {
-		sslMode = "enable"
-	} else {
-		sslMode = "disable"
+// Подключаем к PostgreSql. С data_base.auto_migrate сначала применяем миграции,
+// затем проверяем, что схема не отстает от бинаря.
+func InitDB(cfg *config.Config) (*pgxpool.Pool, error) {
+	pool, err := Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := prepare(cfg, pool); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+// InitShards подключается к шардам 1..N из data_base.shards.addrs так же, как InitDB
+// к основной БД: с повтором подключения, миграциями и проверкой схемы.
+func InitShards(cfg *config.Config) ([]*pgxpool.Pool, error) {
+	shards, err := OpenShards(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for i, pool := range shards {
+		if err := prepare(cfg, pool); err != nil {
+			closeAll(shards)
+			return nil, fmt.Errorf("shard %d: %w", i+1, err)
+		}
+	}
+	return shards, nil
+}
+
+// prepare применяет миграции при data_base.auto_migrate и проверяет схему.
+func prepare(cfg *config.Config, pool *pgxpool.Pool) error {
+	ctx := context.Background()
+	if cfg.Database.AutoMigrate {
+		if err := Migrate(ctx, pool, MigrateUp, nil); err != nil {
+			return fmt.Errorf("auto migrate error: %w", err)
+		}
 	}
+	return CheckSchema(ctx, pool)
+}
 
-	// Достаём данные для подключения из конифга
-	dsn := "postgres://" + cfg.Database.User +
-		":" + cfg.Database.Password +
-		"@" + cfg.Database.Adress +
-		"/" + cfg.Database.Name +
-		"?sslmode=" + sslMode
+// Open подключается к PostgreSql без проверки схемы — для команд миграций.
+// Пока Postgres недоступен (например, еще стартует в docker-compose), подключение
+// повторяется с экспоненциальной паузой в пределах data_base.retry.timeout.
+func Open(cfg *config.Config) (*pgxpool.Pool, error) {
+	return open(cfg, cfg.Database.Adress, "PostgreSQL")
+}
 
-	// Открываем соединение с базой данных
-	db, err := sql.Open("postgres", dsn)
+// OpenShards подключается к шардам без проверки схемы — для команд миграций.
+func OpenShards(cfg *config.Config) ([]*pgxpool.Pool, error) {
+	shards := make([]*pgxpool.Pool, 0, len(cfg.Database.Shards.Addrs))
+	for i, addr := range cfg.Database.Shards.Addrs {
+		pool, err := open(cfg, addr, fmt.Sprintf("PostgreSQL shard %d", i+1))
+		if err != nil {
+			closeAll(shards)
+			return nil, err
+		}
+		shards = append(shards, pool)
+	}
+	return shards, nil
+}
+
+func open(cfg *config.Config, addr, name string) (*pgxpool.Pool, error) {
+	poolCfg, err := poolConfig(cfg.Database, addr)
 	if err != nil {
 		return nil, err
 	}
 
-	// Проверяем подключение
-	if err := db.Ping(); err != nil {
-		db.Close()
+	retry := cfg.Database.Retry
+	deadline := time.Now().Add(retry.Timeout)
+	backoff := max(retry.InitialBackoff, 10*time.Millisecond)
+	for attempt := 1; ; attempt++ {
+		pool, err := connect(poolCfg, cfg.Database.ConnectTimeout)
+		if err == nil {
+			log.Printf("Successfully connected to %s", name)
+			return pool, nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return nil, fmt.Errorf("postgres connect error after %d attempts: %w", attempt, err)
+		}
+
+		log.Printf("%s is unavailable (attempt %d), retrying in %s: %v", name, attempt, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+		if retry.MaxBackoff > 0 && backoff > retry.MaxBackoff {
+			backoff = retry.MaxBackoff
+		}
+	}
+}
+
+// OpenReplicas открывает пулы реплик для чтения. Подключение ленивое: недоступная
+// реплика не мешает старту, ее исключит из ротации проверка здоровья.
+func OpenReplicas(cfg *config.Config) ([]*pgxpool.Pool, error) {
+	replicas := make([]*pgxpool.Pool, 0, len(cfg.Database.Replicas.Addrs))
+	for _, addr := range cfg.Database.Replicas.Addrs {
+		poolCfg, err := poolConfig(cfg.Database, addr)
+		if err == nil {
+			var pool *pgxpool.Pool
+			if pool, err = pgxpool.NewWithConfig(context.Background(), poolCfg); err == nil {
+				replicas = append(replicas, pool)
+				continue
+			}
+		}
+		closeAll(replicas)
+		return nil, err
+	}
+	return replicas, nil
+}
+
+func closeAll(pools []*pgxpool.Pool) {
+	for _, pool := range pools {
+		pool.Close()
+	}
+}
+
+// connect создает пул и проверяет, что БД отвечает.
+func connect(poolCfg *pgxpool.Config, timeout time.Duration) (*pgxpool.Pool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
 		return nil, err
 	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+// poolConfig собирает настройки пула для addr: адреса host:port с учетными данными
+// основной БД или полного DSN.
+func poolConfig(cfg config.Database, addr string) (*pgxpool.Config, error) {
+	dsn := addr
+	if !strings.Contains(addr, "://") {
+		cfg.Adress = addr
+		var err error
+		if dsn, err = DSN(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid postgres DSN: %w", err)
+	}
+	poolCfg.MaxConns = int32(cfg.Pool.MaxOpen)
+	poolCfg.MinConns = int32(min(cfg.Pool.MinIdle, cfg.Pool.MaxOpen))
+	poolCfg.MaxConnLifetime = cfg.Pool.MaxLifetime
+	poolCfg.MaxConnIdleTime = cfg.Pool.MaxIdleTime
+
+	// Запросы подготавливаются на соединении при первом выполнении и дальше идут
+	// по готовому плану. Без кэша каждый запрос описывается заново
+	if cfg.Pool.StatementCache {
+		poolCfg.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+	} else {
+		poolCfg.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeExec
+	}
+	return poolCfg, nil
+}
+
+// DSN собирает строку подключения. Учетные данные и параметры экранируются,
+// поэтому пароль может содержать любые символы.
+func DSN(cfg config.Database) (string, error) {
+	sslMode, err := sslMode(cfg.SslMode)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("sslmode", sslMode)
+	if cfg.TLS.CAFile != "" {
+		query.Set("sslrootcert", cfg.TLS.CAFile)
+	}
+	if cfg.TLS.CertFile != "" {
+		query.Set("sslcert", cfg.TLS.CertFile)
+	}
+	if cfg.TLS.KeyFile != "" {
+		query.Set("sslkey", cfg.TLS.KeyFile)
+	}
+	if seconds := int(cfg.ConnectTimeout.Seconds()); seconds > 0 {
+		query.Set("connect_timeout", strconv.Itoa(seconds))
+	}
 
-	log.Println("Successfully connected to PostgreSQL")
-	return db, nil
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     cfg.Adress,
+		Path:     "/" + cfg.Name,
+		RawQuery: query.Encode(),
+	}
+	return dsn.String(), nil
+}
+
+// sslMode проверяет sslmode из конфига. Прежние булевы значения переводятся в disable/require.
+func sslMode(mode string) (string, error) {
+	switch mode {
+	case "", "false":
+		return "disable", nil
+	case "true":
+		return "require", nil
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown data_base.ssl_mode %q", mode)
+	}
 }
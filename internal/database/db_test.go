@@ -0,0 +1,261 @@
+package database
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"order_service/internal/config"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func testDatabase() config.Database {
+	return config.Database{
+		Adress:  "db.local:5433",
+		User:    "orders",
+		Name:    "orders_db",
+		SslMode: "disable",
+		Pool:    config.DatabasePool{MaxOpen: 20, MinIdle: 2, StatementCache: true},
+	}
+}
+
+func TestDSNEscapesCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     string
+		password string
+	}{
+		{"plain", "orders", "secret"},
+		{"at sign", "orders", "p@ss"},
+		{"colon", "orders", "p:ss"},
+		{"slash", "orders", "p/ss"},
+		{"question mark", "orders", "p?ss"},
+		{"hash", "orders", "p#ss"},
+		{"percent", "orders", "p%41ss"},
+		{"space", "orders", "p ss"},
+		{"all together", "ord@rs:1", "@:/?#% &="},
+		{"unicode", "заказы", "пароль"},
+		{"empty", "orders", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testDatabase()
+			cfg.User, cfg.Password = tt.user, tt.password
+			dsn, err := DSN(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := pgconn.ParseConfig(dsn)
+			if err != nil {
+				t.Fatalf("parse %s: %v", dsn, err)
+			}
+			if parsed.User != tt.user || parsed.Password != tt.password {
+				t.Fatalf("credentials %q/%q, want %q/%q", parsed.User, parsed.Password, tt.user, tt.password)
+			}
+			if parsed.Host != "db.local" || parsed.Port != 5433 || parsed.Database != "orders_db" {
+				t.Fatalf("parsed %s:%d/%s from %s", parsed.Host, parsed.Port, parsed.Database, dsn)
+			}
+		})
+	}
+}
+
+func TestSSLMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{"", "disable", false},
+		{"false", "disable", false},
+		{"true", "require", false},
+		{"disable", "disable", false},
+		{"allow", "allow", false},
+		{"prefer", "prefer", false},
+		{"require", "require", false},
+		{"verify-ca", "verify-ca", false},
+		{"verify-full", "verify-full", false},
+		{"on", "", true},
+		{"verify_full", "", true},
+		{"Require", "", true},
+	}
+	for _, tt := range tests {
+		got, err := sslMode(tt.mode)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("sslMode(%q) = %q, %v; want %q, error %v", tt.mode, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestDSNSSLMode(t *testing.T) {
+	for _, mode := range []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"} {
+		t.Run(mode, func(t *testing.T) {
+			cfg := testDatabase()
+			cfg.SslMode = mode
+			dsn, err := DSN(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := url.Parse(dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := u.Query().Get("sslmode"); got != mode {
+				t.Fatalf("sslmode %q, want %q", got, mode)
+			}
+			parsed, err := pgconn.ParseConfig(dsn)
+			if err != nil {
+				t.Fatalf("pgx rejects sslmode %s: %v", mode, err)
+			}
+			if (parsed.TLSConfig == nil) != (mode == "disable" || mode == "allow") {
+				t.Fatalf("sslmode %s: TLS config %v", mode, parsed.TLSConfig)
+			}
+		})
+	}
+
+	cfg := testDatabase()
+	cfg.SslMode = "on"
+	if _, err := DSN(cfg); err == nil {
+		t.Fatal("DSN accepted unknown ssl_mode")
+	}
+}
+
+func TestDSNParams(t *testing.T) {
+	cfg := testDatabase()
+	cfg.SslMode = "verify-full"
+	cfg.TLS = config.DatabaseTLS{CAFile: "/etc/pg/ca file.pem", CertFile: "/etc/pg/client.crt", KeyFile: "/etc/pg/client&key.pem"}
+	cfg.ConnectTimeout = 7500 * time.Millisecond
+
+	dsn, err := DSN(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"sslmode":         "verify-full",
+		"sslrootcert":     "/etc/pg/ca file.pem",
+		"sslcert":         "/etc/pg/client.crt",
+		"sslkey":          "/etc/pg/client&key.pem",
+		"connect_timeout": "7",
+	}
+	query := u.Query()
+	if len(query) != len(want) {
+		t.Fatalf("query %v, want %v", query, want)
+	}
+	for k, v := range want {
+		if got := query.Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+
+	// Без файлов и таймаута лишних параметров нет
+	dsn, err = DSN(testDatabase())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "postgres://orders:@db.local:5433/orders_db?sslmode=disable"; dsn != want {
+		t.Fatalf("DSN = %s, want %s", dsn, want)
+	}
+}
+
+func TestPoolConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		addr         string
+		pool         config.DatabasePool
+		wantHost     string
+		wantUser     string
+		wantDatabase string
+		wantMax      int32
+		wantMin      int32
+		wantMode     pgx.QueryExecMode
+	}{
+		{
+			name:     "host and port with main credentials",
+			addr:     "replica.local:5432",
+			pool:     config.DatabasePool{MaxOpen: 20, MinIdle: 2, StatementCache: true},
+			wantHost: "replica.local", wantUser: "orders", wantDatabase: "orders_db",
+			wantMax: 20, wantMin: 2, wantMode: pgx.QueryExecModeCacheStatement,
+		},
+		{
+			name:     "raw DSN passes through",
+			addr:     "postgres://other:pw@shard.local:6432/shard_db?sslmode=disable",
+			pool:     config.DatabasePool{MaxOpen: 20, MinIdle: 2, StatementCache: true},
+			wantHost: "shard.local", wantUser: "other", wantDatabase: "shard_db",
+			wantMax: 20, wantMin: 2, wantMode: pgx.QueryExecModeCacheStatement,
+		},
+		{
+			name:     "min idle clamped to max open",
+			addr:     "db.local:5432",
+			pool:     config.DatabasePool{MaxOpen: 5, MinIdle: 10, StatementCache: true},
+			wantHost: "db.local", wantUser: "orders", wantDatabase: "orders_db",
+			wantMax: 5, wantMin: 5, wantMode: pgx.QueryExecModeCacheStatement,
+		},
+		{
+			name:     "min idle equal to max open",
+			addr:     "db.local:5432",
+			pool:     config.DatabasePool{MaxOpen: 4, MinIdle: 4, StatementCache: true},
+			wantHost: "db.local", wantUser: "orders", wantDatabase: "orders_db",
+			wantMax: 4, wantMin: 4, wantMode: pgx.QueryExecModeCacheStatement,
+		},
+		{
+			name:     "statement cache off",
+			addr:     "db.local:5432",
+			pool:     config.DatabasePool{MaxOpen: 20, MinIdle: 0},
+			wantHost: "db.local", wantUser: "orders", wantDatabase: "orders_db",
+			wantMax: 20, wantMin: 0, wantMode: pgx.QueryExecModeExec,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testDatabase()
+			cfg.Pool = tt.pool
+			cfg.Pool.MaxLifetime = 30 * time.Minute
+			cfg.Pool.MaxIdleTime = 5 * time.Minute
+
+			poolCfg, err := poolConfig(cfg, tt.addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn := poolCfg.ConnConfig
+			if conn.Host != tt.wantHost || conn.User != tt.wantUser || conn.Database != tt.wantDatabase {
+				t.Fatalf("connects to %s@%s/%s, want %s@%s/%s",
+					conn.User, conn.Host, conn.Database, tt.wantUser, tt.wantHost, tt.wantDatabase)
+			}
+			if poolCfg.MaxConns != tt.wantMax || poolCfg.MinConns != tt.wantMin {
+				t.Fatalf("conns %d..%d, want %d..%d", poolCfg.MinConns, poolCfg.MaxConns, tt.wantMin, tt.wantMax)
+			}
+			if poolCfg.MaxConnLifetime != 30*time.Minute || poolCfg.MaxConnIdleTime != 5*time.Minute {
+				t.Fatalf("lifetime %s, idle time %s", poolCfg.MaxConnLifetime, poolCfg.MaxConnIdleTime)
+			}
+			if conn.DefaultQueryExecMode != tt.wantMode {
+				t.Fatalf("exec mode %s, want %s", conn.DefaultQueryExecMode, tt.wantMode)
+			}
+		})
+	}
+}
+
+func TestPoolConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		sslMode string
+		addr    string
+	}{
+		{"unknown ssl mode", "on", "db.local:5432"},
+		{"malformed DSN", "disable", "postgres://db.local:port/x"},
+		{"unknown DSN sslmode", "disable", "postgres://u@db.local/x?sslmode=on"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testDatabase()
+			cfg.SslMode = tt.sslMode
+			if _, err := poolConfig(cfg, tt.addr); err == nil {
+				t.Fatal("poolConfig accepted invalid settings")
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"text/tabwriter"
+
+	"order_service/migrations"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// ErrSchemaOutdated — в БД применены не все миграции, которые ожидает бинарь.
+var ErrSchemaOutdated = errors.New("database schema is outdated")
+
+// Команды Migrate.
+const (
+	MigrateUp     = "up"
+	MigrateDown   = "down"
+	MigrateStatus = "status"
+	MigrateRedo   = "redo"
+)
+
+// newMigrator собирает goose-провайдер поверх встроенных миграций. Изменяющие команды
+// берут advisory-блокировку Postgres, поэтому реплики, стартующие одновременно, не применяют
+// миграции параллельно: остальные дождутся первой и увидят схему уже актуальной.
+func newMigrator(db *sql.DB) (*goose.Provider, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+	return goose.NewProvider(goose.DialectPostgres, db, migrations.FS,
+		goose.WithSessionLocker(locker),
+		goose.WithDisableGlobalRegistry(true),
+	)
+}
+
+// Migrate выполняет команду миграций: up — применить все, down — откатить последнюю,
+// redo — откатить и применить последнюю заново, status — вывести состояние в out.
+func Migrate(ctx context.Context, pool *pgxpool.Pool, command string, out io.Writer) error {
+	// goose работает через database/sql; обертка берет соединения из того же пула
+	db := stdlib.OpenDBFromPool(pool)
+	defer db.Close()
+
+	p, err := newMigrator(db)
+	if err != nil {
+		return fmt.Errorf("migrations init error: %w", err)
+	}
+
+	switch command {
+	case MigrateUp:
+		results, err := p.Up(ctx)
+		logResults(results...)
+		return err
+	case MigrateDown:
+		result, err := p.Down(ctx)
+		logResults(result)
+		return err
+	case MigrateRedo:
+		result, err := p.Down(ctx)
+		logResults(result)
+		if err != nil {
+			return err
+		}
+		result, err = p.UpByOne(ctx)
+		logResults(result)
+		return err
+	case MigrateStatus:
+		statuses, err := p.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "version\tstate\tapplied at\tfile")
+		for _, s := range statuses {
+			appliedAt := "-"
+			if !s.AppliedAt.IsZero() {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Source.Version, s.State, appliedAt, s.Source.Path)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q", command)
+	}
+}
+
+func logResults(results ...*goose.MigrationResult) {
+	for _, r := range results {
+		if r != nil {
+			log.Printf("Migration %s", r)
+		}
+	}
+}
+
+// CheckSchema сверяет версию схемы в БД с последней встроенной миграцией.
+// Отставшая схема — ошибка: код рассчитывает на колонки, которых еще нет. Схема новее бинаря
+// допустима (так бывает при выкатке, пока работают старые реплики), но попадает в лог.
+func CheckSchema(ctx context.Context, pool *pgxpool.Pool) error {
+	db := stdlib.OpenDBFromPool(pool)
+	defer db.Close()
+
+	p, err := newMigrator(db)
+	if err != nil {
+		return fmt.Errorf("migrations init error: %w", err)
+	}
+	current, expected, err := p.GetVersions(ctx)
+	if err != nil {
+		return fmt.Errorf("schema version check error: %w", err)
+	}
+	if current < expected {
+		return fmt.Errorf("%w: version %d, expected %d (run migrations or enable data_base.auto_migrate)",
+			ErrSchemaOutdated, current, expected)
+	}
+	if current > expected {
+		log.Printf("Database schema version %d is newer than expected %d", current, expected)
+	}
+	return nil
+}
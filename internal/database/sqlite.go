@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+
+	"order_service/internal/config"
+	"order_service/migrations"
+
+	"github.com/pressly/goose/v3"
+	_ "modernc.org/sqlite"
+)
+
+// Хранилища заказов, см. storage.driver.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
+)
+
+// OpenSQLite открывает файл встроенного хранилища storage.path и применяет к нему
+// миграции: это хранилище для локальной разработки, отдельного шага миграций у него нет.
+// Запись в SQLite последовательна, поэтому пул ограничен одним соединением.
+func OpenSQLite(cfg *config.Config) (*sql.DB, error) {
+	dsn := "file:" + cfg.Storage.Path + "?" + url.Values{
+		"_pragma": {"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)"},
+	}.Encode()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite open error: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if err := migrateSQLite(context.Background(), db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	log.Printf("Using SQLite storage %s", cfg.Storage.Path)
+	return db, nil
+}
+
+func migrateSQLite(ctx context.Context, db *sql.DB) error {
+	fsys, err := fs.Sub(migrations.SQLiteFS, "sqlite")
+	if err != nil {
+		return err
+	}
+	p, err := goose.NewProvider(goose.DialectSQLite3, db, fsys, goose.WithDisableGlobalRegistry(true))
+	if err != nil {
+		return fmt.Errorf("sqlite migrations init error: %w", err)
+	}
+	results, err := p.Up(ctx)
+	logResults(results...)
+	if err != nil {
+		return fmt.Errorf("sqlite migrate error: %w", err)
+	}
+	return nil
+}
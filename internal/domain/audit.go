@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Действия, которые попадают в журнал аудита.
+const (
+	AuditOrderCreate  = "order.create"
+	AuditOrderUpdate  = "order.update"
+	AuditOrderDelete  = "order.delete"
+	AuditOrderErase   = "order.erase"
+	AuditOrderMove    = "order.move"
+	AuditOrderReadPII = "order.read_pii"
+	AuditOrdersList   = "orders.list_pii"
+	AuditOrdersExport = "orders.export_pii"
+)
+
+// AuditEvent — запись журнала аудита.
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Actor      string          `json:"actor"`
+	Source     string          `json:"source"` // http, kafka, cli
+	Action     string          `json:"action"`
+	OrderUID   string          `json:"order_uid,omitempty"`
+	Changes    json.RawMessage `json:"changes,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+}
@@ -0,0 +1,8 @@
+package domain
+
+// ErasedValue — значение, которым затираются персональные данные при GDPR-стирании.
+const ErasedValue = "[erased]"
+
+// ErasedDeliveryFields — поля доставки, которые затираются при стирании.
+// Город и регион не идентифицируют человека и остаются для аналитики.
+var ErasedDeliveryFields = []string{"name", "phone", "zip", "address", "email"}
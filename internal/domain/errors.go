@@ -9,4 +9,10 @@ var (
 	ErrOrderUIDNotUnique = errors.New("order UID is not unique")
 	ErrOrderUIDEmpty     = errors.New("order UID cannot be empty")
 	ErrInternal          = errors.New("internal server error")
+	ErrInvalidFilter     = errors.New("invalid order filter")
+	ErrActorRequired     = errors.New("actor is required")
+	ErrVersionMismatch   = errors.New("order version mismatch")
+	ErrPIIErased         = errors.New("personal data of the order is erased")
+	ErrOrderArchived     = errors.New("order is archived")
+	ErrInvalidMessage    = errors.New("invalid order message")
 )
@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// OrderFilter описывает условия выборки заказов для листинга и выгрузки.
+// Пустые поля не участвуют в фильтрации.
+type OrderFilter struct {
+	CustomerID      string
+	TrackNumber     string
+	DeliveryService string
+	Locale          string
+	Email           string // поиск по равенству, при шифровании — через слепой индекс
+	Phone           string
+	DateFrom        *time.Time // включительно
+	DateTo          *time.Time // не включительно
+	Limit           int        // 0 — без ограничения
+}
@@ -1,74 +1,404 @@
 package domain
 
 import (
+	"embed"
+	"errors"
 	"fmt"
+	"math"
 	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-func generateRandomString(prefix string, length int) string {
-	const chars = "abcdefghijklmnopqrstuvwxyz0123456789"
-	result := make([]byte, length)
-	for i := range result {
-		result[i] = chars[rand.Intn(len(chars))]
+//go:embed wordlists
+var wordlistsFS embed.FS
+
+// MaxGeneratedItems — наибольшее число товаров в сгенерированном заказе.
+const MaxGeneratedItems = 100
+
+// ItemWeight — вес количества товаров в распределении генератора.
+type ItemWeight struct {
+	Count  int
+	Weight int
+}
+
+// GeneratorOptions — параметры генерации тестовых заказов.
+type GeneratorOptions struct {
+	Seed             int64        // 0 — случайный сид
+	ItemWeights      []ItemWeight // распределение числа товаров в заказе
+	Currencies       []string
+	Providers        []string
+	DeliveryServices []string
+	Locale           string    // en или ru, определяет имена, города и формат телефона
+	InvalidRate      float64   // доля заведомо невалидных заказов, 0..1
+	DuplicateRate    float64   // доля заказов с UID, уже выданным ранее, 0..1
+	BaseTime         time.Time // даты заказов отсчитываются назад от нее; нулевая — см. NewGenerator
+}
+
+// ParseItemWeights разбирает распределение числа товаров вида "1:60,2:25,3:15".
+func ParseItemWeights(s string) ([]ItemWeight, error) {
+	var weights []ItemWeight
+	for _, part := range strings.Split(s, ",") {
+		count, weight, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid item weight %q, expected count:weight", part)
+		}
+		var w ItemWeight
+		var err error
+		if w.Count, err = strconv.Atoi(strings.TrimSpace(count)); err != nil {
+			return nil, fmt.Errorf("invalid item count %q", count)
+		}
+		if w.Weight, err = strconv.Atoi(strings.TrimSpace(weight)); err != nil {
+			return nil, fmt.Errorf("invalid item weight %q", weight)
+		}
+		weights = append(weights, w)
 	}
-	return prefix + string(result)
+	return weights, nil
 }
 
-func GenerateRandomOrder() Order {
-	uid := uuid.New().String()
-	trackNumber := generateRandomString("WBIL", 10)
-	price := rand.Intn(1000) + 100
-	sale := rand.Intn(50)
-	totalPrice := price * (100 - sale) / 100
+// DefaultGeneratorOptions возвращает параметры по умолчанию.
+func DefaultGeneratorOptions() GeneratorOptions {
+	return GeneratorOptions{
+		ItemWeights:      []ItemWeight{{1, 60}, {2, 25}, {3, 10}, {5, 5}},
+		Currencies:       []string{"USD"},
+		Providers:        []string{"wbpay"},
+		DeliveryServices: []string{"meest"},
+		Locale:           "en",
+	}
+}
+
+// seededBaseTime — точка отсчета дат при заданном сиде, чтобы вывод не зависел от текущего времени.
+var seededBaseTime = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// generatorLocale — словари и форматы для одной локали.
+type generatorLocale struct {
+	firstNames  []string
+	lastNames   []string
+	cities      [][2]string // город, регион
+	streets     []string
+	phonePrefix string
+	phoneDigits int
+	zipDigits   int
+}
+
+var (
+	generatorLocales = map[string]*generatorLocale{
+		"en": loadGeneratorLocale("en", "+1", 10, 5),
+		"ru": loadGeneratorLocale("ru", "+7", 10, 6),
+	}
+	brands   = mustReadWordlist("wordlists/brands.txt")
+	products = mustReadWordlist("wordlists/products.txt")
+)
+
+func loadGeneratorLocale(name, phonePrefix string, phoneDigits, zipDigits int) *generatorLocale {
+	l := &generatorLocale{
+		firstNames:  mustReadWordlist("wordlists/" + name + "/first_names.txt"),
+		lastNames:   mustReadWordlist("wordlists/" + name + "/last_names.txt"),
+		streets:     mustReadWordlist("wordlists/" + name + "/streets.txt"),
+		phonePrefix: phonePrefix,
+		phoneDigits: phoneDigits,
+		zipDigits:   zipDigits,
+	}
+	for _, line := range mustReadWordlist("wordlists/" + name + "/cities.txt") {
+		city, region, _ := strings.Cut(line, "|")
+		l.cities = append(l.cities, [2]string{city, region})
+	}
+	return l
+}
+
+func mustReadWordlist(path string) []string {
+	data, err := wordlistsFS.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+	var words []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			words = append(words, line)
+		}
+	}
+	return words
+}
+
+// Generator выдает тестовые заказы. При одинаковом сиде и параметрах
+// последовательность заказов всегда одна и та же. Не потокобезопасен.
+type Generator struct {
+	opts   GeneratorOptions
+	rng    *rand.Rand
+	locale *generatorLocale
+	total  int
+	issued []string
+}
+
+// NewGenerator проверяет параметры и создает генератор.
+// Незаданные поля берутся из DefaultGeneratorOptions. Без BaseTime даты отсчитываются
+// от seededBaseTime, если сид задан, и от текущего времени, если нет: вывод воспроизводится
+// по паре Seed и BaseTime.
+func NewGenerator(opts GeneratorOptions) (*Generator, error) {
+	def := DefaultGeneratorOptions()
+	if len(opts.ItemWeights) == 0 {
+		opts.ItemWeights = def.ItemWeights
+	}
+	if len(opts.Currencies) == 0 {
+		opts.Currencies = def.Currencies
+	}
+	if len(opts.Providers) == 0 {
+		opts.Providers = def.Providers
+	}
+	if len(opts.DeliveryServices) == 0 {
+		opts.DeliveryServices = def.DeliveryServices
+	}
+	if opts.Locale == "" {
+		opts.Locale = def.Locale
+	}
+
+	locale, ok := generatorLocales[opts.Locale]
+	if !ok {
+		return nil, fmt.Errorf("unsupported locale: %q", opts.Locale)
+	}
+	if opts.InvalidRate < 0 || opts.InvalidRate > 1 {
+		return nil, errors.New("invalid rate must be between 0 and 1")
+	}
+	if opts.DuplicateRate < 0 || opts.DuplicateRate > 1 {
+		return nil, errors.New("duplicate rate must be between 0 and 1")
+	}
+	for _, w := range opts.ItemWeights {
+		if w.Count < 1 || w.Weight < 0 {
+			return nil, fmt.Errorf("invalid item weight %d:%d", w.Count, w.Weight)
+		}
+		if w.Count > MaxGeneratedItems {
+			return nil, fmt.Errorf("item count %d exceeds maximum of %d", w.Count, MaxGeneratedItems)
+		}
+	}
+
+	// Порядок весов влияет на выбор, поэтому нормализуем его
+	weights := append([]ItemWeight(nil), opts.ItemWeights...)
+	sort.Slice(weights, func(i, j int) bool { return weights[i].Count < weights[j].Count })
+	opts.ItemWeights = weights
+
+	total := 0
+	for _, w := range weights {
+		// Сумма весов идет в rand.Intn: переполнение дало бы отрицательную границу и панику
+		if w.Weight > math.MaxInt32-total {
+			return nil, errors.New("item weights sum is too large")
+		}
+		total += w.Weight
+	}
+	if total == 0 {
+		return nil, errors.New("item weights must not all be zero")
+	}
+
+	if opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+		if opts.BaseTime.IsZero() {
+			// С точностью до секунды: так время можно передать обратно в RFC 3339
+			opts.BaseTime = time.Now().UTC().Truncate(time.Second)
+		}
+	}
+	if opts.BaseTime.IsZero() {
+		opts.BaseTime = seededBaseTime
+	}
+
+	return &Generator{
+		opts:   opts,
+		rng:    rand.New(rand.NewSource(opts.Seed)),
+		locale: locale,
+		total:  total,
+	}, nil
+}
+
+// Seed возвращает фактически использованный сид. Вывод воспроизводится по нему вместе с BaseTime.
+func (g *Generator) Seed() int64 {
+	return g.opts.Seed
+}
+
+// BaseTime возвращает фактически использованную точку отсчета дат.
+func (g *Generator) BaseTime() time.Time {
+	return g.opts.BaseTime
+}
+
+// Options возвращает фактически использованные параметры генератора.
+func (g *Generator) Options() GeneratorOptions {
+	return g.opts
+}
+
+// Generate выдает n заказов.
+func (g *Generator) Generate(n int) []Order {
+	orders := make([]Order, n)
+	for i := range orders {
+		orders[i] = g.Next()
+	}
+	return orders
+}
+
+// Next выдает очередной заказ.
+func (g *Generator) Next() Order {
+	// Все случайные величины берутся в фиксированном порядке, иначе сломается воспроизводимость
+	duplicate := g.rng.Float64() < g.opts.DuplicateRate && len(g.issued) > 0
+	invalid := g.rng.Float64() < g.opts.InvalidRate
+
+	uid := g.uuid()
+	if duplicate {
+		uid = g.issued[g.rng.Intn(len(g.issued))]
+	} else {
+		g.issued = append(g.issued, uid)
+	}
+
+	order := g.order(uid)
+	if invalid {
+		g.corrupt(&order)
+	}
+	return order
+}
+
+func (g *Generator) order(uid string) Order {
+	l := g.locale
+	trackNumber := "WBIL" + g.code(10)
+	created := g.opts.BaseTime.Add(-time.Duration(g.rng.Int63n(int64(30 * 24 * time.Hour)))).Truncate(time.Second)
+
+	first, last := g.pick(l.firstNames), g.pick(l.lastNames)
+	city := l.cities[g.rng.Intn(len(l.cities))]
+
+	items := make([]Item, g.itemCount())
+	goodsTotal := 0
+	for i := range items {
+		price := (g.rng.Intn(200) + 5) * 10
+		sale := []int{0, 0, 10, 15, 20, 30, 50}[g.rng.Intn(7)]
+		total := price * (100 - sale) / 100
+		goodsTotal += total
+		items[i] = Item{
+			ChrtID:      g.rng.Intn(9000000) + 1000000,
+			TrackNumber: trackNumber,
+			Price:       price,
+			Rid:         g.code(19),
+			Name:        g.pick(products),
+			Sale:        sale,
+			Size:        []string{"0", "S", "M", "L", "XL", "42"}[g.rng.Intn(6)],
+			TotalPrice:  total,
+			NmID:        g.rng.Intn(9000000) + 1000000,
+			Brand:       g.pick(brands),
+			Status:      []int{202, 202, 202, 200, 201, 203}[g.rng.Intn(6)],
+		}
+	}
+	deliveryCost := []int{0, 500, 1000, 1500}[g.rng.Intn(4)]
 
 	return Order{
 		OrderUID:    uid,
 		TrackNumber: trackNumber,
 		Entry:       "WBIL",
 		Delivery: Delivery{
-			Name:    generateRandomString("User", 6),
-			Phone:   fmt.Sprintf("+972%07d", rand.Intn(10000000)),
-			Zip:     fmt.Sprintf("%06d", rand.Intn(1000000)),
-			City:    generateRandomString("City", 5),
-			Address: generateRandomString("Street", 8),
-			Region:  generateRandomString("Region", 4),
-			Email:   generateRandomString("user", 6) + "@example.com",
+			Name:    first + " " + last,
+			Phone:   l.phonePrefix + g.digits(l.phoneDigits),
+			Zip:     g.digits(l.zipDigits),
+			City:    city[0],
+			Address: fmt.Sprintf("%s %d", g.pick(l.streets), g.rng.Intn(150)+1),
+			Region:  city[1],
+			Email:   strings.ToLower(transliterate(first)+"."+transliterate(last)) + fmt.Sprintf("%d@example.com", g.rng.Intn(100)),
 		},
 		Payment: Payment{
 			Transaction:  uid,
 			RequestID:    "",
-			Currency:     "USD",
-			Provider:     "wbpay",
-			Amount:       price + 1500,
-			PaymentDt:    time.Now().Unix(),
-			Bank:         "alpha",
-			DeliveryCost: 1500,
-			GoodsTotal:   totalPrice,
+			Currency:     g.pick(g.opts.Currencies),
+			Provider:     g.pick(g.opts.Providers),
+			Amount:       goodsTotal + deliveryCost,
+			PaymentDt:    created.Unix(),
+			Bank:         []string{"alpha", "sber", "tinkoff", "vtb"}[g.rng.Intn(4)],
+			DeliveryCost: deliveryCost,
+			GoodsTotal:   goodsTotal,
 			CustomFee:    0,
 		},
-		Items: []Item{{
-			ChrtID:      rand.Intn(10000000),
-			TrackNumber: trackNumber,
-			Price:       price,
-			Rid:         generateRandomString("rid", 12),
-			Name:        generateRandomString("Product", 6),
-			Sale:        sale,
-			Size:        "0",
-			TotalPrice:  totalPrice,
-			NmID:        rand.Intn(10000000),
-			Brand:       generateRandomString("Brand", 5),
-			Status:      202,
-		}},
-		Locale:            "en",
+		Items:             items,
+		Locale:            g.opts.Locale,
 		InternalSignature: "",
-		CustomerID:        "test",
-		DeliveryService:   "meest",
-		Shardkey:          "9",
-		SmID:              99,
-		DateCreated:       time.Now(),
-		OofShard:          "1",
+		CustomerID:        "customer" + g.digits(6),
+		DeliveryService:   g.pick(g.opts.DeliveryServices),
+		Shardkey:          fmt.Sprint(g.rng.Intn(10)),
+		SmID:              g.rng.Intn(100) + 1,
+		DateCreated:       created,
+		OofShard:          fmt.Sprint(g.rng.Intn(2) + 1),
+	}
+}
+
+// corrupt делает заказ невалидным одним из типичных способов.
+func (g *Generator) corrupt(order *Order) {
+	switch g.rng.Intn(4) {
+	case 0:
+		order.OrderUID = ""
+	case 1:
+		order.Payment.Amount = -order.Payment.Amount - 1
+	case 2:
+		order.Items = []Item{}
+	case 3:
+		order.TrackNumber = ""
+	}
+}
+
+func (g *Generator) itemCount() int {
+	n := g.rng.Intn(g.total)
+	for _, w := range g.opts.ItemWeights {
+		if n < w.Weight {
+			return w.Count
+		}
+		n -= w.Weight
+	}
+	return g.opts.ItemWeights[len(g.opts.ItemWeights)-1].Count
+}
+
+func (g *Generator) uuid() string {
+	id, err := uuid.NewRandomFromReader(g.rng)
+	if err != nil {
+		panic(err) // rand.Rand.Read не возвращает ошибок
+	}
+	return id.String()
+}
+
+func (g *Generator) pick(values []string) string {
+	return values[g.rng.Intn(len(values))]
+}
+
+func (g *Generator) code(length int) string {
+	const chars = "abcdefghijklmnopqrstuvwxyz0123456789"
+	result := make([]byte, length)
+	for i := range result {
+		result[i] = chars[g.rng.Intn(len(chars))]
+	}
+	return string(result)
+}
+
+func (g *Generator) digits(length int) string {
+	result := make([]byte, length)
+	for i := range result {
+		result[i] = byte('0' + g.rng.Intn(10))
+	}
+	return string(result)
+}
+
+// transliterate переводит кириллицу в латиницу для email.
+func transliterate(s string) string {
+	const from = "абвгдеёжзийклмнопрстуфхцчшщъыьэюя"
+	to := []string{"a", "b", "v", "g", "d", "e", "e", "zh", "z", "i", "y", "k", "l", "m", "n", "o", "p",
+		"r", "s", "t", "u", "f", "kh", "ts", "ch", "sh", "sch", "", "y", "", "e", "yu", "ya"}
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if i := strings.IndexRune(from, r); i >= 0 {
+			b.WriteString(to[len([]rune(from[:i]))])
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// GenerateRandomOrder выдает один случайный заказ с параметрами по умолчанию.
+func GenerateRandomOrder() Order {
+	g, err := NewGenerator(DefaultGeneratorOptions())
+	if err != nil {
+		panic(err) // параметры по умолчанию всегда валидны
 	}
+	return g.Next()
 }
@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGeneratorReproducibleFromSeedAndBaseTime(t *testing.T) {
+	first, err := NewGenerator(GeneratorOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := first.Generate(5)
+
+	// Все, что нужно для повтора, генератор отдает наружу
+	base, err := time.Parse(time.RFC3339Nano, first.BaseTime().Format(time.RFC3339Nano))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := NewGenerator(GeneratorOptions{Seed: first.Seed(), BaseTime: base})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := again.Generate(5); !reflect.DeepEqual(got, want) {
+		t.Fatalf("orders differ for seed %d and base time %s", first.Seed(), base)
+	}
+}
+
+func TestParseItemWeights(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []ItemWeight
+		wantErr bool
+	}{
+		{"1:60,2:25,3:15", []ItemWeight{{1, 60}, {2, 25}, {3, 15}}, false},
+		{" 1 : 60 , 2:40 ", []ItemWeight{{1, 60}, {2, 40}}, false},
+		{"3abc:60xyz", nil, true},
+		{"3:60xyz", nil, true},
+		{"3 4:60", nil, true},
+		{"1:60,2", nil, true},
+		{"1:60,", nil, true},
+		{"one:60", nil, true},
+		{"1:", nil, true},
+		{"", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseItemWeights(tt.in)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseItemWeights(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestNewGeneratorRejectsBadItemWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights []ItemWeight
+	}{
+		{"zero count", []ItemWeight{{0, 1}}},
+		{"negative weight", []ItemWeight{{1, -1}}},
+		{"too many items", []ItemWeight{{MaxGeneratedItems + 1, 1}}},
+		{"all zero", []ItemWeight{{1, 0}, {2, 0}}},
+		{"weight sum overflow", []ItemWeight{{1, math.MaxInt32}, {2, 1}}},
+		{"huge weight", []ItemWeight{{1, math.MaxInt}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewGenerator(GeneratorOptions{Seed: 1, ItemWeights: tt.weights}); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+
+	g, err := NewGenerator(GeneratorOptions{Seed: 1, ItemWeights: []ItemWeight{{MaxGeneratedItems, math.MaxInt32}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(g.Next().Items); n != MaxGeneratedItems {
+		t.Fatalf("items = %d, want %d", n, MaxGeneratedItems)
+	}
+}
@@ -20,23 +20,38 @@ type Order struct {
 	SmID              int       `json:"sm_id"`
 	DateCreated       time.Time `json:"date_created"`
 	OofShard          string    `json:"oof_shard"`
+	Version           int64     `json:"version"`    // растет при каждом изменении, задается хранилищем
+	UpdatedAt         time.Time `json:"updated_at"` // время последнего изменения, задается хранилищем
+}
+
+// OrderVersion — UID заказа и его версия после изменения.
+type OrderVersion struct {
+	OrderUID string
+	Version  int64
+}
+
+// OrderMeta — сведения для условных запросов: по ним проверяется актуальность без чтения заказа.
+type OrderMeta struct {
+	Version   int64
+	UpdatedAt time.Time
 }
 
 // Delivery представляет информацию о доставке.
+// Тег pii задает вид персональных данных поля для маскирования (см. пакет pii).
 type Delivery struct {
-	Name    string `json:"name"`
-	Phone   string `json:"phone"`
-	Zip     string `json:"zip"`
+	Name    string `json:"name" pii:"name"`
+	Phone   string `json:"phone" pii:"phone"`
+	Zip     string `json:"zip" pii:"zip"`
 	City    string `json:"city"`
-	Address string `json:"address"`
+	Address string `json:"address" pii:"address"`
 	Region  string `json:"region"`
-	Email   string `json:"email"`
+	Email   string `json:"email" pii:"email"`
 }
 
 // Payment представляет информацию об оплате.
 type Payment struct {
-	Transaction  string `json:"transaction"`
-	RequestID    string `json:"request_id"`
+	Transaction  string `json:"transaction" pii:"id"`
+	RequestID    string `json:"request_id" pii:"id"`
 	Currency     string `json:"currency"`
 	Provider     string `json:"provider"`
 	Amount       int    `json:"amount"`
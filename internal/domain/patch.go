@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OrderPatch — частичное изменение заказа: контакты и адрес доставки, статусы товаров.
+// Незаданные поля не меняются.
+type OrderPatch struct {
+	Delivery *DeliveryPatch    `json:"delivery,omitempty"`
+	Items    []ItemStatusPatch `json:"items,omitempty"`
+}
+
+// DeliveryPatch — изменение полей доставки. nil — поле не меняется.
+type DeliveryPatch struct {
+	Name    *string `json:"name,omitempty"`
+	Phone   *string `json:"phone,omitempty"`
+	Zip     *string `json:"zip,omitempty"`
+	City    *string `json:"city,omitempty"`
+	Address *string `json:"address,omitempty"`
+	Region  *string `json:"region,omitempty"`
+	Email   *string `json:"email,omitempty"`
+}
+
+// ItemStatusPatch — новый статус товара, товар ищется по rid.
+type ItemStatusPatch struct {
+	Rid    string `json:"rid"`
+	Status int    `json:"status"`
+}
+
+// fields возвращает пары «изменение — поле доставки» для применения патча.
+func (p *DeliveryPatch) fields(d *Delivery) map[string][2]*string {
+	return map[string][2]*string{
+		"name":    {p.Name, &d.Name},
+		"phone":   {p.Phone, &d.Phone},
+		"zip":     {p.Zip, &d.Zip},
+		"city":    {p.City, &d.City},
+		"address": {p.Address, &d.Address},
+		"region":  {p.Region, &d.Region},
+		"email":   {p.Email, &d.Email},
+	}
+}
+
+// Validate проверяет патч до обращения к хранилищу.
+func (p OrderPatch) Validate() error {
+	if p.Delivery == nil && len(p.Items) == 0 {
+		return fmt.Errorf("%w: patch is empty", ErrInvalidOrder)
+	}
+	if p.Delivery != nil {
+		for name, f := range p.Delivery.fields(&Delivery{}) {
+			if f[0] != nil && strings.TrimSpace(*f[0]) == "" {
+				return fmt.Errorf("%w: delivery.%s cannot be empty", ErrInvalidOrder, name)
+			}
+		}
+		if p.Delivery.Email != nil && !strings.Contains(*p.Delivery.Email, "@") {
+			return fmt.Errorf("%w: delivery.email is malformed", ErrInvalidOrder)
+		}
+	}
+	seen := make(map[string]bool, len(p.Items))
+	for _, item := range p.Items {
+		if item.Rid == "" {
+			return fmt.Errorf("%w: items[].rid is required", ErrInvalidOrder)
+		}
+		if seen[item.Rid] {
+			return fmt.Errorf("%w: item %s is patched twice", ErrInvalidOrder, item.Rid)
+		}
+		seen[item.Rid] = true
+		if item.Status < 0 {
+			return fmt.Errorf("%w: item %s status must be non-negative", ErrInvalidOrder, item.Rid)
+		}
+	}
+	return nil
+}
+
+// Apply применяет патч к заказу на месте.
+func (p OrderPatch) Apply(order *Order) error {
+	if p.Delivery != nil {
+		for _, f := range p.Delivery.fields(&order.Delivery) {
+			if f[0] != nil {
+				*f[1] = strings.TrimSpace(*f[0])
+			}
+		}
+	}
+	for _, patch := range p.Items {
+		found := false
+		for i := range order.Items {
+			if order.Items[i].Rid == patch.Rid {
+				order.Items[i].Status = patch.Status
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: item %s not found in order", ErrInvalidOrder, patch.Rid)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package encryption
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"order_service/internal/config"
+)
+
+// Схема конвертного шифрования: для каждой строки генерируется ключ данных (DEK),
+// поля шифруются им через AES-GCM, а сам DEK хранится рядом, зашифрованный мастер-ключом (KEK).
+// Ротация мастер-ключа требует только перешифровать DEK, сами поля не трогаются.
+
+const (
+	keySize = 32 // AES-256
+
+	// sealedPrefix отличает зашифрованное значение от открытого текста (старые строки, затертые данные).
+	sealedPrefix = "enc:"
+)
+
+var (
+	ErrUnknownKey  = errors.New("unknown encryption key id")
+	ErrInvalidData = errors.New("invalid encrypted data")
+)
+
+// Keyring хранит мастер-ключи по идентификаторам и ключ для слепых индексов.
+type Keyring struct {
+	keys     map[string][]byte
+	activeID string
+	indexKey []byte
+}
+
+// LoadKeyring собирает ключи из конфига и файла ключей.
+// Возвращает nil без ошибки, если шифрование выключено.
+func LoadKeyring(cfg *config.Config) (*Keyring, error) {
+	ec := cfg.Encryption
+	if !ec.Enabled {
+		return nil, nil
+	}
+
+	keys := map[string]string{}
+	for id, key := range ec.Keys {
+		keys[id] = key
+	}
+	if ec.KeysFile != "" {
+		fileKeys, err := readKeysFile(ec.KeysFile)
+		if err != nil {
+			return nil, err
+		}
+		for id, key := range fileKeys {
+			keys[id] = key
+		}
+	}
+
+	return NewKeyring(keys, ec.ActiveKeyID, ec.IndexKey)
+}
+
+// NewKeyring создает Keyring из ключей в base64. activeID — ключ для новых записей.
+func NewKeyring(keys map[string]string, activeID, indexKey string) (*Keyring, error) {
+	k := &Keyring{keys: map[string][]byte{}, activeID: activeID}
+	for id, encoded := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		k.keys[id] = key
+	}
+	if _, ok := k.keys[activeID]; !ok {
+		return nil, fmt.Errorf("active key %q: %w", activeID, ErrUnknownKey)
+	}
+
+	var err error
+	if k.indexKey, err = decodeKey(indexKey); err != nil {
+		return nil, fmt.Errorf("index key: %w", err)
+	}
+	return k, nil
+}
+
+// readKeysFile читает файл ключей: по строке "<id>:<base64>", пустые строки и # игнорируются.
+func readKeysFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keys file: %w", err)
+	}
+	defer f.Close()
+
+	keys := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, key, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, errors.New("invalid keys file line, expected <id>:<base64>")
+		}
+		keys[strings.TrimSpace(id)] = strings.TrimSpace(key)
+	}
+	return keys, scanner.Err()
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64: %w", err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", keySize, len(key))
+	}
+	return key, nil
+}
+
+// ActiveKeyID возвращает идентификатор ключа, которым шифруются новые DEK.
+func (k *Keyring) ActiveKeyID() string {
+	return k.activeID
+}
+
+// NewDataKey генерирует DEK и возвращает его вместе с обернутой формой "<kid>:<base64>" для хранения.
+func (k *Keyring) NewDataKey() ([]byte, string, error) {
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, "", err
+	}
+	wrapped, err := k.wrap(k.activeID, dek)
+	if err != nil {
+		return nil, "", err
+	}
+	return dek, wrapped, nil
+}
+
+// UnwrapKey расшифровывает DEK мастер-ключом, указанным в обернутой форме.
+func (k *Keyring) UnwrapKey(wrapped string) ([]byte, error) {
+	id, encoded, ok := strings.Cut(wrapped, ":")
+	if !ok {
+		return nil, ErrInvalidData
+	}
+	kek, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidData
+	}
+	return open(kek, data, []byte(id))
+}
+
+// Rewrap перешифровывает DEK активным ключом. Второй результат — false, если DEK уже на активном ключе.
+func (k *Keyring) Rewrap(wrapped string) (string, bool, error) {
+	if strings.HasPrefix(wrapped, k.activeID+":") {
+		return wrapped, false, nil
+	}
+	dek, err := k.UnwrapKey(wrapped)
+	if err != nil {
+		return "", false, err
+	}
+	rewrapped, err := k.wrap(k.activeID, dek)
+	if err != nil {
+		return "", false, err
+	}
+	return rewrapped, true, nil
+}
+
+func (k *Keyring) wrap(id string, dek []byte) (string, error) {
+	data, err := seal(k.keys[id], dek, []byte(id))
+	if err != nil {
+		return "", err
+	}
+	return id + ":" + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Seal шифрует значение ключом данных. aad привязывает шифротекст к строке и полю,
+// чтобы его нельзя было переставить в другую запись. Пустая строка не шифруется.
+func Seal(dek []byte, plaintext, aad string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	data, err := seal(dek, []byte(plaintext), []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Open расшифровывает значение. Значения без префикса возвращаются как есть:
+// это строки до миграции и затертые при GDPR-стирании данные.
+func Open(dek []byte, value, aad string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, sealedPrefix)
+	if !ok {
+		return value, nil
+	}
+	if dek == nil {
+		return "", fmt.Errorf("%w: missing data key", ErrInvalidData)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidData
+	}
+	plaintext, err := open(dek, data, []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// IsSealed сообщает, зашифровано ли значение.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+// BlindIndex считает детерминированный HMAC нормализованного значения для поиска по равенству.
+// kind разделяет индексы разных полей, чтобы одинаковые значения не совпадали между ними.
+func (k *Keyring) BlindIndex(kind, value string) string {
+	if value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(kind + ":" + Normalize(kind, value)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Normalize приводит значение к каноническому виду перед построением индекса:
+// email — в нижний регистр, телефон — только цифры.
+func Normalize(kind, value string) string {
+	value = strings.TrimSpace(value)
+	switch kind {
+	case "email":
+		return strings.ToLower(value)
+	case "phone":
+		var b strings.Builder
+		for _, r := range value {
+			if r >= '0' && r <= '9' {
+				b.WriteRune(r)
+			}
+		}
+		return b.String()
+	}
+	return value
+}
+
+// seal шифрует AES-GCM, результат — nonce || ciphertext.
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key, data, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrInvalidData
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
+	if err != nil {
+		return nil, ErrInvalidData
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,67 @@
+package export
+
+import (
+	"fmt"
+	"io"
+
+	"order_service/internal/domain"
+)
+
+// Format — формат выгрузки заказов.
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatNDJSON  Format = "ndjson"
+	FormatParquet Format = "parquet"
+)
+
+// ParseFormat разбирает формат из строки. Пустая строка означает NDJSON.
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case "", FormatNDJSON:
+		return FormatNDJSON, nil
+	case FormatCSV:
+		return FormatCSV, nil
+	case FormatParquet:
+		return FormatParquet, nil
+	}
+	return "", fmt.Errorf("unsupported export format: %q", s)
+}
+
+// ContentType возвращает MIME-тип формата.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	default:
+		return "application/x-ndjson"
+	}
+}
+
+// Extension возвращает расширение файла для формата.
+func (f Format) Extension() string {
+	return "." + string(f)
+}
+
+// Writer пишет заказы в выходной поток по одному.
+// Close дописывает хвост формата (футер parquet, буфер csv) и не закрывает сам поток.
+type Writer interface {
+	Write(order *domain.Order) error
+	Close() error
+}
+
+// NewWriter создает Writer для указанного формата.
+func NewWriter(format Format, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatNDJSON:
+		return newNDJSONWriter(w), nil
+	case FormatParquet:
+		return newParquetWriter(w)
+	}
+	return nil, fmt.Errorf("unsupported export format: %q", format)
+}
@@ -0,0 +1,309 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"order_service/internal/domain"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// testOrders возвращает n заказов генератора и два крайних случая: заказ без товаров
+// и заказ с символами, которые CSV должен экранировать.
+func testOrders(t *testing.T, n int) []domain.Order {
+	t.Helper()
+	gen, err := domain.NewGenerator(domain.GeneratorOptions{Seed: 11})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders := gen.Generate(n)
+
+	empty := gen.Next()
+	empty.Items = nil
+	quoted := gen.Next()
+	quoted.Delivery.Name = `Иванов, "Иван"`
+	quoted.Delivery.Address = "ул. Мира 15\nкв. 7"
+	quoted.Items[0].Name = "a;b,c\n"
+	return append(orders, empty, quoted)
+}
+
+func write(t *testing.T, format Format, orders []domain.Order) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range orders {
+		if err := w.Write(&orders[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// flatOrder — заказ в том виде, в каком его сохраняет плоская выгрузка: без служебных полей,
+// время с точностью до формата.
+func flatOrder(order domain.Order, precision time.Duration) domain.Order {
+	order.DateCreated = order.DateCreated.Truncate(precision).UTC()
+	order.Version = 0
+	order.UpdatedAt = time.Time{}
+	if len(order.Items) == 0 {
+		order.Items = nil
+	}
+	return order
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	orders := testOrders(t, 20)
+	r := csv.NewReader(bytes.NewReader(write(t, FormatCSV, orders)))
+	header, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(header, flatHeader) {
+		t.Fatalf("header %v, want %v", header, flatHeader)
+	}
+	col := map[string]int{}
+	for i, name := range header {
+		col[name] = i
+	}
+
+	var got []domain.Order
+	rows := 0
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows++
+		got = appendCSVRow(t, got, col, record)
+	}
+
+	wantRows := 0
+	for i := range orders {
+		wantRows += max(len(orders[i].Items), 1)
+	}
+	if rows != wantRows {
+		t.Fatalf("%d rows, want %d", rows, wantRows)
+	}
+	if len(got) != len(orders) {
+		t.Fatalf("%d orders, want %d", len(got), len(orders))
+	}
+	for i := range orders {
+		if want := flatOrder(orders[i], time.Second); !reflect.DeepEqual(got[i], want) {
+			t.Fatalf("order %d:\ngot  %+v\nwant %+v", i, got[i], want)
+		}
+	}
+}
+
+// appendCSVRow собирает заказы обратно из строк: строки одного заказа идут подряд.
+func appendCSVRow(t *testing.T, orders []domain.Order, col map[string]int, record []string) []domain.Order {
+	t.Helper()
+	str := func(name string) string { return record[col[name]] }
+	num := func(name string) int {
+		n, err := strconv.Atoi(str(name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return n
+	}
+
+	if len(orders) == 0 || orders[len(orders)-1].OrderUID != str("order_uid") {
+		created, err := time.Parse(time.RFC3339, str("date_created"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		orders = append(orders, domain.Order{
+			OrderUID:          str("order_uid"),
+			TrackNumber:       str("track_number"),
+			Entry:             str("entry"),
+			Locale:            str("locale"),
+			InternalSignature: str("internal_signature"),
+			CustomerID:        str("customer_id"),
+			DeliveryService:   str("delivery_service"),
+			Shardkey:          str("shardkey"),
+			SmID:              num("sm_id"),
+			DateCreated:       created,
+			OofShard:          str("oof_shard"),
+			Delivery: domain.Delivery{
+				Name:    str("delivery_name"),
+				Phone:   str("delivery_phone"),
+				Zip:     str("delivery_zip"),
+				City:    str("delivery_city"),
+				Address: str("delivery_address"),
+				Region:  str("delivery_region"),
+				Email:   str("delivery_email"),
+			},
+			Payment: domain.Payment{
+				Transaction:  str("payment_transaction"),
+				RequestID:    str("payment_request_id"),
+				Currency:     str("payment_currency"),
+				Provider:     str("payment_provider"),
+				Amount:       num("payment_amount"),
+				PaymentDt:    int64(num("payment_dt")),
+				Bank:         str("payment_bank"),
+				DeliveryCost: num("payment_delivery_cost"),
+				GoodsTotal:   num("payment_goods_total"),
+				CustomFee:    num("payment_custom_fee"),
+			},
+		})
+	}
+	// У заказа без товаров поля товара пустые
+	if str("item_chrt_id") == "" {
+		return orders
+	}
+	order := &orders[len(orders)-1]
+	order.Items = append(order.Items, domain.Item{
+		ChrtID:      num("item_chrt_id"),
+		TrackNumber: str("item_track_number"),
+		Price:       num("item_price"),
+		Rid:         str("item_rid"),
+		Name:        str("item_name"),
+		Sale:        num("item_sale"),
+		Size:        str("item_size"),
+		TotalPrice:  num("item_total_price"),
+		NmID:        num("item_nm_id"),
+		Brand:       str("item_brand"),
+		Status:      num("item_status"),
+	})
+	return orders
+}
+
+func TestNDJSONRoundTrip(t *testing.T) {
+	orders := testOrders(t, 20)
+	for i := range orders {
+		orders[i].Version = int64(i + 1)
+	}
+	data := write(t, FormatNDJSON, orders)
+	if n := bytes.Count(data, []byte("\n")); n != len(orders) {
+		t.Fatalf("%d lines, want %d", n, len(orders))
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	for i := 0; ; i++ {
+		var got domain.Order
+		err := dec.Decode(&got)
+		if errors.Is(err, io.EOF) {
+			if i != len(orders) {
+				t.Fatalf("%d orders, want %d", i, len(orders))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		// NDJSON сохраняет заказ целиком, включая версию и наносекунды
+		if !got.DateCreated.Equal(orders[i].DateCreated) {
+			t.Fatalf("order %d: date_created %s, want %s", i, got.DateCreated, orders[i].DateCreated)
+		}
+		got.DateCreated = orders[i].DateCreated
+		if !reflect.DeepEqual(got, orders[i]) {
+			t.Fatalf("order %d:\ngot  %+v\nwant %+v", i, got, orders[i])
+		}
+	}
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	orders := testOrders(t, 20)
+	file, err := buffer.NewBufferFile(write(t, FormatParquet, orders))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := reader.NewParquetReader(file, new(flatRow), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.ReadStop()
+
+	var want []flatRow
+	for i := range orders {
+		want = append(want, flatten(&orders[i])...)
+	}
+	if n := pr.GetNumRows(); n != int64(len(want)) {
+		t.Fatalf("%d rows, want %d", n, len(want))
+	}
+	got := make([]flatRow, len(want))
+	if err := pr.Read(&got); err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Fatalf("row %d:\ngot  %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+
+	// Поля товара у заказа без товаров — NULL, а не нули
+	empty := got[len(got)-1-len(orders[len(orders)-1].Items)]
+	if empty.OrderUID != orders[len(orders)-2].OrderUID || empty.ItemChrtID != nil || empty.ItemName != nil {
+		t.Fatalf("row of order without items: %+v", empty)
+	}
+	if created := time.UnixMilli(got[0].DateCreated); !created.Equal(orders[0].DateCreated.Truncate(time.Millisecond)) {
+		t.Fatalf("date_created %s, want %s", created, orders[0].DateCreated)
+	}
+}
+
+func TestEmptyExport(t *testing.T) {
+	// Пустая выгрузка остается корректным файлом своего формата
+	if got := string(write(t, FormatNDJSON, nil)); got != "" {
+		t.Fatalf("ndjson %q, want empty", got)
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(write(t, FormatCSV, nil))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || !reflect.DeepEqual(records[0], flatHeader) {
+		t.Fatalf("csv %v, want header only", records)
+	}
+
+	file, err := buffer.NewBufferFile(write(t, FormatParquet, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := reader.NewParquetReader(file, new(flatRow), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.ReadStop()
+	if n := pr.GetNumRows(); n != 0 {
+		t.Fatalf("parquet has %d rows", n)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Format
+		wantErr bool
+	}{
+		{"", FormatNDJSON, false},
+		{"ndjson", FormatNDJSON, false},
+		{"csv", FormatCSV, false},
+		{"parquet", FormatParquet, false},
+		{"xlsx", "", true},
+		{"CSV", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
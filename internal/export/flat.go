@@ -0,0 +1,165 @@
+package export
+
+import (
+	"strconv"
+	"time"
+
+	"order_service/internal/domain"
+)
+
+// flatRow — заказ, развернутый в плоскую строку: данные заказа, доставки и оплаты
+// повторяются для каждого товара. Заказ без товаров дает одну строку с пустыми полями товара.
+type flatRow struct {
+	OrderUID          string `parquet:"name=order_uid, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TrackNumber       string `parquet:"name=track_number, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Entry             string `parquet:"name=entry, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Locale            string `parquet:"name=locale, type=BYTE_ARRAY, convertedtype=UTF8"`
+	InternalSignature string `parquet:"name=internal_signature, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CustomerID        string `parquet:"name=customer_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DeliveryService   string `parquet:"name=delivery_service, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Shardkey          string `parquet:"name=shardkey, type=BYTE_ARRAY, convertedtype=UTF8"`
+	SmID              int64  `parquet:"name=sm_id, type=INT64"`
+	DateCreated       int64  `parquet:"name=date_created, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	OofShard          string `parquet:"name=oof_shard, type=BYTE_ARRAY, convertedtype=UTF8"`
+
+	DeliveryName    string `parquet:"name=delivery_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DeliveryPhone   string `parquet:"name=delivery_phone, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DeliveryZip     string `parquet:"name=delivery_zip, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DeliveryCity    string `parquet:"name=delivery_city, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DeliveryAddress string `parquet:"name=delivery_address, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DeliveryRegion  string `parquet:"name=delivery_region, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DeliveryEmail   string `parquet:"name=delivery_email, type=BYTE_ARRAY, convertedtype=UTF8"`
+
+	PaymentTransaction  string `parquet:"name=payment_transaction, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PaymentRequestID    string `parquet:"name=payment_request_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PaymentCurrency     string `parquet:"name=payment_currency, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PaymentProvider     string `parquet:"name=payment_provider, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PaymentAmount       int64  `parquet:"name=payment_amount, type=INT64"`
+	PaymentDt           int64  `parquet:"name=payment_dt, type=INT64"`
+	PaymentBank         string `parquet:"name=payment_bank, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PaymentDeliveryCost int64  `parquet:"name=payment_delivery_cost, type=INT64"`
+	PaymentGoodsTotal   int64  `parquet:"name=payment_goods_total, type=INT64"`
+	PaymentCustomFee    int64  `parquet:"name=payment_custom_fee, type=INT64"`
+
+	ItemChrtID      *int64  `parquet:"name=item_chrt_id, type=INT64, repetitiontype=OPTIONAL"`
+	ItemTrackNumber *string `parquet:"name=item_track_number, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	ItemPrice       *int64  `parquet:"name=item_price, type=INT64, repetitiontype=OPTIONAL"`
+	ItemRid         *string `parquet:"name=item_rid, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	ItemName        *string `parquet:"name=item_name, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	ItemSale        *int64  `parquet:"name=item_sale, type=INT64, repetitiontype=OPTIONAL"`
+	ItemSize        *string `parquet:"name=item_size, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	ItemTotalPrice  *int64  `parquet:"name=item_total_price, type=INT64, repetitiontype=OPTIONAL"`
+	ItemNmID        *int64  `parquet:"name=item_nm_id, type=INT64, repetitiontype=OPTIONAL"`
+	ItemBrand       *string `parquet:"name=item_brand, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	ItemStatus      *int64  `parquet:"name=item_status, type=INT64, repetitiontype=OPTIONAL"`
+}
+
+// flatHeader — заголовок CSV, порядок совпадает с flatRow.record.
+var flatHeader = []string{
+	"order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id",
+	"delivery_service", "shardkey", "sm_id", "date_created", "oof_shard",
+	"delivery_name", "delivery_phone", "delivery_zip", "delivery_city", "delivery_address",
+	"delivery_region", "delivery_email",
+	"payment_transaction", "payment_request_id", "payment_currency", "payment_provider",
+	"payment_amount", "payment_dt", "payment_bank", "payment_delivery_cost",
+	"payment_goods_total", "payment_custom_fee",
+	"item_chrt_id", "item_track_number", "item_price", "item_rid", "item_name", "item_sale",
+	"item_size", "item_total_price", "item_nm_id", "item_brand", "item_status",
+}
+
+// flatten разворачивает заказ в строки, по одной на товар.
+func flatten(order *domain.Order) []flatRow {
+	base := flatRow{
+		OrderUID:          order.OrderUID,
+		TrackNumber:       order.TrackNumber,
+		Entry:             order.Entry,
+		Locale:            order.Locale,
+		InternalSignature: order.InternalSignature,
+		CustomerID:        order.CustomerID,
+		DeliveryService:   order.DeliveryService,
+		Shardkey:          order.Shardkey,
+		SmID:              int64(order.SmID),
+		DateCreated:       order.DateCreated.UnixMilli(),
+		OofShard:          order.OofShard,
+
+		DeliveryName:    order.Delivery.Name,
+		DeliveryPhone:   order.Delivery.Phone,
+		DeliveryZip:     order.Delivery.Zip,
+		DeliveryCity:    order.Delivery.City,
+		DeliveryAddress: order.Delivery.Address,
+		DeliveryRegion:  order.Delivery.Region,
+		DeliveryEmail:   order.Delivery.Email,
+
+		PaymentTransaction:  order.Payment.Transaction,
+		PaymentRequestID:    order.Payment.RequestID,
+		PaymentCurrency:     order.Payment.Currency,
+		PaymentProvider:     order.Payment.Provider,
+		PaymentAmount:       int64(order.Payment.Amount),
+		PaymentDt:           order.Payment.PaymentDt,
+		PaymentBank:         order.Payment.Bank,
+		PaymentDeliveryCost: int64(order.Payment.DeliveryCost),
+		PaymentGoodsTotal:   int64(order.Payment.GoodsTotal),
+		PaymentCustomFee:    int64(order.Payment.CustomFee),
+	}
+
+	if len(order.Items) == 0 {
+		return []flatRow{base}
+	}
+
+	rows := make([]flatRow, len(order.Items))
+	for i := range order.Items {
+		item := order.Items[i]
+		row := base
+		row.ItemChrtID = int64Ptr(item.ChrtID)
+		row.ItemTrackNumber = &item.TrackNumber
+		row.ItemPrice = int64Ptr(item.Price)
+		row.ItemRid = &item.Rid
+		row.ItemName = &item.Name
+		row.ItemSale = int64Ptr(item.Sale)
+		row.ItemSize = &item.Size
+		row.ItemTotalPrice = int64Ptr(item.TotalPrice)
+		row.ItemNmID = int64Ptr(item.NmID)
+		row.ItemBrand = &item.Brand
+		row.ItemStatus = int64Ptr(item.Status)
+		rows[i] = row
+	}
+	return rows
+}
+
+// record возвращает строку CSV в порядке flatHeader.
+func (r *flatRow) record() []string {
+	return []string{
+		r.OrderUID, r.TrackNumber, r.Entry, r.Locale, r.InternalSignature, r.CustomerID,
+		r.DeliveryService, r.Shardkey, strconv.FormatInt(r.SmID, 10),
+		time.UnixMilli(r.DateCreated).UTC().Format(time.RFC3339), r.OofShard,
+		r.DeliveryName, r.DeliveryPhone, r.DeliveryZip, r.DeliveryCity, r.DeliveryAddress,
+		r.DeliveryRegion, r.DeliveryEmail,
+		r.PaymentTransaction, r.PaymentRequestID, r.PaymentCurrency, r.PaymentProvider,
+		strconv.FormatInt(r.PaymentAmount, 10), strconv.FormatInt(r.PaymentDt, 10), r.PaymentBank,
+		strconv.FormatInt(r.PaymentDeliveryCost, 10), strconv.FormatInt(r.PaymentGoodsTotal, 10),
+		strconv.FormatInt(r.PaymentCustomFee, 10),
+		formatInt(r.ItemChrtID), formatString(r.ItemTrackNumber), formatInt(r.ItemPrice),
+		formatString(r.ItemRid), formatString(r.ItemName), formatInt(r.ItemSale),
+		formatString(r.ItemSize), formatInt(r.ItemTotalPrice), formatInt(r.ItemNmID),
+		formatString(r.ItemBrand), formatInt(r.ItemStatus),
+	}
+}
+
+func int64Ptr(v int) *int64 {
+	n := int64(v)
+	return &n
+}
+
+func formatInt(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
+
+func formatString(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
@@ -0,0 +1,25 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+
+	"order_service/internal/domain"
+)
+
+// ndjsonWriter пишет по одному JSON-документу domain.Order на строку.
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	return &ndjsonWriter{enc: json.NewEncoder(w)}
+}
+
+func (n *ndjsonWriter) Write(order *domain.Order) error {
+	return n.enc.Encode(order) // Encode сам добавляет перевод строки
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
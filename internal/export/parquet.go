@@ -0,0 +1,45 @@
+package export
+
+import (
+	"fmt"
+	"io"
+
+	"order_service/internal/domain"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// parquetRowGroupSize ограничивает буфер row group, чтобы память не росла вместе с выгрузкой.
+const parquetRowGroupSize = 8 * 1024 * 1024
+
+// parquetWriter пишет те же плоские строки, что и CSV, в колоночном формате.
+type parquetWriter struct {
+	pw *writer.ParquetWriter
+}
+
+func newParquetWriter(w io.Writer) (*parquetWriter, error) {
+	pw, err := writer.NewParquetWriterFromWriter(w, new(flatRow), 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create parquet writer: %w", err)
+	}
+	pw.RowGroupSize = parquetRowGroupSize
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+	return &parquetWriter{pw: pw}, nil
+}
+
+func (p *parquetWriter) Write(order *domain.Order) error {
+	for _, row := range flatten(order) {
+		if err := p.pw.Write(row); err != nil {
+			return fmt.Errorf("failed to write parquet row: %w", err)
+		}
+	}
+	return nil
+}
+
+func (p *parquetWriter) Close() error {
+	if err := p.pw.WriteStop(); err != nil {
+		return fmt.Errorf("failed to finish parquet file: %w", err)
+	}
+	return nil
+}
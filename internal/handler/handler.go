@@ -1,36 +1,63 @@
 package handler
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"order_service/internal/audit"
+	"order_service/internal/auth"
+	"order_service/internal/cache"
 	"order_service/internal/config"
 	"order_service/internal/domain"
+	"order_service/internal/export"
+	"order_service/internal/message"
+	"order_service/internal/pii"
 	"order_service/internal/queue"
+	"order_service/internal/render"
 	"order_service/internal/service"
 	"strconv"
+	"strings"
+	"time"
 
-	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/go-chi/chi/v5"
 )
 
 // OrderHandler определяет интерфейс для HTTP-хендлеров заказов.
 type OrderHandler interface {
 	GetOrderByID(w http.ResponseWriter, r *http.Request)
+	UpdateOrder(w http.ResponseWriter, r *http.Request)
 	GenerateOrders(w http.ResponseWriter, r *http.Request)
+	ListOrders(w http.ResponseWriter, r *http.Request)
 	SendOrderToKafka(w http.ResponseWriter, r *http.Request)
+	ExportOrders(w http.ResponseWriter, r *http.Request)
+	DeleteOrder(w http.ResponseWriter, r *http.Request)
+	EraseOrder(w http.ResponseWriter, r *http.Request)
+	EraseCustomer(w http.ResponseWriter, r *http.Request)
+	GetOrderAudit(w http.ResponseWriter, r *http.Request)
+	InvalidateCache(w http.ResponseWriter, r *http.Request)
+	FlushCache(w http.ResponseWriter, r *http.Request)
 }
 
 // orderHandler — реализация OrderHandler.
 type orderHandler struct {
-	service service.OrderService
-	config  *config.Config
+	service   service.OrderService
+	config    *config.Config
+	masker    *pii.Masker
+	publisher queue.Publisher
 }
 
 // NewOrderHandler создает новый экземпляр orderHandler.
-func NewOrderHandler(service service.OrderService, config *config.Config) OrderHandler {
+func NewOrderHandler(service service.OrderService, config *config.Config, masker *pii.Masker, publisher queue.Publisher) OrderHandler {
 	return &orderHandler{
-		service: service,
-		config:  config,
+		service:   service,
+		config:    config,
+		masker:    masker,
+		publisher: publisher,
 	}
 }
 
@@ -52,32 +79,143 @@ func (h *orderHandler) GetOrderByID(w http.ResponseWriter, r *http.Request) {
 	// Создаем контекст из запроса
 	ctx := r.Context()
 
+	// Условный запрос проверяем по версии из кэша, не читая сам заказ
+	if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+		meta, err := h.service.GetOrderMeta(ctx, orderUID)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		etag := h.orderETag(r, meta.Version)
+		if notModified(r, etag, meta.UpdatedAt) {
+			h.setCacheHeaders(w, etag, meta.UpdatedAt)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
 	// Вызываем сервис для получения заказа
 	order, err := h.service.GetOrderByID(ctx, orderUID)
 	if err != nil {
-		if errors.Is(err, domain.ErrOrderUIDNotUnique) || errors.Is(err, domain.ErrOrderUIDEmpty) {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		}
-		if errors.Is(err, domain.ErrOrderNotFound) {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		}
-		if errors.Is(err, domain.ErrInternal) {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		writeServiceError(w, err)
 		return
 	}
+	etag := h.orderETag(r, order.Version)
+	order = h.shapeOrder(r, order)
 
-	// Устанавливаем заголовок Content-Type
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	// Устанавливаем заголовки кэширования и возвращаем заказ в формате из Accept
+	h.setCacheHeaders(w, etag, order.UpdatedAt)
+	writeOrders(w, r, http.StatusOK, order)
+}
 
-	// Возвращаем заказ
-	if err := json.NewEncoder(w).Encode(order); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+// UpdateOrder обрабатывает PATCH /order/{orderID} — частичное изменение заказа.
+// Заголовок If-Match с ETag из GET обязателен: при несовпадении версии возвращается 412.
+// Требует orders:write.
+func (h *orderHandler) UpdateOrder(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requirePermission(w, r, auth.PermOrderWrite); !ok {
 		return
 	}
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" {
+		http.Error(w, `{"error": "If-Match header is required"}`, http.StatusPreconditionRequired)
+		return
+	}
+	var version int64 // "*" — изменение любой версии
+	if ifMatch != "*" {
+		v, ok := parseVersionETag(ifMatch)
+		if !ok {
+			http.Error(w, `{"error": "Invalid If-Match header"}`, http.StatusBadRequest)
+			return
+		}
+		version = v
+	}
+
+	var patch domain.OrderPatch
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patch); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": %q}`, "Invalid JSON: "+err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	order, err := h.service.UpdateOrder(r.Context(), chi.URLParam(r, "orderID"), version, patch)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	etag := h.orderETag(r, order.Version)
+	order = h.shapeOrder(r, order)
+
+	h.setCacheHeaders(w, etag, order.UpdatedAt)
+	writeOrders(w, r, http.StatusOK, order)
 }
 
+// maskedETagSuffix отличает ETag маскированного представления: оно не совпадает
+// с полным по содержимому, поэтому у одной версии два разных ETag.
+const maskedETagSuffix = "-masked"
+
+// orderETag строит ETag заказа из его версии с учетом того, какое представление получит
+// вызывающий: маскированное или нет и в каком формате.
+func (h *orderHandler) orderETag(r *http.Request, version int64) string {
+	v := strconv.FormatInt(version, 10)
+	if !auth.FromContext(r.Context()).Has(auth.PermPIIRead) {
+		v += maskedETagSuffix
+	}
+	if codec := render.Negotiate(r.Header.Get("Accept")); codec != render.JSON {
+		v += "." + codec.Name()
+	}
+	return `"` + v + `"`
+}
+
+// parseVersionETag достает версию из ETag, выданного orderETag.
+func parseVersionETag(etag string) (int64, bool) {
+	etag = strings.TrimPrefix(etag, "W/")
+	if len(etag) < 2 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		return 0, false
+	}
+	digits := strings.TrimRight(etag[1:len(etag)-1], "-.abcdefghijklmnopqrstuvwxyz")
+	v, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || v < 1 {
+		return 0, false
+	}
+	return v, true
+}
+
+// setCacheHeaders выставляет заголовки HTTP-кэширования заказа.
+func (h *orderHandler) setCacheHeaders(w http.ResponseWriter, etag string, modified time.Time) {
+	w.Header().Set("ETag", etag)
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if h.config.HttpServer.CacheControl != "" {
+		w.Header().Set("Cache-Control", h.config.HttpServer.CacheControl)
+	}
+	// Маскирование зависит от токена, формат — от Accept
+	w.Header().Add("Vary", "Authorization")
+	w.Header().Add("Vary", "Accept")
+}
+
+// notModified проверяет условия If-None-Match и If-Modified-Since (RFC 9110, 13.2.2):
+// при наличии If-None-Match дата не учитывается.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !modified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// GenerateOrders обрабатывает GET /order/generate?count=N — генерацию тестовых заказов.
+// Параметры генератора (seed, items, currencies, ...) см. в parseGeneratorOptions.
 func (h *orderHandler) GenerateOrders(w http.ResponseWriter, r *http.Request) {
 	// Получаем параметр count из query
 	countStr := r.URL.Query().Get("count")
@@ -93,64 +231,397 @@ func (h *orderHandler) GenerateOrders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	opts, err := parseGeneratorOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": %q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+	gen, err := domain.NewGenerator(opts)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": %q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+
 	// Генерируем заказы
-	orders := make([]domain.Order, count)
-	for i := 0; i < count; i++ {
-		orders[i] = domain.GenerateRandomOrder()
+	orders := gen.Generate(count)
+
+	// Сид и точку отсчета дат возвращаем в заголовках, чтобы выборку можно было воспроизвести
+	w.Header().Set("X-Generator-Seed", strconv.FormatInt(gen.Seed(), 10))
+	w.Header().Set("X-Generator-Base-Time", gen.BaseTime().Format(time.RFC3339Nano))
+
+	// Сериализуем и отправляем ответ в формате из Accept
+	writeOrders(w, r, http.StatusOK, orders)
+}
+
+// ListOrders обрабатывает GET /orders — листинг заказов по фильтру (параметры как у выгрузки).
+func (h *orderHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseOrderFilter(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": %q}`, err.Error()), http.StatusBadRequest)
+		return
 	}
 
-	// Устанавливаем заголовок Content-Type
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	orders, err := h.service.ListOrders(r.Context(), filter)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	for i := range orders {
+		orders[i] = *h.shapeOrder(r, &orders[i])
+	}
+	writeOrders(w, r, http.StatusOK, orders)
+}
+
+// writeOrders кодирует заказ или список заказов в формат, выбранный по Accept (по умолчанию JSON).
+func writeOrders(w http.ResponseWriter, r *http.Request, status int, v any) {
+	codec := render.Negotiate(r.Header.Get("Accept"))
 
-	// Сериализуем и отправляем ответ
-	if err := json.NewEncoder(w).Encode(orders); err != nil {
+	// Кодируем в буфер: при ошибке еще можно ответить 500
+	var buf bytes.Buffer
+	if err := codec.Encode(&buf, v); err != nil {
+		log.Printf("Failed to encode response as %s: %v", codec.Name(), err)
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
+
+	w.Header().Set("Content-Type", codec.ContentType())
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
 }
 
-func (h *orderHandler) SendOrderToKafka(w http.ResponseWriter, r *http.Request) {
-	var order domain.Order
+// parseGeneratorOptions разбирает параметры генератора из query:
+// seed, base_time (RFC 3339), items (1:60,2:25), currencies, providers, delivery_services
+// (через запятую), locale, invalid_rate, duplicate_rate.
+func parseGeneratorOptions(q url.Values) (domain.GeneratorOptions, error) {
+	var opts domain.GeneratorOptions
+	var err error
 
-	// Декодируем JSON из тела запроса
-	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
-		http.Error(w, `{"error": "Invalid order JSON"}`, http.StatusBadRequest)
+	if v := q.Get("seed"); v != "" {
+		if opts.Seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return opts, errors.New("invalid seed")
+		}
+	}
+	if v := q.Get("base_time"); v != "" {
+		if opts.BaseTime, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return opts, errors.New("invalid base_time, expected RFC 3339")
+		}
+	}
+	if v := q.Get("items"); v != "" {
+		if opts.ItemWeights, err = domain.ParseItemWeights(v); err != nil {
+			return opts, err
+		}
+	}
+	opts.Currencies = splitList(q.Get("currencies"))
+	opts.Providers = splitList(q.Get("providers"))
+	opts.DeliveryServices = splitList(q.Get("delivery_services"))
+	opts.Locale = q.Get("locale")
+
+	if v := q.Get("invalid_rate"); v != "" {
+		if opts.InvalidRate, err = strconv.ParseFloat(v, 64); err != nil {
+			return opts, errors.New("invalid invalid_rate")
+		}
+	}
+	if v := q.Get("duplicate_rate"); v != "" {
+		if opts.DuplicateRate, err = strconv.ParseFloat(v, 64); err != nil {
+			return opts, errors.New("invalid duplicate_rate")
+		}
+	}
+	return opts, nil
+}
+
+// splitList разбирает список через запятую, пропуская пустые элементы.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// maxOrderBodySize ограничивает тело POST /order.
+const maxOrderBodySize = 16 << 20
+
+// SendOrderToKafka обрабатывает POST /order — публикует заказ в брокер из broker.driver.
+// Текст ответа прежний: на него опирается фронтенд.
+func (h *orderHandler) SendOrderToKafka(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxOrderBodySize))
+	if err != nil {
+		http.Error(w, `{"error": "Failed to read request body"}`, http.StatusBadRequest)
 		return
 	}
 
-	// Сериализация в JSON
-	orderJSON, err := json.Marshal(order)
+	// Заказ проверяется по схеме сообщения до публикации: ошибку с путем поля получает клиент, а не DLQ
+	order, _, err := message.Decode(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": %q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+	orderJSON, err := message.Encode(order, audit.SourceHTTP)
 	if err != nil {
 		http.Error(w, `{"error": "Failed to marshal order"}`, http.StatusInternalServerError)
 		return
 	}
 
-	// Инициализация Kafka producer
-	producer, err := queue.StartKafkaProducer(h.config)
+	// Отправляем сообщение в брокер и ждем подтверждения
+	if err := h.publisher.Publish(r.Context(), []byte(order.OrderUID), orderJSON); err != nil {
+		http.Error(w, `{"error": "Failed to send message to broker"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// Ответ клиенту
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Order sent to Kafka successfully"})
+}
+
+// ExportOrders обрабатывает GET /orders/export?format=csv|ndjson|parquet — потоковую выгрузку заказов.
+func (h *orderHandler) ExportOrders(w http.ResponseWriter, r *http.Request) {
+	format, err := export.ParseFormat(r.URL.Query().Get("format"))
 	if err != nil {
-		http.Error(w, `{"error": "Failed to connect to Kafka"}`, http.StatusInternalServerError)
+		http.Error(w, `{"error": "Invalid format parameter"}`, http.StatusBadRequest)
 		return
 	}
-	defer producer.Close()
 
-	// Отправляем сообщение в Kafka
-	topic := "orders"
-	err = producer.Produce(&kafka.Message{
-		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
-		Value:          orderJSON,
-	}, nil)
+	filter, err := parseOrderFilter(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": %q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
 
+	// Выгрузка может идти дольше WriteTimeout сервера, снимаем дедлайн для этого запроса
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	var ew export.Writer
+	rows := 0
+	err = h.service.ExportOrders(r.Context(), filter, func(order *domain.Order) error {
+		// Заголовки отправляем при первой строке, чтобы ошибки запроса еще можно было вернуть статусом
+		if ew == nil {
+			w.Header().Set("Content-Type", format.ContentType())
+			w.Header().Set("Content-Disposition", `attachment; filename="orders`+format.Extension()+`"`)
+			w.WriteHeader(http.StatusOK)
+			if ew, err = export.NewWriter(format, w); err != nil {
+				return err
+			}
+		}
+		if err := ew.Write(h.shapeOrder(r, order)); err != nil {
+			return err
+		}
+		rows++
+		if rows%1000 == 0 {
+			_ = rc.Flush()
+		}
+		return nil
+	})
+
+	if ew == nil {
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidFilter) {
+				http.Error(w, fmt.Sprintf(`{"error": %q}`, err.Error()), http.StatusBadRequest)
+				return
+			}
+			log.Printf("Failed to export orders: %v", err)
+			http.Error(w, `{"error": "Failed to export orders"}`, http.StatusInternalServerError)
+			return
+		}
+		// Пустая выгрузка — все равно отдаем корректный файл (заголовок CSV, футер parquet)
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", `attachment; filename="orders`+format.Extension()+`"`)
+		w.WriteHeader(http.StatusOK)
+		if ew, err = export.NewWriter(format, w); err != nil {
+			log.Printf("Failed to start export: %v", err)
+			return
+		}
+	}
+
+	// Статус уже отправлен, оборванный поток клиент увидит по неполному файлу
 	if err != nil {
-		http.Error(w, `{"error": "Failed to send message to Kafka"}`, http.StatusInternalServerError)
+		log.Printf("Export interrupted after %d orders: %v", rows, err)
 		return
 	}
+	if err := ew.Close(); err != nil {
+		log.Printf("Failed to finish export: %v", err)
+	}
+}
 
-	// Ждём подтверждения (или таймаута)
-	producer.Flush(1000)
+// parseOrderFilter разбирает фильтр заказов из query-параметров.
+// Даты принимаются в RFC3339 или в виде YYYY-MM-DD.
+func parseOrderFilter(r *http.Request) (domain.OrderFilter, error) {
+	q := r.URL.Query()
+	filter := domain.OrderFilter{
+		CustomerID:      q.Get("customer_id"),
+		TrackNumber:     q.Get("track_number"),
+		DeliveryService: q.Get("delivery_service"),
+		Locale:          q.Get("locale"),
+		Email:           q.Get("email"),
+		Phone:           q.Get("phone"),
+	}
+
+	var err error
+	if filter.DateFrom, err = parseDateParam(q.Get("date_from")); err != nil {
+		return filter, fmt.Errorf("invalid date_from: %w", err)
+	}
+	if filter.DateTo, err = parseDateParam(q.Get("date_to")); err != nil {
+		return filter, fmt.Errorf("invalid date_to: %w", err)
+	}
+
+	if limit := q.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit < 0 {
+			return filter, errors.New("invalid limit")
+		}
+	}
+	return filter, nil
+}
+
+func parseDateParam(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.Parse(time.DateOnly, s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// DeleteOrder обрабатывает DELETE /order/{orderID} — мягкое удаление заказа. Требует orders:delete.
+func (h *orderHandler) DeleteOrder(w http.ResponseWriter, r *http.Request) {
+	actor, ok := requirePermission(w, r, auth.PermOrderDelete)
+	if !ok {
+		return
+	}
+	err := h.service.DeleteOrder(r.Context(), chi.URLParam(r, "orderID"), actor)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EraseOrder обрабатывает POST /order/{orderID}/erase — GDPR-стирание персональных данных заказа. Требует orders:erase.
+func (h *orderHandler) EraseOrder(w http.ResponseWriter, r *http.Request) {
+	actor, ok := requirePermission(w, r, auth.PermOrderErase)
+	if !ok {
+		return
+	}
+	err := h.service.EraseOrder(r.Context(), chi.URLParam(r, "orderID"), actor)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EraseCustomer обрабатывает POST /customers/{customerID}/erase — стирание по всем заказам покупателя. Требует orders:erase.
+func (h *orderHandler) EraseCustomer(w http.ResponseWriter, r *http.Request) {
+	actor, ok := requirePermission(w, r, auth.PermOrderErase)
+	if !ok {
+		return
+	}
+	erased, err := h.service.EraseCustomer(r.Context(), chi.URLParam(r, "customerID"), actor)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
 
-	// Ответ клиенту
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Order sent to Kafka successfully"})
+	json.NewEncoder(w).Encode(map[string][]string{"erased_orders": erased})
+}
+
+// GetOrderAudit обрабатывает GET /order/{orderID}/audit — журнал аудита заказа. Требует audit:read.
+func (h *orderHandler) GetOrderAudit(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requirePermission(w, r, auth.PermAuditRead); !ok {
+		return
+	}
+
+	events, err := h.service.GetOrderAudit(r.Context(), chi.URLParam(r, "orderID"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(events)
+}
+
+// InvalidateCache обрабатывает POST /admin/cache/orders/{orderID}/invalidate — удаление заказа
+// из кэша всех реплик. Требует cache:admin.
+func (h *orderHandler) InvalidateCache(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requirePermission(w, r, auth.PermCacheAdmin); !ok {
+		return
+	}
+	if err := h.service.InvalidateCachedOrder(r.Context(), chi.URLParam(r, "orderID")); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// FlushCache обрабатывает POST /admin/cache/flush — сброс пространства ключей заказов. Требует cache:admin.
+func (h *orderHandler) FlushCache(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requirePermission(w, r, auth.PermCacheAdmin); !ok {
+		return
+	}
+	if err := h.service.FlushOrderCache(r.Context()); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// shapeOrder маскирует персональные данные, если у вызывающего нет разрешения pii:read.
+func (h *orderHandler) shapeOrder(r *http.Request, order *domain.Order) *domain.Order {
+	if auth.FromContext(r.Context()).Has(auth.PermPIIRead) {
+		return order
+	}
+	return h.masker.MaskOrder(order)
+}
+
+// requirePermission проверяет разрешение вызывающего и возвращает его имя для журнала.
+// Анонимный вызывающий получает 401, аутентифицированный без разрешения — 403.
+func requirePermission(w http.ResponseWriter, r *http.Request, permission string) (string, bool) {
+	p := auth.FromContext(r.Context())
+	if p.IsAnonymous() {
+		http.Error(w, `{"error": "authentication required"}`, http.StatusUnauthorized)
+		return "", false
+	}
+	if !p.Has(permission) {
+		http.Error(w, fmt.Sprintf(`{"error": "%s permission required"}`, permission), http.StatusForbidden)
+		return "", false
+	}
+	return p.Actor, true
+}
+
+// writeServiceError переводит ошибку сервиса в HTTP-статус.
+// Ошибки, не относящиеся к бизнес-логике, логируются и наружу не раскрываются.
+func writeServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrOrderUIDNotUnique),
+		errors.Is(err, domain.ErrOrderUIDEmpty),
+		errors.Is(err, domain.ErrInvalidOrder),
+		errors.Is(err, domain.ErrInvalidFilter),
+		errors.Is(err, domain.ErrActorRequired):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, domain.ErrVersionMismatch):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+	case errors.Is(err, domain.ErrPIIErased):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, cache.ErrUnavailable):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, domain.ErrOrderNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, domain.ErrOrderArchived):
+		http.Error(w, err.Error(), http.StatusGone)
+	default:
+		log.Printf("Internal error: %v", err)
+		http.Error(w, domain.ErrInternal.Error(), http.StatusInternalServerError)
+	}
 }
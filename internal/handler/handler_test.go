@@ -0,0 +1,379 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"order_service/internal/auth"
+	"order_service/internal/config"
+	"order_service/internal/domain"
+	"order_service/internal/pii"
+	"order_service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// fakeService отдает заранее заданный заказ и записывает вызовы. Методы, которые тест
+// не задал, паникуют на встроенном nil-интерфейсе.
+type fakeService struct {
+	service.OrderService
+	order *domain.Order
+	err   error
+
+	metaCalls  int
+	orderCalls int
+
+	updateCalls   int
+	updateVersion int64
+	updatePatch   domain.OrderPatch
+}
+
+func (s *fakeService) GetOrderMeta(ctx context.Context, orderUID string) (*domain.OrderMeta, error) {
+	s.metaCalls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &domain.OrderMeta{Version: s.order.Version, UpdatedAt: s.order.UpdatedAt}, nil
+}
+
+func (s *fakeService) GetOrderByID(ctx context.Context, orderUID string) (*domain.Order, error) {
+	s.orderCalls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	order := *s.order
+	return &order, nil
+}
+
+// UpdateOrder проверяет версию как хранилище: 0 — без проверки.
+func (s *fakeService) UpdateOrder(ctx context.Context, orderUID string, expectedVersion int64, patch domain.OrderPatch) (*domain.Order, error) {
+	s.updateCalls++
+	s.updateVersion, s.updatePatch = expectedVersion, patch
+	if s.err != nil {
+		return nil, s.err
+	}
+	if expectedVersion != 0 && expectedVersion != s.order.Version {
+		return nil, domain.ErrVersionMismatch
+	}
+	order := *s.order
+	order.Version++
+	if patch.Delivery != nil && patch.Delivery.City != nil {
+		order.Delivery.City = *patch.Delivery.City
+	}
+	return &order, nil
+}
+
+// testOrderUpdated — время изменения тестового заказа, с долями секунды, как в БД.
+var testOrderUpdated = time.Date(2026, 10, 19, 12, 30, 15, 500_000_000, time.UTC)
+
+func newTestHandler(t *testing.T, svc service.OrderService) http.Handler {
+	t.Helper()
+	masker, err := pii.NewMasker(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{HttpServer: config.HttpServer{CacheControl: "private, no-cache"}}
+	h := NewOrderHandler(svc, cfg, masker, nil)
+
+	r := chi.NewRouter()
+	r.Get("/order/{orderID}", h.GetOrderByID)
+	r.Patch("/order/{orderID}", h.UpdateOrder)
+	return r
+}
+
+// testOrder — заказ версии 3.
+func testOrder() *domain.Order {
+	return &domain.Order{
+		OrderUID:  "b563feb7b2b84b6test",
+		Delivery:  domain.Delivery{Name: "Test Testov", Phone: "+9720000000", Email: "test@gmail.com"},
+		Items:     []domain.Item{{ChrtID: 9934930, Rid: "ab4219087a764ae0btest"}},
+		Version:   3,
+		UpdatedAt: testOrderUpdated,
+	}
+}
+
+// Вызывающие с разным доступом к персональным данным.
+var (
+	piiReader = auth.Principal{Actor: "support", Permissions: []string{auth.PermPIIRead, auth.PermOrderWrite}}
+	writer    = auth.Principal{Actor: "editor", Permissions: []string{auth.PermOrderWrite}}
+)
+
+// do выполняет запрос от имени principal с заголовками headers.
+func do(h http.Handler, method, path string, principal auth.Principal, headers map[string]string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	r = r.WithContext(auth.WithPrincipal(r.Context(), principal))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestParseVersionETag(t *testing.T) {
+	tests := []struct {
+		etag    string
+		version int64
+		ok      bool
+	}{
+		{`"3"`, 3, true},
+		{`W/"3"`, 3, true},
+		{`"12-masked"`, 12, true},
+		{`"3.msgpack"`, 3, true},
+		{`"3-masked.protobuf"`, 3, true},
+		{`W/"3-masked.protobuf"`, 3, true},
+		{`3`, 0, false},
+		{`"3`, 0, false},
+		{`"`, 0, false},
+		{`""`, 0, false},
+		{`"0"`, 0, false},
+		{`"-1"`, 0, false},
+		{`"masked"`, 0, false},
+		{`"3 4"`, 0, false},
+		{`W/`, 0, false},
+		{`"99999999999999999999"`, 0, false},
+	}
+	for _, tt := range tests {
+		version, ok := parseVersionETag(tt.etag)
+		if version != tt.version || ok != tt.ok {
+			t.Errorf("parseVersionETag(%s) = %d, %v; want %d, %v", tt.etag, version, ok, tt.version, tt.ok)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	modified := testOrderUpdated
+	sameSecond := modified.Truncate(time.Second).Format(http.TimeFormat)
+	before := modified.Add(-time.Minute).Format(http.TimeFormat)
+	after := modified.Add(time.Minute).Format(http.TimeFormat)
+
+	tests := []struct {
+		name     string
+		inm      string
+		ims      string
+		modified time.Time
+		want     bool
+	}{
+		{"no conditions", "", "", modified, false},
+		{"matching etag", `"3"`, "", modified, true},
+		{"weak etag", `W/"3"`, "", modified, true},
+		{"any", "*", "", modified, true},
+		{"one of list", `"1", W/"2", "3"`, "", modified, true},
+		{"other version", `"2"`, "", modified, false},
+		{"other representation", `"3-masked"`, "", modified, false},
+		{"etag wins over date", `"2"`, after, modified, false},
+		{"matching etag ignores old date", `"3"`, before, modified, true},
+		{"not modified since", "", after, modified, true},
+		{"same second", "", sameSecond, modified, true},
+		{"modified since", "", before, modified, false},
+		{"bad date", "", "yesterday", modified, false},
+		{"unknown modification time", "", after, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/order/x", nil)
+			if tt.inm != "" {
+				r.Header.Set("If-None-Match", tt.inm)
+			}
+			if tt.ims != "" {
+				r.Header.Set("If-Modified-Since", tt.ims)
+			}
+			if got := notModified(r, `"3"`, tt.modified); got != tt.want {
+				t.Fatalf("notModified = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetOrderConditional(t *testing.T) {
+	lastModified := testOrderUpdated.Format(http.TimeFormat)
+	tests := []struct {
+		name      string
+		principal auth.Principal
+		headers   map[string]string
+		status    int
+		etag      string
+	}{
+		{"no conditions", piiReader, nil, http.StatusOK, `"3"`},
+		{"matching etag", piiReader, map[string]string{"If-None-Match": `"3"`}, http.StatusNotModified, `"3"`},
+		{"weak etag", piiReader, map[string]string{"If-None-Match": `W/"3"`}, http.StatusNotModified, `"3"`},
+		{"any", piiReader, map[string]string{"If-None-Match": "*"}, http.StatusNotModified, `"3"`},
+		{"stale etag", piiReader, map[string]string{"If-None-Match": `"2"`}, http.StatusOK, `"3"`},
+		{"masked etag", writer, map[string]string{"If-None-Match": `"3-masked"`}, http.StatusNotModified, `"3-masked"`},
+		{"full etag for masked caller", writer, map[string]string{"If-None-Match": `"3"`}, http.StatusOK, `"3-masked"`},
+		{"codec etag", piiReader,
+			map[string]string{"Accept": "application/msgpack", "If-None-Match": `"3.msgpack"`}, http.StatusNotModified, `"3.msgpack"`},
+		{"json etag for msgpack", piiReader,
+			map[string]string{"Accept": "application/msgpack", "If-None-Match": `"3"`}, http.StatusOK, `"3.msgpack"`},
+		{"masked codec etag", writer,
+			map[string]string{"Accept": "application/x-protobuf", "If-None-Match": `"3-masked.protobuf"`}, http.StatusNotModified, `"3-masked.protobuf"`},
+		{"not modified since", piiReader, map[string]string{"If-Modified-Since": lastModified}, http.StatusNotModified, `"3"`},
+		{"modified since", piiReader,
+			map[string]string{"If-Modified-Since": testOrderUpdated.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK, `"3"`},
+		{"etag wins over date", piiReader,
+			map[string]string{"If-None-Match": `"2"`, "If-Modified-Since": lastModified}, http.StatusOK, `"3"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeService{order: testOrder()}
+			rec := do(newTestHandler(t, svc), http.MethodGet, "/order/b563feb7b2b84b6test", tt.principal, tt.headers, "")
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			h := rec.Header()
+			if h.Get("ETag") != tt.etag || h.Get("Last-Modified") != lastModified || h.Get("Cache-Control") != "private, no-cache" {
+				t.Fatalf("headers ETag %q, Last-Modified %q, Cache-Control %q; want %s, %s, private, no-cache",
+					h.Get("ETag"), h.Get("Last-Modified"), h.Get("Cache-Control"), tt.etag, lastModified)
+			}
+
+			if tt.status == http.StatusNotModified {
+				// 304 отдается по версии, без чтения и кодирования заказа
+				if rec.Body.Len() != 0 || svc.metaCalls != 1 || svc.orderCalls != 0 {
+					t.Fatalf("304 with %d body bytes after %d meta and %d order reads", rec.Body.Len(), svc.metaCalls, svc.orderCalls)
+				}
+				return
+			}
+			if svc.orderCalls != 1 {
+				t.Fatalf("order read %d times, want 1", svc.orderCalls)
+			}
+		})
+	}
+}
+
+func TestGetOrderMasksWithoutPIIRead(t *testing.T) {
+	svc := &fakeService{order: testOrder()}
+	rec := do(newTestHandler(t, svc), http.MethodGet, "/order/b563feb7b2b84b6test", writer, nil, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var got domain.Order
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Delivery.Phone == svc.order.Delivery.Phone || got.Delivery.Email == svc.order.Delivery.Email {
+		t.Fatalf("delivery returned unmasked: %+v", got.Delivery)
+	}
+}
+
+func TestGetOrderErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"archived", domain.ErrOrderArchived, http.StatusGone},
+		{"deleted or missing", domain.ErrOrderNotFound, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		for _, conditional := range []bool{false, true} {
+			var headers map[string]string
+			if conditional {
+				headers = map[string]string{"If-None-Match": "*"}
+			}
+			svc := &fakeService{err: tt.err}
+			rec := do(newTestHandler(t, svc), http.MethodGet, "/order/b563feb7b2b84b6test", piiReader, headers, "")
+			if rec.Code != tt.status {
+				t.Errorf("%s, conditional %v: status %d, want %d", tt.name, conditional, rec.Code, tt.status)
+			}
+			if rec.Header().Get("ETag") != "" {
+				t.Errorf("%s, conditional %v: ETag %q on error", tt.name, conditional, rec.Header().Get("ETag"))
+			}
+		}
+	}
+}
+
+func TestUpdateOrder(t *testing.T) {
+	const patch = `{"delivery": {"city": "Haifa"}}`
+	tests := []struct {
+		name      string
+		principal auth.Principal
+		ifMatch   string
+		body      string
+		err       error
+		status    int
+		// version — версия, с которой вызван сервис; -1 — сервис не вызывается
+		version int64
+	}{
+		{"anonymous", auth.Anonymous, `"3"`, patch, nil, http.StatusUnauthorized, -1},
+		{"no write permission", auth.Principal{Actor: "reader", Permissions: []string{auth.PermPIIRead}}, `"3"`, patch, nil, http.StatusForbidden, -1},
+		{"missing If-Match", piiReader, "", patch, nil, http.StatusPreconditionRequired, -1},
+		{"blank If-Match", piiReader, "  ", patch, nil, http.StatusPreconditionRequired, -1},
+		{"unquoted etag", piiReader, "3", patch, nil, http.StatusBadRequest, -1},
+		{"etag without version", piiReader, `"masked"`, patch, nil, http.StatusBadRequest, -1},
+		{"zero version", piiReader, `"0"`, patch, nil, http.StatusBadRequest, -1},
+		{"etag list", piiReader, `"3", "4"`, patch, nil, http.StatusBadRequest, -1},
+		{"invalid json", piiReader, `"3"`, `{"delivery":`, nil, http.StatusBadRequest, -1},
+		{"unknown field", piiReader, `"3"`, `{"version": 10}`, nil, http.StatusBadRequest, -1},
+		{"matching version", piiReader, `"3"`, patch, nil, http.StatusOK, 3},
+		{"weak etag", piiReader, `W/"3"`, patch, nil, http.StatusOK, 3},
+		{"masked codec etag", writer, `"3-masked.msgpack"`, patch, nil, http.StatusOK, 3},
+		{"any version", piiReader, "*", patch, nil, http.StatusOK, 0},
+		{"version mismatch", piiReader, `"2"`, patch, nil, http.StatusPreconditionFailed, 2},
+		{"deleted or missing", piiReader, `"3"`, patch, domain.ErrOrderNotFound, http.StatusNotFound, 3},
+		{"invalid patch", piiReader, `"3"`, patch, domain.ErrInvalidOrder, http.StatusBadRequest, 3},
+		{"archived", piiReader, "*", patch, domain.ErrOrderArchived, http.StatusGone, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeService{order: testOrder(), err: tt.err}
+			var headers map[string]string
+			if tt.ifMatch != "" {
+				headers = map[string]string{"If-Match": tt.ifMatch}
+			}
+			rec := do(newTestHandler(t, svc), http.MethodPatch, "/order/b563feb7b2b84b6test", tt.principal, headers, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.version < 0 {
+				if svc.updateCalls != 0 {
+					t.Fatal("service called for rejected request")
+				}
+				return
+			}
+			if svc.updateCalls != 1 || svc.updateVersion != tt.version {
+				t.Fatalf("service called %d times with version %d, want once with %d", svc.updateCalls, svc.updateVersion, tt.version)
+			}
+			if tt.status != http.StatusOK {
+				if rec.Header().Get("ETag") != "" {
+					t.Fatalf("ETag %q on error", rec.Header().Get("ETag"))
+				}
+				return
+			}
+
+			// Ответ — измененный заказ с ETag новой версии для следующего If-Match
+			wantETag := `"4"`
+			if tt.principal.Actor == writer.Actor {
+				wantETag = `"4-masked"`
+			}
+			if got := rec.Header().Get("ETag"); got != wantETag {
+				t.Fatalf("ETag %q, want %s", got, wantETag)
+			}
+			if city := svc.updatePatch.Delivery.City; city == nil || *city != "Haifa" {
+				t.Fatalf("patch passed to service: %+v", svc.updatePatch)
+			}
+		})
+	}
+}
+
+func TestUpdateOrderETagChains(t *testing.T) {
+	// ETag ответа GET подходит для If-Match, а после изменения старый ETag дает 412
+	svc := &fakeService{order: testOrder()}
+	h := newTestHandler(t, svc)
+	etag := do(h, http.MethodGet, "/order/b563feb7b2b84b6test", piiReader, nil, "").Header().Get("ETag")
+
+	rec := do(h, http.MethodPatch, "/order/b563feb7b2b84b6test", piiReader, map[string]string{"If-Match": etag}, `{}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update with GET ETag %s: status %d: %s", etag, rec.Code, rec.Body)
+	}
+	svc.order.Version++
+
+	rec = do(h, http.MethodPatch, "/order/b563feb7b2b84b6test", piiReader, map[string]string{"If-Match": etag}, `{}`)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("update with stale ETag %s: status %d, want 412", etag, rec.Code)
+	}
+}
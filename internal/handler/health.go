@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Pinger проверяет доступность БД (*pgxpool.Pool).
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// CacheState сообщает состояние выключателя кэша (см. cache.BreakerCache).
+type CacheState interface {
+	State() string
+}
+
+// HealthHandler определяет интерфейс для проверок здоровья сервиса.
+type HealthHandler interface {
+	Live(w http.ResponseWriter, r *http.Request)
+	Ready(w http.ResponseWriter, r *http.Request)
+}
+
+// healthHandler — реализация HealthHandler.
+type healthHandler struct {
+	db    Pinger
+	cache CacheState
+}
+
+// NewHealthHandler создает новый экземпляр healthHandler.
+func NewHealthHandler(db Pinger, cache CacheState) HealthHandler {
+	return &healthHandler{db: db, cache: cache}
+}
+
+// healthStatus — ответ проверок здоровья.
+type healthStatus struct {
+	Status string            `json:"status"` // ok, degraded, unavailable
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Live обрабатывает GET /healthz: процесс жив и отвечает.
+func (h *healthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+// Ready обрабатывает GET /readyz. Без БД сервис не готов (503). Недоступный Redis
+// готовности не мешает: заказы читаются из БД, статус — degraded.
+func (h *healthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	status := healthStatus{Status: "ok", Checks: map[string]string{"database": "ok", "cache": h.cache.State()}}
+	code := http.StatusOK
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	if err := h.db.Ping(ctx); err != nil {
+		status.Status, status.Checks["database"] = "unavailable", err.Error()
+		code = http.StatusServiceUnavailable
+	} else if status.Checks["cache"] != "closed" {
+		status.Status = "degraded"
+	}
+
+	writeHealth(w, code, status)
+}
+
+func writeHealth(w http.ResponseWriter, code int, status healthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
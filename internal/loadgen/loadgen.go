@@ -0,0 +1,210 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"order_service/internal/audit"
+	"order_service/internal/domain"
+	"order_service/internal/message"
+	"order_service/internal/queue"
+)
+
+// OrderReader — источник, через который проверяется, что заказ дошел до хранилища.
+// Реализуется repository.OrderRepository: опрос идет мимо сервиса, чтобы не писать
+// в журнал аудита чтение персональных данных на каждый заказ и не считать эту запись в задержку.
+type OrderReader interface {
+	GetByID(ctx context.Context, orderUID string) (*domain.Order, error)
+}
+
+// Config — параметры нагрузки.
+type Config struct {
+	Rate         float64       // целевая скорость, заказов в секунду
+	Duration     time.Duration // длительность отправки, включая разгон
+	RampUp       time.Duration // время линейного разгона от 0 до Rate
+	Timeout      time.Duration // сколько ждать появления заказа после отправки
+	PollInterval time.Duration // интервал опроса OrderReader
+	Concurrency  int           // максимум заказов в полете одновременно
+}
+
+// Report — итог прогона.
+type Report struct {
+	Sent       int           `json:"sent"`
+	PublishErr int           `json:"publish_errors"`
+	Completed  int           `json:"completed"`
+	TimedOut   int           `json:"timed_out"`
+	Elapsed    time.Duration `json:"elapsed"`
+	Throughput float64       `json:"throughput"` // прочитанных заказов в секунду
+	P50        time.Duration `json:"p50"`
+	P95        time.Duration `json:"p95"`
+	P99        time.Duration `json:"p99"`
+	Max        time.Duration `json:"max"`
+}
+
+// Runner публикует сгенерированные заказы с заданной скоростью и замеряет
+// сквозную задержку: от отправки в брокер до чтения через OrderReader.
+type Runner struct {
+	producer queue.Publisher
+	reader   OrderReader
+	gen      *domain.Generator
+	cfg      Config
+}
+
+// NewRunner проверяет параметры и создает Runner.
+func NewRunner(producer queue.Publisher, reader OrderReader, gen *domain.Generator, cfg Config) (*Runner, error) {
+	if cfg.Rate <= 0 {
+		return nil, errors.New("rate must be positive")
+	}
+	if cfg.Duration <= 0 {
+		return nil, errors.New("duration must be positive")
+	}
+	if cfg.RampUp < 0 || cfg.RampUp > cfg.Duration {
+		return nil, errors.New("ramp-up must be between 0 and duration")
+	}
+	// Дубликат читается сразу по UID первого заказа, а невалидный не дойдет до хранилища
+	// и провисит до таймаута: и то и другое искажает перцентили
+	if opts := gen.Options(); opts.InvalidRate > 0 || opts.DuplicateRate > 0 {
+		return nil, errors.New("invalid and duplicate rates are not supported by load test")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 50 * time.Millisecond
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1000
+	}
+	return &Runner{producer: producer, reader: reader, gen: gen, cfg: cfg}, nil
+}
+
+// Run выполняет прогон и ждет, пока все отправленные заказы будут прочитаны или истечет их таймаут.
+func (r *Runner) Run(ctx context.Context) (*Report, error) {
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		latencies []time.Duration
+		report    Report
+	)
+	sem := make(chan struct{}, r.cfg.Concurrency)
+
+	start := time.Now()
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+		}
+
+		elapsed := time.Since(start)
+		if elapsed >= r.cfg.Duration {
+			break loop
+		}
+
+		// Отправляем столько, сколько отстаем от целевой кривой
+		for report.Sent < r.expectedSent(elapsed) {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				break loop
+			}
+
+			order := r.gen.Next()
+			report.Sent++
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				latency, err := r.track(ctx, &order)
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case errors.Is(err, errPublish):
+					report.PublishErr++
+				case err != nil:
+					report.TimedOut++
+				default:
+					report.Completed++
+					latencies = append(latencies, latency)
+				}
+			}()
+		}
+	}
+
+	wg.Wait()
+	report.Elapsed = time.Since(start)
+	if report.Elapsed > 0 {
+		report.Throughput = float64(report.Completed) / report.Elapsed.Seconds()
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	report.P50 = percentile(latencies, 0.50)
+	report.P95 = percentile(latencies, 0.95)
+	report.P99 = percentile(latencies, 0.99)
+	if len(latencies) > 0 {
+		report.Max = latencies[len(latencies)-1]
+	}
+	return &report, ctx.Err()
+}
+
+var errPublish = errors.New("publish failed")
+
+// track публикует заказ и опрашивает OrderReader, пока заказ не станет доступен.
+func (r *Runner) track(ctx context.Context, order *domain.Order) (time.Duration, error) {
+	value, err := message.Encode(order, audit.SourceLoadgen)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errPublish, err)
+	}
+
+	sentAt := time.Now()
+	if err := r.producer.Publish(ctx, []byte(order.OrderUID), value); err != nil {
+		return 0, fmt.Errorf("%w: %v", errPublish, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+	for {
+		if _, err := r.reader.GetByID(ctx, order.OrderUID); err == nil {
+			return time.Since(sentAt), nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(r.cfg.PollInterval):
+		}
+	}
+}
+
+// expectedSent — сколько заказов должно быть отправлено к моменту t
+// при линейном разгоне до Rate за RampUp и постоянной скорости после.
+func (r *Runner) expectedSent(t time.Duration) int {
+	rate, ramp, sec := r.cfg.Rate, r.cfg.RampUp.Seconds(), t.Seconds()
+	if sec < ramp {
+		return int(rate * sec * sec / (2 * ramp))
+	}
+	return int(rate*ramp/2 + rate*(sec-ramp))
+}
+
+// percentile берет перцентиль из отсортированной выборки.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p*float64(len(sorted))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
@@ -0,0 +1,179 @@
+package loadgen
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"order_service/internal/audit"
+	"order_service/internal/domain"
+	"order_service/internal/message"
+	"order_service/internal/queue"
+)
+
+func TestExpectedSent(t *testing.T) {
+	tests := []struct {
+		name   string
+		ramp   time.Duration
+		at     time.Duration
+		expect int
+	}{
+		{"start", 10 * time.Second, 0, 0},
+		{"early ramp", 10 * time.Second, time.Second, 5},
+		{"mid ramp", 10 * time.Second, 5 * time.Second, 125},
+		{"end of ramp", 10 * time.Second, 10 * time.Second, 500},
+		{"after ramp", 10 * time.Second, 12 * time.Second, 700},
+		{"no ramp", 0, 3 * time.Second, 300},
+		{"no ramp fraction", 0, 1500 * time.Millisecond, 150},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Runner{cfg: Config{Rate: 100, RampUp: tt.ramp}}
+			if got := r.expectedSent(tt.at); got != tt.expect {
+				t.Fatalf("expectedSent(%s) = %d, want %d", tt.at, got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestExpectedSentMonotonic(t *testing.T) {
+	r := &Runner{cfg: Config{Rate: 37, RampUp: 3 * time.Second}}
+	prev := 0
+	for at := time.Duration(0); at <= 10*time.Second; at += 7 * time.Millisecond {
+		got := r.expectedSent(at)
+		if got < prev {
+			t.Fatalf("expectedSent(%s) = %d, less than %d before", at, got, prev)
+		}
+		prev = got
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	hundred := make([]time.Duration, 100)
+	for i := range hundred {
+		hundred[i] = time.Duration(i+1) * time.Millisecond
+	}
+	tests := []struct {
+		name   string
+		sorted []time.Duration
+		p      float64
+		expect time.Duration
+	}{
+		{"empty", nil, 0.5, 0},
+		{"single p50", []time.Duration{7}, 0.5, 7},
+		{"single p99", []time.Duration{7}, 0.99, 7},
+		{"p0", hundred, 0, time.Millisecond},
+		{"p50", hundred, 0.50, 50 * time.Millisecond},
+		{"p95", hundred, 0.95, 95 * time.Millisecond},
+		{"p99", hundred, 0.99, 99 * time.Millisecond},
+		{"p100", hundred, 1, 100 * time.Millisecond},
+		{"p50 of two", []time.Duration{1, 2}, 0.5, 1},
+		{"p99 of ten", []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 0.99, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.sorted, tt.p); got != tt.expect {
+				t.Fatalf("percentile(%v) = %s, want %s", tt.p, got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestNewRunnerRejectsInvalidAndDuplicateRates(t *testing.T) {
+	tests := []struct {
+		name string
+		opts domain.GeneratorOptions
+	}{
+		{"invalid", domain.GeneratorOptions{Seed: 1, InvalidRate: 0.1}},
+		{"duplicate", domain.GeneratorOptions{Seed: 1, DuplicateRate: 0.1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen, err := domain.NewGenerator(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := NewRunner(newStore(), newStore(), gen, Config{Rate: 1, Duration: time.Second}); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestRunSendsAtRateAndTracksOrders(t *testing.T) {
+	gen, err := domain.NewGenerator(domain.GeneratorOptions{Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := newStore()
+	cfg := Config{Rate: 200, Duration: 300 * time.Millisecond, RampUp: 100 * time.Millisecond, PollInterval: time.Millisecond}
+	runner, err := NewRunner(store, store, gen, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := runner.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Отправка останавливается на последнем тике до Duration, поэтому заказов не больше,
+	// чем по кривой на момент Duration
+	if want := runner.expectedSent(cfg.Duration); report.Sent > want || report.Sent < want/2 {
+		t.Fatalf("sent = %d, want close to %d", report.Sent, want)
+	}
+	if report.Completed != report.Sent || report.TimedOut != 0 || report.PublishErr != 0 {
+		t.Fatalf("report = %+v, want all sent orders completed", report)
+	}
+	if report.P50 > report.P95 || report.P95 > report.P99 || report.P99 > report.Max {
+		t.Fatalf("percentiles out of order: %+v", report)
+	}
+	if got := store.sources(); len(got) != 1 || got[audit.SourceLoadgen] != report.Sent {
+		t.Fatalf("sources = %v, want all from %s", got, audit.SourceLoadgen)
+	}
+}
+
+// store — брокер и хранилище сразу: опубликованный заказ сразу доступен для чтения.
+type store struct {
+	mu       sync.Mutex
+	orders   map[string]*domain.Order
+	bySource map[string]int
+}
+
+func newStore() *store {
+	return &store{orders: make(map[string]*domain.Order), bySource: make(map[string]int)}
+}
+
+func (s *store) Publish(_ context.Context, _, value []byte) error {
+	order, env, err := message.Decode(value)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orders[order.OrderUID] = order
+	s.bySource[env.Source]++
+	return nil
+}
+
+func (s *store) PublishTo(context.Context, string, []byte, []byte, ...queue.Header) error {
+	return nil
+}
+
+func (s *store) Close() {}
+
+// sources — сколько заказов пришло от каждого источника.
+func (s *store) sources() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bySource
+}
+
+func (s *store) GetByID(_ context.Context, orderUID string) (*domain.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if order, ok := s.orders[orderUID]; ok {
+		return order, nil
+	}
+	return nil, domain.ErrOrderNotFound
+}
@@ -0,0 +1,128 @@
+// Package message — конверт сообщений о заказах в брокере: версия схемы, ID сообщения, время
+// и источник публикации, заказ. Конверт и заказ проверяются по JSON Schema из schemas, встроенным
+// в бинарник; сообщения прежних версий поднимаются до текущей.
+package message
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"order_service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// CurrentVersion — версия схемы, в которой публикуются сообщения.
+// Версия 1 — заказ без конверта, как сообщения публиковались раньше.
+const CurrentVersion = 2
+
+// Envelope — конверт сообщения.
+type Envelope struct {
+	SchemaVersion int             `json:"schema_version"`
+	MessageID     string          `json:"message_id"`
+	ProducedAt    time.Time       `json:"produced_at"`
+	Source        string          `json:"source"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// upcasters поднимают заказ версии i до версии i+1 на месте.
+var upcasters = map[int]func(order map[string]any){
+	1: dropStorageFields,
+}
+
+// dropStorageFields убирает из заказа версию и время изменения: их задает хранилище,
+// значения из сообщения никогда не использовались.
+func dropStorageFields(order map[string]any) {
+	delete(order, "version")
+	delete(order, "updated_at")
+}
+
+// Encode заворачивает заказ в конверт текущей версии. source — кто публикует: http, cli, loadgen.
+func Encode(order *domain.Order, source string) ([]byte, error) {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return nil, err
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	dropStorageFields(payload)
+	if data, err = json.Marshal(payload); err != nil {
+		return nil, err
+	}
+	return json.Marshal(Envelope{
+		SchemaVersion: CurrentVersion,
+		MessageID:     uuid.NewString(),
+		ProducedAt:    time.Now().UTC(),
+		Source:        source,
+		Payload:       data,
+	})
+}
+
+// Decode проверяет сообщение по схемам, поднимает заказ до текущей версии и возвращает его вместе
+// с конвертом. Сообщение без конверта считается заказом версии 1, конверт для него собирается
+// без ID и источника. Ошибка проверки — *SchemaError с путями полей.
+func Decode(data []byte) (*domain.Order, *Envelope, error) {
+	var message any
+	if err := decodeJSON(data, &message); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", domain.ErrInvalidMessage, err)
+	}
+	fields, ok := message.(map[string]any)
+	if !ok {
+		return nil, nil, &SchemaError{Schema: "envelope", Violations: []Violation{{Message: "message must be a JSON object"}}}
+	}
+
+	env := &Envelope{SchemaVersion: 1, Payload: data}
+	payload, prefix := fields, ""
+	if _, ok := fields["schema_version"]; ok {
+		if err := validate(envelopeSchema, "envelope", "", message); err != nil {
+			return nil, nil, err
+		}
+		if err := json.Unmarshal(data, env); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", domain.ErrInvalidMessage, err)
+		}
+		if env.SchemaVersion > CurrentVersion {
+			return nil, nil, &SchemaError{Schema: "envelope", Violations: []Violation{{
+				Path:    "/schema_version",
+				Message: fmt.Sprintf("unsupported schema version %d, supported 1..%d", env.SchemaVersion, CurrentVersion),
+			}}}
+		}
+		payload, prefix = fields["payload"].(map[string]any), "/payload"
+	}
+
+	name := fmt.Sprintf("order.v%d", env.SchemaVersion)
+	if err := validate(orderSchemas[env.SchemaVersion], name, prefix, payload); err != nil {
+		return nil, nil, err
+	}
+	for version := env.SchemaVersion; version < CurrentVersion; version++ {
+		upcasters[version](payload)
+	}
+
+	upcasted, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	var order domain.Order
+	if err := decodeJSON(upcasted, &order); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", domain.ErrInvalidMessage, err)
+	}
+	return &order, env, nil
+}
+
+// decodeJSON разбирает ровно одно JSON-значение. Числа остаются json.Number, чтобы схема
+// отличала целые от дробных, а поля вне структуры — ошибка.
+func decodeJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("unexpected data after JSON value")
+	}
+	return nil
+}
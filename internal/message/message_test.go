@@ -0,0 +1,346 @@
+package message
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"order_service/internal/domain"
+)
+
+// testOrders возвращает заказы генератора с версией и временем изменения, как из хранилища.
+func testOrders(t *testing.T, n int) []domain.Order {
+	t.Helper()
+	gen, err := domain.NewGenerator(domain.GeneratorOptions{Seed: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders := gen.Generate(n)
+	for i := range orders {
+		orders[i].Version = int64(i + 2)
+		orders[i].UpdatedAt = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	}
+	return orders
+}
+
+// withoutStorageFields — заказ, каким его должен вернуть Decode: без версии и времени изменения.
+func withoutStorageFields(order domain.Order) domain.Order {
+	order.Version = 0
+	order.UpdatedAt = time.Time{}
+	return order
+}
+
+// expectSameOrder сравнивает заказы по JSON: время после разбора может отличаться зоной.
+func expectSameOrder(t *testing.T, got *domain.Order, want domain.Order) {
+	t.Helper()
+	gotJSON, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJSON, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(gotJSON) != string(wantJSON) {
+		t.Fatalf("decoded order\n%s\nwant\n%s", gotJSON, wantJSON)
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	for _, order := range testOrders(t, 10) {
+		start := time.Now()
+		data, err := Encode(&order, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, env, err := Decode(data)
+		if err != nil {
+			t.Fatalf("decode %s: %v", order.OrderUID, err)
+		}
+		expectSameOrder(t, got, withoutStorageFields(order))
+		if env.SchemaVersion != CurrentVersion || env.Source != "test" || env.MessageID == "" {
+			t.Fatalf("envelope %+v", env)
+		}
+		if env.ProducedAt.Before(start.Add(-time.Second)) || env.ProducedAt.After(time.Now().Add(time.Second)) {
+			t.Fatalf("produced_at %s, want about %s", env.ProducedAt, start)
+		}
+	}
+}
+
+func TestEncodeUsesUniqueMessageIDs(t *testing.T) {
+	order := testOrders(t, 1)[0]
+	seen := map[string]bool{}
+	for i := 0; i < 10; i++ {
+		data, err := Encode(&order, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, env, err := Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[env.MessageID] {
+			t.Fatalf("message id %s repeated", env.MessageID)
+		}
+		seen[env.MessageID] = true
+	}
+}
+
+func TestDecodeUpcastsVersion1(t *testing.T) {
+	order := testOrders(t, 1)[0]
+	bare, err := json.Marshal(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := json.Marshal(map[string]any{
+		"schema_version": 1,
+		"message_id":     "m-1",
+		"produced_at":    "2026-10-19T12:00:00Z",
+		"source":         "legacy",
+		"payload":        json.RawMessage(bare),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want Envelope
+	}{
+		// Сообщение без конверта — заказ в том виде, как его публиковали до версии 2
+		{"bare order", bare, Envelope{SchemaVersion: 1}},
+		{"version 1 envelope", wrapped, Envelope{SchemaVersion: 1, MessageID: "m-1", Source: "legacy"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, env, err := Decode(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// version и updated_at из сообщения отбрасываются: их задает хранилище
+			expectSameOrder(t, got, withoutStorageFields(order))
+			if env.SchemaVersion != tt.want.SchemaVersion || env.MessageID != tt.want.MessageID || env.Source != tt.want.Source {
+				t.Fatalf("envelope %+v, want %+v", env, tt.want)
+			}
+		})
+	}
+}
+
+// validMessage возвращает сообщение текущей версии в виде дерева JSON для порчи в тестах.
+func validMessage(t *testing.T) map[string]any {
+	t.Helper()
+	order := testOrders(t, 1)[0]
+	if len(order.Items) < 2 {
+		order.Items = append(order.Items, order.Items[0])
+	}
+	data, err := Encode(&order, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var message map[string]any
+	if err := json.Unmarshal(data, &message); err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+func TestDecodeReportsViolationPaths(t *testing.T) {
+	payload := func(m map[string]any) map[string]any { return m["payload"].(map[string]any) }
+	object := func(m map[string]any, key string) map[string]any { return payload(m)[key].(map[string]any) }
+	item := func(m map[string]any, i int) map[string]any { return payload(m)["items"].([]any)[i].(map[string]any) }
+
+	tests := []struct {
+		name   string
+		spoil  func(m map[string]any)
+		schema string
+		want   []Violation
+	}{
+		{
+			name:   "missing order field",
+			spoil:  func(m map[string]any) { delete(payload(m), "order_uid") },
+			schema: "order.v2",
+			want:   []Violation{{"/payload/order_uid", "required property is missing"}},
+		},
+		{
+			name:   "missing nested fields",
+			spoil:  func(m map[string]any) { delete(object(m, "delivery"), "zip"); delete(object(m, "delivery"), "city") },
+			schema: "order.v2",
+			want: []Violation{
+				{"/payload/delivery/city", "required property is missing"},
+				{"/payload/delivery/zip", "required property is missing"},
+			},
+		},
+		{
+			name:   "unknown field",
+			spoil:  func(m map[string]any) { object(m, "payment")["discount"] = 1 },
+			schema: "order.v2",
+			want:   []Violation{{"/payload/payment/discount", "property is not allowed"}},
+		},
+		{
+			name:   "unknown field with slash",
+			spoil:  func(m map[string]any) { payload(m)["a/b~c"] = 1 },
+			schema: "order.v2",
+			want:   []Violation{{"/payload/a~1b~0c", "property is not allowed"}},
+		},
+		{
+			name:   "storage field in version 2",
+			spoil:  func(m map[string]any) { payload(m)["version"] = 5 },
+			schema: "order.v2",
+			want:   []Violation{{"/payload/version", "property is not allowed"}},
+		},
+		{
+			name:   "negative amount in second item",
+			spoil:  func(m map[string]any) { item(m, 1)["price"] = -1 },
+			schema: "order.v2",
+			want:   []Violation{{Path: "/payload/items/1/price"}},
+		},
+		{
+			name:   "fractional integer",
+			spoil:  func(m map[string]any) { payload(m)["sm_id"] = 1.5 },
+			schema: "order.v2",
+			want:   []Violation{{Path: "/payload/sm_id"}},
+		},
+		{
+			name:   "bad email",
+			spoil:  func(m map[string]any) { object(m, "delivery")["email"] = "not an email" },
+			schema: "order.v2",
+			want:   []Violation{{Path: "/payload/delivery/email"}},
+		},
+		{
+			name:   "no items",
+			spoil:  func(m map[string]any) { payload(m)["items"] = []any{} },
+			schema: "order.v2",
+			want:   []Violation{{Path: "/payload/items"}},
+		},
+		{
+			name: "several violations sorted by path",
+			spoil: func(m map[string]any) {
+				payload(m)["sm_id"] = "1"
+				object(m, "payment")["amount"] = -5
+			},
+			schema: "order.v2",
+			want:   []Violation{{Path: "/payload/payment/amount"}, {Path: "/payload/sm_id"}},
+		},
+		{
+			name:   "envelope without source",
+			spoil:  func(m map[string]any) { delete(m, "source") },
+			schema: "envelope",
+			want:   []Violation{{"/source", "required property is missing"}},
+		},
+		{
+			name:   "unknown envelope field",
+			spoil:  func(m map[string]any) { m["trace_id"] = "x" },
+			schema: "envelope",
+			want:   []Violation{{"/trace_id", "property is not allowed"}},
+		},
+		{
+			name:   "bad produced_at",
+			spoil:  func(m map[string]any) { m["produced_at"] = "yesterday" },
+			schema: "envelope",
+			want:   []Violation{{Path: "/produced_at"}},
+		},
+		{
+			name:   "zero version",
+			spoil:  func(m map[string]any) { m["schema_version"] = 0 },
+			schema: "envelope",
+			want:   []Violation{{Path: "/schema_version"}},
+		},
+		{
+			name:   "unknown version",
+			spoil:  func(m map[string]any) { m["schema_version"] = CurrentVersion + 1 },
+			schema: "envelope",
+			want:   []Violation{{"/schema_version", "unsupported schema version 3, supported 1..2"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validMessage(t)
+			tt.spoil(message)
+			data, err := json.Marshal(message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectViolations(t, data, tt.schema, tt.want)
+		})
+	}
+}
+
+func TestDecodeVersion1ViolationPaths(t *testing.T) {
+	// У заказа без конверта пути идут от корня сообщения
+	message := validMessage(t)["payload"].(map[string]any)
+	message["items"].([]any)[0].(map[string]any)["sale"] = -1
+	delete(message, "track_number")
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectViolations(t, data, "order.v1", []Violation{
+		{Path: "/items/0/sale"},
+		{"/track_number", "required property is missing"},
+	})
+}
+
+// expectViolations проверяет, что Decode отклоняет data по схеме schema с нарушениями want.
+// Пустое сообщение в want — текст библиотеки, он не проверяется.
+func expectViolations(t *testing.T, data []byte, schema string, want []Violation) {
+	t.Helper()
+	_, _, err := Decode(data)
+	if !errors.Is(err, domain.ErrInvalidMessage) {
+		t.Fatalf("want %v, got %v", domain.ErrInvalidMessage, err)
+	}
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("want *SchemaError, got %T: %v", err, err)
+	}
+	if schemaErr.Schema != schema {
+		t.Fatalf("schema %s, want %s: %v", schemaErr.Schema, schema, err)
+	}
+	got := make([]Violation, len(schemaErr.Violations))
+	for i, v := range schemaErr.Violations {
+		got[i] = v
+		if want[min(i, len(want)-1)].Message == "" {
+			got[i].Message = ""
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("violations %+v, want %+v", schemaErr.Violations, want)
+	}
+}
+
+func TestDecodeRejectsMalformedJSON(t *testing.T) {
+	valid, err := json.Marshal(validMessage(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"truncated", string(valid[:len(valid)/2])},
+		{"trailing data", string(valid) + "{}"},
+		{"not an object", "[1, 2]"},
+		{"string", `"order"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Decode([]byte(tt.data)); !errors.Is(err, domain.ErrInvalidMessage) {
+				t.Fatalf("want %v, got %v", domain.ErrInvalidMessage, err)
+			}
+		})
+	}
+}
+
+func TestSchemaErrorMessage(t *testing.T) {
+	err := &SchemaError{Schema: "order.v2", Violations: []Violation{
+		{"", "message must be a JSON object"},
+		{"/payload/order_uid", "required property is missing"},
+	}}
+	want := "message does not match order.v2: /: message must be a JSON object; /payload/order_uid: required property is missing"
+	if err.Error() != want {
+		t.Fatalf("Error() = %q, want %q", err.Error(), want)
+	}
+}
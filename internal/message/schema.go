@@ -0,0 +1,143 @@
+package message
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"order_service/internal/domain"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+//go:embed schemas/*.json
+var schemaFS embed.FS
+
+// schemaBaseURL — общий $id схем: по нему разрешаются ссылки между файлами.
+const schemaBaseURL = "https://order-service.local/schemas/"
+
+var (
+	envelopeSchema *jsonschema.Schema
+	// orderSchemas — схема заказа по версии конверта, индекс 0 не используется
+	orderSchemas []*jsonschema.Schema
+)
+
+func init() {
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.AssertFormat = true
+	entries, err := schemaFS.ReadDir("schemas")
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		data, err := schemaFS.ReadFile("schemas/" + entry.Name())
+		if err != nil {
+			panic(err)
+		}
+		if err := compiler.AddResource(schemaBaseURL+entry.Name(), bytes.NewReader(data)); err != nil {
+			panic(fmt.Sprintf("schema %s: %v", entry.Name(), err))
+		}
+	}
+
+	envelopeSchema = compiler.MustCompile(schemaBaseURL + "envelope.json")
+	orderSchemas = make([]*jsonschema.Schema, CurrentVersion+1)
+	for version := 1; version <= CurrentVersion; version++ {
+		orderSchemas[version] = compiler.MustCompile(fmt.Sprintf("%sorder.v%d.json", schemaBaseURL, version))
+	}
+}
+
+// Violation — нарушение схемы. Path — JSON Pointer поля в сообщении, пустой для всего сообщения.
+type Violation struct {
+	Path    string
+	Message string
+}
+
+// SchemaError — сообщение не соответствует схеме Schema. Сопоставляется с domain.ErrInvalidMessage.
+type SchemaError struct {
+	Schema     string
+	Violations []Violation
+}
+
+func (e *SchemaError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		path := v.Path
+		if path == "" {
+			path = "/"
+		}
+		parts[i] = path + ": " + v.Message
+	}
+	return fmt.Sprintf("message does not match %s: %s", e.Schema, strings.Join(parts, "; "))
+}
+
+func (e *SchemaError) Unwrap() error {
+	return domain.ErrInvalidMessage
+}
+
+// quotedName — имя свойства в сообщениях required и additionalProperties.
+var quotedName = regexp.MustCompile(`'([^']*)'`)
+
+// pointerEscaper экранирует имя свойства для JSON Pointer.
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// leafViolations переводит лист дерева ошибок в нарушения. Отсутствующее и лишнее свойства
+// библиотека относит к объекту, здесь путь указывает на само свойство.
+func leafViolations(prefix string, e *jsonschema.ValidationError) []Violation {
+	location := prefix + e.InstanceLocation
+	var message string
+	switch {
+	case strings.HasSuffix(e.KeywordLocation, "/required"):
+		message = "required property is missing"
+	case strings.HasSuffix(e.KeywordLocation, "/additionalProperties"):
+		message = "property is not allowed"
+	default:
+		return []Violation{{Path: location, Message: e.Message}}
+	}
+	var violations []Violation
+	for _, match := range quotedName.FindAllStringSubmatch(e.Message, -1) {
+		violations = append(violations, Violation{Path: location + "/" + pointerEscaper.Replace(match[1]), Message: message})
+	}
+	if len(violations) == 0 {
+		return []Violation{{Path: location, Message: e.Message}}
+	}
+	return violations
+}
+
+// validate проверяет значение по схеме. prefix — путь значения внутри сообщения.
+func validate(schema *jsonschema.Schema, name, prefix string, value any) error {
+	err := schema.Validate(value)
+	if err == nil {
+		return nil
+	}
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+
+	// Нарушения — листья дерева ошибок: промежуточные узлы повторяют их общими словами
+	seen := make(map[Violation]bool)
+	var violations []Violation
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) > 0 {
+			for _, cause := range e.Causes {
+				walk(cause)
+			}
+			return
+		}
+		for _, v := range leafViolations(prefix, e) {
+			if !seen[v] {
+				seen[v] = true
+				violations = append(violations, v)
+			}
+		}
+	}
+	walk(validationErr)
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Path < violations[j].Path })
+	return &SchemaError{Schema: name, Violations: violations}
+}
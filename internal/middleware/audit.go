@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"order_service/internal/audit"
+	"order_service/internal/auth"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader — заголовок с идентификатором запроса. Если клиент его не прислал, он генерируется.
+const RequestIDHeader = "X-Request-ID"
+
+// AuditContext кладет в контекст сведения для журнала аудита: инициатора и идентификатор запроса.
+// Подключается после auth.Middleware.
+func AuditContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := strings.TrimSpace(r.Header.Get(RequestIDHeader))
+		if requestID == "" || utf8.RuneCountInString(requestID) > audit.MaxRequestIDLen {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		// Инициатор — только аутентифицированный вызывающий: заголовкам клиента верить нельзя
+		actor := auth.FromContext(r.Context()).Actor
+		ctx := audit.WithMeta(r.Context(), audit.Meta{Actor: actor, Source: audit.SourceHTTP, RequestID: requestID})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
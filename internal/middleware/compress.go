@@ -0,0 +1,209 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressibleTypes — типы ответов, которые имеет смысл сжимать. Parquet и статика
+// в бинарных форматах уже сжаты.
+var compressibleTypes = map[string]bool{
+	"application/json":       true,
+	"application/x-ndjson":   true,
+	"application/msgpack":    true,
+	"application/x-protobuf": true,
+	"application/javascript": true,
+	"image/svg+xml":          true,
+}
+
+var gzipPool = sync.Pool{New: func() any {
+	w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+	return w
+}}
+
+var zstdPool = sync.Pool{New: func() any {
+	w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedDefault))
+	return w
+}}
+
+// Compress сжимает ответы gzip или zstd по заголовку Accept-Encoding. Ответ копится в буфере,
+// пока не наберет minSize байт: короткие ответы отдаются как есть. Flush (потоковая выгрузка)
+// принимает решение сразу, не дожидаясь порога.
+func Compress(minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: minSize, status: http.StatusOK}
+			defer cw.Close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding выбирает zstd или gzip с учетом q-значений; при равенстве предпочитается zstd.
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if name == "*" {
+			name = "gzip"
+		}
+		if name != "gzip" && name != "zstd" || q <= 0 {
+			continue
+		}
+		if q > bestQ || q == bestQ && name == "zstd" {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	status   int
+	buf      []byte
+	enc      io.WriteCloser // nil, если ответ не сжимается
+	decided  bool
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided {
+		return
+	}
+	cw.status = code
+	// У этих ответов нет тела, сжимать нечего
+	if code == http.StatusNoContent || code == http.StatusNotModified || code < 200 {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, b...)
+		if len(cw.buf) < cw.minSize {
+			return len(b), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// decide отправляет заголовки и накопленный буфер, сжимая их, если это возможно.
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+	h := cw.Header()
+	h.Add("Vary", "Accept-Encoding")
+
+	if compress && cw.compressible() {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		// Сжатое представление побайтно отличается от исходного: ETag становится слабым, как у nginx
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		switch cw.encoding {
+		case "zstd":
+			enc := zstdPool.Get().(*zstd.Encoder)
+			enc.Reset(cw.ResponseWriter)
+			cw.enc = enc
+		default:
+			enc := gzipPool.Get().(*gzip.Writer)
+			enc.Reset(cw.ResponseWriter)
+			cw.enc = enc
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(cw.buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf)
+	}
+	cw.buf = nil
+	return err
+}
+
+func (cw *compressWriter) compressible() bool {
+	h := cw.Header()
+	// Уже закодированные ответы и диапазоны (Range) не трогаем
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" || cw.status == http.StatusPartialContent {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return compressibleTypes[mediaType] || strings.HasPrefix(mediaType, "text/")
+}
+
+// Flush отправляет уже накопленные данные: для потоковых ответов порог не ждем.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide(true)
+	}
+	switch enc := cw.enc.(type) {
+	case *gzip.Writer:
+		enc.Flush()
+	case *zstd.Encoder:
+		enc.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Close дописывает хвост сжатого потока и возвращает кодировщик в пул.
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		// Порог не набран — отдаем без сжатия
+		cw.decide(false)
+	}
+	if cw.enc == nil {
+		return nil
+	}
+	err := cw.enc.Close()
+	switch enc := cw.enc.(type) {
+	case *gzip.Writer:
+		gzipPool.Put(enc)
+	case *zstd.Encoder:
+		zstdPool.Put(enc)
+	}
+	cw.enc = nil
+	return err
+}
+
+// Unwrap дает http.ResponseController доступ к исходному ResponseWriter.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
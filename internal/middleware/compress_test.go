@@ -0,0 +1,232 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"zstd", "zstd"},
+		{"GZip", "gzip"},
+		{"gzip, deflate, br", "gzip"},
+		{"gzip, zstd", "zstd"},
+		{"zstd;q=0.5, gzip", "gzip"},
+		{"gzip;q=0.8, zstd;q=0.9", "zstd"},
+		{"gzip;q=0.5, zstd;q=0.5", "zstd"},
+		{"zstd;q=0, gzip;q=0.1", "gzip"},
+		{"gzip;q=0", ""},
+		{"gzip;q=abc", ""},
+		{"*", "gzip"},
+		{"*;q=0.5, zstd", "zstd"},
+		{"br, identity", ""},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.header); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+const testMinSize = 64
+
+// serve пропускает запрос через Compress(testMinSize) с handler и возвращает ответ
+// с распакованным телом.
+func serve(t *testing.T, method, acceptEncoding string, handler http.HandlerFunc) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+	r := httptest.NewRequest(method, "/orders", nil)
+	if acceptEncoding != "" {
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	Compress(testMinSize)(handler).ServeHTTP(rec, r)
+
+	var body io.Reader = rec.Body
+	switch rec.Header().Get("Content-Encoding") {
+	case "gzip":
+		zr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = zr
+	case "zstd":
+		zr, err := zstd.NewReader(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		body = zr
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec, string(data)
+}
+
+// respond отвечает телом body с заданными заголовками и статусом.
+func respond(status int, headers map[string]string, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range headers {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(status)
+		// Пишем частями, чтобы порог набирался через буфер
+		for len(body) > 0 {
+			n := min(len(body), 10)
+			w.Write([]byte(body[:n]))
+			body = body[n:]
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	large := `{"orders":"` + strings.Repeat("x", 2*testMinSize) + `"}`
+	small := `{"ok":true}`
+	jsonType := map[string]string{"Content-Type": "application/json", "ETag": `"v1"`}
+
+	tests := []struct {
+		name           string
+		method         string
+		acceptEncoding string
+		status         int
+		headers        map[string]string
+		body           string
+		wantEncoding   string
+		wantETag       string
+	}{
+		{"gzip above threshold", http.MethodGet, "gzip", 200, jsonType, large, "gzip", `W/"v1"`},
+		{"zstd above threshold", http.MethodGet, "gzip;q=0.5, zstd", 200, jsonType, large, "zstd", `W/"v1"`},
+		{"below threshold", http.MethodGet, "gzip", 200, jsonType, small, "", `"v1"`},
+		{"no accept-encoding", http.MethodGet, "", 200, jsonType, large, "", `"v1"`},
+		{"encoding refused", http.MethodGet, "gzip;q=0", 200, jsonType, large, "", `"v1"`},
+		{"head", http.MethodHead, "gzip", 200, jsonType, "", "", `"v1"`},
+		{"weak etag stays weak", http.MethodGet, "gzip", 200,
+			map[string]string{"Content-Type": "application/json", "ETag": `W/"v1"`}, large, "gzip", `W/"v1"`},
+		{"text with charset", http.MethodGet, "gzip", 200,
+			map[string]string{"Content-Type": "text/csv; charset=utf-8"}, large, "gzip", ""},
+		{"incompressible type", http.MethodGet, "gzip", 200,
+			map[string]string{"Content-Type": "application/vnd.apache.parquet", "ETag": `"v1"`}, large, "", `"v1"`},
+		{"already encoded", http.MethodGet, "gzip", 200,
+			map[string]string{"Content-Type": "application/json", "Content-Encoding": "br"}, large, "br", ""},
+		{"partial content", http.MethodGet, "gzip", http.StatusPartialContent, jsonType, large, "", `"v1"`},
+		{"not modified", http.MethodGet, "gzip", http.StatusNotModified, jsonType, "", "", `"v1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, body := serve(t, tt.method, tt.acceptEncoding, respond(tt.status, tt.headers, tt.body))
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("Content-Encoding %q, want %q", got, tt.wantEncoding)
+			}
+			if got := rec.Header().Get("ETag"); got != tt.wantETag {
+				t.Fatalf("ETag %q, want %q", got, tt.wantETag)
+			}
+			if tt.wantEncoding == "br" {
+				return // Тело отдано как есть, распаковать его нечем
+			}
+			if body != tt.body {
+				t.Fatalf("body %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestCompressVary(t *testing.T) {
+	for _, body := range []string{"{}", `"` + strings.Repeat("x", testMinSize) + `"`} {
+		rec, _ := serve(t, http.MethodGet, "gzip", respond(200, map[string]string{"Content-Type": "application/json"}, body))
+		if got := rec.Header().Values("Vary"); len(got) != 1 || got[0] != "Accept-Encoding" {
+			t.Fatalf("Vary %v for %d bytes, want Accept-Encoding", got, len(body))
+		}
+	}
+}
+
+func TestCompressDropsContentLength(t *testing.T) {
+	body := strings.Repeat("x", 2*testMinSize)
+	headers := map[string]string{"Content-Type": "text/plain", "Content-Length": "128"}
+	rec, got := serve(t, http.MethodGet, "gzip", respond(200, headers, body))
+	if rec.Header().Get("Content-Length") != "" {
+		t.Fatalf("Content-Length %q left on compressed response", rec.Header().Get("Content-Length"))
+	}
+	if got != body {
+		t.Fatalf("body differs after decompression")
+	}
+}
+
+func TestCompressFlushBelowThreshold(t *testing.T) {
+	// Потоковый ответ сжимается сразу при Flush, не дожидаясь порога
+	lines := []string{`{"n":1}` + "\n", `{"n":2}` + "\n"}
+	rec, body := serve(t, http.MethodGet, "gzip", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, line := range lines {
+			io.WriteString(w, line)
+			if err := http.NewResponseController(w).Flush(); err != nil {
+				t.Errorf("flush: %v", err)
+			}
+		}
+	})
+	if !rec.Flushed {
+		t.Fatal("flush did not reach the underlying writer")
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding %q, want gzip", got)
+	}
+	if want := strings.Join(lines, ""); body != want {
+		t.Fatalf("body %q, want %q", body, want)
+	}
+}
+
+func TestCompressFlushWithoutCompression(t *testing.T) {
+	rec, body := serve(t, http.MethodGet, "gzip", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		io.WriteString(w, "chunk")
+		http.NewResponseController(w).Flush()
+	})
+	if !rec.Flushed || rec.Header().Get("Content-Encoding") != "" || body != "chunk" {
+		t.Fatalf("flushed %v, encoding %q, body %q; want flushed plain chunk",
+			rec.Flushed, rec.Header().Get("Content-Encoding"), body)
+	}
+}
+
+func TestCompressUnwrap(t *testing.T) {
+	var unwrapped http.ResponseWriter
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	Compress(testMinSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			t.Fatal("response writer has no Unwrap")
+		}
+		unwrapped = u.Unwrap()
+	})).ServeHTTP(rec, r)
+	if unwrapped != rec {
+		t.Fatal("Unwrap does not return the original writer")
+	}
+}
+
+func TestCompressReusesPooledEncoders(t *testing.T) {
+	// Кодировщик из пула после Reset не должен тащить хвост предыдущего ответа
+	for i := 0; i < 3; i++ {
+		for _, encoding := range []string{"gzip", "zstd"} {
+			body := strings.Repeat(string(rune('a'+i)), 3*testMinSize)
+			_, got := serve(t, http.MethodGet, encoding, respond(200, map[string]string{"Content-Type": "text/plain"}, body))
+			if got != body {
+				t.Fatalf("%s response %d differs after decompression", encoding, i)
+			}
+		}
+	}
+}
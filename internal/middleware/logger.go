@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"order_service/internal/pii"
 	"time"
 )
 
@@ -20,11 +21,27 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 }
 
 func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
-	lrw.body.Write(b) // сохраняем копию
+	// Копию тела держим только для ошибок, иначе большие выгрузки целиком оседают в памяти
+	if lrw.status >= 400 {
+		lrw.body.Write(b)
+	}
 	return lrw.ResponseWriter.Write(b)
 }
 
-func RequestLogger(next http.Handler) http.Handler {
+// Unwrap дает http.ResponseController доступ к исходному ResponseWriter (Flush, дедлайны).
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
+// RequestLogger логирует запросы и ответы. Тела ошибочных ответов попадают в лог
+// только после маскирования персональных данных.
+func RequestLogger(masker *pii.Masker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return requestLogger(masker, next)
+	}
+}
+
+func requestLogger(masker *pii.Masker, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		lrw := &loggingResponseWriter{ResponseWriter: w, status: http.StatusOK}
@@ -49,7 +66,7 @@ func RequestLogger(next http.Handler) http.Handler {
 		if lrw.status >= 400 {
 			// Ошибочный ответ → логируем тело
 			log.Printf("⬅️  %s %s %d (%s)\nResponse: %s",
-				r.Method, r.RequestURI, lrw.status, duration, lrw.body.String())
+				r.Method, r.RequestURI, lrw.status, duration, masker.MaskJSON(lrw.body.Bytes()))
 		} else {
 			log.Printf("⬅️  %s %s %d (%s)",
 				r.Method, r.RequestURI, lrw.status, duration)
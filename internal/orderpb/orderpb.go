@@ -0,0 +1,314 @@
+// Package orderpb кодирует заказы в protobuf по схеме api/proto/order.proto.
+// Сгенерированного кода нет: сообщения собираются и разбираются через protowire,
+// номера полей должны совпадать со схемой.
+package orderpb
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"order_service/internal/domain"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ErrMalformed возвращается при разборе поврежденного сообщения.
+var ErrMalformed = errors.New("malformed protobuf message")
+
+// MarshalOrder кодирует сообщение Order.
+func MarshalOrder(o *domain.Order) []byte {
+	return appendOrder(nil, o)
+}
+
+// MarshalOrders кодирует сообщение OrderList.
+func MarshalOrders(orders []domain.Order) []byte {
+	var b []byte
+	for i := range orders {
+		b = appendMessage(b, 1, appendOrder(nil, &orders[i]))
+	}
+	return b
+}
+
+func appendOrder(b []byte, o *domain.Order) []byte {
+	b = appendString(b, 1, o.OrderUID)
+	b = appendString(b, 2, o.TrackNumber)
+	b = appendString(b, 3, o.Entry)
+	b = appendMessage(b, 4, appendDelivery(nil, &o.Delivery))
+	b = appendMessage(b, 5, appendPayment(nil, &o.Payment))
+	for i := range o.Items {
+		b = appendMessage(b, 6, appendItem(nil, &o.Items[i]))
+	}
+	b = appendString(b, 7, o.Locale)
+	b = appendString(b, 8, o.InternalSignature)
+	b = appendString(b, 9, o.CustomerID)
+	b = appendString(b, 10, o.DeliveryService)
+	b = appendString(b, 11, o.Shardkey)
+	b = appendInt(b, 12, int64(o.SmID))
+	b = appendTimestamp(b, 13, o.DateCreated)
+	b = appendString(b, 14, o.OofShard)
+	b = appendInt(b, 15, o.Version)
+	b = appendTimestamp(b, 16, o.UpdatedAt)
+	return b
+}
+
+func appendDelivery(b []byte, d *domain.Delivery) []byte {
+	b = appendString(b, 1, d.Name)
+	b = appendString(b, 2, d.Phone)
+	b = appendString(b, 3, d.Zip)
+	b = appendString(b, 4, d.City)
+	b = appendString(b, 5, d.Address)
+	b = appendString(b, 6, d.Region)
+	b = appendString(b, 7, d.Email)
+	return b
+}
+
+func appendPayment(b []byte, p *domain.Payment) []byte {
+	b = appendString(b, 1, p.Transaction)
+	b = appendString(b, 2, p.RequestID)
+	b = appendString(b, 3, p.Currency)
+	b = appendString(b, 4, p.Provider)
+	b = appendInt(b, 5, int64(p.Amount))
+	b = appendInt(b, 6, p.PaymentDt)
+	b = appendString(b, 7, p.Bank)
+	b = appendInt(b, 8, int64(p.DeliveryCost))
+	b = appendInt(b, 9, int64(p.GoodsTotal))
+	b = appendInt(b, 10, int64(p.CustomFee))
+	return b
+}
+
+func appendItem(b []byte, it *domain.Item) []byte {
+	b = appendInt(b, 1, int64(it.ChrtID))
+	b = appendString(b, 2, it.TrackNumber)
+	b = appendInt(b, 3, int64(it.Price))
+	b = appendString(b, 4, it.Rid)
+	b = appendString(b, 5, it.Name)
+	b = appendInt(b, 6, int64(it.Sale))
+	b = appendString(b, 7, it.Size)
+	b = appendInt(b, 8, int64(it.TotalPrice))
+	b = appendInt(b, 9, int64(it.NmID))
+	b = appendString(b, 10, it.Brand)
+	b = appendInt(b, 11, int64(it.Status))
+	return b
+}
+
+// Значения по умолчанию в proto3 не пишутся.
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+// appendTimestamp пишет google.protobuf.Timestamp{seconds = 1, nanos = 2}.
+func appendTimestamp(b []byte, num protowire.Number, t time.Time) []byte {
+	if t.IsZero() {
+		return b
+	}
+	var ts []byte
+	ts = appendInt(ts, 1, t.Unix())
+	ts = appendInt(ts, 2, int64(t.Nanosecond()))
+	return appendMessage(b, num, ts)
+}
+
+// field — значение поля сообщения: varint или байты (строка, вложенное сообщение).
+type field struct {
+	num   protowire.Number
+	u     uint64
+	bytes []byte
+}
+
+func (f field) int() int       { return int(int64(f.u)) }
+func (f field) int64() int64   { return int64(f.u) }
+func (f field) string() string { return string(f.bytes) }
+
+// consumeFields перебирает поля сообщения. Неизвестные поля пропускаются — так старый
+// код читает сообщения, записанные по более новой схеме.
+func consumeFields(b []byte, fn func(f field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("%w: %v", ErrMalformed, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		f := field{num: num}
+		switch typ {
+		case protowire.VarintType:
+			f.u, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n >= 0 {
+				b = b[n:]
+				continue
+			}
+		}
+		if n < 0 {
+			return fmt.Errorf("%w: field %d: %v", ErrMalformed, num, protowire.ParseError(n))
+		}
+		b = b[n:]
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalOrder разбирает сообщение Order.
+func UnmarshalOrder(b []byte, o *domain.Order) error {
+	*o = domain.Order{Items: []domain.Item{}}
+	return consumeFields(b, func(f field) error {
+		var err error
+		switch f.num {
+		case 1:
+			o.OrderUID = f.string()
+		case 2:
+			o.TrackNumber = f.string()
+		case 3:
+			o.Entry = f.string()
+		case 4:
+			err = unmarshalDelivery(f.bytes, &o.Delivery)
+		case 5:
+			err = unmarshalPayment(f.bytes, &o.Payment)
+		case 6:
+			var item domain.Item
+			err = unmarshalItem(f.bytes, &item)
+			o.Items = append(o.Items, item)
+		case 7:
+			o.Locale = f.string()
+		case 8:
+			o.InternalSignature = f.string()
+		case 9:
+			o.CustomerID = f.string()
+		case 10:
+			o.DeliveryService = f.string()
+		case 11:
+			o.Shardkey = f.string()
+		case 12:
+			o.SmID = f.int()
+		case 13:
+			o.DateCreated, err = unmarshalTimestamp(f.bytes)
+		case 14:
+			o.OofShard = f.string()
+		case 15:
+			o.Version = f.int64()
+		case 16:
+			o.UpdatedAt, err = unmarshalTimestamp(f.bytes)
+		}
+		return err
+	})
+}
+
+func unmarshalDelivery(b []byte, d *domain.Delivery) error {
+	return consumeFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			d.Name = f.string()
+		case 2:
+			d.Phone = f.string()
+		case 3:
+			d.Zip = f.string()
+		case 4:
+			d.City = f.string()
+		case 5:
+			d.Address = f.string()
+		case 6:
+			d.Region = f.string()
+		case 7:
+			d.Email = f.string()
+		}
+		return nil
+	})
+}
+
+func unmarshalPayment(b []byte, p *domain.Payment) error {
+	return consumeFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			p.Transaction = f.string()
+		case 2:
+			p.RequestID = f.string()
+		case 3:
+			p.Currency = f.string()
+		case 4:
+			p.Provider = f.string()
+		case 5:
+			p.Amount = f.int()
+		case 6:
+			p.PaymentDt = f.int64()
+		case 7:
+			p.Bank = f.string()
+		case 8:
+			p.DeliveryCost = f.int()
+		case 9:
+			p.GoodsTotal = f.int()
+		case 10:
+			p.CustomFee = f.int()
+		}
+		return nil
+	})
+}
+
+func unmarshalItem(b []byte, it *domain.Item) error {
+	return consumeFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			it.ChrtID = f.int()
+		case 2:
+			it.TrackNumber = f.string()
+		case 3:
+			it.Price = f.int()
+		case 4:
+			it.Rid = f.string()
+		case 5:
+			it.Name = f.string()
+		case 6:
+			it.Sale = f.int()
+		case 7:
+			it.Size = f.string()
+		case 8:
+			it.TotalPrice = f.int()
+		case 9:
+			it.NmID = f.int()
+		case 10:
+			it.Brand = f.string()
+		case 11:
+			it.Status = f.int()
+		}
+		return nil
+	})
+}
+
+func unmarshalTimestamp(b []byte) (time.Time, error) {
+	var sec, nsec int64
+	err := consumeFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			sec = f.int64()
+		case 2:
+			nsec = f.int64()
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(sec, nsec).UTC(), nil
+}
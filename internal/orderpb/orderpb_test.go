@@ -0,0 +1,171 @@
+package orderpb
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"order_service/internal/domain"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// generated возвращает n заказов генератора с заполненными служебными полями.
+func generated(t *testing.T, n int) []domain.Order {
+	t.Helper()
+	gen, err := domain.NewGenerator(domain.GeneratorOptions{Seed: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders := gen.Generate(n)
+	for i := range orders {
+		orders[i].DateCreated = orders[i].DateCreated.UTC()
+		orders[i].Version = int64(i + 1)
+		orders[i].UpdatedAt = time.Date(2026, 10, 19, 12, 0, 0, 123456789, time.UTC)
+	}
+	return orders
+}
+
+func TestOrderRoundTrip(t *testing.T) {
+	for _, order := range generated(t, 20) {
+		var got domain.Order
+		if err := UnmarshalOrder(MarshalOrder(&order), &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, order) {
+			t.Fatalf("round trip changed order %s:\ngot  %+v\nwant %+v", order.OrderUID, got, order)
+		}
+	}
+}
+
+func TestOrderRoundTripEdgeValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		order domain.Order
+	}{
+		{"empty", domain.Order{Items: []domain.Item{}}},
+		{"negative numbers", domain.Order{
+			SmID:    -1,
+			Payment: domain.Payment{Amount: -100, PaymentDt: -1637907727},
+			Items:   []domain.Item{{ChrtID: -5, Price: -1, Status: -202}},
+		}},
+		{"before epoch", domain.Order{
+			DateCreated: time.Date(1960, 1, 2, 3, 4, 5, 6, time.UTC),
+			Items:       []domain.Item{},
+		}},
+		{"unicode", domain.Order{
+			OrderUID: "заказ-1",
+			Delivery: domain.Delivery{Name: "Иван Иванов", City: "Кирьят-Моцкин"},
+			Items:    []domain.Item{{Name: "Mascaras 💄"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got domain.Order
+			if err := UnmarshalOrder(MarshalOrder(&tt.order), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.order) {
+				t.Fatalf("round trip:\ngot  %+v\nwant %+v", got, tt.order)
+			}
+		})
+	}
+}
+
+func TestOrdersRoundTrip(t *testing.T) {
+	orders := generated(t, 5)
+	var got []domain.Order
+	err := consumeFields(MarshalOrders(orders), func(f field) error {
+		if f.num != 1 {
+			t.Fatalf("OrderList field %d, want 1", f.num)
+		}
+		var order domain.Order
+		if err := UnmarshalOrder(f.bytes, &order); err != nil {
+			return err
+		}
+		got = append(got, order)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, orders) {
+		t.Fatal("round trip changed order list")
+	}
+	if b := MarshalOrders(nil); len(b) != 0 {
+		t.Fatalf("empty list encoded as %x", b)
+	}
+}
+
+func TestMarshalOrderWireFormat(t *testing.T) {
+	// Номера и типы полей из api/proto/order.proto: при расхождении клиенты со сгенерированным
+	// кодом прочитают ответ неправильно
+	order := domain.Order{
+		OrderUID:    "a",
+		Delivery:    domain.Delivery{Email: "e"},
+		Payment:     domain.Payment{Amount: 2},
+		Items:       []domain.Item{{Status: 3}},
+		SmID:        4,
+		DateCreated: time.Unix(5, 6),
+		Version:     7,
+	}
+	want := []byte{
+		0x0a, 0x01, 'a', // 1: order_uid
+		0x22, 0x03, 0x3a, 0x01, 'e', // 4: delivery {7: email}
+		0x2a, 0x02, 0x28, 0x02, // 5: payment {5: amount}
+		0x32, 0x02, 0x58, 0x03, // 6: items {11: status}
+		0x60, 0x04, // 12: sm_id
+		0x6a, 0x04, 0x08, 0x05, 0x10, 0x06, // 13: date_created {1: seconds, 2: nanos}
+		0x78, 0x07, // 15: version
+	}
+	if got := MarshalOrder(&order); !bytes.Equal(got, want) {
+		t.Fatalf("encoded\n%x\nwant\n%x", got, want)
+	}
+}
+
+func TestUnmarshalOrderSkipsUnknownFields(t *testing.T) {
+	order := generated(t, 1)[0]
+	b := MarshalOrder(&order)
+	// Поля из будущей версии схемы всех типов
+	b = protowire.AppendTag(b, 100, protowire.VarintType)
+	b = protowire.AppendVarint(b, 42)
+	b = protowire.AppendTag(b, 101, protowire.BytesType)
+	b = protowire.AppendString(b, "future")
+	b = protowire.AppendTag(b, 102, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, 1)
+	b = protowire.AppendTag(b, 103, protowire.Fixed32Type)
+	b = protowire.AppendFixed32(b, 1)
+
+	var got domain.Order
+	if err := UnmarshalOrder(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, order) {
+		t.Fatal("unknown fields changed decoded order")
+	}
+}
+
+func TestUnmarshalOrderMalformed(t *testing.T) {
+	order := generated(t, 1)[0]
+	valid := MarshalOrder(&order)
+	tests := []struct {
+		name string
+		b    []byte
+	}{
+		{"truncated", valid[:len(valid)-1]},
+		{"bad tag", []byte{0x00}},
+		{"length past end", []byte{0x0a, 0x05, 'a'}},
+		{"truncated varint", []byte{0x60, 0x80}},
+		{"malformed nested", []byte{0x22, 0x02, 0x0a, 0x05}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got domain.Order
+			if err := UnmarshalOrder(tt.b, &got); !errors.Is(err, ErrMalformed) {
+				t.Fatalf("want %v, got %v", ErrMalformed, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package pii
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"order_service/internal/domain"
+)
+
+// Виды персональных данных, которыми размечены поля domain.Delivery и domain.Payment.
+const (
+	KindName    = "name"
+	KindPhone   = "phone"
+	KindEmail   = "email"
+	KindAddress = "address"
+	KindZip     = "zip"
+	KindID      = "id"
+)
+
+// DefaultRules — правила маскирования, если в конфиге не задано иное.
+var DefaultRules = map[string]string{
+	KindName:    "partial:1:0",
+	KindPhone:   "partial:4:2",
+	KindEmail:   "email",
+	KindAddress: "redact",
+	KindZip:     "partial:0:2",
+	KindID:      "partial:4:0",
+}
+
+// maskChar — символ, которым заменяются скрытые знаки.
+const maskChar = "*"
+
+// rule маскирует одно значение.
+type rule func(string) string
+
+// Masker маскирует персональные данные по правилам для каждого вида поля.
+type Masker struct {
+	rules map[string]rule
+	// jsonKinds сопоставляет json-имена размеченных полей их виду, нужен для маскирования логов
+	jsonKinds map[string]string
+}
+
+// NewMasker разбирает правила. Виды без правила берутся из DefaultRules.
+func NewMasker(rules map[string]string) (*Masker, error) {
+	m := &Masker{rules: map[string]rule{}, jsonKinds: map[string]string{}}
+
+	merged := map[string]string{}
+	for kind, spec := range DefaultRules {
+		merged[kind] = spec
+	}
+	for kind, spec := range rules {
+		merged[kind] = spec
+	}
+	for kind, spec := range merged {
+		r, err := parseRule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("pii rule for %s: %w", kind, err)
+		}
+		m.rules[kind] = r
+	}
+
+	for _, t := range []reflect.Type{reflect.TypeOf(domain.Delivery{}), reflect.TypeOf(domain.Payment{})} {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if kind := f.Tag.Get("pii"); kind != "" {
+				name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+				m.jsonKinds[name] = kind
+			}
+		}
+	}
+	return m, nil
+}
+
+// parseRule разбирает правило: keep, redact, email или partial:<начало>:<конец>.
+func parseRule(spec string) (rule, error) {
+	name, args, _ := strings.Cut(spec, ":")
+	switch name {
+	case "keep":
+		return func(s string) string { return s }, nil
+	case "redact":
+		return func(s string) string {
+			if s == "" {
+				return s
+			}
+			return strings.Repeat(maskChar, 3)
+		}, nil
+	case "email":
+		return maskEmail, nil
+	case "partial":
+		start, end, ok := strings.Cut(args, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid partial rule %q, expected partial:<start>:<end>", spec)
+		}
+		keepStart, err1 := strconv.Atoi(start)
+		keepEnd, err2 := strconv.Atoi(end)
+		if err1 != nil || err2 != nil || keepStart < 0 || keepEnd < 0 {
+			return nil, fmt.Errorf("invalid partial rule %q", spec)
+		}
+		return func(s string) string { return maskPartial(s, keepStart, keepEnd) }, nil
+	}
+	return nil, fmt.Errorf("unknown rule %q", spec)
+}
+
+// maskPartial оставляет keepStart первых и keepEnd последних символов, остальное скрывает.
+// Если скрытой осталась бы меньше трети значения, скрывается все, кроме первого символа.
+func maskPartial(s string, keepStart, keepEnd int) string {
+	runes := []rune(s)
+	n := len(runes)
+	if n == 0 {
+		return s
+	}
+	if keepStart+keepEnd >= n || (n-keepStart-keepEnd)*3 < n {
+		keepStart, keepEnd = min(keepStart, 1), 0
+		if n == 1 {
+			keepStart = 0
+		}
+	}
+	return string(runes[:keepStart]) + strings.Repeat(maskChar, n-keepStart-keepEnd) + string(runes[n-keepEnd:])
+}
+
+// maskEmail оставляет первый символ локальной части и домен: u***@example.com.
+func maskEmail(s string) string {
+	local, domain, ok := strings.Cut(s, "@")
+	if !ok {
+		return maskPartial(s, 1, 0)
+	}
+	if local == "" {
+		return s
+	}
+	_, size := utf8.DecodeRuneInString(local)
+	return local[:size] + strings.Repeat(maskChar, 3) + "@" + domain
+}
+
+// Mask маскирует значение по правилу для вида kind. Неизвестный вид скрывается целиком.
+func (m *Masker) Mask(kind, value string) string {
+	if r, ok := m.rules[kind]; ok {
+		return r(value)
+	}
+	return maskPartial(value, 0, 0)
+}
+
+// MaskOrder возвращает копию заказа с замаскированными персональными данными.
+func (m *Masker) MaskOrder(order *domain.Order) *domain.Order {
+	masked := *order
+	m.maskStruct(reflect.ValueOf(&masked.Delivery).Elem())
+	m.maskStruct(reflect.ValueOf(&masked.Payment).Elem())
+	return &masked
+}
+
+func (m *Masker) maskStruct(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		kind := t.Field(i).Tag.Get("pii")
+		if kind == "" || v.Field(i).Kind() != reflect.String {
+			continue
+		}
+		v.Field(i).SetString(m.Mask(kind, v.Field(i).String()))
+	}
+}
+
+// MaskJSON маскирует персональные данные в произвольном JSON по именам полей.
+// Одноименные поля вне доставки (например, name товара) тоже маскируются — для логов это допустимо.
+// Не-JSON возвращается без изменений: в нем нет размеченных полей.
+func (m *Masker) MaskJSON(data []byte) []byte {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return data
+	}
+	masked, err := json.Marshal(m.maskValue(v))
+	if err != nil {
+		return data
+	}
+	return masked
+}
+
+func (m *Masker) maskValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for key, field := range val {
+			if s, ok := field.(string); ok {
+				if kind, ok := m.jsonKinds[key]; ok {
+					val[key] = m.Mask(kind, s)
+				}
+				continue
+			}
+			val[key] = m.maskValue(field)
+		}
+	case []any:
+		for i := range val {
+			val[i] = m.maskValue(val[i])
+		}
+	}
+	return v
+}
@@ -0,0 +1,184 @@
+package pii
+
+import (
+	"testing"
+
+	"order_service/internal/domain"
+)
+
+func TestMaskPartial(t *testing.T) {
+	tests := []struct {
+		in                 string
+		keepStart, keepEnd int
+		want               string
+	}{
+		{"+9720000012", 4, 2, "+972*****12"},
+		{"Test Testov", 1, 0, "T**********"},
+		{"2639809", 0, 2, "*****09"},
+		{"", 4, 2, ""},
+		// Открытой осталась бы почти вся строка — скрывается все, кроме первого символа
+		{"1234567", 4, 2, "1******"},
+		{"123", 4, 2, "1**"},
+		{"x", 1, 0, "*"},
+		// Символы, а не байты
+		{"Иванов", 1, 0, "И*****"},
+		{"+7987654321", 4, 2, "+798*****21"},
+	}
+	for _, tt := range tests {
+		if got := maskPartial(tt.in, tt.keepStart, tt.keepEnd); got != tt.want {
+			t.Errorf("maskPartial(%q, %d, %d) = %q, want %q", tt.in, tt.keepStart, tt.keepEnd, got, tt.want)
+		}
+	}
+}
+
+func TestMaskEmail(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"user@example.com", "u***@example.com"},
+		{"u@example.com", "u***@example.com"},
+		{"юзер@пример.рф", "ю***@пример.рф"},
+		{"@example.com", "@example.com"},
+		{"not-an-email", "n***********"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := maskEmail(tt.in); got != tt.want {
+			t.Errorf("maskEmail(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		spec    string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{spec: "keep", in: "secret", want: "secret"},
+		{spec: "redact", in: "Kiryat Mozkin 15", want: "***"},
+		{spec: "redact", in: "", want: ""},
+		{spec: "email", in: "test@gmail.com", want: "t***@gmail.com"},
+		{spec: "partial:4:2", in: "+9720000012", want: "+972*****12"},
+		{spec: "partial:0:0", in: "abc", want: "***"},
+		{spec: "partial", wantErr: true},
+		{spec: "partial:4", wantErr: true},
+		{spec: "partial:a:2", wantErr: true},
+		{spec: "partial:-1:2", wantErr: true},
+		{spec: "hash", wantErr: true},
+		{spec: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		r, err := parseRule(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseRule(%q): expected error", tt.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseRule(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := r(tt.in); got != tt.want {
+			t.Errorf("parseRule(%q)(%q) = %q, want %q", tt.spec, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNewMaskerRejectsInvalidRule(t *testing.T) {
+	if _, err := NewMasker(map[string]string{KindPhone: "partial:x"}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestMaskOrder(t *testing.T) {
+	order := &domain.Order{
+		OrderUID: "b563feb7b2b84b6test",
+		Delivery: domain.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000012",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "user@example.com",
+		},
+		Payment: domain.Payment{
+			Transaction: "b563feb7b2b84b6test",
+			RequestID:   "",
+			Currency:    "USD",
+		},
+	}
+
+	tests := []struct {
+		name  string
+		rules map[string]string
+		want  domain.Delivery
+	}{
+		{
+			name:  "default rules",
+			rules: nil,
+			want: domain.Delivery{
+				Name:    "T**********",
+				Phone:   "+972*****12",
+				Zip:     "*****09",
+				City:    "Kiryat Mozkin",
+				Address: "***",
+				Region:  "Kraiot",
+				Email:   "u***@example.com",
+			},
+		},
+		{
+			name:  "configured rules",
+			rules: map[string]string{KindName: "keep", KindAddress: "partial:0:2"},
+			want: domain.Delivery{
+				Name:    "Test Testov",
+				Phone:   "+972*****12",
+				Zip:     "*****09",
+				City:    "Kiryat Mozkin",
+				Address: "*************15",
+				Region:  "Kraiot",
+				Email:   "u***@example.com",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMasker(tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			masked := m.MaskOrder(order)
+			if masked.Delivery != tt.want {
+				t.Errorf("delivery = %+v, want %+v", masked.Delivery, tt.want)
+			}
+			if masked.Payment.Transaction != "b563***************" || masked.Payment.RequestID != "" {
+				t.Errorf("payment ids = %q, %q", masked.Payment.Transaction, masked.Payment.RequestID)
+			}
+			if masked.Payment.Currency != "USD" || masked.OrderUID != order.OrderUID {
+				t.Errorf("fields without pii tag changed: %+v", masked)
+			}
+			// Исходный заказ не меняется
+			if order.Delivery.Phone != "+9720000012" {
+				t.Errorf("original order modified: %q", order.Delivery.Phone)
+			}
+		})
+	}
+}
+
+func TestMaskJSON(t *testing.T) {
+	m, err := NewMasker(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := `{"order_uid":"o1","delivery":{"phone":"+9720000012","email":"user@example.com","city":"Haifa"}}`
+	want := `{"delivery":{"city":"Haifa","email":"u***@example.com","phone":"+972*****12"},"order_uid":"o1"}`
+	if got := string(m.MaskJSON([]byte(in))); got != want {
+		t.Errorf("MaskJSON = %s, want %s", got, want)
+	}
+	if got := string(m.MaskJSON([]byte("not json"))); got != "not json" {
+		t.Errorf("MaskJSON(non-JSON) = %q", got)
+	}
+}
@@ -0,0 +1,92 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"order_service/internal/config"
+)
+
+// Брокеры сообщений, см. broker.driver.
+const (
+	BrokerKafka   = "kafka"
+	BrokerNATS    = "nats"
+	BrokerChannel = "channel"
+)
+
+// Смещение, с которого группа без подтвержденных сообщений начинает чтение.
+const (
+	OffsetEarliest = "earliest"
+	OffsetLatest   = "latest"
+)
+
+// Header — заголовок сообщения.
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Message — сообщение, полученное из брокера.
+type Message struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers []Header
+	// ID однозначно указывает на сообщение у брокера: топик/партиция/смещение в Kafka, поток/номер в NATS
+	ID string
+	// Source — источник для журнала аудита: audit.SourceKafka, audit.SourceNATS или audit.SourceChannel
+	Source string
+}
+
+// Publisher публикует сообщения и ждет подтверждения брокера.
+type Publisher interface {
+	// Publish отправляет сообщение в основной топик заказов.
+	Publish(ctx context.Context, key, value []byte) error
+	// PublishTo отправляет сообщение в указанный топик.
+	PublishTo(ctx context.Context, topic string, key, value []byte, headers ...Header) error
+	Close()
+}
+
+// Subscription — что читает подписчик.
+type Subscription struct {
+	Topic string
+	// Group — группа подписчиков: подтверждения общие для всей группы
+	Group string
+	// OffsetReset — OffsetEarliest или OffsetLatest для группы без подтвержденных сообщений
+	OffsetReset string
+}
+
+// Subscriber доставляет сообщения топика обработчику по одному.
+type Subscriber interface {
+	// Receive вызывает handle для каждого сообщения, пока не отменен ctx. Сообщение подтверждается
+	// брокеру только после того, как handle вернул nil; иначе подтверждения нет и, в зависимости
+	// от брокера, сообщение доставят снова. Отмена ctx — штатная остановка, Receive возвращает nil.
+	// Ошибка означает, что подписчик больше не читает: вызывающий закрывает его и создает нового.
+	Receive(ctx context.Context, handle func(ctx context.Context, msg Message) error) error
+	Close()
+}
+
+// Broker создает издателей и подписчиков брокера из broker.driver.
+type Broker interface {
+	NewPublisher() (Publisher, error)
+	NewSubscriber(sub Subscription) (Subscriber, error)
+	// Close закрывает общее подключение. Вызывается после закрытия издателей и подписчиков.
+	Close()
+}
+
+// NewBroker подключается к брокеру из broker.driver.
+func NewBroker(cfg *config.Config) (Broker, error) {
+	switch cfg.Broker.Driver {
+	case BrokerKafka:
+		return &kafkaBroker{cfg: cfg}, nil
+	case BrokerNATS:
+		return NewNATSBroker(cfg)
+	case BrokerChannel:
+		if cfg.Env == "prod" {
+			return nil, fmt.Errorf("broker %q is for local development only", BrokerChannel)
+		}
+		return NewChannelBroker(cfg.Kafka.Topic), nil
+	default:
+		return nil, fmt.Errorf("unknown broker driver %q, expected kafka, nats or channel", cfg.Broker.Driver)
+	}
+}
@@ -0,0 +1,150 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"order_service/internal/audit"
+)
+
+// ChannelBroker — брокер в памяти процесса без внешних сервисов: для тестов и локальной разработки.
+// Топик — журнал сообщений, группа читает его как консюмер Kafka с одной партицией: после успешной
+// обработки подтверждается смещение, новый подписчик группы продолжает с первого неподтвержденного
+// сообщения. Сообщения хранятся до остановки процесса.
+type ChannelBroker struct {
+	mu     sync.Mutex
+	topic  string
+	topics map[string]*channelTopic
+}
+
+type channelTopic struct {
+	messages []Message
+	// committed — смещение следующего сообщения по группам
+	committed map[string]int
+	// appended закрывается и заменяется при добавлении сообщения
+	appended chan struct{}
+}
+
+// NewChannelBroker создает брокер в памяти. topic — основной топик для Publish.
+func NewChannelBroker(topic string) *ChannelBroker {
+	return &ChannelBroker{topic: topic, topics: make(map[string]*channelTopic)}
+}
+
+// topicLocked возвращает топик, создавая его при первом обращении. Вызывается под блокировкой.
+func (b *ChannelBroker) topicLocked(name string) *channelTopic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &channelTopic{committed: make(map[string]int), appended: make(chan struct{})}
+		b.topics[name] = t
+	}
+	return t
+}
+
+func (b *ChannelBroker) NewPublisher() (Publisher, error) {
+	return &channelPublisher{broker: b}, nil
+}
+
+func (b *ChannelBroker) NewSubscriber(sub Subscription) (Subscriber, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topicLocked(sub.Topic)
+	next, ok := t.committed[sub.Group]
+	if !ok && sub.OffsetReset == OffsetLatest {
+		next = len(t.messages)
+	}
+	return &channelSubscriber{broker: b, topic: sub.Topic, group: sub.Group, next: next}, nil
+}
+
+func (b *ChannelBroker) Close() {}
+
+// Committed возвращает смещение, подтвержденное группой в топике.
+func (b *ChannelBroker) Committed(topic, group string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.topicLocked(topic).committed[group]
+}
+
+type channelPublisher struct {
+	broker *ChannelBroker
+}
+
+func (p *channelPublisher) Publish(ctx context.Context, key, value []byte) error {
+	return p.PublishTo(ctx, p.broker.topic, key, value)
+}
+
+// PublishTo добавляет сообщение в журнал топика. Срезы копируются: вызывающий может их переиспользовать.
+func (p *channelPublisher) PublishTo(ctx context.Context, topic string, key, value []byte, headers ...Header) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	msg := Message{
+		Topic:   topic,
+		Key:     append([]byte(nil), key...),
+		Value:   append([]byte(nil), value...),
+		Headers: append([]Header(nil), headers...),
+		Source:  audit.SourceChannel,
+	}
+
+	b := p.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topicLocked(topic)
+	msg.ID = fmt.Sprintf("%s/%d", topic, len(t.messages))
+	t.messages = append(t.messages, msg)
+	close(t.appended)
+	t.appended = make(chan struct{})
+	return nil
+}
+
+func (p *channelPublisher) Close() {}
+
+type channelSubscriber struct {
+	broker *ChannelBroker
+	topic  string
+	group  string
+	next   int
+}
+
+func (s *channelSubscriber) Receive(ctx context.Context, handle func(ctx context.Context, msg Message) error) error {
+	for ctx.Err() == nil {
+		msg, ok, appended := s.poll()
+		if !ok {
+			select {
+			case <-ctx.Done():
+			case <-appended:
+			}
+			continue
+		}
+		offset := s.next
+		s.next++
+		if err := handle(ctx, msg); err != nil {
+			continue
+		}
+		s.commit(offset)
+	}
+	return nil
+}
+
+// poll возвращает следующее сообщение или канал, который закроется при появлении нового.
+func (s *channelSubscriber) poll() (Message, bool, <-chan struct{}) {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	t := s.broker.topicLocked(s.topic)
+	if s.next < len(t.messages) {
+		return t.messages[s.next], true, nil
+	}
+	return Message{}, false, t.appended
+}
+
+// commit подтверждает сообщение offset и все до него, как коммит смещения в Kafka.
+func (s *channelSubscriber) commit(offset int) {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	t := s.broker.topicLocked(s.topic)
+	if offset+1 > t.committed[s.group] {
+		t.committed[s.group] = offset + 1
+	}
+}
+
+func (s *channelSubscriber) Close() {}
@@ -0,0 +1,140 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order_service/internal/audit"
+)
+
+// receiveN читает сообщения, пока их не наберется n, и возвращает значения.
+func receiveN(t *testing.T, s Subscriber, n int, handle func(msg Message) error) []string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var got []string
+	err := s.Receive(ctx, func(ctx context.Context, msg Message) error {
+		got = append(got, string(msg.Value))
+		if len(got) == n {
+			cancel()
+		}
+		return handle(msg)
+	})
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	if len(got) != n {
+		t.Fatalf("received %d messages, want %d", len(got), n)
+	}
+	return got
+}
+
+func publish(t *testing.T, b *ChannelBroker, values ...string) {
+	t.Helper()
+	p, err := b.NewPublisher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range values {
+		if err := p.Publish(context.Background(), []byte("key"), []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func ok(Message) error { return nil }
+
+func TestChannelBrokerDeliversAndCommits(t *testing.T) {
+	b := NewChannelBroker("orders")
+	publish(t, b, "m0", "m1")
+
+	s, err := b.NewSubscriber(Subscription{Topic: "orders", Group: "g", OffsetReset: OffsetEarliest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg Message
+	got := receiveN(t, s, 2, func(m Message) error { msg = m; return nil })
+	if got[0] != "m0" || got[1] != "m1" {
+		t.Fatalf("got %v", got)
+	}
+	if msg.Topic != "orders" || msg.ID != "orders/1" || msg.Source != audit.SourceChannel || string(msg.Key) != "key" {
+		t.Errorf("message = %+v", msg)
+	}
+	if c := b.Committed("orders", "g"); c != 2 {
+		t.Errorf("committed = %d, want 2", c)
+	}
+}
+
+func TestChannelBrokerGroupResumesFromCommitted(t *testing.T) {
+	b := NewChannelBroker("orders")
+	publish(t, b, "m0", "m1", "m2")
+
+	// m1 не обработан, но коммит m2 сдвигает смещение и за него
+	s, _ := b.NewSubscriber(Subscription{Topic: "orders", Group: "g", OffsetReset: OffsetEarliest})
+	receiveN(t, s, 2, func(m Message) error {
+		if string(m.Value) == "m1" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	if c := b.Committed("orders", "g"); c != 1 {
+		t.Fatalf("committed = %d, want 1", c)
+	}
+
+	s, _ = b.NewSubscriber(Subscription{Topic: "orders", Group: "g", OffsetReset: OffsetEarliest})
+	if got := receiveN(t, s, 2, ok); got[0] != "m1" || got[1] != "m2" {
+		t.Fatalf("resumed with %v, want [m1 m2]", got)
+	}
+	if c := b.Committed("orders", "g"); c != 3 {
+		t.Errorf("committed = %d, want 3", c)
+	}
+
+	// Другая группа читает топик независимо
+	s, _ = b.NewSubscriber(Subscription{Topic: "orders", Group: "other", OffsetReset: OffsetEarliest})
+	if got := receiveN(t, s, 3, ok); got[0] != "m0" {
+		t.Fatalf("other group got %v", got)
+	}
+}
+
+func TestChannelBrokerOffsetLatest(t *testing.T) {
+	b := NewChannelBroker("orders")
+	publish(t, b, "old")
+
+	s, _ := b.NewSubscriber(Subscription{Topic: "orders", Group: "g", OffsetReset: OffsetLatest})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		publish(t, b, "new")
+	}()
+	if got := receiveN(t, s, 1, ok); got[0] != "new" {
+		t.Fatalf("got %v, want [new]", got)
+	}
+}
+
+func TestChannelPublisherCopiesAndChecksContext(t *testing.T) {
+	b := NewChannelBroker("orders")
+	p, _ := b.NewPublisher()
+
+	value := []byte("v1")
+	if err := p.PublishTo(context.Background(), "dlq", nil, value, Header{Key: DLQErrorHeader, Value: []byte("boom")}); err != nil {
+		t.Fatal(err)
+	}
+	value[1] = '2'
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.Publish(ctx, nil, []byte("x")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Publish with canceled ctx: err = %v", err)
+	}
+
+	s, _ := b.NewSubscriber(Subscription{Topic: "dlq", Group: "g", OffsetReset: OffsetEarliest})
+	var msg Message
+	receiveN(t, s, 1, func(m Message) error { msg = m; return nil })
+	if string(msg.Value) != "v1" || len(msg.Headers) != 1 || string(msg.Headers[0].Value) != "boom" {
+		t.Errorf("message = %+v", msg)
+	}
+	if _, ok := b.topics["orders"]; ok {
+		t.Error("canceled publish reached the topic")
+	}
+}
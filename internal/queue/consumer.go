@@ -2,102 +2,202 @@ package queue
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
+	"order_service/internal/audit"
 	"order_service/internal/config"
-
-	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"order_service/internal/domain"
 )
 
 type OrderHandler interface {
 	HandleOrder(ctx context.Context, message []byte) error
 }
 
-type KafkaConsumer interface {
+// Consumer читает заказы из брокера и передает их OrderHandler.
+type Consumer interface {
 	Start()
 	Stop()
 }
 
-type kafkaConsumer struct {
-	consumer *kafka.Consumer
-	config   *config.Config
-	handler  OrderHandler
-	wg       *sync.WaitGroup
-	ctx      context.Context
-	cancel   context.CancelFunc
+// receiveRetryDelay — пауза перед повторной подпиской после ошибки брокера.
+const receiveRetryDelay = time.Second
+
+// Паузы перед повторной обработкой сообщения после временной ошибки: удваиваются до максимума.
+const (
+	handleRetryDelay    = 100 * time.Millisecond
+	maxHandleRetryDelay = 30 * time.Second
+)
+
+type consumer struct {
+	broker       Broker
+	subscription Subscription
+	// subscriber — текущий подписчик, nil пока не удалось подписаться заново. Меняется только в Start
+	subscriber Subscriber
+	handler    OrderHandler
+	dlq        Publisher
+	dlqTopic   string
+	retryDelay time.Duration
+	wg         *sync.WaitGroup
+	ctx        context.Context
+	cancel     context.CancelFunc
 }
 
-// Инициализирует новый консюмер
-func NewKafkaConsumer(handler OrderHandler, config *config.Config) (KafkaConsumer, error) {
-	cfg := &kafka.ConfigMap{
-		"bootstrap.servers": config.Kafka.Adress,
-		"group.id":          config.Kafka.GroupId,
-		"auto.offset.reset": config.Kafka.OffsetReset,
+// Инициализирует новый консюмер топика заказов
+func NewConsumer(handler OrderHandler, config *config.Config, broker Broker) (Consumer, error) {
+	subscription := Subscription{
+		Topic:       config.Kafka.Topic,
+		Group:       config.Kafka.GroupId,
+		OffsetReset: config.Kafka.OffsetReset,
 	}
-
-	consumer, err := kafka.NewConsumer(cfg)
+	subscriber, err := broker.NewSubscriber(subscription)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create consumer: %w", err)
+		return nil, fmt.Errorf("failed to subscribe to topic: %w", err)
 	}
 
-	err = consumer.SubscribeTopics([]string{config.Kafka.Topic}, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to subscribe to topics: %w", err)
+	// Издатель для отправки необработанных сообщений в DLQ
+	var dlq Publisher
+	if config.Kafka.DlqTopic != "" {
+		dlq, err = broker.NewPublisher()
+		if err != nil {
+			subscriber.Close()
+			return nil, fmt.Errorf("failed to create dlq publisher: %w", err)
+		}
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &kafkaConsumer{
-		consumer: consumer,
-		config:   config,
-		handler:  handler,
-		wg:       &sync.WaitGroup{},
-		ctx:      ctx,
-		cancel:   cancel,
+	return &consumer{
+		broker:       broker,
+		subscription: subscription,
+		subscriber:   subscriber,
+		handler:      handler,
+		dlq:          dlq,
+		dlqTopic:     config.Kafka.DlqTopic,
+		retryDelay:   handleRetryDelay,
+		wg:           &sync.WaitGroup{},
+		ctx:          ctx,
+		cancel:       cancel,
 	}, nil
 }
 
-// Стартует работу консюмера
-func (k *kafkaConsumer) Start() {
-	k.wg.Add(1)
+// Стартует работу консюмера. Ошибка Receive означает, что подписчик непригоден (например, фатальная
+// ошибка librdkafka): он закрывается, и после паузы консюмер подписывается заново.
+func (c *consumer) Start() {
+	c.wg.Add(1)
 	go func() {
-		defer k.wg.Done()
-		for {
-			select {
-			case <-k.ctx.Done():
-				return
-			default:
-				event := k.consumer.Poll(100)
-				if event == nil {
-					continue
-				}
-				k.consume(event)
+		defer c.wg.Done()
+		for c.ctx.Err() == nil {
+			if c.subscriber == nil && !c.resubscribe() {
+				c.wait()
+				continue
+			}
+			err := c.subscriber.Receive(c.ctx, c.consume)
+			if err == nil {
+				continue
 			}
+			log.Printf("Broker error, resubscribing: %v", err)
+			c.subscriber.Close()
+			c.subscriber = nil
+			c.wait()
 		}
 	}()
 }
 
+// resubscribe создает нового подписчика той же группы. Чтение продолжается с подтвержденного смещения.
+func (c *consumer) resubscribe() bool {
+	subscriber, err := c.broker.NewSubscriber(c.subscription)
+	if err != nil {
+		log.Printf("Failed to resubscribe to topic: %v", err)
+		return false
+	}
+	c.subscriber = subscriber
+	return true
+}
+
+// wait ждет паузу перед повторной подпиской или остановку консюмера.
+func (c *consumer) wait() {
+	select {
+	case <-c.ctx.Done():
+	case <-time.After(receiveRetryDelay):
+	}
+}
+
 // Останавливает работу консюмера
-func (k *kafkaConsumer) Stop() {
-	k.cancel()
-	k.wg.Wait()
-	k.consumer.Close()
+func (c *consumer) Stop() {
+	c.cancel()
+	c.wg.Wait()
+	if c.subscriber != nil {
+		c.subscriber.Close()
+	}
+	if c.dlq != nil {
+		c.dlq.Close()
+	}
 }
 
-// Обрабатывает событие очереди
-func (k *kafkaConsumer) consume(event kafka.Event) {
-	switch e := event.(type) {
-	case *kafka.Message:
-		err := k.handler.HandleOrder(k.ctx, e.Value) // При отмене контекста транзакция бд ролбекнится, кафка не закомитится
-		if err != nil {
+// Обрабатывает сообщение. nil — сообщение обработано или переложено в DLQ и его можно подтвердить.
+// В DLQ попадают только сообщения, которые не обработать и повторно. При временной ошибке, например
+// недоступной БД, обработка повторяется, пока не получится или не остановят консюмер: следующее
+// сообщение не читается, и его подтверждение не сдвинет смещение за необработанное.
+func (c *consumer) consume(ctx context.Context, msg Message) error {
+	ctx = audit.WithMeta(ctx, messageMeta(msg))
+	delay := c.retryDelay
+	for {
+		err := c.handler.HandleOrder(ctx, msg.Value) // При отмене контекста транзакция бд ролбекнится, сообщение не подтвердится
+		if err == nil {
+			return nil
+		}
+		if permanentError(err) {
 			log.Printf("Failed handle order: %s", err)
-			return
+			return c.sendToDLQ(ctx, msg, err)
+		}
+		log.Printf("Failed handle order, retrying in %s: %s", delay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
 		}
-		k.consumer.CommitMessage(e)
-	case kafka.Error:
-		log.Printf("Kafka error: %v", e)
-		return
+		delay = min(2*delay, maxHandleRetryDelay)
+	}
+}
+
+// permanentError сообщает, что сообщение не обработать и повторно: оно не проходит схему,
+// заказ некорректен или уже сохранен.
+func permanentError(err error) bool {
+	return errors.Is(err, domain.ErrInvalidMessage) ||
+		errors.Is(err, domain.ErrInvalidOrder) ||
+		errors.Is(err, domain.ErrOrderUIDEmpty) ||
+		errors.Is(err, domain.ErrOrderUIDNotUnique)
+}
+
+// messageMeta описывает сообщение для журнала аудита: ID однозначно его идентифицирует.
+func messageMeta(msg Message) audit.Meta {
+	return audit.Meta{
+		Actor:     msg.Source + ":" + msg.Topic,
+		Source:    msg.Source,
+		RequestID: msg.ID,
+	}
+}
+
+// Перекладывает необработанное сообщение в DLQ. nil — сообщение можно подтверждать.
+func (c *consumer) sendToDLQ(ctx context.Context, msg Message, handleErr error) error {
+	if c.dlq == nil {
+		return handleErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	headers := make([]Header, 0, len(msg.Headers)+1)
+	headers = append(headers, msg.Headers...)
+	headers = append(headers, Header{Key: DLQErrorHeader, Value: []byte(handleErr.Error())})
+	err := c.dlq.PublishTo(ctx, c.dlqTopic, msg.Key, msg.Value, headers...)
+	if err != nil {
+		log.Printf("Failed to send message to DLQ: %s", err)
+		return errors.Join(handleErr, err)
 	}
+	return nil
 }
@@ -0,0 +1,278 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"order_service/internal/audit"
+	"order_service/internal/config"
+	"order_service/internal/domain"
+)
+
+// recordingHandler запоминает обработанные заказы и падает на заказах из fail.
+// Первые transient вызовов завершаются временной ошибкой.
+type recordingHandler struct {
+	mu        sync.Mutex
+	handled   []string
+	actors    []string
+	fail      map[string]bool
+	transient int
+	calls     int
+}
+
+var errDBDown = errors.New("failed to connect to postgres")
+
+func (h *recordingHandler) HandleOrder(ctx context.Context, message []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls++
+	if h.calls <= h.transient {
+		return errDBDown
+	}
+	if h.fail[string(message)] {
+		return fmt.Errorf("%w: payments.amount must be non-negative", domain.ErrInvalidOrder)
+	}
+	h.handled = append(h.handled, string(message))
+	h.actors = append(h.actors, audit.FromContext(ctx).Actor)
+	return nil
+}
+
+func (h *recordingHandler) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.handled)
+}
+
+// failingBroker — брокер, первый подписчик которого ломается после первого сообщения,
+// как консюмер Kafka после фатальной ошибки.
+type failingBroker struct {
+	*ChannelBroker
+	mu          sync.Mutex
+	subscribers int
+	closed      int
+}
+
+func (b *failingBroker) NewSubscriber(sub Subscription) (Subscriber, error) {
+	s, err := b.ChannelBroker.NewSubscriber(sub)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers++
+	return &failingSubscriber{Subscriber: s, broker: b, broken: b.subscribers == 1}, nil
+}
+
+func (b *failingBroker) stats() (subscribers, closed int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.subscribers, b.closed
+}
+
+type failingSubscriber struct {
+	Subscriber
+	broker *failingBroker
+	broken bool
+}
+
+var errFatal = errors.New("fatal broker error")
+
+func (s *failingSubscriber) Receive(ctx context.Context, handle func(ctx context.Context, msg Message) error) error {
+	if !s.broken {
+		return s.Subscriber.Receive(ctx, handle)
+	}
+	// Сломанный подписчик обрабатывает одно сообщение и больше ничего не читает
+	receiveCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.Subscriber.Receive(receiveCtx, func(ctx context.Context, msg Message) error {
+		defer cancel()
+		return handle(ctx, msg)
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	return errFatal
+}
+
+func (s *failingSubscriber) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.closed++
+}
+
+func testConfig(dlqTopic string) *config.Config {
+	return &config.Config{Kafka: config.Kafka{
+		Topic:       "orders",
+		GroupId:     "order-consumer-group",
+		OffsetReset: OffsetEarliest,
+		DlqTopic:    dlqTopic,
+	}}
+}
+
+// newTestConsumer создает консюмер с короткой паузой перед повторной обработкой.
+func newTestConsumer(t *testing.T, h OrderHandler, cfg *config.Config, b Broker) Consumer {
+	t.Helper()
+	c, err := NewConsumer(h, cfg, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.(*consumer).retryDelay = time.Millisecond
+	return c
+}
+
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConsumerHandlesAndSendsFailuresToDLQ(t *testing.T) {
+	b := NewChannelBroker("orders")
+	publish(t, b, "o1", "bad", "o2")
+
+	h := &recordingHandler{fail: map[string]bool{"bad": true}}
+	c := newTestConsumer(t, h, testConfig("orders-dlq"), b)
+	c.Start()
+	waitFor(t, 2*time.Second, func() bool { return b.Committed("orders", "order-consumer-group") == 3 })
+	c.Stop()
+
+	if h.count() != 2 || h.handled[0] != "o1" || h.handled[1] != "o2" {
+		t.Fatalf("handled %v", h.handled)
+	}
+	if h.actors[0] != audit.SourceChannel+":orders" {
+		t.Errorf("actor = %q", h.actors[0])
+	}
+
+	dlq, _ := b.NewSubscriber(Subscription{Topic: "orders-dlq", Group: "check", OffsetReset: OffsetEarliest})
+	var msg Message
+	receiveN(t, dlq, 1, func(m Message) error { msg = m; return nil })
+	if string(msg.Value) != "bad" || len(msg.Headers) != 1 || msg.Headers[0].Key != DLQErrorHeader ||
+		string(msg.Headers[0].Value) != "invalid order data: payments.amount must be non-negative" {
+		t.Errorf("dlq message = %+v", msg)
+	}
+}
+
+func TestConsumerWithoutDLQLeavesFailedMessageUncommitted(t *testing.T) {
+	b := NewChannelBroker("orders")
+	publish(t, b, "bad")
+
+	h := &recordingHandler{fail: map[string]bool{"bad": true}}
+	c := newTestConsumer(t, h, testConfig(""), b)
+	c.Start()
+	time.Sleep(20 * time.Millisecond)
+	c.Stop()
+
+	if got := b.Committed("orders", "order-consumer-group"); got != 0 {
+		t.Fatalf("committed = %d, want 0", got)
+	}
+}
+
+func TestConsumerResubscribesAfterBrokerError(t *testing.T) {
+	b := &failingBroker{ChannelBroker: NewChannelBroker("orders")}
+	publish(t, b.ChannelBroker, "o1", "o2")
+
+	h := &recordingHandler{}
+	c := newTestConsumer(t, h, testConfig(""), b)
+	c.Start()
+	// Сломанный подписчик закрывается, новый продолжает с подтвержденного смещения
+	waitFor(t, 3*time.Second, func() bool { return h.count() == 2 })
+	c.Stop()
+
+	if h.handled[0] != "o1" || h.handled[1] != "o2" {
+		t.Fatalf("handled %v", h.handled)
+	}
+	if subscribers, closed := b.stats(); subscribers != 2 || closed != 2 {
+		t.Errorf("subscribers = %d, closed = %d, want 2 and 2", subscribers, closed)
+	}
+}
+
+func TestReplayDLQ(t *testing.T) {
+	b := NewChannelBroker("orders")
+	p, _ := b.NewPublisher()
+	for _, v := range []string{"d1", "d2"} {
+		if err := p.PublishTo(context.Background(), "orders-dlq", nil, []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := ReplayDLQ(context.Background(), testConfig("orders-dlq"), b, p, 20*time.Millisecond)
+	if err != nil || n != 2 {
+		t.Fatalf("ReplayDLQ = %d, %v; want 2, nil", n, err)
+	}
+	s, _ := b.NewSubscriber(Subscription{Topic: "orders", Group: "check", OffsetReset: OffsetEarliest})
+	if got := receiveN(t, s, 2, ok); got[0] != "d1" || got[1] != "d2" {
+		t.Fatalf("replayed %v", got)
+	}
+
+	// Повторный реплей ничего не переотправляет: сообщения DLQ подтверждены
+	if n, err := ReplayDLQ(context.Background(), testConfig("orders-dlq"), b, p, 20*time.Millisecond); n != 0 {
+		t.Fatalf("second ReplayDLQ = %d, %v", n, err)
+	}
+}
+
+// Временная ошибка не отправляет сообщение в DLQ и не подтверждает его: обработка повторяется.
+func TestConsumerRetriesTransientErrors(t *testing.T) {
+	b := NewChannelBroker("orders")
+	publish(t, b, "o1", "o2")
+
+	h := &recordingHandler{transient: 3}
+	c := newTestConsumer(t, h, testConfig("orders-dlq"), b)
+	c.Start()
+	waitFor(t, 2*time.Second, func() bool { return b.Committed("orders", "order-consumer-group") == 2 })
+	c.Stop()
+
+	if len(h.handled) != 2 || h.handled[0] != "o1" || h.handled[1] != "o2" || h.calls != 5 {
+		t.Fatalf("handled %v in %d calls", h.handled, h.calls)
+	}
+	if _, ok := b.topics["orders-dlq"]; ok {
+		t.Error("transient failure reached the DLQ")
+	}
+}
+
+// Остановка во время повторов оставляет сообщение неподтвержденным.
+func TestConsumerStopDuringRetryLeavesMessageUncommitted(t *testing.T) {
+	b := NewChannelBroker("orders")
+	publish(t, b, "o1")
+
+	h := &recordingHandler{transient: 1 << 30}
+	c := newTestConsumer(t, h, testConfig("orders-dlq"), b)
+	c.Start()
+	waitFor(t, 2*time.Second, func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return h.calls >= 3
+	})
+	c.Stop()
+
+	if got := b.Committed("orders", "order-consumer-group"); got != 0 {
+		t.Fatalf("committed = %d, want 0", got)
+	}
+}
+
+func TestPermanentError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("failed to decode order message: %w", domain.ErrInvalidMessage), true},
+		{fmt.Errorf("failed create order o1: %w", domain.ErrInvalidOrder), true},
+		{fmt.Errorf("failed create order o1: %w", domain.ErrOrderUIDNotUnique), true},
+		{domain.ErrOrderUIDEmpty, true},
+		{fmt.Errorf("failed create order o1: %w", errDBDown), false},
+		{context.DeadlineExceeded, false},
+	}
+	for _, tt := range tests {
+		if got := permanentError(tt.err); got != tt.want {
+			t.Errorf("permanentError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
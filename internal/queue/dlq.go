@@ -0,0 +1,64 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"order_service/internal/config"
+)
+
+// DLQErrorHeader — заголовок сообщения в DLQ с текстом ошибки обработки.
+const DLQErrorHeader = "x-dlq-error"
+
+// dlqReplayGroupSuffix отделяет группу реплея от основной группы консюмеров.
+const dlqReplayGroupSuffix = "-dlq-replay"
+
+// ReplayDLQ перечитывает DLQ и публикует сообщения обратно в основной топик.
+// Работает, пока в DLQ есть сообщения; останавливается, если новых нет дольше idle.
+// Сообщение DLQ подтверждается только после публикации. Возвращает число переотправленных сообщений.
+func ReplayDLQ(ctx context.Context, cfg *config.Config, broker Broker, publisher Publisher, idle time.Duration) (int, error) {
+	if cfg.Kafka.DlqTopic == "" {
+		return 0, errors.New("dlq topic is not configured")
+	}
+
+	subscriber, err := broker.NewSubscriber(Subscription{
+		Topic:       cfg.Kafka.DlqTopic,
+		Group:       cfg.Kafka.GroupId + dlqReplayGroupSuffix,
+		OffsetReset: OffsetEarliest,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to subscribe to dlq: %w", err)
+	}
+	defer subscriber.Close()
+
+	receiveCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	idleTimer := time.AfterFunc(idle, stop)
+	defer idleTimer.Stop()
+
+	replayed := 0
+	var publishErr error
+	err = subscriber.Receive(receiveCtx, func(ctx context.Context, msg Message) error {
+		if !idleTimer.Stop() {
+			return context.Canceled
+		}
+		if err := publisher.Publish(ctx, msg.Key, msg.Value); err != nil {
+			publishErr = err
+			stop()
+			return err
+		}
+		replayed++
+		idleTimer.Reset(idle)
+		return nil
+	})
+	switch {
+	case publishErr != nil:
+		return replayed, publishErr
+	case err != nil:
+		return replayed, err
+	default:
+		return replayed, ctx.Err()
+	}
+}
@@ -0,0 +1,92 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"order_service/internal/audit"
+	"order_service/internal/config"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// kafkaBroker — Kafka: у каждого издателя и подписчика свое подключение.
+type kafkaBroker struct {
+	cfg *config.Config
+}
+
+func (b *kafkaBroker) NewPublisher() (Publisher, error) {
+	return NewKafkaProducer(b.cfg)
+}
+
+// NewSubscriber подписывает консюмер группы на топик. Смещения коммитятся только явно,
+// после успешной обработки сообщения.
+func (b *kafkaBroker) NewSubscriber(sub Subscription) (Subscriber, error) {
+	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":  b.cfg.Kafka.Adress,
+		"group.id":           sub.Group,
+		"auto.offset.reset":  sub.OffsetReset,
+		"enable.auto.commit": false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
+	}
+	if err := consumer.SubscribeTopics([]string{sub.Topic}, nil); err != nil {
+		consumer.Close()
+		return nil, fmt.Errorf("failed to subscribe to topics: %w", err)
+	}
+	return &kafkaSubscriber{consumer: consumer}, nil
+}
+
+func (b *kafkaBroker) Close() {}
+
+type kafkaSubscriber struct {
+	consumer *kafka.Consumer
+}
+
+// Receive коммитит смещение сообщения после успешной обработки. Необработанное сообщение
+// не коммитится, но чтение идет дальше: коммит следующего сообщения сдвигает смещение и за него.
+func (s *kafkaSubscriber) Receive(ctx context.Context, handle func(ctx context.Context, msg Message) error) error {
+	for ctx.Err() == nil {
+		switch e := s.consumer.Poll(100).(type) {
+		case *kafka.Message:
+			if err := handle(ctx, kafkaMessage(e)); err != nil {
+				continue
+			}
+			if _, err := s.consumer.CommitMessage(e); err != nil {
+				log.Printf("Failed to commit kafka offset: %v", err)
+			}
+		case kafka.Error:
+			log.Printf("Kafka error: %v", e)
+			if e.IsFatal() {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+func (s *kafkaSubscriber) Close() {
+	s.consumer.Close()
+}
+
+// kafkaMessage переводит сообщение Kafka в Message. Топик, партиция и смещение однозначно его идентифицируют.
+func kafkaMessage(msg *kafka.Message) Message {
+	topic := ""
+	if msg.TopicPartition.Topic != nil {
+		topic = *msg.TopicPartition.Topic
+	}
+	headers := make([]Header, len(msg.Headers))
+	for i, h := range msg.Headers {
+		headers[i] = Header{Key: h.Key, Value: h.Value}
+	}
+	return Message{
+		Topic:   topic,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+		ID:      fmt.Sprintf("%s/%d/%d", topic, msg.TopicPartition.Partition, msg.TopicPartition.Offset),
+		Source:  audit.SourceKafka,
+	}
+}
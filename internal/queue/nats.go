@@ -0,0 +1,176 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"order_service/internal/audit"
+	"order_service/internal/config"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// natsKeyHeader — заголовок с ключом сообщения: в NATS у сообщения нет отдельного ключа.
+const natsKeyHeader = "x-message-key"
+
+// natsSetupTimeout ограничивает создание потока и консюмеров.
+const natsSetupTimeout = 10 * time.Second
+
+// NATSBroker — NATS JetStream. Топики — субъекты потока broker.nats.stream, группа подписчиков —
+// долговременный консюмер с явным подтверждением. Издатели и подписчики делят одно подключение.
+type NATSBroker struct {
+	conn *nats.Conn
+	js   jetstream.JetStream
+	cfg  *config.Config
+}
+
+// NewNATSBroker подключается к NATS и создает или обновляет поток с топиком заказов и DLQ.
+func NewNATSBroker(cfg *config.Config) (*NATSBroker, error) {
+	conn, err := nats.Connect(cfg.Broker.Nats.Adress, nats.Name("order-service"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to nats: %w", err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open jetstream: %w", err)
+	}
+
+	subjects := []string{cfg.Kafka.Topic}
+	if cfg.Kafka.DlqTopic != "" {
+		subjects = append(subjects, cfg.Kafka.DlqTopic)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), natsSetupTimeout)
+	defer cancel()
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     cfg.Broker.Nats.Stream,
+		Subjects: subjects,
+		Storage:  jetstream.FileStorage,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create stream %s: %w", cfg.Broker.Nats.Stream, err)
+	}
+	return &NATSBroker{conn: conn, js: js, cfg: cfg}, nil
+}
+
+func (b *NATSBroker) NewPublisher() (Publisher, error) {
+	return &natsPublisher{js: b.js, topic: b.cfg.Kafka.Topic}, nil
+}
+
+// NewSubscriber создает или обновляет долговременный консюмер группы. Неподтвержденное
+// сообщение доставляется снова, всего не более broker.nats.max_deliver раз.
+func (b *NATSBroker) NewSubscriber(sub Subscription) (Subscriber, error) {
+	deliver := jetstream.DeliverAllPolicy
+	if sub.OffsetReset == OffsetLatest {
+		deliver = jetstream.DeliverNewPolicy
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), natsSetupTimeout)
+	defer cancel()
+	consumer, err := b.js.CreateOrUpdateConsumer(ctx, b.cfg.Broker.Nats.Stream, jetstream.ConsumerConfig{
+		Durable:       sub.Group,
+		FilterSubject: sub.Topic,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		DeliverPolicy: deliver,
+		AckWait:       b.cfg.Broker.Nats.AckWait,
+		MaxDeliver:    b.cfg.Broker.Nats.MaxDeliver,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer %s: %w", sub.Group, err)
+	}
+	return &natsSubscriber{consumer: consumer}, nil
+}
+
+// Close дожидается отправки буферизованных сообщений и закрывает подключение.
+func (b *NATSBroker) Close() {
+	if err := b.conn.Drain(); err != nil {
+		b.conn.Close()
+	}
+}
+
+type natsPublisher struct {
+	js    jetstream.JetStream
+	topic string
+}
+
+func (p *natsPublisher) Publish(ctx context.Context, key, value []byte) error {
+	return p.PublishTo(ctx, p.topic, key, value)
+}
+
+func (p *natsPublisher) PublishTo(ctx context.Context, topic string, key, value []byte, headers ...Header) error {
+	msg := nats.NewMsg(topic)
+	msg.Data = value
+	for _, h := range headers {
+		msg.Header.Add(h.Key, string(h.Value))
+	}
+	if key != nil {
+		msg.Header.Set(natsKeyHeader, string(key))
+	}
+	if _, err := p.js.PublishMsg(ctx, msg); err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	return nil
+}
+
+// Close ничего не делает: подключение принадлежит NATSBroker.
+func (p *natsPublisher) Close() {}
+
+type natsSubscriber struct {
+	consumer jetstream.Consumer
+}
+
+// Receive подтверждает сообщение после успешной обработки и ждет ответа сервера, необработанное
+// отклоняет: сервер доставит его снова.
+func (s *natsSubscriber) Receive(ctx context.Context, handle func(ctx context.Context, msg Message) error) error {
+	messages, err := s.consumer.Messages()
+	if err != nil {
+		return fmt.Errorf("failed to pull messages: %w", err)
+	}
+	defer messages.Stop()
+	stop := context.AfterFunc(ctx, messages.Stop)
+	defer stop()
+
+	for {
+		msg, err := messages.Next()
+		if errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := handle(ctx, natsMessage(msg)); err != nil {
+			if err := msg.Nak(); err != nil {
+				log.Printf("Failed to nak nats message: %v", err)
+			}
+			continue
+		}
+		if err := msg.DoubleAck(ctx); err != nil {
+			log.Printf("Failed to ack nats message: %v", err)
+		}
+	}
+}
+
+// Close ничего не делает: консюмер долговременный, подключение принадлежит NATSBroker.
+func (s *natsSubscriber) Close() {}
+
+// natsMessage переводит сообщение JetStream в Message. Поток и номер в нем однозначно его идентифицируют.
+func natsMessage(msg jetstream.Msg) Message {
+	m := Message{Topic: msg.Subject(), Value: msg.Data(), Source: audit.SourceNATS}
+	for name, values := range msg.Headers() {
+		for _, value := range values {
+			if name == natsKeyHeader {
+				m.Key = []byte(value)
+				continue
+			}
+			m.Headers = append(m.Headers, Header{Key: name, Value: []byte(value)})
+		}
+	}
+	if meta, err := msg.Metadata(); err == nil {
+		m.ID = fmt.Sprintf("%s/%d", meta.Stream, meta.Sequence.Stream)
+	}
+	return m
+}
@@ -1,6 +1,9 @@
 package queue
 
 import (
+	"context"
+	"fmt"
+
 	"order_service/internal/config"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
@@ -16,3 +19,58 @@ func StartKafkaProducer(cfg *config.Config) (*kafka.Producer, error) {
 	}
 	return producer, nil
 }
+
+type kafkaProducer struct {
+	producer *kafka.Producer
+	topic    string
+}
+
+// NewKafkaProducer создает продюсер для топика заказов из конфига.
+func NewKafkaProducer(cfg *config.Config) (Publisher, error) {
+	producer, err := StartKafkaProducer(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create producer: %w", err)
+	}
+	return &kafkaProducer{producer: producer, topic: cfg.Kafka.Topic}, nil
+}
+
+func (p *kafkaProducer) Publish(ctx context.Context, key, value []byte) error {
+	return p.PublishTo(ctx, p.topic, key, value)
+}
+
+func (p *kafkaProducer) PublishTo(ctx context.Context, topic string, key, value []byte, headers ...Header) error {
+	kafkaHeaders := make([]kafka.Header, len(headers))
+	for i, h := range headers {
+		kafkaHeaders[i] = kafka.Header{Key: h.Key, Value: h.Value}
+	}
+	delivery := make(chan kafka.Event, 1)
+	err := p.producer.Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            key,
+		Value:          value,
+		Headers:        kafkaHeaders,
+	}, delivery)
+	if err != nil {
+		return fmt.Errorf("failed to produce message: %w", err)
+	}
+
+	// Ждем отчет о доставке
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-delivery:
+		m, ok := e.(*kafka.Message)
+		if !ok {
+			return fmt.Errorf("unexpected delivery event: %v", e)
+		}
+		if m.TopicPartition.Error != nil {
+			return fmt.Errorf("failed to deliver message: %w", m.TopicPartition.Error)
+		}
+		return nil
+	}
+}
+
+func (p *kafkaProducer) Close() {
+	p.producer.Flush(5000)
+	p.producer.Close()
+}
@@ -0,0 +1,32 @@
+package render
+
+import (
+	"fmt"
+	"io"
+
+	"order_service/internal/domain"
+	"order_service/internal/orderpb"
+)
+
+// protobufCodec кодирует заказы по схеме api/proto/order.proto.
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string        { return "protobuf" }
+func (protobufCodec) ContentType() string { return "application/x-protobuf" }
+
+// Encode поддерживает только заказ и список заказов.
+func (protobufCodec) Encode(w io.Writer, v any) error {
+	var b []byte
+	switch val := v.(type) {
+	case *domain.Order:
+		b = orderpb.MarshalOrder(val)
+	case domain.Order:
+		b = orderpb.MarshalOrder(&val)
+	case []domain.Order:
+		b = orderpb.MarshalOrders(val)
+	default:
+		return fmt.Errorf("%w: protobuf, %T", ErrUnsupportedValue, v)
+	}
+	_, err := w.Write(b)
+	return err
+}
@@ -0,0 +1,100 @@
+// Package render кодирует ответы API в формат, выбранный по заголовку Accept.
+package render
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ErrUnsupportedValue возвращается кодеком, который не умеет кодировать значение данного типа.
+var ErrUnsupportedValue = errors.New("value is not supported by codec")
+
+// Codec кодирует ответ в один формат.
+type Codec interface {
+	// Name — короткое имя формата, используется в ETag.
+	Name() string
+	ContentType() string
+	Encode(w io.Writer, v any) error
+}
+
+// Форматы ответов.
+var (
+	JSON     Codec = jsonCodec{}
+	MsgPack  Codec = msgpackCodec{}
+	Protobuf Codec = protobufCodec{}
+)
+
+// byMediaType — поддерживаемые типы Accept. Для MessagePack и protobuf нет единого
+// зарегистрированного типа, поэтому принимаются распространенные варианты.
+var byMediaType = map[string]Codec{
+	"application/json":        JSON,
+	"application/msgpack":     MsgPack,
+	"application/x-msgpack":   MsgPack,
+	"application/vnd.msgpack": MsgPack,
+	"application/protobuf":    Protobuf,
+	"application/x-protobuf":  Protobuf,
+}
+
+// Negotiate выбирает кодек по заголовку Accept с учетом q-значений.
+// Без заголовка, для */* и для неподдерживаемых типов ответ остается в JSON.
+func Negotiate(accept string) Codec {
+	type candidate struct {
+		codec Codec
+		q     float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		codec, ok := byMediaType[mediaType]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{codec, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return JSON
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	return candidates[0].codec
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string        { return "json" }
+func (jsonCodec) ContentType() string { return "application/json" }
+func (jsonCodec) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string        { return "msgpack" }
+func (msgpackCodec) ContentType() string { return "application/msgpack" }
+
+// Encode использует json-теги, чтобы имена полей совпадали с JSON-ответом.
+func (msgpackCodec) Encode(w io.Writer, v any) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	return enc.Encode(v)
+}
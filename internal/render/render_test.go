@@ -0,0 +1,88 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"order_service/internal/domain"
+	"order_service/internal/orderpb"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   Codec
+	}{
+		{"", JSON},
+		{"*/*", JSON},
+		{"text/html", JSON},
+		{"application/json", JSON},
+		{"application/msgpack", MsgPack},
+		{"application/x-msgpack", MsgPack},
+		{"application/vnd.msgpack", MsgPack},
+		{"application/protobuf", Protobuf},
+		{"application/x-protobuf", Protobuf},
+		{"Application/X-Protobuf", Protobuf},
+		{"application/x-protobuf; charset=binary", Protobuf},
+		{"text/html, application/msgpack, */*;q=0.8", MsgPack},
+		{"application/json;q=0.5, application/msgpack", MsgPack},
+		{"application/json, application/msgpack;q=0.9", JSON},
+		{"application/msgpack, application/x-protobuf", MsgPack},
+		{"application/x-protobuf;q=0.7, application/msgpack;q=0.7", Protobuf},
+		{"application/x-protobuf;q=0", JSON},
+		{"application/x-protobuf;q=abc, application/msgpack;q=0.1", MsgPack},
+		{"application/x-protobuf;;", JSON},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.accept); got != tt.want {
+			t.Errorf("Negotiate(%q) = %s, want %s", tt.accept, got.Name(), tt.want.Name())
+		}
+	}
+}
+
+func TestMsgPackUsesJSONNames(t *testing.T) {
+	order := domain.Order{OrderUID: "b563feb7b2b84b6test", SmID: 99}
+	var buf bytes.Buffer
+	if err := MsgPack.Encode(&buf, order); err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := msgpack.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["order_uid"] != order.OrderUID || decoded["sm_id"] != int8(99) {
+		t.Fatalf("decoded %v, want order_uid and sm_id keys", decoded)
+	}
+}
+
+func TestProtobufEncode(t *testing.T) {
+	order := domain.Order{OrderUID: "b563feb7b2b84b6test", Items: []domain.Item{{ChrtID: 1}}}
+	tests := []struct {
+		name string
+		v    any
+		want []byte
+	}{
+		{"pointer", &order, orderpb.MarshalOrder(&order)},
+		{"value", order, orderpb.MarshalOrder(&order)},
+		{"list", []domain.Order{order, order}, orderpb.MarshalOrders([]domain.Order{order, order})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Protobuf.Encode(&buf, tt.v); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), tt.want) {
+				t.Fatalf("encoded %x, want %x", buf.Bytes(), tt.want)
+			}
+		})
+	}
+
+	err := Protobuf.Encode(&bytes.Buffer{}, map[string]string{"error": "not found"})
+	if !errors.Is(err, ErrUnsupportedValue) {
+		t.Fatalf("encode map: want %v, got %v", ErrUnsupportedValue, err)
+	}
+}
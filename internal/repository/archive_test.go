@@ -0,0 +1,220 @@
+package repository_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"order_service/internal/config"
+	"order_service/internal/domain"
+	"order_service/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Выгрузка устаревших секций в архив. Проверки идут в отдельной схеме БД из postgresDSNEnv.
+
+// archiveEnv — схема с секциями старого месяца, в которой лежат заказы old, текущий заказ
+// current и заказ stale из секции по умолчанию.
+type archiveEnv struct {
+	pool       *pgxpool.Pool
+	repo       repository.OrderRepository
+	partitions *repository.PartitionManager
+	month      time.Time // старый месяц с секциями
+	now        time.Time // момент, на который старый месяц устарел, а текущий — нет
+	old        []*domain.Order
+	current    *domain.Order
+	stale      *domain.Order
+}
+
+// archiveRetention — срок хранения, при котором на момент archiveEnv.now устарел только старый месяц.
+const archiveRetention = 24 * time.Hour
+
+func newArchiveEnv(t *testing.T) *archiveEnv {
+	ctx := context.Background()
+	pool := openTestPostgres(t, pgx.QueryExecModeCacheStatement)
+
+	partitions, err := repository.NewPartitionManager(pool, config.DatabasePartitions{CheckInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Миграция создает секции с текущего месяца, секции старого месяца тест создает сам
+	thisMonth := time.Now().UTC()
+	thisMonth = time.Date(thisMonth.Year(), thisMonth.Month(), 1, 0, 0, 0, 0, time.UTC)
+	e := &archiveEnv{
+		pool:       pool,
+		repo:       repository.NewOrderRepository(pool, nil),
+		partitions: partitions,
+		month:      thisMonth.AddDate(0, -3, 0),
+		now:        thisMonth.AddDate(0, -1, 0),
+	}
+	if _, err := pool.Exec(ctx, `SELECT create_order_partitions($1, 1)`, e.month); err != nil {
+		t.Fatal(err)
+	}
+
+	gen, err := domain.NewGenerator(domain.GeneratorOptions{Seed: 1, ItemWeights: []domain.ItemWeight{{Count: 2, Weight: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(uid string, created time.Time) *domain.Order {
+		order := gen.Next()
+		order.OrderUID = uid
+		order.DateCreated = created
+		if err := e.repo.Create(ctx, &order); err != nil {
+			t.Fatalf("create %s: %v", uid, err)
+		}
+		return &order
+	}
+	for i := 0; i < 2; i++ {
+		e.old = append(e.old, create(fmt.Sprintf("archive-old-%d", i), e.month.Add(time.Duration(i+1)*24*time.Hour)))
+	}
+	e.current = create("archive-current", time.Now().Add(-time.Minute).Truncate(time.Microsecond))
+	e.stale = create("archive-stale", e.month.AddDate(-5, 0, 0))
+	return e
+}
+
+// partitionsExist сообщает, сколько из секций старого месяца есть в схеме.
+func (e *archiveEnv) partitionsExist(t *testing.T) int {
+	t.Helper()
+	n := 0
+	for _, table := range []string{"orders", "deliveries", "payments", "items"} {
+		var exists bool
+		name := table + "_" + e.month.Format("p200601")
+		if err := e.pool.QueryRow(context.Background(), `SELECT to_regclass($1) IS NOT NULL`, name).Scan(&exists); err != nil {
+			t.Fatal(err)
+		}
+		if exists {
+			n++
+		}
+	}
+	return n
+}
+
+// archiveBuffer — архив в памяти; closeErr имитирует сбой записи при Close.
+type archiveBuffer struct {
+	bytes.Buffer
+	closed   bool
+	closeErr error
+}
+
+func (b *archiveBuffer) Close() error {
+	b.closed = true
+	return b.closeErr
+}
+
+func TestPartitionExpired(t *testing.T) {
+	e := newArchiveEnv(t)
+	ctx := context.Background()
+
+	months, err := e.partitions.Expired(ctx, e.now, archiveRetention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(months) != 1 || !months[0].Equal(e.month) {
+		t.Fatalf("expired months %v, want %s", months, e.month)
+	}
+	if months, err = e.partitions.Expired(ctx, e.now, 0); err != nil || len(months) != 0 {
+		t.Fatalf("expired months without retention %v, %v, want none", months, err)
+	}
+
+	stale, err := e.partitions.ExpiredInDefault(ctx, e.now, archiveRetention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stale != 1 {
+		t.Fatalf("expired orders in default partition %d, want 1", stale)
+	}
+}
+
+func TestPartitionArchive(t *testing.T) {
+	e := newArchiveEnv(t)
+	ctx := context.Background()
+	const name = "orders-test.ndjson.gz"
+
+	var w archiveBuffer
+	n, err := e.partitions.Archive(ctx, e.month, name, &w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(e.old) || !w.closed {
+		t.Fatalf("archived %d orders, closed %v; want %d, closed", n, w.closed, len(e.old))
+	}
+
+	// Строки каждой таблицы заказа, и только заказов старого месяца
+	old := map[string]bool{}
+	for _, order := range e.old {
+		old[order.OrderUID] = true
+	}
+	counts := map[string]int{}
+	scanner := bufio.NewScanner(&w)
+	for scanner.Scan() {
+		var line struct {
+			Table string `json:"table"`
+			Row   struct {
+				OrderUID string `json:"order_uid"`
+			} `json:"row"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("archive line %q: %v", scanner.Text(), err)
+		}
+		if !old[line.Row.OrderUID] {
+			t.Fatalf("archive has %s row of order %q", line.Table, line.Row.OrderUID)
+		}
+		counts[line.Table]++
+	}
+	want := map[string]int{"orders": 2, "deliveries": 2, "payments": 2, "items": 4}
+	if fmt.Sprint(counts) != fmt.Sprint(want) {
+		t.Fatalf("archive rows per table %v, want %v", counts, want)
+	}
+
+	if got := e.partitionsExist(t); got != 0 {
+		t.Fatalf("%d partitions of archived month left", got)
+	}
+	for _, order := range e.old {
+		var archivedAt *time.Time
+		var archive *string
+		err := e.pool.QueryRow(ctx, `SELECT archived_at, archive FROM order_keys WHERE order_uid = $1`, order.OrderUID).
+			Scan(&archivedAt, &archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if archivedAt == nil || archive == nil || *archive != name {
+			t.Fatalf("order %s: archived_at %v, archive %v; want set to %s", order.OrderUID, archivedAt, archive, name)
+		}
+		if _, err := e.repo.GetByID(ctx, order.OrderUID); !errors.Is(err, domain.ErrOrderArchived) {
+			t.Fatalf("get archived %s: want %v, got %v", order.OrderUID, domain.ErrOrderArchived, err)
+		}
+	}
+	for _, order := range []*domain.Order{e.current, e.stale} {
+		if _, err := e.repo.GetByID(ctx, order.OrderUID); err != nil {
+			t.Fatalf("get %s after archive: %v", order.OrderUID, err)
+		}
+	}
+}
+
+func TestPartitionArchiveKeepsPartitionsOnWriteError(t *testing.T) {
+	e := newArchiveEnv(t)
+	ctx := context.Background()
+
+	errWrite := errors.New("disk full")
+	w := archiveBuffer{closeErr: errWrite}
+	if _, err := e.partitions.Archive(ctx, e.month, "orders-test.ndjson.gz", &w); !errors.Is(err, errWrite) {
+		t.Fatalf("archive: want %v, got %v", errWrite, err)
+	}
+
+	if got := e.partitionsExist(t); got != 4 {
+		t.Fatalf("%d of 4 partitions left after failed archive", got)
+	}
+	for _, order := range e.old {
+		if _, err := e.repo.GetByID(ctx, order.OrderUID); err != nil {
+			t.Fatalf("get %s after failed archive: %v", order.OrderUID, err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"order_service/internal/domain"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AuditRepository — журнал аудита. Записи только добавляются.
+type AuditRepository interface {
+	// Record пишет событие вне транзакции изменения (чтения персональных данных).
+	Record(ctx context.Context, event domain.AuditEvent) error
+	ListByOrder(ctx context.Context, orderUID string) ([]domain.AuditEvent, error)
+}
+
+type auditRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewAuditRepository создает новый экземпляр auditRepository.
+func NewAuditRepository(db *pgxpool.Pool) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+// insertAuditEvent пишет событие аудита. Для изменений вызывается внутри их транзакции,
+// чтобы изменение и запись о нем фиксировались или откатывались вместе.
+func insertAuditEvent(ctx context.Context, q dbtx, event domain.AuditEvent) error {
+	var changes []byte // nil пишется как NULL
+	if len(event.Changes) > 0 {
+		changes = event.Changes
+	}
+	_, err := q.Exec(ctx, `
+        INSERT INTO audit_events (actor, source, action, order_uid, changes, request_id)
+        VALUES ($1, $2, $3, $4, $5, $6)`,
+		event.Actor, event.Source, event.Action, nullString(event.OrderUID),
+		changes, nullString(event.RequestID))
+	if err != nil {
+		return fmt.Errorf("postgres insert audit event error: %w", err)
+	}
+	return nil
+}
+
+func (r *auditRepository) Record(ctx context.Context, event domain.AuditEvent) error {
+	return insertAuditEvent(ctx, r.db, event)
+}
+
+// ListByOrder возвращает события заказа в хронологическом порядке.
+func (r *auditRepository) ListByOrder(ctx context.Context, orderUID string) ([]domain.AuditEvent, error) {
+	rows, err := r.db.Query(ctx, `
+        SELECT id, occurred_at, actor, source, action, order_uid, changes, request_id
+        FROM audit_events WHERE order_uid = $1
+        ORDER BY occurred_at, id`, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres audit query error: %w", err)
+	}
+	defer rows.Close()
+
+	events := []domain.AuditEvent{}
+	for rows.Next() {
+		var e domain.AuditEvent
+		var uid, requestID pgtype.Text
+		if err := rows.Scan(&e.ID, &e.OccurredAt, &e.Actor, &e.Source, &e.Action, &uid, &e.Changes, &requestID); err != nil {
+			return nil, fmt.Errorf("postgres audit scan error: %w", err)
+		}
+		e.OrderUID, e.RequestID = uid.String, requestID.String
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres audit iteration error: %w", err)
+	}
+	return events, nil
+}
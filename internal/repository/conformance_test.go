@@ -0,0 +1,604 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"order_service/internal/config"
+	"order_service/internal/database"
+	"order_service/internal/domain"
+	"order_service/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Общие проверки хранилищ заказов: Postgres, SQLite и память должны вести себя одинаково —
+// ошибки, версии, фильтры, порядок выдачи, мягкое удаление, стирание и аудит.
+// Postgres проверяется, только если задан postgresDSNEnv.
+
+// postgresDSNEnv — переменная с DSN тестовой БД Postgres. Проверки идут в отдельной схеме,
+// которая удаляется после прогона.
+const postgresDSNEnv = "ORDER_SERVICE_TEST_POSTGRES_DSN"
+
+func TestConformanceMemory(t *testing.T) {
+	db := repository.NewMemoryDB()
+	runConformance(t, repository.NewMemoryOrderRepository(db), repository.NewMemoryAuditRepository(db))
+}
+
+func TestConformanceSQLite(t *testing.T) {
+	cfg := &config.Config{Storage: config.Storage{Path: filepath.Join(t.TempDir(), "orders.db")}}
+	db, err := database.OpenSQLite(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	runConformance(t, repository.NewSQLiteOrderRepository(db), repository.NewSQLiteAuditRepository(db))
+}
+
+func TestConformancePostgres(t *testing.T) {
+	pool := openTestPostgres(t, pgx.QueryExecModeCacheStatement)
+	runConformance(t, repository.NewOrderRepository(pool, nil), repository.NewAuditRepository(pool))
+}
+
+// TestConformanceShardedPostgres гоняет те же проверки на двух шардах в отдельных схемах,
+// для обоих способов поиска шарда.
+func TestConformanceShardedPostgres(t *testing.T) {
+	for _, lookup := range []string{repository.ShardLookupDirectory, repository.ShardLookupFanout} {
+		t.Run(lookup, func(t *testing.T) {
+			dbs := []*pgxpool.Pool{
+				openTestPostgres(t, pgx.QueryExecModeCacheStatement),
+				openTestPostgres(t, pgx.QueryExecModeCacheStatement),
+			}
+			repo, err := repository.NewShardedRepository(dbs, nil, lookup)
+			if err != nil {
+				t.Fatal(err)
+			}
+			runConformance(t, repo, repository.NewShardedAuditRepository(dbs))
+		})
+	}
+}
+
+// openTestPostgres подключается к БД из postgresDSNEnv, создает для теста отдельную схему
+// с миграциями и удаляет ее вместе со всеми заказами и журналом после теста. mode — режим
+// выполнения запросов, как у пула с data_base.pool.statement_cache.
+func openTestPostgres(tb testing.TB, mode pgx.QueryExecMode) *pgxpool.Pool {
+	tb.Helper()
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		tb.Skipf("%s is not set", postgresDSNEnv)
+	}
+	ctx := context.Background()
+
+	admin, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(admin.Close)
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+pgx.Identifier{schema}.Sanitize()); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		if _, err := admin.Exec(ctx, "DROP SCHEMA "+pgx.Identifier{schema}.Sanitize()+" CASCADE"); err != nil {
+			tb.Errorf("drop test schema %s: %v", schema, err)
+		}
+	})
+
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	poolCfg.ConnConfig.RuntimeParams["search_path"] = schema
+	poolCfg.ConnConfig.DefaultQueryExecMode = mode
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(pool.Close) // Закрывается до удаления схемы: Cleanup выполняются в обратном порядке
+	if err := database.Migrate(ctx, pool, database.MigrateUp, nil); err != nil {
+		tb.Fatal(err)
+	}
+	return pool
+}
+
+// runConformance выполняет проверки по очереди, каждую в своем подтесте.
+func runConformance(t *testing.T, repo repository.OrderRepository, audit repository.AuditRepository) {
+	gen, err := domain.NewGenerator(domain.DefaultGeneratorOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &suite{
+		repo:   repo,
+		audit:  audit,
+		gen:    gen,
+		prefix: fmt.Sprintf("conformance-%d", time.Now().UnixNano()),
+	}
+	for _, c := range checks {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.run(context.Background(), s); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// suite — состояние прогона на одном хранилище: репозитории и источник уникальных заказов.
+type suite struct {
+	repo   repository.OrderRepository
+	audit  repository.AuditRepository
+	gen    *domain.Generator
+	prefix string
+	seq    int
+}
+
+type check struct {
+	name string
+	run  func(ctx context.Context, s *suite) error
+}
+
+var checks = []check{
+	{"create and get", checkCreateGet},
+	{"duplicate uid", checkDuplicate},
+	{"missing order", checkMissing},
+	{"invalid order", checkInvalid},
+	{"update versions", checkUpdate},
+	{"concurrent update", checkConcurrentUpdate},
+	{"list filters and order", checkList},
+	{"export order and limit", checkExport},
+	{"soft delete", checkDelete},
+	{"erase order", checkEraseOrder},
+	{"erase customer", checkEraseCustomer},
+	{"audit log", checkAudit},
+}
+
+// order возвращает корректный заказ с уникальными UID, покупателем и email.
+func (s *suite) order() *domain.Order {
+	s.seq++
+	order := s.gen.Next()
+	order.OrderUID = fmt.Sprintf("%s-%d", s.prefix, s.seq)
+	order.CustomerID = order.OrderUID
+	order.Delivery.Email = order.OrderUID + "@example.com"
+	order.DateCreated = time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	return &order
+}
+
+// created сохраняет заказ из order.
+func (s *suite) created(ctx context.Context, customize func(*domain.Order)) (*domain.Order, error) {
+	order := s.order()
+	if customize != nil {
+		customize(order)
+	}
+	if err := s.repo.Create(ctx, order); err != nil {
+		return nil, fmt.Errorf("create %s: %w", order.OrderUID, err)
+	}
+	return order, nil
+}
+
+// expectErr проверяет, что op вернула ошибку target.
+func expectErr(op string, err, target error) error {
+	if !errors.Is(err, target) {
+		return fmt.Errorf("%s: want %v, got %v", op, target, err)
+	}
+	return nil
+}
+
+func uids(orders []domain.Order) []string {
+	result := make([]string, len(orders))
+	for i := range orders {
+		result[i] = orders[i].OrderUID
+	}
+	return result
+}
+
+func expectStrings(op string, got, want []string) error {
+	if !reflect.DeepEqual(got, want) {
+		return fmt.Errorf("%s: want %v, got %v", op, want, got)
+	}
+	return nil
+}
+
+func checkCreateGet(ctx context.Context, s *suite) error {
+	order, err := s.created(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if order.Version != 1 || order.UpdatedAt.IsZero() {
+		return fmt.Errorf("create: want version 1 and updated_at, got %d and %v", order.Version, order.UpdatedAt)
+	}
+	got, err := s.repo.GetByID(ctx, order.OrderUID)
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	if d := got.DateCreated.Sub(order.DateCreated); d > time.Microsecond || d < -time.Microsecond {
+		return fmt.Errorf("get: date_created %v, want %v", got.DateCreated, order.DateCreated)
+	}
+	if got.Version != 1 {
+		return fmt.Errorf("get: version %d, want 1", got.Version)
+	}
+	got.DateCreated, got.UpdatedAt = order.DateCreated, order.UpdatedAt
+	if !reflect.DeepEqual(got, order) {
+		return fmt.Errorf("get: stored order differs from created")
+	}
+	return nil
+}
+
+func checkDuplicate(ctx context.Context, s *suite) error {
+	order, err := s.created(ctx, nil)
+	if err != nil {
+		return err
+	}
+	duplicate := s.order()
+	duplicate.OrderUID = order.OrderUID
+	return expectErr("create duplicate", s.repo.Create(ctx, duplicate), domain.ErrOrderUIDNotUnique)
+}
+
+func checkMissing(ctx context.Context, s *suite) error {
+	uid := s.prefix + "-missing"
+	_, err := s.repo.GetByID(ctx, uid)
+	if err := expectErr("get", err, domain.ErrOrderNotFound); err != nil {
+		return err
+	}
+	status := domain.ItemStatusPatch{Rid: "missing", Status: 1}
+	_, err = s.repo.Update(ctx, uid, 0, domain.OrderPatch{Items: []domain.ItemStatusPatch{status}})
+	if err := expectErr("update", err, domain.ErrOrderNotFound); err != nil {
+		return err
+	}
+	_, err = s.repo.Delete(ctx, uid, s.prefix)
+	if err := expectErr("delete", err, domain.ErrOrderNotFound); err != nil {
+		return err
+	}
+	_, err = s.repo.EraseOrder(ctx, uid, s.prefix)
+	return expectErr("erase", err, domain.ErrOrderNotFound)
+}
+
+// checkInvalid проверяет, что ошибка называет колонку родительской таблицы, а не секции.
+func checkInvalid(ctx context.Context, s *suite) error {
+	cases := []struct {
+		column string
+		spoil  func(*domain.Order)
+	}{
+		{"payments.amount", func(o *domain.Order) { o.Payment.Amount = -1 }},
+		{"items.price", func(o *domain.Order) { o.Items[0].Price = -1 }},
+	}
+	for _, c := range cases {
+		order := s.order()
+		c.spoil(order)
+		err := s.repo.Create(ctx, order)
+		if err := expectErr("create", err, domain.ErrInvalidOrder); err != nil {
+			return err
+		}
+		if want := c.column + " must be non-negative"; !strings.HasSuffix(err.Error(), want) {
+			return fmt.Errorf("create: error %q does not name %s", err, c.column)
+		}
+		_, err = s.repo.GetByID(ctx, order.OrderUID)
+		if err := expectErr("get rejected order", err, domain.ErrOrderNotFound); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkUpdate(ctx context.Context, s *suite) error {
+	order, err := s.created(ctx, nil)
+	if err != nil {
+		return err
+	}
+	city := "Conformance City"
+	patch := domain.OrderPatch{Delivery: &domain.DeliveryPatch{City: &city}}
+
+	updated, err := s.repo.Update(ctx, order.OrderUID, 1, patch)
+	if err != nil {
+		return fmt.Errorf("update with version: %w", err)
+	}
+	if updated.Version != 2 || updated.Delivery.City != city {
+		return fmt.Errorf("update with version: got version %d, city %q", updated.Version, updated.Delivery.City)
+	}
+	_, err = s.repo.Update(ctx, order.OrderUID, 1, patch)
+	if err := expectErr("update with stale version", err, domain.ErrVersionMismatch); err != nil {
+		return err
+	}
+	updated, err = s.repo.Update(ctx, order.OrderUID, 0, patch)
+	if err != nil {
+		return fmt.Errorf("unconditional update: %w", err)
+	}
+	if updated.Version != 3 {
+		return fmt.Errorf("unconditional update: version %d, want 3", updated.Version)
+	}
+	status := domain.ItemStatusPatch{Rid: s.prefix + "-unknown", Status: 1}
+	_, err = s.repo.Update(ctx, order.OrderUID, 0, domain.OrderPatch{Items: []domain.ItemStatusPatch{status}})
+	if err := expectErr("update unknown item", err, domain.ErrInvalidOrder); err != nil {
+		return err
+	}
+
+	got, err := s.repo.GetByID(ctx, order.OrderUID)
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	if got.Version != 3 || got.Delivery.City != city {
+		return fmt.Errorf("get: version %d, city %q after updates", got.Version, got.Delivery.City)
+	}
+	return nil
+}
+
+// checkConcurrentUpdate — из двух изменений одной версии проходит ровно одно.
+func checkConcurrentUpdate(ctx context.Context, s *suite) error {
+	order, err := s.created(ctx, nil)
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			city := fmt.Sprintf("City %d", i)
+			_, errs[i] = s.repo.Update(ctx, order.OrderUID, 1, domain.OrderPatch{Delivery: &domain.DeliveryPatch{City: &city}})
+		}(i)
+	}
+	wg.Wait()
+
+	won := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			won++
+		case !errors.Is(err, domain.ErrVersionMismatch):
+			return fmt.Errorf("concurrent update: %w", err)
+		}
+	}
+	if won != 1 {
+		return fmt.Errorf("concurrent update: %d updates of version 1 succeeded, want 1", won)
+	}
+	return nil
+}
+
+// customerOrders создает заказы одного покупателя с date_created через час, старые первыми.
+func (s *suite) customerOrders(ctx context.Context, n int) (string, []*domain.Order, error) {
+	customer := fmt.Sprintf("%s-customer-%d", s.prefix, s.seq)
+	start := time.Now().Add(-time.Duration(n+1) * time.Hour).Truncate(time.Microsecond)
+	orders := make([]*domain.Order, n)
+	for i := range orders {
+		order, err := s.created(ctx, func(o *domain.Order) {
+			o.CustomerID = customer
+			o.DateCreated = start.Add(time.Duration(i) * time.Hour)
+		})
+		if err != nil {
+			return "", nil, err
+		}
+		orders[i] = order
+	}
+	return customer, orders, nil
+}
+
+func checkList(ctx context.Context, s *suite) error {
+	customer, orders, err := s.customerOrders(ctx, 3)
+	if err != nil {
+		return err
+	}
+
+	listed, err := s.repo.List(ctx, domain.OrderFilter{CustomerID: customer})
+	if err != nil {
+		return fmt.Errorf("list: %w", err)
+	}
+	newFirst := []string{orders[2].OrderUID, orders[1].OrderUID, orders[0].OrderUID}
+	if err := expectStrings("list by customer", uids(listed), newFirst); err != nil {
+		return err
+	}
+	listed, err = s.repo.List(ctx, domain.OrderFilter{CustomerID: customer, Limit: 2})
+	if err != nil {
+		return fmt.Errorf("list with limit: %w", err)
+	}
+	if err := expectStrings("list with limit", uids(listed), newFirst[:2]); err != nil {
+		return err
+	}
+	from, to := orders[1].DateCreated, orders[2].DateCreated
+	listed, err = s.repo.List(ctx, domain.OrderFilter{CustomerID: customer, DateFrom: &from, DateTo: &to})
+	if err != nil {
+		return fmt.Errorf("list by dates: %w", err)
+	}
+	if err := expectStrings("list by dates", uids(listed), []string{orders[1].OrderUID}); err != nil {
+		return err
+	}
+
+	// email ищется без учета регистра, телефон — точно
+	listed, err = s.repo.List(ctx, domain.OrderFilter{Email: strings.ToUpper(orders[0].Delivery.Email)})
+	if err != nil {
+		return fmt.Errorf("list by email: %w", err)
+	}
+	if err := expectStrings("list by email", uids(listed), []string{orders[0].OrderUID}); err != nil {
+		return err
+	}
+	listed, err = s.repo.List(ctx, domain.OrderFilter{CustomerID: customer, Phone: orders[1].Delivery.Phone})
+	if err != nil {
+		return fmt.Errorf("list by phone: %w", err)
+	}
+	for _, order := range listed {
+		if order.Delivery.Phone != orders[1].Delivery.Phone {
+			return fmt.Errorf("list by phone: got order %s with phone %q", order.OrderUID, order.Delivery.Phone)
+		}
+	}
+	if len(listed) == 0 {
+		return fmt.Errorf("list by phone: order %s not found", orders[1].OrderUID)
+	}
+	return nil
+}
+
+func checkExport(ctx context.Context, s *suite) error {
+	customer, orders, err := s.customerOrders(ctx, 3)
+	if err != nil {
+		return err
+	}
+	export := func(filter domain.OrderFilter) ([]string, error) {
+		var exported []string
+		err := s.repo.Export(ctx, filter, func(order *domain.Order) error {
+			exported = append(exported, order.OrderUID)
+			return nil
+		})
+		return exported, err
+	}
+
+	exported, err := export(domain.OrderFilter{CustomerID: customer})
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	oldFirst := []string{orders[0].OrderUID, orders[1].OrderUID, orders[2].OrderUID}
+	if err := expectStrings("export", exported, oldFirst); err != nil {
+		return err
+	}
+	exported, err = export(domain.OrderFilter{CustomerID: customer, Limit: 2})
+	if err != nil {
+		return fmt.Errorf("export with limit: %w", err)
+	}
+	if err := expectStrings("export with limit", exported, oldFirst[:2]); err != nil {
+		return err
+	}
+
+	stop := errors.New("stop export")
+	err = s.repo.Export(ctx, domain.OrderFilter{CustomerID: customer}, func(*domain.Order) error { return stop })
+	return expectErr("export callback error", err, stop)
+}
+
+func checkDelete(ctx context.Context, s *suite) error {
+	order, err := s.created(ctx, nil)
+	if err != nil {
+		return err
+	}
+	version, err := s.repo.Delete(ctx, order.OrderUID, s.prefix)
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+	if version != order.Version+1 {
+		return fmt.Errorf("delete: version %d, want %d", version, order.Version+1)
+	}
+	_, err = s.repo.GetByID(ctx, order.OrderUID)
+	if err := expectErr("get deleted", err, domain.ErrOrderNotFound); err != nil {
+		return err
+	}
+	listed, err := s.repo.List(ctx, domain.OrderFilter{CustomerID: order.CustomerID})
+	if err != nil {
+		return fmt.Errorf("list: %w", err)
+	}
+	if len(listed) != 0 {
+		return fmt.Errorf("list: deleted order is listed")
+	}
+	_, err = s.repo.Delete(ctx, order.OrderUID, s.prefix)
+	if err := expectErr("delete twice", err, domain.ErrOrderNotFound); err != nil {
+		return err
+	}
+	// Мягко удаленный заказ по-прежнему стирается
+	if _, err := s.repo.EraseOrder(ctx, order.OrderUID, s.prefix); err != nil {
+		return fmt.Errorf("erase deleted: %w", err)
+	}
+	return nil
+}
+
+func checkEraseOrder(ctx context.Context, s *suite) error {
+	order, err := s.created(ctx, nil)
+	if err != nil {
+		return err
+	}
+	version, err := s.repo.EraseOrder(ctx, order.OrderUID, s.prefix)
+	if err != nil {
+		return fmt.Errorf("erase: %w", err)
+	}
+	erased, err := s.repo.GetByID(ctx, order.OrderUID)
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	d := erased.Delivery
+	for name, value := range map[string]string{"name": d.Name, "phone": d.Phone, "zip": d.Zip, "address": d.Address, "email": d.Email} {
+		if value != domain.ErasedValue {
+			return fmt.Errorf("get: delivery.%s is %q after erase", name, value)
+		}
+	}
+	if d.City != order.Delivery.City {
+		return fmt.Errorf("get: delivery.city changed by erase")
+	}
+	if erased.Version != order.Version+1 || version != erased.Version {
+		return fmt.Errorf("get: version %d after erase returned %d, want %d", erased.Version, version, order.Version+1)
+	}
+
+	// Повторное стирание ничего не меняет
+	if version, err := s.repo.EraseOrder(ctx, order.OrderUID, s.prefix); err != nil || version != erased.Version {
+		return fmt.Errorf("erase twice: version %d, err %v", version, err)
+	}
+	again, err := s.repo.GetByID(ctx, order.OrderUID)
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	if again.Version != erased.Version {
+		return fmt.Errorf("erase twice: version %d, want %d", again.Version, erased.Version)
+	}
+	name := "Restored Name"
+	_, err = s.repo.Update(ctx, order.OrderUID, 0, domain.OrderPatch{Delivery: &domain.DeliveryPatch{Name: &name}})
+	return expectErr("update erased delivery", err, domain.ErrPIIErased)
+}
+
+func checkEraseCustomer(ctx context.Context, s *suite) error {
+	customer, orders, err := s.customerOrders(ctx, 2)
+	if err != nil {
+		return err
+	}
+	erased, err := s.repo.EraseCustomer(ctx, customer, s.prefix)
+	if err != nil {
+		return fmt.Errorf("erase customer: %w", err)
+	}
+	want := []string{orders[0].OrderUID, orders[1].OrderUID}
+	if want[0] > want[1] {
+		want[0], want[1] = want[1], want[0]
+	}
+	got := make([]string, len(erased))
+	for i, v := range erased {
+		got[i] = v.OrderUID
+		stored, err := s.repo.GetByID(ctx, v.OrderUID)
+		if err != nil {
+			return fmt.Errorf("get: %w", err)
+		}
+		if v.Version != stored.Version || v.Version != orders[0].Version+1 {
+			return fmt.Errorf("erase customer: %s version %d, stored %d", v.OrderUID, v.Version, stored.Version)
+		}
+	}
+	if err := expectStrings("erase customer", got, want); err != nil {
+		return err
+	}
+	_, err = s.repo.EraseCustomer(ctx, s.prefix+"-unknown", s.prefix)
+	return expectErr("erase unknown customer", err, domain.ErrOrderNotFound)
+}
+
+func checkAudit(ctx context.Context, s *suite) error {
+	order, err := s.created(ctx, nil)
+	if err != nil {
+		return err
+	}
+	city := "Audit City"
+	if _, err := s.repo.Update(ctx, order.OrderUID, 0, domain.OrderPatch{Delivery: &domain.DeliveryPatch{City: &city}}); err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+	if _, err := s.repo.EraseOrder(ctx, order.OrderUID, s.prefix); err != nil {
+		return fmt.Errorf("erase: %w", err)
+	}
+	if _, err := s.repo.Delete(ctx, order.OrderUID, s.prefix); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	events, err := s.audit.ListByOrder(ctx, order.OrderUID)
+	if err != nil {
+		return fmt.Errorf("list audit: %w", err)
+	}
+	actions := make([]string, len(events))
+	for i, event := range events {
+		actions[i] = event.Action
+	}
+	want := []string{domain.AuditOrderCreate, domain.AuditOrderUpdate, domain.AuditOrderErase, domain.AuditOrderDelete}
+	return expectStrings("audit actions", actions, want)
+}
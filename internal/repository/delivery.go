@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"order_service/internal/domain"
+	"order_service/internal/encryption"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// deliveryRow — доставка в том виде, в котором она хранится в БД.
+type deliveryRow struct {
+	domain.Delivery
+	dek       pgtype.Text
+	emailBidx pgtype.Text
+	phoneBidx pgtype.Text
+}
+
+// deliveryPII возвращает указатели на шифруемые поля доставки — те же, что затираются при стирании.
+func deliveryPII(d *domain.Delivery) map[string]*string {
+	return map[string]*string{
+		"name":    &d.Name,
+		"phone":   &d.Phone,
+		"zip":     &d.Zip,
+		"address": &d.Address,
+		"email":   &d.Email,
+	}
+}
+
+// fieldAAD привязывает шифротекст поля к заказу, чтобы его нельзя было подставить в чужую строку.
+func fieldAAD(orderUID, field string) string {
+	return orderUID + "|" + field
+}
+
+// sealDelivery готовит доставку к записи. Без ключей данные пишутся открытым текстом.
+func (r *orderRepository) sealDelivery(orderUID string, d domain.Delivery) (deliveryRow, error) {
+	return sealDelivery(r.keyring, orderUID, d)
+}
+
+func sealDelivery(keyring *encryption.Keyring, orderUID string, d domain.Delivery) (deliveryRow, error) {
+	row := deliveryRow{Delivery: d}
+	if keyring == nil {
+		return row, nil
+	}
+
+	dek, wrapped, err := keyring.NewDataKey()
+	if err != nil {
+		return row, fmt.Errorf("failed to create data key: %w", err)
+	}
+	row.dek = pgtype.Text{String: wrapped, Valid: true}
+	row.emailBidx = nullString(keyring.BlindIndex("email", d.Email))
+	row.phoneBidx = nullString(keyring.BlindIndex("phone", d.Phone))
+
+	for field, value := range deliveryPII(&row.Delivery) {
+		if *value, err = encryption.Seal(dek, *value, fieldAAD(orderUID, field)); err != nil {
+			return row, fmt.Errorf("failed to encrypt %s: %w", field, err)
+		}
+	}
+	return row, nil
+}
+
+// openDelivery расшифровывает поля доставки, прочитанные из БД, на месте.
+func (r *orderRepository) openDelivery(orderUID string, d *domain.Delivery, dek pgtype.Text) error {
+	if !dek.Valid {
+		return nil // Строка не зашифрована: до миграции или после стирания
+	}
+	if r.keyring == nil {
+		return errors.New("delivery is encrypted, but encryption is not configured")
+	}
+
+	key, err := r.keyring.UnwrapKey(dek.String)
+	if err != nil {
+		return fmt.Errorf("failed to unwrap data key of order %s: %w", orderUID, err)
+	}
+	for field, value := range deliveryPII(d) {
+		if *value, err = encryption.Open(key, *value, fieldAAD(orderUID, field)); err != nil {
+			return fmt.Errorf("failed to decrypt %s of order %s: %w", field, orderUID, err)
+		}
+	}
+	return nil
+}
+
+func nullString(s string) pgtype.Text {
+	return pgtype.Text{String: s, Valid: s != ""}
+}
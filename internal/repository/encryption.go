@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"order_service/internal/domain"
+	"order_service/internal/encryption"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DeliveryEncryptor обслуживает шифрование уже сохраненных доставок: переводит открытые строки
+// в зашифрованные и перешифровывает ключи данных при ротации мастер-ключа.
+// Оба метода обрабатывают не больше limit строк за транзакцию и возвращают их число;
+// вызывать их нужно в цикле, пока не вернется 0.
+type DeliveryEncryptor interface {
+	EncryptBatch(ctx context.Context, limit int) (int, error)
+	RekeyBatch(ctx context.Context, limit int) (int, error)
+}
+
+type deliveryEncryptor struct {
+	db      *pgxpool.Pool
+	keyring *encryption.Keyring
+}
+
+// NewDeliveryEncryptor создает DeliveryEncryptor. Без ключей работать не может.
+func NewDeliveryEncryptor(db *pgxpool.Pool, keyring *encryption.Keyring) (DeliveryEncryptor, error) {
+	if keyring == nil {
+		return nil, errors.New("encryption is not configured")
+	}
+	return &deliveryEncryptor{db: db, keyring: keyring}, nil
+}
+
+// EncryptBatch шифрует очередную пачку открытых строк. Затертые строки не трогает.
+// SKIP LOCKED позволяет запускать несколько экземпляров параллельно.
+func (e *deliveryEncryptor) EncryptBatch(ctx context.Context, limit int) (int, error) {
+	tx, err := e.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("postgres begin tx error: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+        SELECT order_uid, name, phone, zip, city, address, region, email
+        FROM deliveries
+        WHERE dek IS NULL AND erased_at IS NULL
+        ORDER BY order_uid
+        LIMIT $1
+        FOR UPDATE SKIP LOCKED`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("postgres select plaintext deliveries error: %w", err)
+	}
+
+	type plainRow struct {
+		uid      string
+		delivery domain.Delivery
+	}
+	var batch []plainRow
+	for rows.Next() {
+		var p plainRow
+		d := &p.delivery
+		if err := rows.Scan(&p.uid, &d.Name, &d.Phone, &d.Zip, &d.City, &d.Address, &d.Region, &d.Email); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("postgres scan delivery error: %w", err)
+		}
+		batch = append(batch, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("postgres deliveries iteration error: %w", err)
+	}
+
+	for _, p := range batch {
+		row, err := sealDelivery(e.keyring, p.uid, p.delivery)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec(ctx, `
+            UPDATE deliveries
+            SET name = $2, phone = $3, zip = $4, address = $5, email = $6, dek = $7, email_bidx = $8, phone_bidx = $9
+            WHERE order_uid = $1`,
+			p.uid, row.Name, row.Phone, row.Zip, row.Address, row.Email, row.dek, row.emailBidx, row.phoneBidx)
+		if err != nil {
+			return 0, fmt.Errorf("postgres encrypt delivery error: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("postgres commit error: %w", err)
+	}
+	return len(batch), nil
+}
+
+// RekeyBatch перешифровывает активным мастер-ключом ключи данных, обернутые другими ключами.
+// Сами поля не меняются.
+func (e *deliveryEncryptor) RekeyBatch(ctx context.Context, limit int) (int, error) {
+	tx, err := e.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("postgres begin tx error: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Ключ данных хранится как <id мастер-ключа>:<обертка>. ID сравнивается целиком, а не через LIKE:
+	// '_' и '%' в ID были бы шаблоном
+	rows, err := tx.Query(ctx, `
+        SELECT order_uid, dek
+        FROM deliveries
+        WHERE dek IS NOT NULL AND split_part(dek, ':', 1) <> $1
+        ORDER BY order_uid
+        LIMIT $2
+        FOR UPDATE SKIP LOCKED`, e.keyring.ActiveKeyID(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("postgres select stale keys error: %w", err)
+	}
+
+	rewrapped := map[string]string{}
+	for rows.Next() {
+		var uid, dek string
+		if err := rows.Scan(&uid, &dek); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("postgres scan key error: %w", err)
+		}
+		if rewrapped[uid], _, err = e.keyring.Rewrap(dek); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to rewrap key of order %s: %w", uid, err)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("postgres keys iteration error: %w", err)
+	}
+
+	for uid, dek := range rewrapped {
+		if _, err := tx.Exec(ctx, `UPDATE deliveries SET dek = $2 WHERE order_uid = $1`, uid, dek); err != nil {
+			return 0, fmt.Errorf("postgres rekey delivery error: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("postgres commit error: %w", err)
+	}
+	return len(rewrapped), nil
+}
@@ -0,0 +1,71 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"order_service/internal/domain"
+	"order_service/internal/encryption"
+	"order_service/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(rune(b)), 32)))
+}
+
+// Идентификатор ключа с '_' не должен совпадать с другими ключами как шаблон LIKE.
+func TestRekeyBatchPostgresMatchesKeyIDExactly(t *testing.T) {
+	ctx := context.Background()
+	pool := openTestPostgres(t, pgx.QueryExecModeCacheStatement)
+
+	keys := map[string]string{"kx1": testKey('a'), "k_1": testKey('b')}
+	oldRing, err := encryption.NewKeyring(keys, "kx1", testKey('i'))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRing, err := encryption.NewKeyring(keys, "k_1", testKey('i'))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gen, err := domain.NewGenerator(domain.DefaultGeneratorOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := gen.Next()
+	order.DateCreated = time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	if err := repository.NewOrderRepository(pool, oldRing).Create(ctx, &order); err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := repository.NewDeliveryEncryptor(pool, newRing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := enc.RekeyBatch(ctx, 10); err != nil || n != 1 {
+		t.Fatalf("RekeyBatch = %d, %v; want 1, nil", n, err)
+	}
+	if n, err := enc.RekeyBatch(ctx, 10); err != nil || n != 0 {
+		t.Fatalf("second RekeyBatch = %d, %v; want 0, nil", n, err)
+	}
+
+	var dek string
+	if err := pool.QueryRow(ctx, `SELECT dek FROM deliveries WHERE order_uid = $1`, order.OrderUID).Scan(&dek); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(dek, "k_1:") {
+		t.Fatalf("dek = %q, want wrapped by k_1", dek)
+	}
+	got, err := repository.NewOrderRepository(pool, newRing).GetByID(ctx, order.OrderUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Delivery.Phone != order.Delivery.Phone {
+		t.Errorf("phone after rekey = %q, want %q", got.Delivery.Phone, order.Delivery.Phone)
+	}
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"order_service/internal/audit"
+	"order_service/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Delete мягко удаляет заказ: он пропадает из чтения, но данные остаются для аудита.
+// Версия растет, чтобы кэш не принял заказ от чтения, начатого до удаления.
+func (r *orderRepository) Delete(ctx context.Context, orderUID, actor string) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("postgres begin tx error: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var deletedAt time.Time
+	var version int64
+	err = tx.QueryRow(ctx, `
+        UPDATE orders SET deleted_at = now(), deleted_by = $2, version = version + 1, updated_at = now()
+        WHERE order_uid = $1 AND deleted_at IS NULL
+        RETURNING deleted_at, version`, orderUID, actor).Scan(&deletedAt, &version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, missingOrder(ctx, tx, orderUID) // Нет заказа, он уже удален или в архиве
+		}
+		return 0, fmt.Errorf("postgres soft delete error: %w", err)
+	}
+
+	changes, err := json.Marshal(map[string]audit.Change{"deleted_at": {New: deletedAt}})
+	if err != nil {
+		return 0, err
+	}
+	if err := insertAuditEvent(ctx, tx, audit.NewEvent(ctx, domain.AuditOrderDelete, orderUID, changes)); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("postgres commit error: %w", err)
+	}
+	return version, nil
+}
+
+// EraseOrder необратимо затирает персональные данные доставки заказа.
+// Работает и для мягко удаленных заказов. Повторное стирание ничего не меняет.
+func (r *orderRepository) EraseOrder(ctx context.Context, orderUID, actor string) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("postgres begin tx error: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var customerID string
+	err = tx.QueryRow(ctx, `SELECT customer_id FROM orders WHERE order_uid = $1 FOR UPDATE`, orderUID).Scan(&customerID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, missingOrder(ctx, tx, orderUID)
+		}
+		return 0, fmt.Errorf("postgres erase lock error: %w", err)
+	}
+
+	versions, err := eraseDeliveries(ctx, tx, []string{orderUID}, customerID, actor)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("postgres commit error: %w", err)
+	}
+	return versions[0].Version, nil
+}
+
+// EraseCustomer затирает персональные данные во всех заказах покупателя одной транзакцией.
+// Возвращает все заказы покупателя; журнал пишется только для стертых этим вызовом.
+func (r *orderRepository) EraseCustomer(ctx context.Context, customerID, actor string) ([]domain.OrderVersion, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres begin tx error: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокируем заказы покупателя, чтобы параллельная запись не проскочила между выборкой и стиранием
+	rows, err := tx.Query(ctx, `SELECT order_uid FROM orders WHERE customer_id = $1 FOR UPDATE`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres erase lock error: %w", err)
+	}
+	var uids []string
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("postgres erase scan error: %w", err)
+		}
+		uids = append(uids, uid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres erase iteration error: %w", err)
+	}
+	if len(uids) == 0 {
+		return nil, domain.ErrOrderNotFound
+	}
+
+	versions, err := eraseDeliveries(ctx, tx, uids, customerID, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("postgres commit error: %w", err)
+	}
+	return versions, nil
+}
+
+// eraseDeliveries затирает еще не стертые доставки и пишет журнал стирания в той же транзакции.
+// Возвращает версии всех заказов uids после стирания.
+func eraseDeliveries(ctx context.Context, tx pgx.Tx, uids []string, customerID, actor string) ([]domain.OrderVersion, error) {
+	rows, err := tx.Query(ctx, `
+        UPDATE deliveries
+        SET name = $2, phone = $2, zip = $2, address = $2, email = $2,
+            dek = NULL, email_bidx = NULL, phone_bidx = NULL, erased_at = now()
+        WHERE order_uid = ANY($1) AND erased_at IS NULL
+        RETURNING order_uid`, uids, domain.ErasedValue)
+	if err != nil {
+		return nil, fmt.Errorf("postgres erase delivery error: %w", err)
+	}
+	erased := []string{}
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("postgres erase scan error: %w", err)
+		}
+		erased = append(erased, uid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres erase iteration error: %w", err)
+	}
+
+	// Содержимое заказа изменилось: новая версия не даст клиентам и кэшу считать старую актуальной
+	_, err = tx.Exec(ctx, `
+        UPDATE orders SET version = version + 1, updated_at = now()
+        WHERE order_uid = ANY($1)`, erased)
+	if err != nil {
+		return nil, fmt.Errorf("postgres erase order version error: %w", err)
+	}
+
+	changes, err := json.Marshal(map[string][]string{"erased_fields": domain.ErasedDeliveryFields})
+	if err != nil {
+		return nil, err
+	}
+	for _, uid := range erased {
+		_, err := tx.Exec(ctx, `
+            INSERT INTO erasures (order_uid, customer_id, erased_by, fields)
+            VALUES ($1, $2, $3, $4)`, uid, customerID, actor, domain.ErasedDeliveryFields)
+		if err != nil {
+			return nil, fmt.Errorf("postgres insert erasure error: %w", err)
+		}
+		if err := insertAuditEvent(ctx, tx, audit.NewEvent(ctx, domain.AuditOrderErase, uid, changes)); err != nil {
+			return nil, err
+		}
+	}
+	return orderVersions(ctx, tx, uids)
+}
+
+// orderVersions читает текущие версии заказов по возрастанию UID.
+func orderVersions(ctx context.Context, tx pgx.Tx, uids []string) ([]domain.OrderVersion, error) {
+	rows, err := tx.Query(ctx, `
+        SELECT order_uid, version FROM orders
+        WHERE order_uid = ANY($1)
+        ORDER BY order_uid`, uids)
+	if err != nil {
+		return nil, fmt.Errorf("postgres order version query error: %w", err)
+	}
+	versions, err := pgx.CollectRows(rows, pgx.RowToStructByPos[domain.OrderVersion])
+	if err != nil {
+		return nil, fmt.Errorf("postgres order version scan error: %w", err)
+	}
+	return versions, nil
+}
+
+// missingOrder объясняет, почему заказа нет среди живых: он в архиве или не существует.
+func missingOrder(ctx context.Context, q dbtx, orderUID string) error {
+	var archived bool
+	err := q.QueryRow(ctx, `SELECT archived_at IS NOT NULL FROM order_keys WHERE order_uid = $1`, orderUID).Scan(&archived)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("postgres order key query error: %w", err)
+	}
+	if archived {
+		return domain.ErrOrderArchived
+	}
+	return domain.ErrOrderNotFound
+}
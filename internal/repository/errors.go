@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"order_service/internal/domain"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Классы ошибок Postgres, означающие некорректные данные заказа, а не сбой БД.
+const (
+	pgNotNullViolation    = "23502"
+	pgForeignKeyViolation = "23503"
+	pgUniqueViolation     = "23505"
+	pgCheckViolation      = "23514"
+	pgStringTooLong       = "22001"
+	pgNumericOutOfRange   = "22003"
+)
+
+// constraintError переводит нарушение ограничения схемы в domain.ErrInvalidOrder
+// с колонкой-нарушителем вида "таблица.колонка". Для прочих ошибок возвращает nil.
+func constraintError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil
+	}
+
+	switch pgErr.Code {
+	case pgNotNullViolation:
+		return fmt.Errorf("%w: %s is required", domain.ErrInvalidOrder, column(pgErr))
+	case pgCheckViolation:
+		return fmt.Errorf("%w: %s must be non-negative", domain.ErrInvalidOrder, column(pgErr))
+	case pgForeignKeyViolation:
+		return fmt.Errorf("%w: %s references missing row", domain.ErrInvalidOrder, column(pgErr))
+	case pgStringTooLong, pgNumericOutOfRange:
+		return fmt.Errorf("%w: %s: %s", domain.ErrInvalidOrder, column(pgErr), pgErr.Message)
+	}
+	return nil
+}
+
+// column возвращает колонку из ошибки Postgres. Для CHECK и внешних ключей Postgres колонку
+// не сообщает, она восстанавливается из имени ограничения <таблица>_<колонка>_check|fkey.
+// Ошибки секций Postgres сообщает с именем секции, в ответ попадает родительская таблица.
+func column(pgErr *pgconn.PgError) string {
+	table := parentTable(pgErr.TableName)
+	name := pgErr.ColumnName
+	if name == "" && pgErr.ConstraintName != "" {
+		name = strings.TrimPrefix(pgErr.ConstraintName, table+"_")
+		name = strings.TrimSuffix(strings.TrimSuffix(name, "_check"), "_fkey")
+	}
+	if name == "" {
+		return table
+	}
+	return table + "." + name
+}
+
+// parentTable возвращает родительскую таблицу секции <таблица>_pYYYYMM или <таблица>_default.
+// Прочие имена возвращаются как есть.
+func parentTable(name string) string {
+	for _, table := range partitionTables {
+		suffix, ok := strings.CutPrefix(name, table+"_")
+		if !ok {
+			continue
+		}
+		if suffix == "default" {
+			return table
+		}
+		if _, err := time.Parse(partitionSuffix, suffix); err == nil {
+			return table
+		}
+	}
+	return name
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"order_service/internal/domain"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestConstraintErrorNamesParentColumn(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *pgconn.PgError
+		want string
+	}{
+		{
+			name: "check on monthly partition",
+			err:  &pgconn.PgError{Code: pgCheckViolation, TableName: "items_p202610", ConstraintName: "items_price_check"},
+			want: "items.price must be non-negative",
+		},
+		{
+			name: "check on default partition",
+			err:  &pgconn.PgError{Code: pgCheckViolation, TableName: "payments_default", ConstraintName: "payments_delivery_cost_check"},
+			want: "payments.delivery_cost must be non-negative",
+		},
+		{
+			name: "check on plain table",
+			err:  &pgconn.PgError{Code: pgCheckViolation, TableName: "items", ConstraintName: "items_total_price_check"},
+			want: "items.total_price must be non-negative",
+		},
+		{
+			name: "not null reports column",
+			err:  &pgconn.PgError{Code: pgNotNullViolation, TableName: "deliveries_p202601", ColumnName: "phone"},
+			want: "deliveries.phone is required",
+		},
+		{
+			name: "foreign key",
+			err:  &pgconn.PgError{Code: pgForeignKeyViolation, TableName: "orders_p202612", ConstraintName: "orders_order_uid_fkey"},
+			want: "orders.order_uid references missing row",
+		},
+		{
+			name: "too long",
+			err:  &pgconn.PgError{Code: pgStringTooLong, TableName: "items_default", ColumnName: "brand", Message: "value too long"},
+			want: "items.brand: value too long",
+		},
+		{
+			name: "table only",
+			err:  &pgconn.PgError{Code: pgNotNullViolation, TableName: "orders_p202610"},
+			want: "orders is required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := constraintError(tt.err)
+			if !errors.Is(err, domain.ErrInvalidOrder) {
+				t.Fatalf("err = %v, want ErrInvalidOrder", err)
+			}
+			if want := domain.ErrInvalidOrder.Error() + ": " + tt.want; err.Error() != want {
+				t.Errorf("err = %q, want %q", err, want)
+			}
+		})
+	}
+	if err := constraintError(&pgconn.PgError{Code: pgUniqueViolation}); err != nil {
+		t.Errorf("unique violation mapped to %v", err)
+	}
+	if err := constraintError(errors.New("connection refused")); err != nil {
+		t.Errorf("non-postgres error mapped to %v", err)
+	}
+}
@@ -0,0 +1,343 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"order_service/internal/audit"
+	"order_service/internal/domain"
+)
+
+// MemoryDB — заказы и журнал аудита в памяти процесса для локальной разработки.
+// Данные пропадают при остановке, персональные данные не шифруются.
+type MemoryDB struct {
+	mu      sync.RWMutex
+	orders  map[string]*memoryOrder
+	events  []domain.AuditEvent
+	eventID int64
+}
+
+type memoryOrder struct {
+	order   domain.Order
+	deleted bool
+	erased  bool
+}
+
+// NewMemoryDB создает пустое хранилище в памяти.
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{orders: make(map[string]*memoryOrder)}
+}
+
+// memoryRepository — реализация OrderRepository поверх MemoryDB.
+type memoryRepository struct {
+	db *MemoryDB
+}
+
+// NewMemoryOrderRepository создает репозиторий заказов в памяти.
+func NewMemoryOrderRepository(db *MemoryDB) OrderRepository {
+	return &memoryRepository{db: db}
+}
+
+// storedTime приводит время к точности timestamptz Postgres, чтобы хранилища
+// возвращали одинаковые значения.
+func storedTime(t time.Time) time.Time {
+	return t.Round(time.Microsecond)
+}
+
+// checkOrder повторяет CHECK-ограничения схемы Postgres для хранилищ, которые их не держат.
+func checkOrder(order *domain.Order) error {
+	type column struct {
+		name  string
+		value int
+	}
+	columns := []column{
+		{"payments.amount", order.Payment.Amount},
+		{"payments.delivery_cost", order.Payment.DeliveryCost},
+		{"payments.goods_total", order.Payment.GoodsTotal},
+		{"payments.custom_fee", order.Payment.CustomFee},
+	}
+	for _, item := range order.Items {
+		columns = append(columns, column{"items.price", item.Price}, column{"items.sale", item.Sale},
+			column{"items.total_price", item.TotalPrice}, column{"items.status", item.Status})
+	}
+	for _, c := range columns {
+		if c.value < 0 {
+			return fmt.Errorf("%w: %s must be non-negative", domain.ErrInvalidOrder, c.name)
+		}
+	}
+	return nil
+}
+
+// cloneOrder копирует заказ вместе с товарами.
+func cloneOrder(order *domain.Order) *domain.Order {
+	clone := *order
+	clone.Items = append([]domain.Item{}, order.Items...)
+	return &clone
+}
+
+// matchesFilter — условия buildOrderFilter для заказов в памяти. email без шифрования
+// ищется без учета регистра, как и в Postgres.
+func matchesFilter(stored *memoryOrder, filter domain.OrderFilter) bool {
+	order := &stored.order
+	switch {
+	case stored.deleted:
+		return false
+	case filter.CustomerID != "" && order.CustomerID != filter.CustomerID:
+		return false
+	case filter.TrackNumber != "" && order.TrackNumber != filter.TrackNumber:
+		return false
+	case filter.DeliveryService != "" && order.DeliveryService != filter.DeliveryService:
+		return false
+	case filter.Locale != "" && order.Locale != filter.Locale:
+		return false
+	case filter.Email != "" && !strings.EqualFold(order.Delivery.Email, filter.Email):
+		return false
+	case filter.Phone != "" && order.Delivery.Phone != filter.Phone:
+		return false
+	case filter.DateFrom != nil && order.DateCreated.Before(*filter.DateFrom):
+		return false
+	case filter.DateTo != nil && !order.DateCreated.Before(*filter.DateTo):
+		return false
+	}
+	return true
+}
+
+// appendEvent пишет событие в журнал. Время события задает хранилище, как default now() в Postgres.
+// Вызывается под блокировкой записи.
+func (db *MemoryDB) appendEvent(event domain.AuditEvent) {
+	db.eventID++
+	event.ID = db.eventID
+	event.OccurredAt = storedTime(time.Now())
+	db.events = append(db.events, event)
+}
+
+func (r *memoryRepository) Create(ctx context.Context, order *domain.Order) error {
+	if err := checkOrder(order); err != nil {
+		return err
+	}
+	changes, err := audit.Diff(nil, order)
+	if err != nil {
+		return err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	if _, ok := r.db.orders[order.OrderUID]; ok {
+		return domain.ErrOrderUIDNotUnique
+	}
+	order.Version, order.UpdatedAt = 1, storedTime(time.Now())
+	stored := cloneOrder(order)
+	stored.DateCreated = storedTime(order.DateCreated)
+	r.db.orders[order.OrderUID] = &memoryOrder{order: *stored}
+	r.db.appendEvent(audit.NewEvent(ctx, domain.AuditOrderCreate, order.OrderUID, changes))
+	return nil
+}
+
+func (r *memoryRepository) GetByID(ctx context.Context, orderUID string) (*domain.Order, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	stored, ok := r.db.orders[orderUID]
+	if !ok || stored.deleted {
+		return nil, domain.ErrOrderNotFound
+	}
+	return cloneOrder(&stored.order), nil
+}
+
+func (r *memoryRepository) Update(ctx context.Context, orderUID string, expectedVersion int64, patch domain.OrderPatch) (*domain.Order, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	stored, ok := r.db.orders[orderUID]
+	if !ok || stored.deleted {
+		return nil, domain.ErrOrderNotFound
+	}
+	before := &stored.order
+	if expectedVersion != 0 && before.Version != expectedVersion {
+		return nil, domain.ErrVersionMismatch
+	}
+	// Стертые персональные данные не восстанавливаются через изменение заказа
+	if patch.Delivery != nil && stored.erased {
+		return nil, domain.ErrPIIErased
+	}
+
+	after := cloneOrder(before)
+	if err := patch.Apply(after); err != nil {
+		return nil, err
+	}
+	if err := checkOrder(after); err != nil {
+		return nil, err
+	}
+	after.Version++
+	after.UpdatedAt = storedTime(time.Now())
+
+	changes, err := audit.Diff(before, after)
+	if err != nil {
+		return nil, err
+	}
+	stored.order = *cloneOrder(after)
+	r.db.appendEvent(audit.NewEvent(ctx, domain.AuditOrderUpdate, orderUID, changes))
+	return after, nil
+}
+
+// List возвращает заказы по фильтру, новые первыми.
+func (r *memoryRepository) List(ctx context.Context, filter domain.OrderFilter) ([]domain.Order, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	matched := r.find(filter)
+	sort.Slice(matched, func(i, j int) bool { return listedBefore(matched[i], matched[j]) })
+	orders := []domain.Order{}
+	for _, order := range matched {
+		if len(orders) == filter.Limit {
+			break
+		}
+		orders = append(orders, *order)
+	}
+	return orders, nil
+}
+
+// Export отдает в fn копии заказов по фильтру в порядке date_created. fn вызывается
+// без блокировки хранилища.
+func (r *memoryRepository) Export(ctx context.Context, filter domain.OrderFilter, fn func(*domain.Order) error) error {
+	matched := r.find(filter)
+	sort.Slice(matched, func(i, j int) bool { return exportsBefore(matched[i], matched[j]) })
+	for i, order := range matched {
+		if filter.Limit > 0 && i == filter.Limit {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// find возвращает копии заказов, подходящих под фильтр, без учета Limit.
+func (r *memoryRepository) find(filter domain.OrderFilter) []*domain.Order {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	var matched []*domain.Order
+	for _, stored := range r.db.orders {
+		if matchesFilter(stored, filter) {
+			matched = append(matched, cloneOrder(&stored.order))
+		}
+	}
+	return matched
+}
+
+// Delete мягко удаляет заказ: он пропадает из чтения, но остается для стирания и аудита.
+func (r *memoryRepository) Delete(ctx context.Context, orderUID, actor string) (int64, error) {
+	deletedAt := storedTime(time.Now())
+	changes, err := json.Marshal(map[string]audit.Change{"deleted_at": {New: deletedAt}})
+	if err != nil {
+		return 0, err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	stored, ok := r.db.orders[orderUID]
+	if !ok || stored.deleted {
+		return 0, domain.ErrOrderNotFound
+	}
+	stored.deleted = true
+	stored.order.Version++
+	stored.order.UpdatedAt = deletedAt
+	r.db.appendEvent(audit.NewEvent(ctx, domain.AuditOrderDelete, orderUID, changes))
+	return stored.order.Version, nil
+}
+
+// EraseOrder затирает персональные данные доставки. Работает и для мягко удаленных заказов.
+func (r *memoryRepository) EraseOrder(ctx context.Context, orderUID, actor string) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	stored, ok := r.db.orders[orderUID]
+	if !ok {
+		return 0, domain.ErrOrderNotFound
+	}
+	if err := r.erase(ctx, stored); err != nil {
+		return 0, err
+	}
+	return stored.order.Version, nil
+}
+
+// EraseCustomer затирает персональные данные во всех заказах покупателя и возвращает их версии.
+func (r *memoryRepository) EraseCustomer(ctx context.Context, customerID, actor string) ([]domain.OrderVersion, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var uids []string
+	for uid, stored := range r.db.orders {
+		if stored.order.CustomerID == customerID {
+			uids = append(uids, uid)
+		}
+	}
+	if len(uids) == 0 {
+		return nil, domain.ErrOrderNotFound
+	}
+	sort.Strings(uids)
+	versions := make([]domain.OrderVersion, len(uids))
+	for i, uid := range uids {
+		stored := r.db.orders[uid]
+		if err := r.erase(ctx, stored); err != nil {
+			return nil, err
+		}
+		versions[i] = domain.OrderVersion{OrderUID: uid, Version: stored.order.Version}
+	}
+	return versions, nil
+}
+
+// erase затирает еще не стертую доставку и пишет журнал. Вызывается под блокировкой записи.
+func (r *memoryRepository) erase(ctx context.Context, stored *memoryOrder) error {
+	if stored.erased {
+		return nil
+	}
+	changes, err := json.Marshal(map[string][]string{"erased_fields": domain.ErasedDeliveryFields})
+	if err != nil {
+		return err
+	}
+	d := &stored.order.Delivery
+	d.Name, d.Phone, d.Zip, d.Address, d.Email = domain.ErasedValue, domain.ErasedValue,
+		domain.ErasedValue, domain.ErasedValue, domain.ErasedValue
+	stored.erased = true
+	// Содержимое заказа изменилось: новая версия не даст клиентам и кэшу считать старую актуальной
+	stored.order.Version++
+	stored.order.UpdatedAt = storedTime(time.Now())
+	r.db.appendEvent(audit.NewEvent(ctx, domain.AuditOrderErase, stored.order.OrderUID, changes))
+	return nil
+}
+
+// memoryAuditRepository — журнал аудита поверх MemoryDB.
+type memoryAuditRepository struct {
+	db *MemoryDB
+}
+
+// NewMemoryAuditRepository создает журнал аудита в памяти.
+func NewMemoryAuditRepository(db *MemoryDB) AuditRepository {
+	return &memoryAuditRepository{db: db}
+}
+
+func (r *memoryAuditRepository) Record(ctx context.Context, event domain.AuditEvent) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	r.db.appendEvent(event)
+	return nil
+}
+
+// ListByOrder возвращает события заказа в хронологическом порядке.
+func (r *memoryAuditRepository) ListByOrder(ctx context.Context, orderUID string) ([]domain.AuditEvent, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	events := []domain.AuditEvent{}
+	for _, event := range r.db.events {
+		if event.OrderUID == orderUID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"order_service/internal/config"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// partitionTables — секционированные таблицы заказа в порядке выгрузки.
+// Удаляются они в обратном порядке: дочерние раньше заказов.
+var partitionTables = []string{"orders", "deliveries", "payments", "items"}
+
+// partitionSuffix — формат суффикса помесячной секции: orders_p202601.
+const partitionSuffix = "p200601"
+
+// PartitionManager обслуживает помесячные секции заказов: заранее создает будущие
+// и выгружает в архив старые.
+type PartitionManager struct {
+	db  *pgxpool.Pool
+	cfg config.DatabasePartitions
+}
+
+// NewPartitionManager создает PartitionManager. С нулевым check_interval не запустится Run.
+func NewPartitionManager(db *pgxpool.Pool, cfg config.DatabasePartitions) (*PartitionManager, error) {
+	if cfg.CheckInterval <= 0 {
+		return nil, fmt.Errorf("data_base.partitions.check_interval must be positive, got %s", cfg.CheckInterval)
+	}
+	return &PartitionManager{db: db, cfg: cfg}, nil
+}
+
+// Run создает секции при старте и затем раз в check_interval, пока не отменен ctx.
+func (m *PartitionManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.EnsureAhead(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to create order partitions: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// EnsureAhead создает секции текущего месяца и ahead следующих. Реплики сервиса делают это
+// под общей advisory-блокировкой. Если в секции по умолчанию уже лежат строки нового месяца,
+// Postgres откажет в создании секции — такие строки нужно перенести вручную.
+func (m *PartitionManager) EnsureAhead(ctx context.Context) error {
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres begin tx error: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('create_order_partitions'))`); err != nil {
+		return fmt.Errorf("postgres partition lock error: %w", err)
+	}
+	_, err = tx.Exec(ctx, `SELECT create_order_partitions(date_trunc('month', now() AT TIME ZONE 'UTC')::date, $1)`,
+		m.cfg.Ahead+1)
+	if err != nil {
+		return fmt.Errorf("postgres create partitions error: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// Expired возвращает месяцы (UTC), секции которых целиком старше retention на момент now,
+// от старых к новым. При нулевом retention ничего не устаревает. Строки из секций *_default
+// в месяцы не попадают и не архивируются — их число показывает ExpiredInDefault.
+func (m *PartitionManager) Expired(ctx context.Context, now time.Time, retention time.Duration) ([]time.Time, error) {
+	if retention <= 0 {
+		return nil, nil
+	}
+	rows, err := m.db.Query(ctx, `
+        SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+        WHERE i.inhparent = 'orders'::regclass`)
+	if err != nil {
+		return nil, fmt.Errorf("postgres partitions query error: %w", err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("postgres partitions scan error: %w", err)
+	}
+	return expiredMonths(names, now.Add(-retention)), nil
+}
+
+// expiredMonths отбирает из имен секций orders месяцы, закончившиеся не позже cutoff,
+// от старых к новым.
+func expiredMonths(names []string, cutoff time.Time) []time.Time {
+	var months []time.Time
+	for _, name := range names {
+		suffix, ok := strings.CutPrefix(name, "orders_")
+		if !ok {
+			continue
+		}
+		month, err := time.Parse(partitionSuffix, suffix)
+		if err != nil {
+			continue // orders_default и посторонние секции
+		}
+		if !month.AddDate(0, 1, 0).After(cutoff) {
+			months = append(months, month)
+		}
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Before(months[j]) })
+	return months
+}
+
+// ExpiredInDefault возвращает число заказов в orders_default из месяцев, которые Expired
+// считает устаревшими. Такие заказы попадают туда, если секции их месяца не было при записи;
+// Archive их не выгружает, и до переноса в помесячную секцию они остаются в БД.
+func (m *PartitionManager) ExpiredInDefault(ctx context.Context, now time.Time, retention time.Duration) (int, error) {
+	if retention <= 0 {
+		return 0, nil
+	}
+	cutoff := now.Add(-retention).UTC()
+	before := time.Date(cutoff.Year(), cutoff.Month(), 1, 0, 0, 0, 0, time.UTC)
+	var n int
+	err := m.db.QueryRow(ctx, `SELECT count(*) FROM orders_default WHERE date_created < $1`, before).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("postgres default partition query error: %w", err)
+	}
+	return n, nil
+}
+
+// Archive выгружает секции месяца в w как NDJSON — строки вида {"table": ..., "row": ...}
+// в том виде, в котором они хранятся (персональные данные остаются зашифрованными), — и удаляет
+// секции. Заказы месяца помечаются в реестре архивными с именем архива name.
+// w закрывается (фиксируя архив) только после успешной выгрузки и до удаления секций:
+// если запись архива не удалась, секции остаются. При ошибке w не закрывается.
+// Возвращает число выгруженных заказов.
+func (m *PartitionManager) Archive(ctx context.Context, month time.Time, name string, w io.WriteCloser) (int, error) {
+	suffix := month.UTC().Format(partitionSuffix)
+	partition := func(table string) string {
+		return pgx.Identifier{table + "_" + suffix}.Sanitize()
+	}
+
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("postgres begin tx error: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Запись в секции запрещена до конца транзакции: архив совпадает с удаляемыми строками
+	orders := 0
+	for _, table := range partitionTables {
+		if _, err := tx.Exec(ctx, "LOCK TABLE "+partition(table)+" IN EXCLUSIVE MODE"); err != nil {
+			return 0, fmt.Errorf("postgres lock partition error: %w", err)
+		}
+		n, err := dumpPartition(ctx, tx, table, partition(table), w)
+		if err != nil {
+			return 0, err
+		}
+		if table == "orders" {
+			orders = n
+		}
+	}
+	if err := w.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write archive %s: %w", name, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+        UPDATE order_keys SET archived_at = now(), archive = $1
+        WHERE order_uid IN (SELECT order_uid FROM `+partition("orders")+`)`, name)
+	if err != nil {
+		return 0, fmt.Errorf("postgres mark archived error: %w", err)
+	}
+	for i := len(partitionTables) - 1; i >= 0; i-- {
+		table := partitionTables[i]
+		if _, err := tx.Exec(ctx, "ALTER TABLE "+table+" DETACH PARTITION "+partition(table)); err != nil {
+			return 0, fmt.Errorf("postgres detach partition error: %w", err)
+		}
+		if _, err := tx.Exec(ctx, "DROP TABLE "+partition(table)); err != nil {
+			return 0, fmt.Errorf("postgres drop partition error: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("postgres commit error: %w", err)
+	}
+	return orders, nil
+}
+
+// dumpPartition пишет строки секции в w и возвращает их число.
+func dumpPartition(ctx context.Context, tx pgx.Tx, table, partition string, w io.Writer) (int, error) {
+	rows, err := tx.Query(ctx, "SELECT row_to_json(t)::text FROM "+partition+" t")
+	if err != nil {
+		return 0, fmt.Errorf("postgres dump partition error: %w", err)
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		var row []byte
+		if err := rows.Scan(&row); err != nil {
+			return 0, fmt.Errorf("postgres dump scan error: %w", err)
+		}
+		if _, err := fmt.Fprintf(w, `{"table":%q,"row":%s}`+"\n", table, row); err != nil {
+			return 0, fmt.Errorf("failed to write archive: %w", err)
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("postgres dump iteration error: %w", err)
+	}
+	return n, nil
+}
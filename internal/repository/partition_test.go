@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"order_service/internal/config"
+)
+
+func TestExpiredMonths(t *testing.T) {
+	month := func(year int, m time.Month) time.Time {
+		return time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+	}
+	names := []string{
+		"orders_p202603", "orders_default", "orders_p202601", "orders_p202602",
+		"orders_archive", "orders_p2026", "deliveries_p202601", "orders_p202604",
+	}
+	tests := []struct {
+		name   string
+		cutoff time.Time
+		want   []time.Time
+	}{
+		{"before all", month(2026, time.January), nil},
+		{"inside first month", month(2026, time.January).Add(15 * 24 * time.Hour), nil},
+		{"first month end", month(2026, time.February), []time.Time{month(2026, time.January)}},
+		{"just before month end", month(2026, time.March).Add(-time.Nanosecond), []time.Time{month(2026, time.January)}},
+		{"inside later month", month(2026, time.March).Add(time.Hour), []time.Time{month(2026, time.January), month(2026, time.February)}},
+		{"after all", month(2027, time.January), []time.Time{
+			month(2026, time.January), month(2026, time.February), month(2026, time.March), month(2026, time.April),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expiredMonths(names, tt.cutoff); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expiredMonths(%s) = %v, want %v", tt.cutoff, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpiredWithoutRetention(t *testing.T) {
+	// Без retention БД не опрашивается
+	m := &PartitionManager{}
+	months, err := m.Expired(context.Background(), time.Now(), 0)
+	if err != nil || months != nil {
+		t.Fatalf("Expired = %v, %v, want nothing", months, err)
+	}
+	n, err := m.ExpiredInDefault(context.Background(), time.Now(), 0)
+	if err != nil || n != 0 {
+		t.Fatalf("ExpiredInDefault = %d, %v, want 0", n, err)
+	}
+}
+
+func TestNewPartitionManagerRejectsInvalidInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Hour} {
+		if _, err := NewPartitionManager(nil, config.DatabasePartitions{CheckInterval: interval}); err == nil {
+			t.Errorf("check_interval %s accepted", interval)
+		}
+	}
+	if _, err := NewPartitionManager(nil, config.DatabasePartitions{CheckInterval: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+}
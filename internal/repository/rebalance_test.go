@@ -0,0 +1,257 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"order_service/internal/audit"
+	"order_service/internal/domain"
+	"order_service/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Перенос заказов между шардами при переходе с одного шарда на два. Шарды — отдельные
+// схемы тестовой БД из postgresDSNEnv.
+
+// rebalanceEnv — основная БД и второй шард, заказы в которых созданы до добавления второго шарда.
+type rebalanceEnv struct {
+	dbs     []*pgxpool.Pool
+	single  *repository.ShardedRepository // только основная БД
+	sharded *repository.ShardedRepository // основная БД и второй шард
+	gen     *domain.Generator
+	seq     int
+}
+
+func newRebalanceEnv(t *testing.T) *rebalanceEnv {
+	dbs := []*pgxpool.Pool{
+		openTestPostgres(t, pgx.QueryExecModeCacheStatement),
+		openTestPostgres(t, pgx.QueryExecModeCacheStatement),
+	}
+	single, err := repository.NewShardedRepository(dbs[:1], nil, repository.ShardLookupDirectory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharded, err := repository.NewShardedRepository(dbs, nil, repository.ShardLookupDirectory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, err := domain.NewGenerator(domain.DefaultGeneratorOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &rebalanceEnv{dbs: dbs, single: single, sharded: sharded, gen: gen}
+}
+
+// order возвращает заказ, которому при двух шардах место в шарде to.
+func (e *rebalanceEnv) order(to int) *domain.Order {
+	e.seq++
+	order := e.gen.Next()
+	order.OrderUID = fmt.Sprintf("rebalance-%d", e.seq)
+	order.DateCreated = time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	for i := 0; ; i++ {
+		order.Shardkey = fmt.Sprintf("key-%d", i)
+		if repository.ShardFor(order.Shardkey, len(e.dbs)) == to {
+			return &order
+		}
+	}
+}
+
+// created сохраняет заказ в основную БД, как до добавления второго шарда.
+func (e *rebalanceEnv) created(t *testing.T, to int) *domain.Order {
+	t.Helper()
+	order := e.order(to)
+	if err := e.single.Create(context.Background(), order); err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
+
+// shard возвращает репозиторий одного шарда без каталога.
+func (e *rebalanceEnv) shard(id int) repository.OrderRepository {
+	return repository.NewOrderRepository(e.dbs[id], nil)
+}
+
+// expectOn проверяет, что заказ лежит только в шарде id и каталог указывает на него.
+func (e *rebalanceEnv) expectOn(t *testing.T, orderUID string, id int) *domain.Order {
+	t.Helper()
+	ctx := context.Background()
+	order, err := e.shard(id).GetByID(ctx, orderUID)
+	if err != nil {
+		t.Fatalf("order %s on shard %d: %v", orderUID, id, err)
+	}
+	for other := range e.dbs {
+		if other == id {
+			continue
+		}
+		if _, err := e.shard(other).GetByID(ctx, orderUID); !errors.Is(err, domain.ErrOrderNotFound) {
+			t.Fatalf("order %s on shard %d: want %v, got %v", orderUID, other, domain.ErrOrderNotFound, err)
+		}
+	}
+
+	var shard int
+	err = e.dbs[0].QueryRow(ctx, `SELECT shard FROM order_shards WHERE order_uid = $1`, orderUID).Scan(&shard)
+	if err != nil {
+		t.Fatalf("directory row of %s: %v", orderUID, err)
+	}
+	if shard != id {
+		t.Fatalf("directory points %s to shard %d, want %d", orderUID, shard, id)
+	}
+	return order
+}
+
+// moves возвращает события переноса заказа из журналов всех шардов.
+func (e *rebalanceEnv) moves(t *testing.T, orderUID string) []domain.AuditEvent {
+	t.Helper()
+	events, err := repository.NewShardedAuditRepository(e.dbs).ListByOrder(context.Background(), orderUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var moves []domain.AuditEvent
+	for _, event := range events {
+		if event.Action == domain.AuditOrderMove {
+			moves = append(moves, event)
+		}
+	}
+	return moves
+}
+
+// rebalance переносит заказы пачками по одному, чтобы пройти постраничный просмотр.
+func (e *rebalanceEnv) rebalance(t *testing.T, dryRun bool) (int, []string) {
+	t.Helper()
+	var listed []string
+	n, err := e.sharded.Rebalance(context.Background(), 1, dryRun, func(orderUID string, from, to int) {
+		listed = append(listed, fmt.Sprintf("%s:%d->%d", orderUID, from, to))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n, listed
+}
+
+func TestRebalanceMovesOrderAuditAndDirectory(t *testing.T) {
+	e := newRebalanceEnv(t)
+	ctx := context.Background()
+	stays, moves := e.created(t, 0), e.created(t, 1)
+	want := []string{moves.OrderUID + ":0->1"}
+
+	n, listed := e.rebalance(t, true)
+	if err := expectStrings("dry run", listed, want); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("dry run found %d orders, want 1", n)
+	}
+	if _, err := e.shard(1).GetByID(ctx, moves.OrderUID); !errors.Is(err, domain.ErrOrderNotFound) {
+		t.Fatalf("dry run moved order: %v", err)
+	}
+
+	n, listed = e.rebalance(t, false)
+	if err := expectStrings("rebalance", listed, want); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("moved %d orders, want 1", n)
+	}
+	moved := e.expectOn(t, moves.OrderUID, 1)
+	if moved.Delivery != moves.Delivery || len(moved.Items) != len(moves.Items) || moved.Version != moves.Version {
+		t.Fatalf("moved order differs from created")
+	}
+	if _, err := e.shard(0).GetByID(ctx, stays.OrderUID); err != nil {
+		t.Fatalf("order %s left shard 0: %v", stays.OrderUID, err)
+	}
+
+	events := e.moves(t, moves.OrderUID)
+	if len(events) != 1 {
+		t.Fatalf("got %d move events, want 1", len(events))
+	}
+	var changes map[string]audit.Change
+	if err := json.Unmarshal(events[0].Changes, &changes); err != nil {
+		t.Fatal(err)
+	}
+	if got := changes["shard"].New; got != float64(1) {
+		t.Fatalf("move event changes %s, want shard 1", events[0].Changes)
+	}
+
+	// Дальнейшие изменения и их события пишутся уже в целевой шард
+	city := "Moved City"
+	if _, err := e.sharded.Update(ctx, moves.OrderUID, 0, domain.OrderPatch{Delivery: &domain.DeliveryPatch{City: &city}}); err != nil {
+		t.Fatal(err)
+	}
+	targetEvents, err := repository.NewAuditRepository(e.dbs[1]).ListByOrder(ctx, moves.OrderUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targetEvents) == 0 || targetEvents[len(targetEvents)-1].Action != domain.AuditOrderUpdate {
+		t.Fatalf("update event is not on target shard: %v", targetEvents)
+	}
+
+	if n, _ := e.rebalance(t, false); n != 0 {
+		t.Fatalf("second rebalance moved %d orders, want 0", n)
+	}
+}
+
+func TestRebalanceResumesInterruptedMove(t *testing.T) {
+	tests := []struct {
+		name string
+		// interrupt оставляет заказ в состоянии прерванного переноса
+		interrupt func(t *testing.T, e *rebalanceEnv, order *domain.Order)
+		// wantCity — город в целевом шарде после доведения переноса
+		wantCity func(order *domain.Order) string
+	}{
+		{
+			name: "copied before directory switch",
+			interrupt: func(t *testing.T, e *rebalanceEnv, order *domain.Order) {
+				stale := *order
+				stale.Delivery.City = "Stale Copy"
+				if err := e.shard(1).Create(context.Background(), &stale); err != nil {
+					t.Fatal(err)
+				}
+			},
+			// Копия, на которую каталог еще не указывает, перезаписывается
+			wantCity: func(order *domain.Order) string { return order.Delivery.City },
+		},
+		{
+			name: "directory switched before source delete",
+			interrupt: func(t *testing.T, e *rebalanceEnv, order *domain.Order) {
+				ctx := context.Background()
+				copied := *order
+				copied.Delivery.City = "Target Copy"
+				if err := e.shard(1).Create(ctx, &copied); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := e.dbs[0].Exec(ctx, `UPDATE order_shards SET shard = 1 WHERE order_uid = $1`, order.OrderUID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			// Каталог уже указывает на копию, остается удалить исходный заказ
+			wantCity: func(*domain.Order) string { return "Target Copy" },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newRebalanceEnv(t)
+			order := e.created(t, 1)
+			tt.interrupt(t, e, order)
+
+			if n, _ := e.rebalance(t, false); n != 1 {
+				t.Fatalf("moved %d orders, want 1", n)
+			}
+			moved := e.expectOn(t, order.OrderUID, 1)
+			if want := tt.wantCity(order); moved.Delivery.City != want {
+				t.Fatalf("city on target shard %q, want %q", moved.Delivery.City, want)
+			}
+			if events := e.moves(t, order.OrderUID); len(events) != 1 {
+				t.Fatalf("got %d move events, want 1", len(events))
+			}
+			if n, _ := e.rebalance(t, false); n != 0 {
+				t.Fatalf("second rebalance moved %d orders, want 0", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"order_service/internal/config"
+	"order_service/internal/domain"
+	"order_service/internal/encryption"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ReplicatedRepository направляет запись в основную БД, а чтение — по кругу на здоровые
+// реплики. Если здоровых реплик нет, читает основная БД.
+type ReplicatedRepository struct {
+	primary  OrderRepository
+	replicas []*replica
+	next     atomic.Uint64
+
+	healthInterval time.Duration
+	pinWindow      time.Duration
+
+	mu   sync.Mutex
+	pins map[string]time.Time // UID заказа -> до какого момента читать его с основной БД
+}
+
+type replica struct {
+	id      int
+	db      *pgxpool.Pool
+	repo    OrderRepository
+	healthy atomic.Bool
+}
+
+// NewReplicatedRepository оборачивает репозиторий основной БД. Реплики считаются
+// нездоровыми до первой проверки в Run, поэтому health_interval должен быть положительным.
+func NewReplicatedRepository(primary OrderRepository, replicaDBs []*pgxpool.Pool, keyring *encryption.Keyring, cfg config.DatabaseReplicas) (*ReplicatedRepository, error) {
+	if cfg.HealthInterval <= 0 {
+		return nil, fmt.Errorf("data_base.replicas.health_interval must be positive, got %s", cfg.HealthInterval)
+	}
+	r := &ReplicatedRepository{
+		primary:        primary,
+		healthInterval: cfg.HealthInterval,
+		pinWindow:      cfg.PinWindow,
+		pins:           make(map[string]time.Time),
+	}
+	for i, db := range replicaDBs {
+		r.replicas = append(r.replicas, &replica{id: i, db: db, repo: NewOrderRepository(db, keyring)})
+	}
+	return r, nil
+}
+
+// Run проверяет реплики раз в health_interval, пока не отменен ctx,
+// и заодно чистит истекшие закрепления заказов.
+func (r *ReplicatedRepository) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.healthInterval)
+	defer ticker.Stop()
+
+	for {
+		r.checkReplicas(ctx)
+		r.prunePins()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *ReplicatedRepository) checkReplicas(ctx context.Context) {
+	for _, rep := range r.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, time.Second)
+		err := rep.db.Ping(pingCtx)
+		cancel()
+
+		healthy := err == nil
+		if rep.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Printf("Read replica #%d is healthy", rep.id)
+			} else {
+				log.Printf("Read replica #%d is unavailable: %v", rep.id, err)
+			}
+		}
+	}
+}
+
+// reader выбирает следующую здоровую реплику по кругу или основную БД.
+func (r *ReplicatedRepository) reader() OrderRepository {
+	n := uint64(len(r.replicas))
+	if n == 0 {
+		return r.primary
+	}
+	start := r.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if rep := r.replicas[(start+i)%n]; rep.healthy.Load() {
+			return rep.repo
+		}
+	}
+	return r.primary
+}
+
+// pin закрепляет заказы за основной БД на pin_window после записи.
+func (r *ReplicatedRepository) pin(uids ...string) {
+	if r.pinWindow <= 0 {
+		return
+	}
+	until := time.Now().Add(r.pinWindow)
+	r.mu.Lock()
+	for _, uid := range uids {
+		r.pins[uid] = until
+	}
+	r.mu.Unlock()
+}
+
+func (r *ReplicatedRepository) pinned(uid string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	until, ok := r.pins[uid]
+	return ok && time.Now().Before(until)
+}
+
+func (r *ReplicatedRepository) prunePins() {
+	now := time.Now()
+	r.mu.Lock()
+	for uid, until := range r.pins {
+		if !now.Before(until) {
+			delete(r.pins, uid)
+		}
+	}
+	r.mu.Unlock()
+}
+
+func (r *ReplicatedRepository) Create(ctx context.Context, order *domain.Order) error {
+	if err := r.primary.Create(ctx, order); err != nil {
+		return err
+	}
+	r.pin(order.OrderUID)
+	return nil
+}
+
+// GetByID читает с реплики, кроме недавно записанных заказов. Заказ, которого
+// на реплике нет или который реплика не смогла отдать, дочитывается с основной БД:
+// реплика могла еще не получить заказ, созданный другим экземпляром сервиса.
+func (r *ReplicatedRepository) GetByID(ctx context.Context, orderUID string) (*domain.Order, error) {
+	if r.pinned(orderUID) {
+		return r.primary.GetByID(ctx, orderUID)
+	}
+	reader := r.reader()
+	order, err := reader.GetByID(ctx, orderUID)
+	if err == nil || reader == r.primary || ctx.Err() != nil {
+		return order, err
+	}
+	if !errors.Is(err, domain.ErrOrderNotFound) {
+		log.Printf("Read replica failed for order %s, reading from primary: %v", orderUID, err)
+	}
+	return r.primary.GetByID(ctx, orderUID)
+}
+
+func (r *ReplicatedRepository) Update(ctx context.Context, orderUID string, expectedVersion int64, patch domain.OrderPatch) (*domain.Order, error) {
+	order, err := r.primary.Update(ctx, orderUID, expectedVersion, patch)
+	if err != nil {
+		return nil, err
+	}
+	r.pin(orderUID)
+	return order, nil
+}
+
+// List и Export читают с реплики: листинг может отставать от записи на время репликации.
+func (r *ReplicatedRepository) List(ctx context.Context, filter domain.OrderFilter) ([]domain.Order, error) {
+	return r.reader().List(ctx, filter)
+}
+
+func (r *ReplicatedRepository) Export(ctx context.Context, filter domain.OrderFilter, fn func(*domain.Order) error) error {
+	return r.reader().Export(ctx, filter, fn)
+}
+
+func (r *ReplicatedRepository) Delete(ctx context.Context, orderUID, actor string) (int64, error) {
+	version, err := r.primary.Delete(ctx, orderUID, actor)
+	if err != nil {
+		return 0, err
+	}
+	r.pin(orderUID)
+	return version, nil
+}
+
+func (r *ReplicatedRepository) EraseOrder(ctx context.Context, orderUID, actor string) (int64, error) {
+	version, err := r.primary.EraseOrder(ctx, orderUID, actor)
+	if err != nil {
+		return 0, err
+	}
+	r.pin(orderUID)
+	return version, nil
+}
+
+func (r *ReplicatedRepository) EraseCustomer(ctx context.Context, customerID, actor string) ([]domain.OrderVersion, error) {
+	versions, err := r.primary.EraseCustomer(ctx, customerID, actor)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		r.pin(v.OrderUID)
+	}
+	return versions, nil
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"order_service/internal/config"
+	"order_service/internal/domain"
+)
+
+// routedRepo — хранилище, которое запоминает, сколько чтений ему досталось.
+// Незаданные методы OrderRepository не вызываются.
+type routedRepo struct {
+	OrderRepository
+	name string
+
+	mu      sync.Mutex
+	reads   int
+	missing map[string]bool // заказы, которых здесь нет
+}
+
+func (r *routedRepo) read() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reads++
+}
+
+func (r *routedRepo) readCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reads
+}
+
+func (r *routedRepo) Create(ctx context.Context, order *domain.Order) error { return nil }
+
+func (r *routedRepo) GetByID(ctx context.Context, orderUID string) (*domain.Order, error) {
+	r.read()
+	if r.missing[orderUID] {
+		return nil, domain.ErrOrderNotFound
+	}
+	return &domain.Order{OrderUID: orderUID, TrackNumber: r.name}, nil
+}
+
+func (r *routedRepo) Update(ctx context.Context, orderUID string, expectedVersion int64, patch domain.OrderPatch) (*domain.Order, error) {
+	return &domain.Order{OrderUID: orderUID}, nil
+}
+
+func (r *routedRepo) List(ctx context.Context, filter domain.OrderFilter) ([]domain.Order, error) {
+	r.read()
+	return nil, nil
+}
+
+func (r *routedRepo) Delete(ctx context.Context, orderUID, actor string) (int64, error) {
+	return 2, nil
+}
+
+func (r *routedRepo) EraseCustomer(ctx context.Context, customerID, actor string) ([]domain.OrderVersion, error) {
+	return []domain.OrderVersion{{OrderUID: "c1", Version: 2}, {OrderUID: "c2", Version: 2}}, nil
+}
+
+// newTestReplicated собирает репозиторий из основной БД и n реплик; healthy — какие реплики здоровы.
+func newTestReplicated(pinWindow time.Duration, healthy ...bool) (*ReplicatedRepository, *routedRepo, []*routedRepo) {
+	primary := &routedRepo{name: "primary"}
+	r := &ReplicatedRepository{primary: primary, pinWindow: pinWindow, pins: make(map[string]time.Time)}
+	var repos []*routedRepo
+	for i, ok := range healthy {
+		repo := &routedRepo{name: "replica"}
+		rep := &replica{id: i, repo: repo}
+		rep.healthy.Store(ok)
+		r.replicas = append(r.replicas, rep)
+		repos = append(repos, repo)
+	}
+	return r, primary, repos
+}
+
+func TestReplicatedReadsWithoutReplicasGoToPrimary(t *testing.T) {
+	r, primary, _ := newTestReplicated(0)
+	r.List(context.Background(), domain.OrderFilter{})
+	if primary.readCount() != 1 {
+		t.Fatalf("primary reads = %d, want 1", primary.readCount())
+	}
+}
+
+func TestReplicatedRoundRobin(t *testing.T) {
+	r, primary, replicas := newTestReplicated(0, true, true, true)
+	for i := 0; i < 9; i++ {
+		if _, err := r.List(context.Background(), domain.OrderFilter{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, rep := range replicas {
+		if rep.readCount() != 3 {
+			t.Errorf("replica #%d reads = %d, want 3", i, rep.readCount())
+		}
+	}
+	if primary.readCount() != 0 {
+		t.Errorf("primary reads = %d, want 0", primary.readCount())
+	}
+}
+
+func TestReplicatedSkipsUnhealthyReplicas(t *testing.T) {
+	r, primary, replicas := newTestReplicated(0, true, false, true)
+	for i := 0; i < 10; i++ {
+		r.List(context.Background(), domain.OrderFilter{})
+	}
+	if replicas[1].readCount() != 0 {
+		t.Errorf("unhealthy replica reads = %d, want 0", replicas[1].readCount())
+	}
+	if replicas[0].readCount()+replicas[2].readCount() != 10 || primary.readCount() != 0 {
+		t.Errorf("reads: replicas %d and %d, primary %d", replicas[0].readCount(), replicas[2].readCount(), primary.readCount())
+	}
+
+	// Без здоровых реплик читает основная БД
+	for _, rep := range r.replicas {
+		rep.healthy.Store(false)
+	}
+	r.List(context.Background(), domain.OrderFilter{})
+	if primary.readCount() != 1 {
+		t.Errorf("primary reads = %d, want 1", primary.readCount())
+	}
+}
+
+func TestReplicatedPinsWrittenOrdersToPrimary(t *testing.T) {
+	ctx := context.Background()
+	r, primary, replicas := newTestReplicated(50*time.Millisecond, true)
+
+	r.Create(ctx, &domain.Order{OrderUID: "created"})
+	r.Update(ctx, "updated", 0, domain.OrderPatch{})
+	r.Delete(ctx, "deleted", "admin")
+	r.EraseCustomer(ctx, "customer", "admin")
+	for _, uid := range []string{"created", "updated", "deleted", "c1", "c2"} {
+		order, err := r.GetByID(ctx, uid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if order.TrackNumber != "primary" {
+			t.Errorf("%s read from %s right after write, want primary", uid, order.TrackNumber)
+		}
+	}
+	if order, _ := r.GetByID(ctx, "other"); order.TrackNumber != "replica" {
+		t.Errorf("unpinned order read from %s, want replica", order.TrackNumber)
+	}
+
+	// После pin_window заказ снова читается с реплики, а закрепление удаляется
+	time.Sleep(60 * time.Millisecond)
+	if order, _ := r.GetByID(ctx, "created"); order.TrackNumber != "replica" {
+		t.Errorf("order read from %s after pin window, want replica", order.TrackNumber)
+	}
+	r.prunePins()
+	if len(r.pins) != 0 {
+		t.Errorf("pins after prune = %v", r.pins)
+	}
+	if primary.readCount() != 5 || replicas[0].readCount() != 2 {
+		t.Errorf("reads: primary %d, replica %d", primary.readCount(), replicas[0].readCount())
+	}
+}
+
+func TestReplicatedWithoutPinWindowReadsReplica(t *testing.T) {
+	ctx := context.Background()
+	r, _, _ := newTestReplicated(0, true)
+	r.Create(ctx, &domain.Order{OrderUID: "created"})
+	if order, _ := r.GetByID(ctx, "created"); order.TrackNumber != "replica" {
+		t.Errorf("order read from %s, want replica", order.TrackNumber)
+	}
+}
+
+func TestReplicatedFallsBackToPrimaryOnMiss(t *testing.T) {
+	ctx := context.Background()
+	r, primary, replicas := newTestReplicated(0, true)
+	replicas[0].missing = map[string]bool{"lagging": true}
+
+	order, err := r.GetByID(ctx, "lagging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.TrackNumber != "primary" || primary.readCount() != 1 {
+		t.Errorf("order read from %s, primary reads %d", order.TrackNumber, primary.readCount())
+	}
+
+	// Заказа нет нигде: ошибка основной БД
+	primary.missing = map[string]bool{"absent": true}
+	replicas[0].missing["absent"] = true
+	if _, err := r.GetByID(ctx, "absent"); !errors.Is(err, domain.ErrOrderNotFound) {
+		t.Errorf("GetByID(absent): err = %v, want ErrOrderNotFound", err)
+	}
+}
+
+func TestNewReplicatedRepositoryRejectsInvalidInterval(t *testing.T) {
+	primary := &routedRepo{name: "primary"}
+	for _, interval := range []time.Duration{0, -time.Second} {
+		cfg := config.DatabaseReplicas{HealthInterval: interval, PinWindow: time.Second}
+		if _, err := NewReplicatedRepository(primary, nil, nil, cfg); err == nil {
+			t.Errorf("health_interval %s accepted", interval)
+		}
+	}
+	if _, err := NewReplicatedRepository(primary, nil, nil, config.DatabaseReplicas{HealthInterval: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,35 +2,72 @@ package repository
 
 import (
 	"context"
-	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 
+	"order_service/internal/audit"
 	"order_service/internal/domain"
+	"order_service/internal/encryption"
 
-	"github.com/lib/pq"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // OrderRepository определяет интерфейс для работы с заказами в хранилище.
 type OrderRepository interface {
 	Create(ctx context.Context, order *domain.Order) error
 	GetByID(ctx context.Context, orderUID string) (*domain.Order, error)
+	// Update применяет патч, если текущая версия заказа равна expectedVersion (0 — без проверки),
+	// и возвращает заказ с новой версией.
+	Update(ctx context.Context, orderUID string, expectedVersion int64, patch domain.OrderPatch) (*domain.Order, error)
+	List(ctx context.Context, filter domain.OrderFilter) ([]domain.Order, error)
+	Export(ctx context.Context, filter domain.OrderFilter, fn func(*domain.Order) error) error
+	// Delete мягко удаляет заказ и возвращает новую версию: удаление — тоже изменение заказа.
+	Delete(ctx context.Context, orderUID, actor string) (int64, error)
+	// EraseOrder стирает персональные данные заказа и возвращает его версию после стирания.
+	EraseOrder(ctx context.Context, orderUID, actor string) (int64, error)
+	// EraseCustomer стирает персональные данные во всех заказах покупателя и возвращает
+	// их UID с версиями после стирания, по возрастанию UID.
+	EraseCustomer(ctx context.Context, customerID, actor string) ([]domain.OrderVersion, error)
 }
 
-// orderRepository — реализация OrderRepository с использованием PostgreSQL.
+// orderRepository — реализация OrderRepository с использованием PostgreSQL (pgx).
 type orderRepository struct {
-	db *sql.DB
+	db      *pgxpool.Pool
+	keyring *encryption.Keyring // nil — персональные данные хранятся открытым текстом
 }
 
 // NewOrderRepository создает новый экземпляр orderRepository.
-func NewOrderRepository(db *sql.DB) OrderRepository {
-	return &orderRepository{db: db}
+// keyring может быть nil, если шифрование персональных данных выключено.
+func NewOrderRepository(db *pgxpool.Pool, keyring *encryption.Keyring) OrderRepository {
+	return &orderRepository{db: db, keyring: keyring}
 }
 
+// orderMonth — date_created заказа $1 из реестра. Условие по ключу секционирования
+// позволяет планировщику читать только секцию месяца заказа.
+const orderMonth = `(SELECT date_created FROM order_keys WHERE order_uid = $1)`
+
+// dbtx — общее у *pgxpool.Pool и pgx.Tx.
+type dbtx interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// itemColumns — колонки товаров в порядке вставки через COPY.
+var itemColumns = []string{"order_uid", "chrt_id", "track_number", "price", "rid", "name",
+	"sale", "size", "total_price", "nm_id", "brand", "status", "date_created"}
+
 // Create сохраняет заказ и связанные данные в базу данных.
 func (r *orderRepository) Create(ctx context.Context, order *domain.Order) error {
 	// Проверка уникальности order_uid
 	var exists bool
-	err := r.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM orders WHERE order_uid = $1)`, order.OrderUID).Scan(&exists)
+	err := r.db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM order_keys WHERE order_uid = $1)`, order.OrderUID).Scan(&exists)
 	if err != nil {
 		return fmt.Errorf("postgres check exists error: %w", err)
 	}
@@ -39,60 +76,101 @@ func (r *orderRepository) Create(ctx context.Context, order *domain.Order) error
 	}
 
 	// Начать транзакцию
-	tx, err := r.db.BeginTx(ctx, nil)
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("postgres begin tx error: %w", err)
 	}
-	defer tx.Rollback()
+	defer tx.Rollback(ctx)
+
+	// Реестр UID держит уникальность заказа поверх всех секций
+	_, err = tx.Exec(ctx, `INSERT INTO order_keys (order_uid, date_created) VALUES ($1, $2)`,
+		order.OrderUID, order.DateCreated)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+			return domain.ErrOrderUIDNotUnique // Дубликат на уровне БД
+		}
+		if cErr := constraintError(err); cErr != nil {
+			return cErr
+		}
+		return fmt.Errorf("postgres insert order key error: %w", err)
+	}
 
 	// Вставка основного заказа
-	_, err = tx.ExecContext(ctx, `
+	err = tx.QueryRow(ctx, `
         INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        RETURNING version, updated_at`,
 		order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
-		order.CustomerID, order.DeliveryService, order.Shardkey, order.SmID, order.DateCreated, order.OofShard)
+		order.CustomerID, order.DeliveryService, order.Shardkey, order.SmID, order.DateCreated, order.OofShard).
+		Scan(&order.Version, &order.UpdatedAt)
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-			return domain.ErrOrderUIDNotUnique // Дубликат на уровне БД
+		if cErr := constraintError(err); cErr != nil {
+			return cErr
 		}
 		return fmt.Errorf("postgres insert order error: %w", err)
 	}
 
-	// Вставка данных доставки
-	_, err = tx.ExecContext(ctx, `
-        INSERT INTO deliveries (order_uid, name, phone, zip, city, address, region, email)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
-		order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip,
-		order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email)
+	// Доставка и оплата уходят одним пакетом. Дочерние строки несут date_created заказа,
+	// чтобы лечь в секции того же месяца
+	delivery, err := r.sealDelivery(order.OrderUID, order.Delivery)
 	if err != nil {
-		return fmt.Errorf("postgres insert delivery error: %w", err)
+		return err
 	}
-
-	// Вставка данных оплаты
-	_, err = tx.ExecContext(ctx, `
-        INSERT INTO payments (order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+	batch := &pgx.Batch{}
+	batch.Queue(`
+        INSERT INTO deliveries (order_uid, name, phone, zip, city, address, region, email, dek, email_bidx, phone_bidx, date_created)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		order.OrderUID, delivery.Name, delivery.Phone, delivery.Zip,
+		delivery.City, delivery.Address, delivery.Region, delivery.Email,
+		delivery.dek, delivery.emailBidx, delivery.phoneBidx, order.DateCreated)
+	batch.Queue(`
+        INSERT INTO payments (order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee, date_created)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
 		order.OrderUID, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency,
 		order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDt, order.Payment.Bank,
-		order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee)
-	if err != nil {
-		return fmt.Errorf("postgres insert payment error: %w", err)
+		order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee, order.DateCreated)
+	results := tx.SendBatch(ctx, batch)
+	for _, what := range []string{"delivery", "payment"} {
+		if _, err := results.Exec(); err != nil {
+			results.Close()
+			if cErr := constraintError(err); cErr != nil {
+				return cErr
+			}
+			return fmt.Errorf("postgres insert %s error: %w", what, err)
+		}
+	}
+	if err := results.Close(); err != nil {
+		return fmt.Errorf("postgres batch error: %w", err)
 	}
 
-	// Вставка товаров
-	for _, item := range order.Items {
-		_, err = tx.ExecContext(ctx, `
-            INSERT INTO items (order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status)
-            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
-			order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.Rid, item.Name,
-			item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status)
+	// Вставка товаров через COPY
+	if len(order.Items) > 0 {
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"items"}, itemColumns,
+			pgx.CopyFromSlice(len(order.Items), func(i int) ([]any, error) {
+				item := order.Items[i]
+				return []any{order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.Rid, item.Name,
+					item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status, order.DateCreated}, nil
+			}))
 		if err != nil {
-			return fmt.Errorf("postgres insert item error: %w", err)
+			if cErr := constraintError(err); cErr != nil {
+				return cErr
+			}
+			return fmt.Errorf("postgres insert items error: %w", err)
 		}
 	}
 
+	// Запись аудита в той же транзакции
+	changes, err := audit.Diff(nil, order)
+	if err != nil {
+		return err
+	}
+	if err := insertAuditEvent(ctx, tx, audit.NewEvent(ctx, domain.AuditOrderCreate, order.OrderUID, changes)); err != nil {
+		return err
+	}
+
 	// Подтвердить транзакцию
-	if err := tx.Commit(); err != nil {
+	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("postgres commit error: %w", err)
 	}
 	return nil
@@ -100,52 +178,88 @@ func (r *orderRepository) Create(ctx context.Context, order *domain.Order) error
 
 // GetByID получает заказ по order_uid.
 func (r *orderRepository) GetByID(ctx context.Context, orderUID string) (*domain.Order, error) {
+	return r.getOrder(ctx, r.db, orderUID, false)
+}
+
+// getOrder читает заказ через q одним пакетом запросов: реестр, заказ, доставка, оплата и товары
+// приходят за один проход до БД. С forUpdate строка заказа блокируется до конца транзакции.
+func (r *orderRepository) getOrder(ctx context.Context, q dbtx, orderUID string, forUpdate bool) (*domain.Order, error) {
 	order := &domain.Order{}
 
-	// Получение основного заказа
-	err := r.db.QueryRowContext(ctx, `
-        SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
-        FROM orders WHERE order_uid = $1`, orderUID).
+	orderQuery := `
+        SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, version, updated_at
+        FROM orders WHERE order_uid = $1 AND date_created = ` + orderMonth + ` AND deleted_at IS NULL`
+	if forUpdate {
+		orderQuery += " FOR UPDATE"
+	}
+	batch := &pgx.Batch{}
+	batch.Queue(`SELECT archived_at IS NOT NULL FROM order_keys WHERE order_uid = $1`, orderUID)
+	batch.Queue(orderQuery, orderUID)
+	batch.Queue(`
+        SELECT name, phone, zip, city, address, region, email, dek
+        FROM deliveries WHERE order_uid = $1 AND date_created = `+orderMonth, orderUID)
+	batch.Queue(`
+        SELECT transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
+        FROM payments WHERE order_uid = $1 AND date_created = `+orderMonth, orderUID)
+	batch.Queue(`
+        SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
+        FROM items WHERE order_uid = $1 AND date_created = `+orderMonth+` ORDER BY id`, orderUID)
+	results := q.SendBatch(ctx, batch)
+	defer results.Close()
+
+	// Реестр: заказа нет совсем или его секция уже выгружена в архив
+	var archived bool
+	if err := results.QueryRow().Scan(&archived); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("postgres order key query error: %w", err)
+	}
+	if archived {
+		return nil, domain.ErrOrderArchived
+	}
+
+	// Основной заказ
+	err := results.QueryRow().
 		Scan(&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
-			&order.CustomerID, &order.DeliveryService, &order.Shardkey, &order.SmID, &order.DateCreated, &order.OofShard)
+			&order.CustomerID, &order.DeliveryService, &order.Shardkey, &order.SmID, &order.DateCreated, &order.OofShard,
+			&order.Version, &order.UpdatedAt)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, domain.ErrOrderNotFound
 		}
 		return nil, fmt.Errorf("postgres order query error: %w", err)
 	}
 
-	// Получение данных доставки
-	err = r.db.QueryRowContext(ctx, `
-        SELECT name, phone, zip, city, address, region, email
-        FROM deliveries WHERE order_uid = $1`, orderUID).
+	// Данные доставки
+	var dek pgtype.Text
+	err = results.QueryRow().
 		Scan(&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Zip, &order.Delivery.City,
-			&order.Delivery.Address, &order.Delivery.Region, &order.Delivery.Email)
+			&order.Delivery.Address, &order.Delivery.Region, &order.Delivery.Email, &dek)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, domain.ErrOrderNotFound
 		}
 		return nil, fmt.Errorf("postgres delivery query error: %w", err)
 	}
+	if err := r.openDelivery(orderUID, &order.Delivery, dek); err != nil {
+		return nil, err
+	}
 
-	// Получение данных оплаты
-	err = r.db.QueryRowContext(ctx, `
-        SELECT transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
-        FROM payments WHERE order_uid = $1`, orderUID).
+	// Данные оплаты
+	err = results.QueryRow().
 		Scan(&order.Payment.Transaction, &order.Payment.RequestID, &order.Payment.Currency, &order.Payment.Provider,
 			&order.Payment.Amount, &order.Payment.PaymentDt, &order.Payment.Bank, &order.Payment.DeliveryCost,
 			&order.Payment.GoodsTotal, &order.Payment.CustomFee)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, domain.ErrOrderNotFound
 		}
 		return nil, fmt.Errorf("postgres payment query error: %w", err)
 	}
 
-	// Получение товаров
-	rows, err := r.db.QueryContext(ctx, `
-        SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
-        FROM items WHERE order_uid = $1`, orderUID)
+	// Товары
+	rows, err := results.Query()
 	if err != nil {
 		return nil, fmt.Errorf("postgres items query error: %w", err)
 	}
@@ -167,3 +281,178 @@ func (r *orderRepository) GetByID(ctx context.Context, orderUID string) (*domain
 
 	return order, nil
 }
+
+// defaultListLimit — ограничение листинга, если в фильтре не задан Limit.
+const defaultListLimit = 100
+
+// List возвращает заказы по фильтру, новые первыми.
+func (r *orderRepository) List(ctx context.Context, filter domain.OrderFilter) ([]domain.Order, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	where, args := r.buildOrderFilter(filter)
+	args = append(args, filter.Limit)
+	query := exportQuery + where + fmt.Sprintf(" ORDER BY o.date_created DESC, o.order_uid LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("postgres list query error: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []domain.Order{}
+	for rows.Next() {
+		order, err := r.scanExportRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, *order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres list iteration error: %w", err)
+	}
+	return orders, nil
+}
+
+// exportBatchSize — сколько строк курсора читается за один FETCH.
+const exportBatchSize = 500
+
+// exportQuery (он же запрос листинга) выбирает заказ целиком одной строкой: товары агрегируются в JSON,
+// ключи которого совпадают с json-тегами domain.Item.
+const exportQuery = `
+        SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, o.customer_id,
+               o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.version, o.updated_at,
+               d.name, d.phone, d.zip, d.city, d.address, d.region, d.email, d.dek,
+               p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt, p.bank,
+               p.delivery_cost, p.goods_total, p.custom_fee,
+               COALESCE((SELECT json_agg(json_build_object(
+                   'chrt_id', i.chrt_id, 'track_number', i.track_number, 'price', i.price, 'rid', i.rid,
+                   'name', i.name, 'sale', i.sale, 'size', i.size, 'total_price', i.total_price,
+                   'nm_id', i.nm_id, 'brand', i.brand, 'status', i.status) ORDER BY i.id)
+                   FROM items i WHERE i.order_uid = o.order_uid AND i.date_created = o.date_created), '[]')
+        FROM orders o
+        JOIN deliveries d ON d.order_uid = o.order_uid AND d.date_created = o.date_created
+        JOIN payments p ON p.order_uid = o.order_uid AND p.date_created = o.date_created`
+
+// Export построчно отдает в fn все заказы, подходящие под фильтр.
+// Чтение идет через серверный курсор, поэтому потребление памяти не зависит от объема выгрузки.
+func (r *orderRepository) Export(ctx context.Context, filter domain.OrderFilter, fn func(*domain.Order) error) error {
+	where, args := r.buildOrderFilter(filter)
+	query := exportQuery + where + " ORDER BY o.date_created, o.order_uid"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	// Курсор живет только внутри транзакции
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("postgres begin tx error: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DECLARE export_cursor NO SCROLL CURSOR FOR "+query, args...); err != nil {
+		return fmt.Errorf("postgres declare cursor error: %w", err)
+	}
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM export_cursor", exportBatchSize)
+	for {
+		rows, err := tx.Query(ctx, fetch)
+		if err != nil {
+			return fmt.Errorf("postgres fetch cursor error: %w", err)
+		}
+
+		n := 0
+		for rows.Next() {
+			n++
+			order, err := r.scanExportRow(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			if err := fn(order); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("postgres cursor iteration error: %w", err)
+		}
+
+		if n < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+// scanExportRow собирает заказ из строки exportQuery.
+func (r *orderRepository) scanExportRow(rows pgx.Rows) (*domain.Order, error) {
+	order := &domain.Order{}
+	var items []byte
+	var dek pgtype.Text
+	err := rows.Scan(&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
+		&order.CustomerID, &order.DeliveryService, &order.Shardkey, &order.SmID, &order.DateCreated, &order.OofShard, &order.Version, &order.UpdatedAt,
+		&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Zip, &order.Delivery.City,
+		&order.Delivery.Address, &order.Delivery.Region, &order.Delivery.Email, &dek,
+		&order.Payment.Transaction, &order.Payment.RequestID, &order.Payment.Currency, &order.Payment.Provider,
+		&order.Payment.Amount, &order.Payment.PaymentDt, &order.Payment.Bank, &order.Payment.DeliveryCost,
+		&order.Payment.GoodsTotal, &order.Payment.CustomFee, &items)
+	if err != nil {
+		return nil, fmt.Errorf("postgres export scan error: %w", err)
+	}
+	if err := json.Unmarshal(items, &order.Items); err != nil {
+		return nil, fmt.Errorf("postgres export items decode error: %w", err)
+	}
+	if err := r.openDelivery(order.OrderUID, &order.Delivery, dek); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// buildOrderFilter строит WHERE по фильтру. Колонки адресуются через алиасы exportQuery.
+// Мягко удаленные заказы отсекаются всегда. При включенном шифровании email и телефон
+// ищутся по слепому индексу.
+func (r *orderRepository) buildOrderFilter(filter domain.OrderFilter) (string, []any) {
+	conds := []string{"o.deleted_at IS NULL"}
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.CustomerID != "" {
+		add("o.customer_id = $%d", filter.CustomerID)
+	}
+	if filter.TrackNumber != "" {
+		add("o.track_number = $%d", filter.TrackNumber)
+	}
+	if filter.DeliveryService != "" {
+		add("o.delivery_service = $%d", filter.DeliveryService)
+	}
+	if filter.Locale != "" {
+		add("o.locale = $%d", filter.Locale)
+	}
+	if filter.Email != "" {
+		if r.keyring != nil {
+			add("d.email_bidx = $%d", r.keyring.BlindIndex("email", filter.Email))
+		} else {
+			add("lower(d.email) = $%d", strings.ToLower(filter.Email))
+		}
+	}
+	if filter.Phone != "" {
+		if r.keyring != nil {
+			add("d.phone_bidx = $%d", r.keyring.BlindIndex("phone", filter.Phone))
+		} else {
+			add("d.phone = $%d", filter.Phone)
+		}
+	}
+	if filter.DateFrom != nil {
+		add("o.date_created >= $%d", *filter.DateFrom)
+	}
+	if filter.DateTo != nil {
+		add("o.date_created < $%d", *filter.DateTo)
+	}
+
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
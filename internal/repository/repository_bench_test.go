@@ -0,0 +1,74 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"order_service/internal/domain"
+	"order_service/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// benchExecModes — режимы пула, которые сравнивают бенчмарки: с кэшем подготовленных
+// запросов и без него, как за PgBouncer в режиме transaction. С прежней реализацией
+// на lib/pq их сравнивает scripts/bench-baseline.sh.
+var benchExecModes = []struct {
+	name string
+	mode pgx.QueryExecMode
+}{
+	{"statement_cache=true", pgx.QueryExecModeCacheStatement},
+	{"statement_cache=false", pgx.QueryExecModeExec},
+}
+
+func benchGenerator(b *testing.B) *domain.Generator {
+	b.Helper()
+	opts := domain.DefaultGeneratorOptions()
+	opts.Seed = 1
+	gen, err := domain.NewGenerator(opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return gen
+}
+
+// BenchmarkPostgresCreate измеряет сохранение одного заказа. Нужна БД из postgresDSNEnv.
+func BenchmarkPostgresCreate(b *testing.B) {
+	for _, m := range benchExecModes {
+		repo := repository.NewOrderRepository(openTestPostgres(b, m.mode), nil)
+		gen := benchGenerator(b)
+		b.Run(m.name, func(b *testing.B) {
+			orders := gen.Generate(b.N)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := range orders {
+				if err := repo.Create(context.Background(), &orders[i]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkPostgresGetByID измеряет чтение одного заказа из benchStoredOrders сохраненных.
+func BenchmarkPostgresGetByID(b *testing.B) {
+	const benchStoredOrders = 1000
+	for _, m := range benchExecModes {
+		repo := repository.NewOrderRepository(openTestPostgres(b, m.mode), nil)
+		orders := benchGenerator(b).Generate(benchStoredOrders)
+		for i := range orders {
+			if err := repo.Create(context.Background(), &orders[i]); err != nil {
+				b.Fatal(fmt.Errorf("create %s: %w", orders[i].OrderUID, err))
+			}
+		}
+		b.Run(m.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.GetByID(context.Background(), orders[i%len(orders)].OrderUID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
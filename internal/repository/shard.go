@@ -0,0 +1,632 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"sync"
+
+	"order_service/internal/audit"
+	"order_service/internal/domain"
+	"order_service/internal/encryption"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Способы найти шард заказа по UID.
+const (
+	// ShardLookupDirectory — по каталогу order_shards в основной БД.
+	ShardLookupDirectory = "directory"
+	// ShardLookupFanout — опросом всех шардов.
+	ShardLookupFanout = "fanout"
+)
+
+// ShardedRepository раскладывает заказы по шардам по shardkey. Шард 0 — основная БД,
+// в ней же каталог order_shards. Запись идет в шард заказа, листинг и выгрузка сливают
+// результаты всех шардов.
+type ShardedRepository struct {
+	shards []*shard
+	lookup string
+}
+
+type shard struct {
+	id   int
+	db   *pgxpool.Pool
+	repo OrderRepository
+}
+
+// NewShardedRepository создает ShardedRepository. dbs[0] — основная БД.
+func NewShardedRepository(dbs []*pgxpool.Pool, keyring *encryption.Keyring, lookup string) (*ShardedRepository, error) {
+	switch lookup {
+	case ShardLookupDirectory, ShardLookupFanout:
+	default:
+		return nil, fmt.Errorf("unknown shard lookup %q", lookup)
+	}
+	r := &ShardedRepository{lookup: lookup}
+	for i, db := range dbs {
+		r.shards = append(r.shards, &shard{id: i, db: db, repo: NewOrderRepository(db, keyring)})
+	}
+	return r, nil
+}
+
+// ShardFor возвращает шард заказа с shardkey при n шардах.
+func ShardFor(shardkey string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(shardkey))
+	return int(h.Sum32() % uint32(n))
+}
+
+// Create пишет заказ в его шард. В режиме directory заказ сначала регистрируется в каталоге:
+// каталог же гарантирует уникальность UID между шардами. В режиме fanout уникальность
+// проверяется опросом шардов и не защищает от одновременного создания одного UID в разных шардах.
+func (r *ShardedRepository) Create(ctx context.Context, order *domain.Order) error {
+	target := r.shards[ShardFor(order.Shardkey, len(r.shards))]
+
+	if r.lookup == ShardLookupFanout {
+		_, err := r.locate(ctx, order.OrderUID)
+		if err == nil {
+			return domain.ErrOrderUIDNotUnique
+		}
+		if !errors.Is(err, domain.ErrOrderNotFound) {
+			return err
+		}
+		return target.repo.Create(ctx, order)
+	}
+
+	if err := r.register(ctx, order.OrderUID, target.id); err != nil {
+		return err
+	}
+	if err := target.repo.Create(ctx, order); err != nil {
+		// Заказ с таким UID в шарде уже есть — запись каталога верна
+		if !errors.Is(err, domain.ErrOrderUIDNotUnique) {
+			r.unregister(ctx, order.OrderUID, target.id)
+		}
+		return err
+	}
+	return nil
+}
+
+// register добавляет заказ в каталог. Если процесс упадет между регистрацией и созданием
+// заказа, запись каталога останется и UID будет считаться занятым, пока ее не удалят вручную.
+func (r *ShardedRepository) register(ctx context.Context, orderUID string, shardID int) error {
+	tag, err := r.shards[0].db.Exec(ctx, `
+        INSERT INTO order_shards (order_uid, shard) VALUES ($1, $2)
+        ON CONFLICT (order_uid) DO NOTHING`, orderUID, shardID)
+	if err != nil {
+		return fmt.Errorf("postgres shard directory insert error: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrOrderUIDNotUnique
+	}
+	if shardID == 0 {
+		return nil
+	}
+
+	// Заказы, созданные до шардирования, лежат в основной БД без записи в каталоге
+	exists, err := hasOrder(ctx, r.shards[0].db, orderUID)
+	if err != nil || exists {
+		r.unregister(ctx, orderUID, shardID)
+		if err != nil {
+			return err
+		}
+		return domain.ErrOrderUIDNotUnique
+	}
+	return nil
+}
+
+// unregister удаляет запись каталога после неудачного создания. Ошибка только логируется:
+// исходная ошибка создания важнее.
+func (r *ShardedRepository) unregister(ctx context.Context, orderUID string, shardID int) {
+	_, err := r.shards[0].db.Exec(context.WithoutCancel(ctx),
+		`DELETE FROM order_shards WHERE order_uid = $1 AND shard = $2`, orderUID, shardID)
+	if err != nil {
+		log.Printf("Failed to remove order %s from shard directory: %v", orderUID, err)
+	}
+}
+
+// locate находит шард заказа. По каталогу заказ без записи ищется в основной БД,
+// при опросе шардов ненайденный заказ — ErrOrderNotFound.
+func (r *ShardedRepository) locate(ctx context.Context, orderUID string) (*shard, error) {
+	if r.lookup == ShardLookupDirectory {
+		var shardID int
+		err := r.shards[0].db.QueryRow(ctx, `SELECT shard FROM order_shards WHERE order_uid = $1`, orderUID).Scan(&shardID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return r.shards[0], nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("postgres shard directory query error: %w", err)
+		}
+		if shardID >= len(r.shards) {
+			return nil, fmt.Errorf("order %s is on shard %d, but only %d shards are configured", orderUID, shardID, len(r.shards))
+		}
+		return r.shards[shardID], nil
+	}
+
+	found := make([]bool, len(r.shards))
+	err := r.each(ctx, func(ctx context.Context, s *shard) error {
+		var err error
+		found[s.id], err = hasOrder(ctx, s.db, orderUID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, ok := range found {
+		if ok {
+			return r.shards[i], nil
+		}
+	}
+	return nil, domain.ErrOrderNotFound
+}
+
+// hasOrder проверяет заказ по реестру шарда. Архивные заказы тоже считаются.
+func hasOrder(ctx context.Context, q dbtx, orderUID string) (bool, error) {
+	var exists bool
+	err := q.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM order_keys WHERE order_uid = $1)`, orderUID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("postgres order lookup error: %w", err)
+	}
+	return exists, nil
+}
+
+// each выполняет fn на всех шардах параллельно и возвращает первую ошибку.
+// После первой ошибки контекст остальных вызовов отменяется.
+func (r *ShardedRepository) each(ctx context.Context, fn func(context.Context, *shard) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(r.shards))
+	for _, s := range r.shards {
+		go func(s *shard) { errs <- fn(ctx, s) }(s)
+	}
+	var first error
+	for range r.shards {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+			cancel()
+		}
+	}
+	return first
+}
+
+func (r *ShardedRepository) GetByID(ctx context.Context, orderUID string) (*domain.Order, error) {
+	s, err := r.locate(ctx, orderUID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(ctx, orderUID)
+}
+
+func (r *ShardedRepository) Update(ctx context.Context, orderUID string, expectedVersion int64, patch domain.OrderPatch) (*domain.Order, error) {
+	s, err := r.locate(ctx, orderUID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Update(ctx, orderUID, expectedVersion, patch)
+}
+
+// List собирает первые filter.Limit заказов каждого шарда и оставляет общие первые filter.Limit.
+func (r *ShardedRepository) List(ctx context.Context, filter domain.OrderFilter) ([]domain.Order, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	results := make([][]domain.Order, len(r.shards))
+	err := r.each(ctx, func(ctx context.Context, s *shard) error {
+		var err error
+		results[s.id], err = s.repo.List(ctx, filter)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	orders := []domain.Order{}
+	for _, shardOrders := range results {
+		orders = append(orders, shardOrders...)
+	}
+	sort.Slice(orders, func(i, j int) bool { return listedBefore(&orders[i], &orders[j]) })
+	if len(orders) > filter.Limit {
+		orders = orders[:filter.Limit]
+	}
+	return orders, nil
+}
+
+// Export читает шарды параллельно и сливает их потоки в порядке выгрузки одного шарда:
+// по date_created, при равенстве — по UID.
+func (r *ShardedRepository) Export(ctx context.Context, filter domain.OrderFilter, fn func(*domain.Order) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	streams := make([]chan *domain.Order, len(r.shards))
+	errs := make([]error, len(r.shards))
+	var wg sync.WaitGroup
+	for i, s := range r.shards {
+		stream := make(chan *domain.Order, exportBatchSize)
+		streams[i] = stream
+		wg.Add(1)
+		go func(i int, s *shard) {
+			defer wg.Done()
+			defer close(stream)
+			errs[i] = s.repo.Export(ctx, filter, func(order *domain.Order) error {
+				select {
+				case stream <- order:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+		}(i, s)
+	}
+
+	err := mergeExport(streams, errs, filter.Limit, fn)
+	cancel()
+	wg.Wait()
+	return err
+}
+
+// mergeExport отдает в fn заказы из упорядоченных потоков шардов. Ошибка шарда читается
+// после закрытия его потока.
+func mergeExport(streams []chan *domain.Order, errs []error, limit int, fn func(*domain.Order) error) error {
+	heads := make([]*domain.Order, len(streams))
+	next := func(i int) error {
+		order, ok := <-streams[i]
+		if !ok {
+			heads[i] = nil
+			return errs[i]
+		}
+		heads[i] = order
+		return nil
+	}
+	for i := range streams {
+		if err := next(i); err != nil {
+			return err
+		}
+	}
+
+	for sent := 0; limit <= 0 || sent < limit; sent++ {
+		first := -1
+		for i, order := range heads {
+			if order != nil && (first < 0 || exportsBefore(order, heads[first])) {
+				first = i
+			}
+		}
+		if first < 0 {
+			return nil
+		}
+		if err := fn(heads[first]); err != nil {
+			return err
+		}
+		if err := next(first); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listedBefore — порядок листинга: новые первыми, при равенстве — по UID.
+func listedBefore(a, b *domain.Order) bool {
+	if !a.DateCreated.Equal(b.DateCreated) {
+		return a.DateCreated.After(b.DateCreated)
+	}
+	return a.OrderUID < b.OrderUID
+}
+
+// exportsBefore — порядок выгрузки: по date_created, при равенстве — по UID.
+func exportsBefore(a, b *domain.Order) bool {
+	if !a.DateCreated.Equal(b.DateCreated) {
+		return a.DateCreated.Before(b.DateCreated)
+	}
+	return a.OrderUID < b.OrderUID
+}
+
+func (r *ShardedRepository) Delete(ctx context.Context, orderUID, actor string) (int64, error) {
+	s, err := r.locate(ctx, orderUID)
+	if err != nil {
+		return 0, err
+	}
+	return s.repo.Delete(ctx, orderUID, actor)
+}
+
+func (r *ShardedRepository) EraseOrder(ctx context.Context, orderUID, actor string) (int64, error) {
+	s, err := r.locate(ctx, orderUID)
+	if err != nil {
+		return 0, err
+	}
+	return s.repo.EraseOrder(ctx, orderUID, actor)
+}
+
+// EraseCustomer стирает заказы покупателя в каждом шарде своей транзакцией. При ошибке
+// шарды до него уже стерты; повторный вызов доделает остальные.
+func (r *ShardedRepository) EraseCustomer(ctx context.Context, customerID, actor string) ([]domain.OrderVersion, error) {
+	var versions []domain.OrderVersion
+	for _, s := range r.shards {
+		shardVersions, err := s.repo.EraseCustomer(ctx, customerID, actor)
+		if errors.Is(err, domain.ErrOrderNotFound) {
+			continue // У покупателя нет заказов в этом шарде
+		}
+		if err != nil {
+			return nil, fmt.Errorf("shard %d: %w", s.id, err)
+		}
+		versions = append(versions, shardVersions...)
+	}
+	if len(versions) == 0 {
+		return nil, domain.ErrOrderNotFound
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].OrderUID < versions[j].OrderUID })
+	return versions, nil
+}
+
+// movedTables — таблицы заказа в порядке копирования в новый шард. У items и erasures
+// суррогатный id: в новом шарде он выдается заново, порядок строк сохраняется. Журнал аудита
+// не переносится: он только дописывается и остается в прежнем шарде, а журнал заказа
+// собирается со всех шардов.
+var movedTables = []struct {
+	name   string
+	serial bool
+}{
+	{"order_keys", false},
+	{"orders", false},
+	{"deliveries", false},
+	{"payments", false},
+	{"items", true},
+	{"erasures", true},
+}
+
+// Rebalance переносит заказы, лежащие не в своем шарде, — например, после добавления шардов.
+// Шарды просматриваются пачками по batch заказов, fn вызывается для каждого переносимого
+// заказа; при dryRun заказы только перечисляются. Архивные заказы остаются на месте.
+// Возвращает число перенесенных (при dryRun — найденных) заказов.
+func (r *ShardedRepository) Rebalance(ctx context.Context, batch int, dryRun bool, fn func(orderUID string, from, to int)) (int, error) {
+	moved := 0
+	for _, from := range r.shards {
+		after := ""
+		for {
+			misplaced, last, err := r.misplaced(ctx, from, after, batch)
+			if err != nil {
+				return moved, err
+			}
+			for _, m := range misplaced {
+				fn(m.uid, from.id, m.to)
+				if !dryRun {
+					if err := r.move(ctx, m.uid, from, r.shards[m.to]); err != nil {
+						return moved, fmt.Errorf("move order %s from shard %d to %d: %w", m.uid, from.id, m.to, err)
+					}
+				}
+				moved++
+			}
+			if last == "" {
+				break
+			}
+			after = last
+		}
+	}
+	return moved, nil
+}
+
+type misplacedOrder struct {
+	uid string
+	to  int
+}
+
+// misplaced возвращает заказы шарда после UID after, которым место в другом шарде,
+// и последний просмотренный UID ("" — шард просмотрен целиком).
+func (r *ShardedRepository) misplaced(ctx context.Context, s *shard, after string, batch int) ([]misplacedOrder, string, error) {
+	rows, err := s.db.Query(ctx, `
+        SELECT order_uid, shardkey FROM orders WHERE order_uid > $1
+        ORDER BY order_uid LIMIT $2`, after, batch)
+	if err != nil {
+		return nil, "", fmt.Errorf("postgres rebalance scan error: %w", err)
+	}
+	defer rows.Close()
+
+	var result []misplacedOrder
+	last, n := "", 0
+	for rows.Next() {
+		var shardkey string
+		if err := rows.Scan(&last, &shardkey); err != nil {
+			return nil, "", fmt.Errorf("postgres rebalance scan error: %w", err)
+		}
+		n++
+		if to := ShardFor(shardkey, len(r.shards)); to != s.id {
+			result = append(result, misplacedOrder{uid: last, to: to})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("postgres rebalance iteration error: %w", err)
+	}
+	if n < batch {
+		last = ""
+	}
+	return result, last, nil
+}
+
+// move копирует строки заказа в шард to, переключает каталог и удаляет заказ из from, записывая
+// там событие переноса. Заказ в from заблокирован до конца переноса. Если перенос прервался,
+// повторный запуск доводит его: копия, на которую каталог еще не указывает, перезаписывается,
+// а копия в from, когда каталог уже указывает на to, просто удаляется. В режиме fanout
+// каталога нет, и прерванный перенос нужно повторить до возобновления записи.
+func (r *ShardedRepository) move(ctx context.Context, orderUID string, from, to *shard) error {
+	src, err := from.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres begin tx error: %w", err)
+	}
+	defer src.Rollback(ctx)
+
+	var shardkey string
+	err = src.QueryRow(ctx, `
+        SELECT shardkey FROM orders WHERE order_uid = $1 AND date_created = `+orderMonth+`
+        FOR UPDATE`, orderUID).Scan(&shardkey)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil // Заказ уже перенесен или ушел в архив
+	}
+	if err != nil {
+		return fmt.Errorf("postgres rebalance lock error: %w", err)
+	}
+
+	if r.lookup == ShardLookupDirectory {
+		current, err := r.locate(ctx, orderUID)
+		if err != nil {
+			return err
+		}
+		if current == to {
+			return finishMove(ctx, src, orderUID, from.id, to.id)
+		}
+	}
+
+	if err := copyOrder(ctx, src, to.db, orderUID); err != nil {
+		return err
+	}
+	if r.lookup == ShardLookupDirectory {
+		_, err := r.shards[0].db.Exec(ctx, `
+            INSERT INTO order_shards (order_uid, shard) VALUES ($1, $2)
+            ON CONFLICT (order_uid) DO UPDATE SET shard = EXCLUDED.shard`, orderUID, to.id)
+		if err != nil {
+			return fmt.Errorf("postgres shard directory update error: %w", err)
+		}
+	}
+	return finishMove(ctx, src, orderUID, from.id, to.id)
+}
+
+// finishMove пишет событие переноса и удаляет заказ из исходного шарда в транзакции src.
+// Событие фиксируется вместе с удалением, поэтому повторный запуск прерванного переноса
+// не записывает его дважды.
+func finishMove(ctx context.Context, src pgx.Tx, orderUID string, fromID, toID int) error {
+	changes, err := json.Marshal(map[string]audit.Change{"shard": {Old: fromID, New: toID}})
+	if err != nil {
+		return err
+	}
+	if err := insertAuditEvent(ctx, src, audit.NewEvent(ctx, domain.AuditOrderMove, orderUID, changes)); err != nil {
+		return err
+	}
+	return deleteOrderRows(ctx, src, orderUID, true)
+}
+
+// copyOrder переносит строки заказа из транзакции src в шард dst одной транзакцией,
+// заменяя оставшуюся там копию.
+func copyOrder(ctx context.Context, src pgx.Tx, dst *pgxpool.Pool, orderUID string) error {
+	tx, err := dst.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres begin tx error: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := deleteOrderRows(ctx, tx, orderUID, false); err != nil {
+		return err
+	}
+	for _, table := range movedTables {
+		columns, rows, err := readOrderRows(ctx, src, table.name, table.serial, orderUID)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{table.name}, columns, pgx.CopyFromRows(rows)); err != nil {
+			return fmt.Errorf("postgres copy %s error: %w", table.name, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("postgres commit error: %w", err)
+	}
+	return nil
+}
+
+// readOrderRows читает строки заказа из таблицы как есть. Для таблиц с суррогатным id
+// колонка id пропускается, строки идут в порядке id.
+func readOrderRows(ctx context.Context, q dbtx, table string, serial bool, orderUID string) ([]string, [][]any, error) {
+	query := "SELECT * FROM " + pgx.Identifier{table}.Sanitize() + " WHERE order_uid = $1"
+	if serial {
+		query += " ORDER BY id"
+	}
+	rows, err := q.Query(ctx, query, orderUID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("postgres read %s error: %w", table, err)
+	}
+	defer rows.Close()
+
+	skip := -1
+	var columns []string
+	for i, field := range rows.FieldDescriptions() {
+		if serial && field.Name == "id" {
+			skip = i
+			continue
+		}
+		columns = append(columns, field.Name)
+	}
+
+	var result [][]any
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, nil, fmt.Errorf("postgres read %s error: %w", table, err)
+		}
+		if skip >= 0 {
+			values = append(values[:skip], values[skip+1:]...)
+		}
+		result = append(result, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("postgres read %s error: %w", table, err)
+	}
+	return columns, result, nil
+}
+
+// deleteOrderRows удаляет заказ из шарда: заказ с дочерними строками, отметки о стирании
+// и запись реестра. Журнал аудита остается. С commit транзакция фиксируется.
+func deleteOrderRows(ctx context.Context, tx pgx.Tx, orderUID string, commit bool) error {
+	for _, query := range []string{
+		`DELETE FROM erasures WHERE order_uid = $1`,
+		`DELETE FROM orders WHERE order_uid = $1`,
+		`DELETE FROM order_keys WHERE order_uid = $1`,
+	} {
+		if _, err := tx.Exec(ctx, query, orderUID); err != nil {
+			return fmt.Errorf("postgres delete moved order error: %w", err)
+		}
+	}
+	if !commit {
+		return nil
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("postgres commit error: %w", err)
+	}
+	return nil
+}
+
+// shardedAuditRepository пишет события чтения в основную БД, а журнал заказа собирает
+// со всех шардов: события изменений пишутся в шард заказа вместе с изменением.
+type shardedAuditRepository struct {
+	shards []AuditRepository
+}
+
+// NewShardedAuditRepository создает журнал аудита поверх шардов. dbs[0] — основная БД.
+func NewShardedAuditRepository(dbs []*pgxpool.Pool) AuditRepository {
+	r := &shardedAuditRepository{}
+	for _, db := range dbs {
+		r.shards = append(r.shards, NewAuditRepository(db))
+	}
+	return r
+}
+
+func (r *shardedAuditRepository) Record(ctx context.Context, event domain.AuditEvent) error {
+	return r.shards[0].Record(ctx, event)
+}
+
+// ListByOrder возвращает события заказа из всех шардов в хронологическом порядке.
+func (r *shardedAuditRepository) ListByOrder(ctx context.Context, orderUID string) ([]domain.AuditEvent, error) {
+	events := []domain.AuditEvent{}
+	for _, s := range r.shards {
+		shardEvents, err := s.ListByOrder(ctx, orderUID)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, shardEvents...)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].OccurredAt.Before(events[j].OccurredAt) })
+	return events, nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"order_service/internal/domain"
+)
+
+func TestShardFor(t *testing.T) {
+	// Значения закреплены: при смене хэша заказы окажутся не в своих шардах
+	tests := []struct {
+		shardkey string
+		n        int
+		want     int
+	}{
+		{"", 2, 1},
+		{"a", 2, 0},
+		{"a", 3, 1},
+		{"WBIL", 3, 0},
+		{"customer-42", 4, 2},
+		{"customer-42", 1, 0},
+	}
+	for _, tt := range tests {
+		if got := ShardFor(tt.shardkey, tt.n); got != tt.want {
+			t.Errorf("ShardFor(%q, %d) = %d, want %d", tt.shardkey, tt.n, got, tt.want)
+		}
+	}
+
+	const keys, shards = 10000, 4
+	counts := make([]int, shards)
+	for i := 0; i < keys; i++ {
+		s := ShardFor(fmt.Sprintf("key-%d", i), shards)
+		if s < 0 || s >= shards {
+			t.Fatalf("ShardFor = %d, want 0..%d", s, shards-1)
+		}
+		counts[s]++
+	}
+	for s, n := range counts {
+		if n < keys/shards*9/10 || n > keys/shards*11/10 {
+			t.Errorf("shard %d got %d of %d keys: %v", s, n, keys, counts)
+		}
+	}
+}
+
+func TestMergeExport(t *testing.T) {
+	base := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	order := func(uid string, hour int) *domain.Order {
+		return &domain.Order{OrderUID: uid, DateCreated: base.Add(time.Duration(hour) * time.Hour)}
+	}
+	// Шард 0 и шард 1 отдают заказы в порядке выгрузки, b и c созданы одновременно
+	shards := func() [][]*domain.Order {
+		return [][]*domain.Order{
+			{order("a", 0), order("c", 2), order("e", 5)},
+			{order("b", 2), order("d", 3)},
+			{},
+		}
+	}
+	errShard := errors.New("shard failed")
+	errStop := errors.New("stop")
+
+	tests := []struct {
+		name    string
+		limit   int
+		errs    []error
+		stopAt  string
+		want    []string
+		wantErr error
+	}{
+		{name: "all", want: []string{"a", "b", "c", "d", "e"}},
+		{name: "limit", limit: 3, want: []string{"a", "b", "c"}},
+		{name: "limit above total", limit: 10, want: []string{"a", "b", "c", "d", "e"}},
+		{name: "shard error after its orders", errs: []error{nil, errShard, nil}, want: []string{"a", "b", "c", "d"}, wantErr: errShard},
+		{name: "empty shard error", errs: []error{nil, nil, errShard}, wantErr: errShard},
+		{name: "callback error", stopAt: "c", want: []string{"a", "b", "c"}, wantErr: errStop},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders := shards()
+			streams := make([]chan *domain.Order, len(orders))
+			for i, shardOrders := range orders {
+				streams[i] = make(chan *domain.Order, len(shardOrders))
+				for _, o := range shardOrders {
+					streams[i] <- o
+				}
+				close(streams[i])
+			}
+			errs := tt.errs
+			if errs == nil {
+				errs = make([]error, len(streams))
+			}
+
+			var got []string
+			err := mergeExport(streams, errs, tt.limit, func(o *domain.Order) error {
+				got = append(got, o.OrderUID)
+				if o.OrderUID == tt.stopAt {
+					return errStop
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("exported %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,531 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"order_service/internal/audit"
+	"order_service/internal/domain"
+)
+
+// sqliteRepository — реализация OrderRepository поверх встроенного SQLite для локальной
+// разработки. Заказ хранится JSON-документом, персональные данные не шифруются.
+type sqliteRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteOrderRepository создает репозиторий заказов поверх database.OpenSQLite.
+func NewSQLiteOrderRepository(db *sql.DB) OrderRepository {
+	return &sqliteRepository{db: db}
+}
+
+// sqliteTime и fromSQLiteTime переводят время в микросекунды Unix и обратно.
+func sqliteTime(t time.Time) int64 {
+	return storedTime(t).UnixMicro()
+}
+
+func fromSQLiteTime(micros int64) time.Time {
+	return time.UnixMicro(micros).UTC()
+}
+
+// sqliteOrderColumns — колонки, из которых собирается заказ.
+const sqliteOrderColumns = `data, version, updated_at, date_created`
+
+func scanSQLiteOrder(row interface{ Scan(...any) error }) (*domain.Order, error) {
+	var data []byte
+	var version, updatedAt, dateCreated int64
+	if err := row.Scan(&data, &version, &updatedAt, &dateCreated); err != nil {
+		return nil, err
+	}
+	order := &domain.Order{}
+	if err := json.Unmarshal(data, order); err != nil {
+		return nil, fmt.Errorf("sqlite order decode error: %w", err)
+	}
+	if order.Items == nil {
+		order.Items = []domain.Item{}
+	}
+	order.Version, order.UpdatedAt, order.DateCreated = version, fromSQLiteTime(updatedAt), fromSQLiteTime(dateCreated)
+	return order, nil
+}
+
+// writeSQLiteOrder сохраняет измененный документ заказа, его версию и колонки фильтров.
+func writeSQLiteOrder(ctx context.Context, tx *sql.Tx, order *domain.Order) error {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+        UPDATE orders SET email = ?, phone = ?, version = ?, updated_at = ?, data = ?
+        WHERE order_uid = ?`,
+		strings.ToLower(order.Delivery.Email), order.Delivery.Phone, order.Version,
+		sqliteTime(order.UpdatedAt), data, order.OrderUID)
+	if err != nil {
+		return fmt.Errorf("sqlite update order error: %w", err)
+	}
+	return nil
+}
+
+func insertSQLiteAuditEvent(ctx context.Context, q interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+}, event domain.AuditEvent) error {
+	var changes, orderUID, requestID any
+	if len(event.Changes) > 0 {
+		changes = string(event.Changes)
+	}
+	if event.OrderUID != "" {
+		orderUID = event.OrderUID
+	}
+	if event.RequestID != "" {
+		requestID = event.RequestID
+	}
+	_, err := q.ExecContext(ctx, `
+        INSERT INTO audit_events (occurred_at, actor, source, action, order_uid, changes, request_id)
+        VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		sqliteTime(time.Now()), event.Actor, event.Source, event.Action, orderUID, changes, requestID)
+	if err != nil {
+		return fmt.Errorf("sqlite insert audit event error: %w", err)
+	}
+	return nil
+}
+
+func (r *sqliteRepository) Create(ctx context.Context, order *domain.Order) error {
+	if err := checkOrder(order); err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlite begin tx error: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM orders WHERE order_uid = ?)`, order.OrderUID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("sqlite check exists error: %w", err)
+	}
+	if exists {
+		return domain.ErrOrderUIDNotUnique
+	}
+
+	order.Version, order.UpdatedAt = 1, storedTime(time.Now())
+	data, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO orders (order_uid, customer_id, track_number, delivery_service, locale, email, phone,
+            date_created, version, updated_at, data)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		order.OrderUID, order.CustomerID, order.TrackNumber, order.DeliveryService, order.Locale,
+		strings.ToLower(order.Delivery.Email), order.Delivery.Phone, sqliteTime(order.DateCreated),
+		order.Version, sqliteTime(order.UpdatedAt), data)
+	if err != nil {
+		return fmt.Errorf("sqlite insert order error: %w", err)
+	}
+
+	changes, err := audit.Diff(nil, order)
+	if err != nil {
+		return err
+	}
+	if err := insertSQLiteAuditEvent(ctx, tx, audit.NewEvent(ctx, domain.AuditOrderCreate, order.OrderUID, changes)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sqlite commit error: %w", err)
+	}
+	return nil
+}
+
+func (r *sqliteRepository) GetByID(ctx context.Context, orderUID string) (*domain.Order, error) {
+	order, err := scanSQLiteOrder(r.db.QueryRowContext(ctx,
+		`SELECT `+sqliteOrderColumns+` FROM orders WHERE order_uid = ? AND deleted_at IS NULL`, orderUID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sqlite order query error: %w", err)
+	}
+	return order, nil
+}
+
+// Update применяет патч в транзакции. Пул из одного соединения сериализует транзакции,
+// поэтому из двух конкурентных изменений одной версии проходит одно.
+func (r *sqliteRepository) Update(ctx context.Context, orderUID string, expectedVersion int64, patch domain.OrderPatch) (*domain.Order, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite begin tx error: %w", err)
+	}
+	defer tx.Rollback()
+
+	var erased bool
+	before, err := scanSQLiteOrder(tx.QueryRowContext(ctx,
+		`SELECT `+sqliteOrderColumns+` FROM orders WHERE order_uid = ? AND deleted_at IS NULL`, orderUID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sqlite order query error: %w", err)
+	}
+	if expectedVersion != 0 && before.Version != expectedVersion {
+		return nil, domain.ErrVersionMismatch
+	}
+	if patch.Delivery != nil {
+		// Стертые персональные данные не восстанавливаются через изменение заказа
+		err := tx.QueryRowContext(ctx, `SELECT erased_at IS NOT NULL FROM orders WHERE order_uid = ?`, orderUID).Scan(&erased)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite erasure query error: %w", err)
+		}
+		if erased {
+			return nil, domain.ErrPIIErased
+		}
+	}
+
+	after := cloneOrder(before)
+	if err := patch.Apply(after); err != nil {
+		return nil, err
+	}
+	if err := checkOrder(after); err != nil {
+		return nil, err
+	}
+	after.Version++
+	after.UpdatedAt = storedTime(time.Now())
+	if err := writeSQLiteOrder(ctx, tx, after); err != nil {
+		return nil, err
+	}
+
+	changes, err := audit.Diff(before, after)
+	if err != nil {
+		return nil, err
+	}
+	if err := insertSQLiteAuditEvent(ctx, tx, audit.NewEvent(ctx, domain.AuditOrderUpdate, orderUID, changes)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("sqlite commit error: %w", err)
+	}
+	return after, nil
+}
+
+// buildSQLiteFilter — условия buildOrderFilter для схемы SQLite.
+func buildSQLiteFilter(filter domain.OrderFilter) (string, []any) {
+	conds := []string{"deleted_at IS NULL"}
+	var args []any
+	add := func(cond string, arg any) {
+		conds = append(conds, cond)
+		args = append(args, arg)
+	}
+
+	if filter.CustomerID != "" {
+		add("customer_id = ?", filter.CustomerID)
+	}
+	if filter.TrackNumber != "" {
+		add("track_number = ?", filter.TrackNumber)
+	}
+	if filter.DeliveryService != "" {
+		add("delivery_service = ?", filter.DeliveryService)
+	}
+	if filter.Locale != "" {
+		add("locale = ?", filter.Locale)
+	}
+	if filter.Email != "" {
+		add("email = ?", strings.ToLower(filter.Email))
+	}
+	if filter.Phone != "" {
+		add("phone = ?", filter.Phone)
+	}
+	if filter.DateFrom != nil {
+		add("date_created >= ?", sqliteTime(*filter.DateFrom))
+	}
+	if filter.DateTo != nil {
+		add("date_created < ?", sqliteTime(*filter.DateTo))
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// List возвращает заказы по фильтру, новые первыми.
+func (r *sqliteRepository) List(ctx context.Context, filter domain.OrderFilter) ([]domain.Order, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	where, args := buildSQLiteFilter(filter)
+	rows, err := r.db.QueryContext(ctx, `SELECT `+sqliteOrderColumns+` FROM orders`+where+
+		` ORDER BY date_created DESC, order_uid LIMIT ?`, append(args, filter.Limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite list query error: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []domain.Order{}
+	for rows.Next() {
+		order, err := scanSQLiteOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite list scan error: %w", err)
+		}
+		orders = append(orders, *order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite list iteration error: %w", err)
+	}
+	return orders, nil
+}
+
+// Export отдает в fn заказы по фильтру в порядке date_created пачками по exportBatchSize.
+// Пачка читается целиком до вызова fn: fn может обращаться к хранилищу, а соединение у него одно.
+func (r *sqliteRepository) Export(ctx context.Context, filter domain.OrderFilter, fn func(*domain.Order) error) error {
+	where, args := buildSQLiteFilter(filter)
+	sent := 0
+	var last *domain.Order
+	for {
+		query, batchArgs := `SELECT `+sqliteOrderColumns+` FROM orders`+where, args
+		if last != nil {
+			query += ` AND (date_created > ? OR (date_created = ? AND order_uid > ?))`
+			batchArgs = append(append([]any{}, args...), sqliteTime(last.DateCreated), sqliteTime(last.DateCreated), last.OrderUID)
+		}
+		batch := exportBatchSize
+		if filter.Limit > 0 {
+			batch = min(batch, filter.Limit-sent)
+		}
+		query += ` ORDER BY date_created, order_uid LIMIT ?`
+
+		orders, err := r.exportBatch(ctx, query, append(batchArgs, batch)...)
+		if err != nil {
+			return err
+		}
+		for _, order := range orders {
+			if err := fn(order); err != nil {
+				return err
+			}
+		}
+		sent += len(orders)
+		if len(orders) < batch || (filter.Limit > 0 && sent >= filter.Limit) {
+			return nil
+		}
+		last = orders[len(orders)-1]
+	}
+}
+
+func (r *sqliteRepository) exportBatch(ctx context.Context, query string, args ...any) ([]*domain.Order, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite export query error: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []*domain.Order
+	for rows.Next() {
+		order, err := scanSQLiteOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite export scan error: %w", err)
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite export iteration error: %w", err)
+	}
+	return orders, nil
+}
+
+// Delete мягко удаляет заказ: он пропадает из чтения, но данные остаются для аудита.
+func (r *sqliteRepository) Delete(ctx context.Context, orderUID, actor string) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite begin tx error: %w", err)
+	}
+	defer tx.Rollback()
+
+	deletedAt := storedTime(time.Now())
+	var version int64
+	err = tx.QueryRowContext(ctx, `
+        UPDATE orders SET deleted_at = ?, deleted_by = ?, version = version + 1, updated_at = ?
+        WHERE order_uid = ? AND deleted_at IS NULL
+        RETURNING version`, sqliteTime(deletedAt), actor, sqliteTime(deletedAt), orderUID).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, domain.ErrOrderNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("sqlite soft delete error: %w", err)
+	}
+
+	changes, err := json.Marshal(map[string]audit.Change{"deleted_at": {New: deletedAt}})
+	if err != nil {
+		return 0, err
+	}
+	if err := insertSQLiteAuditEvent(ctx, tx, audit.NewEvent(ctx, domain.AuditOrderDelete, orderUID, changes)); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("sqlite commit error: %w", err)
+	}
+	return version, nil
+}
+
+// EraseOrder затирает персональные данные доставки заказа, в том числе мягко удаленного.
+func (r *sqliteRepository) EraseOrder(ctx context.Context, orderUID, actor string) (int64, error) {
+	var customerID string
+	err := r.db.QueryRowContext(ctx, `SELECT customer_id FROM orders WHERE order_uid = ?`, orderUID).Scan(&customerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, domain.ErrOrderNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("sqlite erase query error: %w", err)
+	}
+	versions, err := r.erase(ctx, `order_uid = ?`, orderUID, customerID, actor)
+	if err != nil {
+		return 0, err
+	}
+	if len(versions) == 0 {
+		return 0, domain.ErrOrderNotFound
+	}
+	return versions[0].Version, nil
+}
+
+// EraseCustomer затирает персональные данные во всех заказах покупателя одной транзакцией.
+func (r *sqliteRepository) EraseCustomer(ctx context.Context, customerID, actor string) ([]domain.OrderVersion, error) {
+	versions, err := r.erase(ctx, `customer_id = ?`, customerID, customerID, actor)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, domain.ErrOrderNotFound
+	}
+	return versions, nil
+}
+
+// erase затирает доставку в заказах по условию cond и возвращает версии всех подходящих
+// заказов по возрастанию UID; журнал пишется только для стертых этим вызовом.
+func (r *sqliteRepository) erase(ctx context.Context, cond string, arg any, customerID, actor string) ([]domain.OrderVersion, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite begin tx error: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT `+sqliteOrderColumns+`, erased_at IS NOT NULL FROM orders WHERE `+cond, arg)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite erase query error: %w", err)
+	}
+	var orders, pending []*domain.Order
+	for rows.Next() {
+		var data []byte
+		var version, updatedAt, dateCreated int64
+		var erased bool
+		if err := rows.Scan(&data, &version, &updatedAt, &dateCreated, &erased); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("sqlite erase scan error: %w", err)
+		}
+		order := &domain.Order{}
+		if err := json.Unmarshal(data, order); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("sqlite order decode error: %w", err)
+		}
+		order.Version, order.UpdatedAt, order.DateCreated = version, fromSQLiteTime(updatedAt), fromSQLiteTime(dateCreated)
+		orders = append(orders, order)
+		if !erased {
+			pending = append(pending, order)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite erase iteration error: %w", err)
+	}
+
+	changes, err := json.Marshal(map[string][]string{"erased_fields": domain.ErasedDeliveryFields})
+	if err != nil {
+		return nil, err
+	}
+	fields, err := json.Marshal(domain.ErasedDeliveryFields)
+	if err != nil {
+		return nil, err
+	}
+	now := storedTime(time.Now())
+	for _, order := range pending {
+		d := &order.Delivery
+		d.Name, d.Phone, d.Zip, d.Address, d.Email = domain.ErasedValue, domain.ErasedValue,
+			domain.ErasedValue, domain.ErasedValue, domain.ErasedValue
+		// Содержимое заказа изменилось: новая версия не даст клиентам и кэшу считать старую актуальной
+		order.Version++
+		order.UpdatedAt = now
+		if err := writeSQLiteOrder(ctx, tx, order); err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE orders SET erased_at = ? WHERE order_uid = ?`,
+			sqliteTime(now), order.OrderUID); err != nil {
+			return nil, fmt.Errorf("sqlite erase order error: %w", err)
+		}
+		_, err := tx.ExecContext(ctx, `
+            INSERT INTO erasures (order_uid, customer_id, erased_by, fields, erased_at)
+            VALUES (?, ?, ?, ?, ?)`, order.OrderUID, customerID, actor, string(fields), sqliteTime(now))
+		if err != nil {
+			return nil, fmt.Errorf("sqlite insert erasure error: %w", err)
+		}
+		if err := insertSQLiteAuditEvent(ctx, tx, audit.NewEvent(ctx, domain.AuditOrderErase, order.OrderUID, changes)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("sqlite commit error: %w", err)
+	}
+	versions := make([]domain.OrderVersion, len(orders))
+	for i, order := range orders {
+		versions[i] = domain.OrderVersion{OrderUID: order.OrderUID, Version: order.Version}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].OrderUID < versions[j].OrderUID })
+	return versions, nil
+}
+
+// sqliteAuditRepository — журнал аудита встроенного хранилища.
+type sqliteAuditRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteAuditRepository создает журнал аудита поверх database.OpenSQLite.
+func NewSQLiteAuditRepository(db *sql.DB) AuditRepository {
+	return &sqliteAuditRepository{db: db}
+}
+
+func (r *sqliteAuditRepository) Record(ctx context.Context, event domain.AuditEvent) error {
+	return insertSQLiteAuditEvent(ctx, r.db, event)
+}
+
+// ListByOrder возвращает события заказа в хронологическом порядке.
+func (r *sqliteAuditRepository) ListByOrder(ctx context.Context, orderUID string) ([]domain.AuditEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, occurred_at, actor, source, action, order_uid, changes, request_id
+        FROM audit_events WHERE order_uid = ?
+        ORDER BY occurred_at, id`, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite audit query error: %w", err)
+	}
+	defer rows.Close()
+
+	events := []domain.AuditEvent{}
+	for rows.Next() {
+		var e domain.AuditEvent
+		var occurredAt int64
+		var uid, changes, requestID sql.NullString
+		if err := rows.Scan(&e.ID, &occurredAt, &e.Actor, &e.Source, &e.Action, &uid, &changes, &requestID); err != nil {
+			return nil, fmt.Errorf("sqlite audit scan error: %w", err)
+		}
+		e.OccurredAt = fromSQLiteTime(occurredAt)
+		e.OrderUID, e.RequestID = uid.String, requestID.String
+		if changes.Valid {
+			e.Changes = json.RawMessage(changes.String)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite audit iteration error: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,122 @@
+package repository_test
+
+// Бенчмарки реализации на database/sql и lib/pq, какой она была до перехода на pgx.
+// В текущем дереве файл не собирается: scripts/bench-baseline.sh копирует его в рабочую
+// копию базового коммита и сравнивает с repository_bench_test.go.
+//
+// lib/pq подготавливает каждый запрос с аргументами заново безымянным оператором, поэтому
+// результат записывается как statement_cache=false — так benchstat сопоставит его
+// со строкой pgx без кэша подготовленных запросов.
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"order_service/internal/database"
+	"order_service/internal/domain"
+	"order_service/internal/repository"
+
+	_ "github.com/lib/pq"
+)
+
+const postgresDSNEnv = "ORDER_SERVICE_TEST_POSTGRES_DSN"
+
+// openBaselinePostgres создает для бенчмарка отдельную схему с миграциями и удаляет ее после.
+func openBaselinePostgres(b *testing.B) *sql.DB {
+	b.Helper()
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		b.Skipf("%s is not set", postgresDSNEnv)
+	}
+	ctx := context.Background()
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { admin.Close() })
+	schema := fmt.Sprintf("bench_%d", time.Now().UnixNano())
+	if _, err := admin.ExecContext(ctx, "CREATE SCHEMA "+schema); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		if _, err := admin.ExecContext(ctx, "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			b.Errorf("drop bench schema %s: %v", schema, err)
+		}
+	})
+
+	// Неизвестные lib/pq параметры строки подключения уходят на сервер как параметры сеанса
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			b.Fatal(err)
+		}
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		dsn = u.String()
+	} else {
+		dsn += " search_path=" + schema
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	db.SetMaxOpenConns(20) // как data_base.pool.max_open по умолчанию
+	b.Cleanup(func() { db.Close() })
+	if err := database.Migrate(ctx, db, database.MigrateUp, nil); err != nil {
+		b.Fatal(err)
+	}
+	return db
+}
+
+func benchGenerator(b *testing.B) *domain.Generator {
+	b.Helper()
+	opts := domain.DefaultGeneratorOptions()
+	opts.Seed = 1
+	gen, err := domain.NewGenerator(opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return gen
+}
+
+func BenchmarkPostgresCreate(b *testing.B) {
+	repo := repository.NewOrderRepository(openBaselinePostgres(b), nil)
+	gen := benchGenerator(b)
+	b.Run("statement_cache=false", func(b *testing.B) {
+		orders := gen.Generate(b.N)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := range orders {
+			if err := repo.Create(context.Background(), &orders[i]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkPostgresGetByID(b *testing.B) {
+	const benchStoredOrders = 1000
+	repo := repository.NewOrderRepository(openBaselinePostgres(b), nil)
+	orders := benchGenerator(b).Generate(benchStoredOrders)
+	for i := range orders {
+		if err := repo.Create(context.Background(), &orders[i]); err != nil {
+			b.Fatal(fmt.Errorf("create %s: %w", orders[i].OrderUID, err))
+		}
+	}
+	b.Run("statement_cache=false", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.GetByID(context.Background(), orders[i%len(orders)].OrderUID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
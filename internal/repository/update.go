@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"order_service/internal/audit"
+	"order_service/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Update применяет патч к заказу под блокировкой строки. Проверка версии и ее увеличение
+// идут в одной транзакции, поэтому из двух конкурентных изменений одной версии проходит одно.
+func (r *orderRepository) Update(ctx context.Context, orderUID string, expectedVersion int64, patch domain.OrderPatch) (*domain.Order, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres begin tx error: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := r.getOrder(ctx, tx, orderUID, true)
+	if err != nil {
+		return nil, err
+	}
+	if expectedVersion != 0 && before.Version != expectedVersion {
+		return nil, domain.ErrVersionMismatch
+	}
+
+	after := *before
+	after.Items = append([]domain.Item(nil), before.Items...)
+	if err := patch.Apply(&after); err != nil {
+		return nil, err
+	}
+
+	if patch.Delivery != nil {
+		// Стертые персональные данные не восстанавливаются через изменение заказа
+		var erased bool
+		err := tx.QueryRow(ctx, `SELECT erased_at IS NOT NULL FROM deliveries WHERE order_uid = $1 AND date_created = $2`,
+			orderUID, before.DateCreated).Scan(&erased)
+		if err != nil {
+			return nil, fmt.Errorf("postgres delivery query error: %w", err)
+		}
+		if erased {
+			return nil, domain.ErrPIIErased
+		}
+
+		// Доставка перешифровывается целиком с новым ключом данных
+		delivery, err := r.sealDelivery(orderUID, after.Delivery)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(ctx, `
+            UPDATE deliveries SET name = $2, phone = $3, zip = $4, city = $5, address = $6, region = $7, email = $8,
+                dek = $9, email_bidx = $10, phone_bidx = $11
+            WHERE order_uid = $1 AND date_created = $12`,
+			orderUID, delivery.Name, delivery.Phone, delivery.Zip, delivery.City, delivery.Address,
+			delivery.Region, delivery.Email, delivery.dek, delivery.emailBidx, delivery.phoneBidx, before.DateCreated)
+		if err != nil {
+			if cErr := constraintError(err); cErr != nil {
+				return nil, cErr
+			}
+			return nil, fmt.Errorf("postgres update delivery error: %w", err)
+		}
+	}
+
+	for _, item := range patch.Items {
+		_, err := tx.Exec(ctx, `UPDATE items SET status = $3 WHERE order_uid = $1 AND rid = $2 AND date_created = $4`,
+			orderUID, item.Rid, item.Status, before.DateCreated)
+		if err != nil {
+			if cErr := constraintError(err); cErr != nil {
+				return nil, cErr
+			}
+			return nil, fmt.Errorf("postgres update item error: %w", err)
+		}
+	}
+
+	err = tx.QueryRow(ctx, `
+        UPDATE orders SET version = version + 1, updated_at = now()
+        WHERE order_uid = $1 AND date_created = $2 RETURNING version, updated_at`,
+		orderUID, before.DateCreated).Scan(&after.Version, &after.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("postgres update order version error: %w", err)
+	}
+
+	changes, err := audit.Diff(before, &after)
+	if err != nil {
+		return nil, err
+	}
+	if err := insertAuditEvent(ctx, tx, audit.NewEvent(ctx, domain.AuditOrderUpdate, orderUID, changes)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("postgres commit error: %w", err)
+	}
+	return &after, nil
+}
@@ -3,11 +3,15 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"order_service/internal/audit"
+	"order_service/internal/auth"
 	"order_service/internal/cache"
 	"order_service/internal/config"
 	"order_service/internal/domain"
+	"order_service/internal/message"
 	"order_service/internal/repository"
 )
 
@@ -15,20 +19,34 @@ import (
 type OrderService interface {
 	CreateOrder(ctx context.Context, order *domain.Order) error
 	GetOrderByID(ctx context.Context, orderUID string) (*domain.Order, error)
+	// GetOrderMeta возвращает версию и время изменения заказа для условных запросов.
+	GetOrderMeta(ctx context.Context, orderUID string) (*domain.OrderMeta, error)
+	// UpdateOrder меняет заказ, если его версия равна expectedVersion (0 — без проверки).
+	UpdateOrder(ctx context.Context, orderUID string, expectedVersion int64, patch domain.OrderPatch) (*domain.Order, error)
 	HandleOrder(ctx context.Context, message []byte) error
+	ListOrders(ctx context.Context, filter domain.OrderFilter) ([]domain.Order, error)
+	ExportOrders(ctx context.Context, filter domain.OrderFilter, fn func(*domain.Order) error) error
+	DeleteOrder(ctx context.Context, orderUID, actor string) error
+	EraseOrder(ctx context.Context, orderUID, actor string) error
+	EraseCustomer(ctx context.Context, customerID, actor string) ([]string, error)
+	GetOrderAudit(ctx context.Context, orderUID string) ([]domain.AuditEvent, error)
+	InvalidateCachedOrder(ctx context.Context, orderUID string) error
+	FlushOrderCache(ctx context.Context) error
 }
 
 // orderService — реализация OrderService.
 type orderService struct {
 	repo   repository.OrderRepository
+	audit  repository.AuditRepository
 	config *config.Config
 	cache  cache.Cache
 }
 
 // NewOrderService создает новый экземпляр orderService.
-func NewOrderService(repo repository.OrderRepository, config *config.Config, cache cache.Cache) OrderService {
+func NewOrderService(repo repository.OrderRepository, audit repository.AuditRepository, config *config.Config, cache cache.Cache) OrderService {
 	return &orderService{
 		repo:   repo,
+		audit:  audit,
 		config: config,
 		cache:  cache,
 	}
@@ -43,11 +61,44 @@ func (s *orderService) CreateOrder(ctx context.Context, order *domain.Order) err
 }
 
 // GetOrderByID получает заказ по order_uid.
+// Чтение с разрешением pii:read записывается в журнал аудита.
 func (s *orderService) GetOrderByID(ctx context.Context, orderUID string) (*domain.Order, error) {
 	if orderUID == "" {
 		return nil, domain.ErrOrderUIDEmpty
 	}
 
+	order, err := s.getOrder(ctx, orderUID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.auditPIIRead(ctx, domain.AuditOrderReadPII, orderUID, nil); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// GetOrderMeta берет версию заказа из кэша без разбора самого заказа.
+// При промахе заказ читается целиком и попадает в кэш.
+func (s *orderService) GetOrderMeta(ctx context.Context, orderUID string) (*domain.OrderMeta, error) {
+	if orderUID == "" {
+		return nil, domain.ErrOrderUIDEmpty
+	}
+
+	meta, err := s.cache.GetOrderMeta(ctx, orderUID)
+	if err == nil {
+		return meta, nil
+	}
+	logCacheError(err)
+
+	order, err := s.getOrder(ctx, orderUID)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.OrderMeta{Version: order.Version, UpdatedAt: order.UpdatedAt}, nil
+}
+
+// getOrder читает заказ из кэша, а при промахе — из БД.
+func (s *orderService) getOrder(ctx context.Context, orderUID string) (*domain.Order, error) {
 	// Сходили в кэш
 	order, err := s.cache.GetOrder(ctx, orderUID)
 	if err == nil {
@@ -64,27 +115,211 @@ func (s *orderService) GetOrderByID(ctx context.Context, orderUID string) (*doma
 	// Кладём заказ в кэш
 	if cacheErr == cache.ErrNotFound {
 		err = s.cache.SetOrder(ctx, orderUID, order)
-		if err != nil {
+		if err != nil && !errors.Is(err, cache.ErrUnavailable) {
 			log.Printf("Ошибка сохранения в кэш: %v\n", err)
 		}
 	} else { // Ошибка кэша не связана с отсутствием заказа
-		log.Printf("Ошибка кэша: %v, fallback на БД\n", err)
+		logCacheError(cacheErr)
+	}
+
+	return order, nil
+}
+
+// logCacheError логирует сбой кэша при чтении. Промах — не сбой, а при разомкнутом
+// выключателе кэш пропускается молча: переход в деградированный режим уже залогирован.
+func logCacheError(err error) {
+	if errors.Is(err, cache.ErrNotFound) || errors.Is(err, cache.ErrUnavailable) {
+		return
+	}
+	log.Printf("Ошибка кэша: %v, fallback на БД\n", err)
+}
+
+// UpdateOrder применяет патч и переписывает заказ в кэше новой версией. Запись в кэш
+// сравнивает версии, поэтому параллельное чтение старой версии ее не затрет.
+func (s *orderService) UpdateOrder(ctx context.Context, orderUID string, expectedVersion int64, patch domain.OrderPatch) (*domain.Order, error) {
+	if orderUID == "" {
+		return nil, domain.ErrOrderUIDEmpty
+	}
+	if err := patch.Validate(); err != nil {
+		return nil, err
+	}
+
+	order, err := s.repo.Update(ctx, orderUID, expectedVersion, patch)
+	if err != nil {
+		return nil, err
 	}
 
+	if err := s.cache.SetOrder(ctx, orderUID, order); err != nil && !errors.Is(err, cache.ErrUnavailable) {
+		// Не удалось переписать — убираем запись, чтобы не отдавать старую версию до TTL
+		log.Printf("Ошибка сохранения в кэш: %v\n", err)
+		s.evict(ctx, orderUID)
+	} else if err := s.cache.Invalidate(ctx, orderUID); err != nil && !errors.Is(err, cache.ErrUnavailable) {
+		// Локальные копии других реплик устареют не дольше своего TTL
+		log.Printf("Ошибка рассылки инвалидации: %v\n", err)
+	}
 	return order, nil
 }
 
-// HandleOrder парсит и сохраняет заказ
-func (s *orderService) HandleOrder(ctx context.Context, message []byte) error {
-	var order domain.Order
-	if err := json.Unmarshal(message, &order); err != nil {
-		return fmt.Errorf("failed to unmarshal order: %w", err)
+// HandleOrder проверяет сообщение по схеме, поднимает его до текущей версии и сохраняет заказ
+func (s *orderService) HandleOrder(ctx context.Context, data []byte) error {
+	order, env, err := message.Decode(data)
+	if err != nil {
+		return fmt.Errorf("failed to decode order message: %w", err)
 	}
 
-	if err := s.CreateOrder(ctx, &order); err != nil {
+	if err := s.CreateOrder(ctx, order); err != nil {
 		return fmt.Errorf("failed create order %s: %w", order.OrderUID, err)
 	}
 
-	log.Printf("Successfully processed order: %s", order.OrderUID)
+	log.Printf("Successfully processed order: %s (schema v%d)", order.OrderUID, env.SchemaVersion)
 	return nil
 }
+
+// maxListLimit — наибольший размер страницы листинга. Большие выборки идут через выгрузку.
+const maxListLimit = 1000
+
+// ListOrders возвращает страницу заказов по фильтру напрямую из БД.
+// Выдача с разрешением pii:read записывается в журнал аудита отдельным от выгрузки действием.
+func (s *orderService) ListOrders(ctx context.Context, filter domain.OrderFilter) ([]domain.Order, error) {
+	if filter.DateFrom != nil && filter.DateTo != nil && !filter.DateFrom.Before(*filter.DateTo) {
+		return nil, fmt.Errorf("%w: date_from must be before date_to", domain.ErrInvalidFilter)
+	}
+	if filter.Limit > maxListLimit {
+		return nil, fmt.Errorf("%w: limit exceeds %d, use export", domain.ErrInvalidFilter, maxListLimit)
+	}
+	if err := s.auditPIIRead(ctx, domain.AuditOrdersList, "", audit.FilterDetails(filter)); err != nil {
+		return nil, err
+	}
+	return s.repo.List(ctx, filter)
+}
+
+// ExportOrders потоково отдает заказы по фильтру. Кэш не используется — выгрузка идет напрямую из БД.
+func (s *orderService) ExportOrders(ctx context.Context, filter domain.OrderFilter, fn func(*domain.Order) error) error {
+	if filter.DateFrom != nil && filter.DateTo != nil && !filter.DateFrom.Before(*filter.DateTo) {
+		return fmt.Errorf("%w: date_from must be before date_to", domain.ErrInvalidFilter)
+	}
+	if err := s.auditPIIRead(ctx, domain.AuditOrdersExport, "", audit.FilterDetails(filter)); err != nil {
+		return err
+	}
+	return s.repo.Export(ctx, filter, fn)
+}
+
+// DeleteOrder мягко удаляет заказ и оставляет в кэше отметку об удалении: просто удаленную
+// запись вернуло бы в кэш чтение, начатое до удаления.
+func (s *orderService) DeleteOrder(ctx context.Context, orderUID, actor string) error {
+	if orderUID == "" {
+		return domain.ErrOrderUIDEmpty
+	}
+	version, err := s.repo.Delete(ctx, orderUID, actor)
+	if err != nil {
+		return err
+	}
+	if err := s.cache.Tombstone(ctx, orderUID, version); err != nil && !errors.Is(err, cache.ErrUnavailable) {
+		log.Printf("Failed to tombstone order %s in cache: %v", orderUID, err)
+	}
+	return nil
+}
+
+// EraseOrder необратимо стирает персональные данные заказа. В кэше остается отметка о версии
+// стирания: просто удаленную запись вернуло бы с данными чтение, начатое до стирания.
+func (s *orderService) EraseOrder(ctx context.Context, orderUID, actor string) error {
+	if orderUID == "" {
+		return domain.ErrOrderUIDEmpty
+	}
+	if actor == "" {
+		return domain.ErrActorRequired
+	}
+	version, err := s.repo.EraseOrder(ctx, orderUID, actor)
+	if err != nil {
+		return err
+	}
+	// Стертые данные не должны дожить в кэше до TTL, поэтому ошибку возвращаем — стирание можно повторить.
+	// При разомкнутом выключателе удаление отложено до восстановления Redis
+	if err := s.cache.Tombstone(ctx, orderUID, version); err != nil && !errors.Is(err, cache.ErrUnavailable) {
+		return fmt.Errorf("order erased, but cache eviction failed: %w", err)
+	}
+	log.Printf("Personal data of order %s erased by %s", orderUID, actor)
+	return nil
+}
+
+// EraseCustomer стирает персональные данные во всех заказах покупателя и возвращает их UID.
+func (s *orderService) EraseCustomer(ctx context.Context, customerID, actor string) ([]string, error) {
+	if customerID == "" {
+		return nil, fmt.Errorf("%w: customer_id is required", domain.ErrInvalidFilter)
+	}
+	if actor == "" {
+		return nil, domain.ErrActorRequired
+	}
+	versions, err := s.repo.EraseCustomer(ctx, customerID, actor)
+	if err != nil {
+		return nil, err
+	}
+	erased := make([]string, len(versions))
+	for i, v := range versions {
+		erased[i] = v.OrderUID
+	}
+	for _, v := range versions {
+		if err := s.cache.Tombstone(ctx, v.OrderUID, v.Version); err != nil && !errors.Is(err, cache.ErrUnavailable) {
+			return erased, fmt.Errorf("orders erased, but cache eviction failed: %w", err)
+		}
+	}
+	log.Printf("Personal data of customer %s erased by %s (%d orders)", customerID, actor, len(erased))
+	return erased, nil
+}
+
+// InvalidateCachedOrder удаляет заказ из кэша на всех репликах.
+func (s *orderService) InvalidateCachedOrder(ctx context.Context, orderUID string) error {
+	if orderUID == "" {
+		return domain.ErrOrderUIDEmpty
+	}
+	if err := s.cache.DeleteOrder(ctx, orderUID); err != nil {
+		return fmt.Errorf("cache invalidation failed: %w", err)
+	}
+	log.Printf("Cache entry of order %s invalidated by %s", orderUID, audit.FromContext(ctx).Actor)
+	return nil
+}
+
+// FlushOrderCache сбрасывает все закэшированные заказы. Прочие ключи Redis не затрагиваются.
+func (s *orderService) FlushOrderCache(ctx context.Context) error {
+	if err := s.cache.Flush(ctx); err != nil {
+		return fmt.Errorf("cache flush failed: %w", err)
+	}
+	log.Printf("Order cache flushed by %s", audit.FromContext(ctx).Actor)
+	return nil
+}
+
+// GetOrderAudit возвращает журнал аудита заказа.
+func (s *orderService) GetOrderAudit(ctx context.Context, orderUID string) ([]domain.AuditEvent, error) {
+	if orderUID == "" {
+		return nil, domain.ErrOrderUIDEmpty
+	}
+	return s.audit.ListByOrder(ctx, orderUID)
+}
+
+// auditPIIRead записывает чтение немаскированных персональных данных. Без записи в журнал
+// данные не отдаются, поэтому ошибка возвращается вызывающему.
+func (s *orderService) auditPIIRead(ctx context.Context, action, orderUID string, details any) error {
+	if !auth.FromContext(ctx).Has(auth.PermPIIRead) {
+		return nil
+	}
+
+	var changes json.RawMessage
+	if details != nil {
+		data, err := json.Marshal(details)
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit details: %w", err)
+		}
+		changes = data
+	}
+	if err := s.audit.Record(ctx, audit.NewEvent(ctx, action, orderUID, changes)); err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	return nil
+}
+
+// evict убирает заказ из кэша. Ошибка только логируется: запись истечет по TTL.
+func (s *orderService) evict(ctx context.Context, orderUID string) {
+	if err := s.cache.DeleteOrder(ctx, orderUID); err != nil && !errors.Is(err, cache.ErrUnavailable) {
+		log.Printf("Ошибка удаления из кэша: %v\n", err)
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"order_service/internal/audit"
+	"order_service/internal/auth"
+	"order_service/internal/cache"
+	"order_service/internal/config"
+	"order_service/internal/domain"
+	"order_service/internal/repository"
+)
+
+func newTestService(t *testing.T) (OrderService, repository.OrderRepository, cache.Cache) {
+	t.Helper()
+	db := repository.NewMemoryDB()
+	repo := repository.NewMemoryOrderRepository(db)
+	c := cache.NewMemoryCache(config.Cache{Ttl: time.Hour, Local: config.CacheLocal{Size: 100}})
+	return NewOrderService(repo, repository.NewMemoryAuditRepository(db), &config.Config{}, c), repo, c
+}
+
+func createTestOrders(t *testing.T, svc OrderService, customerID string, n int) []*domain.Order {
+	t.Helper()
+	gen, err := domain.NewGenerator(domain.DefaultGeneratorOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders := make([]*domain.Order, n)
+	for i := range orders {
+		order := gen.Next()
+		order.CustomerID = customerID
+		if err := svc.CreateOrder(context.Background(), &order); err != nil {
+			t.Fatal(err)
+		}
+		orders[i] = &order
+	}
+	return orders
+}
+
+// Чтение, начатое до стирания, не возвращает в кэш заказ с персональными данными.
+func TestEraseOrderRejectsStaleCacheWrite(t *testing.T) {
+	ctx := audit.WithMeta(context.Background(), audit.Meta{Actor: "admin"})
+	svc, repo, c := newTestService(t)
+	order := createTestOrders(t, svc, "customer", 1)[0]
+
+	stale, err := repo.GetByID(ctx, order.OrderUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.EraseOrder(ctx, order.OrderUID, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetOrder(ctx, order.OrderUID, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := svc.GetOrderByID(ctx, order.OrderUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Delivery.Phone != domain.ErasedValue || got.Version != stale.Version+1 {
+		t.Fatalf("after erase: phone %q, version %d", got.Delivery.Phone, got.Version)
+	}
+}
+
+func TestEraseCustomerRejectsStaleCacheWrites(t *testing.T) {
+	ctx := audit.WithMeta(context.Background(), audit.Meta{Actor: "admin"})
+	svc, repo, c := newTestService(t)
+	orders := createTestOrders(t, svc, "customer", 2)
+
+	var stale []*domain.Order
+	for _, order := range orders {
+		s, err := repo.GetByID(ctx, order.OrderUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stale = append(stale, s)
+	}
+	erased, err := svc.EraseCustomer(ctx, "customer", "admin")
+	if err != nil || len(erased) != 2 {
+		t.Fatalf("EraseCustomer = %v, %v", erased, err)
+	}
+	for _, s := range stale {
+		if err := c.SetOrder(ctx, s.OrderUID, s); err != nil {
+			t.Fatal(err)
+		}
+		got, err := svc.GetOrderByID(ctx, s.OrderUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Delivery.Email != domain.ErasedValue {
+			t.Errorf("%s: email %q after erase", s.OrderUID, got.Delivery.Email)
+		}
+	}
+}
+
+// Листинг и выгрузка с персональными данными различаются в журнале аудита.
+func TestSelectionAuditActions(t *testing.T) {
+	db := repository.NewMemoryDB()
+	auditRepo := repository.NewMemoryAuditRepository(db)
+	c := cache.NewMemoryCache(config.Cache{Ttl: time.Hour, Local: config.CacheLocal{Size: 100}})
+	svc := NewOrderService(repository.NewMemoryOrderRepository(db), auditRepo, &config.Config{}, c)
+	createTestOrders(t, svc, "customer", 2)
+	filter := domain.OrderFilter{CustomerID: "customer", Email: "user@example.com"}
+	export := func(ctx context.Context) error {
+		return svc.ExportOrders(ctx, filter, func(*domain.Order) error { return nil })
+	}
+
+	masked := audit.WithMeta(context.Background(), audit.Meta{Actor: "viewer"})
+	if _, err := svc.ListOrders(masked, filter); err != nil {
+		t.Fatal(err)
+	}
+	if err := export(masked); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := auth.WithPrincipal(audit.WithMeta(context.Background(), audit.Meta{Actor: "admin"}),
+		auth.Principal{Actor: "admin", Permissions: []string{auth.PermPIIRead}})
+	if _, err := svc.ListOrders(ctx, filter); err != nil {
+		t.Fatal(err)
+	}
+	if err := export(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Выборки без pii:read не записываются, события выборок не привязаны к заказу
+	events, err := auditRepo.ListByOrder(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{domain.AuditOrdersList, domain.AuditOrdersExport}
+	if len(events) != len(want) {
+		t.Fatalf("%d selection events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, event := range events {
+		if event.Action != want[i] || event.Actor != "admin" {
+			t.Errorf("event %d: action %q by %q, want %q by admin", i, event.Action, event.Actor, want[i])
+		}
+		if got := string(event.Changes); got != `{"customer_id":"customer","email":"[redacted]"}` {
+			t.Errorf("event %d: details %s", i, got)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+-- +goose Up
+-- Мягкое удаление: заказ скрыт из чтения, но остается в БД для аудита
+ALTER TABLE orders ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE orders ADD COLUMN deleted_by VARCHAR(100);
+
+-- Отметка о стирании персональных данных доставки
+ALTER TABLE deliveries ADD COLUMN erased_at TIMESTAMP;
+
+-- Журнал GDPR-стираний: кто, что и когда стер
+CREATE TABLE erasures (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid VARCHAR(50) NOT NULL,
+    customer_id VARCHAR(50),
+    erased_by VARCHAR(100) NOT NULL,
+    erased_at TIMESTAMP NOT NULL DEFAULT now(),
+    fields TEXT[] NOT NULL
+);
+
+CREATE INDEX erasures_order_uid_idx ON erasures (order_uid);
+CREATE INDEX erasures_customer_id_idx ON erasures (customer_id);
+
+-- +goose Down
+DROP TABLE erasures;
+ALTER TABLE deliveries DROP COLUMN erased_at;
+ALTER TABLE orders DROP COLUMN deleted_by;
+ALTER TABLE orders DROP COLUMN deleted_at;
@@ -0,0 +1,34 @@
+-- +goose Up
+-- Шифротекст длиннее исходных значений, поэтому персональные поля переводим в TEXT
+ALTER TABLE deliveries
+    ALTER COLUMN name TYPE TEXT,
+    ALTER COLUMN phone TYPE TEXT,
+    ALTER COLUMN zip TYPE TEXT,
+    ALTER COLUMN address TYPE TEXT,
+    ALTER COLUMN email TYPE TEXT;
+
+-- Обернутый мастер-ключом ключ данных строки ("<key_id>:<base64>"), NULL — строка не зашифрована
+ALTER TABLE deliveries ADD COLUMN dek TEXT;
+
+-- Слепые индексы (HMAC) для поиска по email и телефону без расшифровки
+ALTER TABLE deliveries ADD COLUMN email_bidx VARCHAR(64);
+ALTER TABLE deliveries ADD COLUMN phone_bidx VARCHAR(64);
+
+CREATE INDEX deliveries_email_bidx_idx ON deliveries (email_bidx);
+CREATE INDEX deliveries_phone_bidx_idx ON deliveries (phone_bidx);
+CREATE INDEX deliveries_dek_null_idx ON deliveries (order_uid) WHERE dek IS NULL;
+
+-- +goose Down
+-- Откат возможен только после расшифровки данных: шифротекст не помещается в прежние VARCHAR
+DROP INDEX deliveries_dek_null_idx;
+DROP INDEX deliveries_phone_bidx_idx;
+DROP INDEX deliveries_email_bidx_idx;
+ALTER TABLE deliveries DROP COLUMN phone_bidx;
+ALTER TABLE deliveries DROP COLUMN email_bidx;
+ALTER TABLE deliveries DROP COLUMN dek;
+ALTER TABLE deliveries
+    ALTER COLUMN name TYPE VARCHAR(100),
+    ALTER COLUMN phone TYPE VARCHAR(20),
+    ALTER COLUMN zip TYPE VARCHAR(20),
+    ALTER COLUMN address TYPE VARCHAR(200),
+    ALTER COLUMN email TYPE VARCHAR(100);
@@ -0,0 +1,32 @@
+-- +goose Up
+-- Журнал аудита: изменения заказов и чтения персональных данных
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMP NOT NULL DEFAULT now(),
+    actor VARCHAR(100) NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    order_uid VARCHAR(50),
+    changes JSONB,
+    request_id VARCHAR(100)
+);
+
+CREATE INDEX audit_events_order_uid_idx ON audit_events (order_uid, occurred_at);
+
+-- Журнал только дополняется: изменение и удаление запрещены на уровне БД
+-- +goose StatementBegin
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+-- +goose Down
+DROP TRIGGER audit_events_append_only ON audit_events;
+DROP FUNCTION audit_events_append_only();
+DROP TABLE audit_events;
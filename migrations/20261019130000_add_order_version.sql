@@ -0,0 +1,6 @@
+-- +goose Up
+-- Версия заказа для оптимистичной блокировки: растет при каждом изменении
+ALTER TABLE orders ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE orders DROP COLUMN version;
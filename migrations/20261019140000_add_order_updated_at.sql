@@ -0,0 +1,9 @@
+-- +goose Up
+-- Время последнего изменения заказа для заголовка Last-Modified
+ALTER TABLE orders ADD COLUMN updated_at TIMESTAMP;
+UPDATE orders SET updated_at = COALESCE(date_created, now());
+ALTER TABLE orders ALTER COLUMN updated_at SET NOT NULL;
+ALTER TABLE orders ALTER COLUMN updated_at SET DEFAULT now();
+
+-- +goose Down
+ALTER TABLE orders DROP COLUMN updated_at;
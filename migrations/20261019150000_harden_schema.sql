@@ -0,0 +1,221 @@
+-- +goose Up
+-- Время храним с часовым поясом: прежние значения записаны в UTC
+ALTER TABLE orders
+    ALTER COLUMN date_created TYPE TIMESTAMPTZ USING date_created AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC',
+    ALTER COLUMN deleted_at TYPE TIMESTAMPTZ USING deleted_at AT TIME ZONE 'UTC';
+ALTER TABLE deliveries
+    ALTER COLUMN erased_at TYPE TIMESTAMPTZ USING erased_at AT TIME ZONE 'UTC';
+ALTER TABLE erasures
+    ALTER COLUMN erased_at TYPE TIMESTAMPTZ USING erased_at AT TIME ZONE 'UTC';
+ALTER TABLE audit_events
+    ALTER COLUMN occurred_at TYPE TIMESTAMPTZ USING occurred_at AT TIME ZONE 'UTC';
+
+-- Ограничения длины не несут смысла и роняют вставку реальных данных
+ALTER TABLE orders
+    ALTER COLUMN track_number TYPE TEXT,
+    ALTER COLUMN entry TYPE TEXT,
+    ALTER COLUMN locale TYPE TEXT,
+    ALTER COLUMN internal_signature TYPE TEXT,
+    ALTER COLUMN customer_id TYPE TEXT,
+    ALTER COLUMN delivery_service TYPE TEXT,
+    ALTER COLUMN shardkey TYPE TEXT,
+    ALTER COLUMN oof_shard TYPE TEXT;
+ALTER TABLE deliveries
+    ALTER COLUMN city TYPE TEXT,
+    ALTER COLUMN region TYPE TEXT;
+ALTER TABLE payments
+    ALTER COLUMN transaction TYPE TEXT,
+    ALTER COLUMN request_id TYPE TEXT,
+    ALTER COLUMN currency TYPE TEXT,
+    ALTER COLUMN provider TYPE TEXT,
+    ALTER COLUMN bank TYPE TEXT;
+ALTER TABLE items
+    ALTER COLUMN track_number TYPE TEXT,
+    ALTER COLUMN rid TYPE TEXT,
+    ALTER COLUMN name TYPE TEXT,
+    ALTER COLUMN size TYPE TEXT,
+    ALTER COLUMN brand TYPE TEXT;
+
+-- Сервис всегда пишет все поля заказа, NULL означает поврежденную запись
+ALTER TABLE orders
+    ALTER COLUMN track_number SET NOT NULL,
+    ALTER COLUMN entry SET NOT NULL,
+    ALTER COLUMN locale SET NOT NULL,
+    ALTER COLUMN internal_signature SET NOT NULL,
+    ALTER COLUMN customer_id SET NOT NULL,
+    ALTER COLUMN delivery_service SET NOT NULL,
+    ALTER COLUMN shardkey SET NOT NULL,
+    ALTER COLUMN sm_id SET NOT NULL,
+    ALTER COLUMN date_created SET NOT NULL,
+    ALTER COLUMN oof_shard SET NOT NULL;
+ALTER TABLE deliveries
+    ALTER COLUMN name SET NOT NULL,
+    ALTER COLUMN phone SET NOT NULL,
+    ALTER COLUMN zip SET NOT NULL,
+    ALTER COLUMN city SET NOT NULL,
+    ALTER COLUMN address SET NOT NULL,
+    ALTER COLUMN region SET NOT NULL,
+    ALTER COLUMN email SET NOT NULL;
+ALTER TABLE payments
+    ALTER COLUMN transaction SET NOT NULL,
+    ALTER COLUMN request_id SET NOT NULL,
+    ALTER COLUMN currency SET NOT NULL,
+    ALTER COLUMN provider SET NOT NULL,
+    ALTER COLUMN amount SET NOT NULL,
+    ALTER COLUMN payment_dt SET NOT NULL,
+    ALTER COLUMN bank SET NOT NULL,
+    ALTER COLUMN delivery_cost SET NOT NULL,
+    ALTER COLUMN goods_total SET NOT NULL,
+    ALTER COLUMN custom_fee SET NOT NULL;
+ALTER TABLE items
+    ALTER COLUMN order_uid SET NOT NULL,
+    ALTER COLUMN chrt_id SET NOT NULL,
+    ALTER COLUMN track_number SET NOT NULL,
+    ALTER COLUMN price SET NOT NULL,
+    ALTER COLUMN rid SET NOT NULL,
+    ALTER COLUMN name SET NOT NULL,
+    ALTER COLUMN sale SET NOT NULL,
+    ALTER COLUMN size SET NOT NULL,
+    ALTER COLUMN total_price SET NOT NULL,
+    ALTER COLUMN nm_id SET NOT NULL,
+    ALTER COLUMN brand SET NOT NULL,
+    ALTER COLUMN status SET NOT NULL;
+
+-- Суммы и статусы не бывают отрицательными. Имена ограничений — <таблица>_<колонка>_check:
+-- по ним репозиторий находит колонку, нарушившую ограничение
+ALTER TABLE payments
+    ADD CONSTRAINT payments_amount_check CHECK (amount >= 0),
+    ADD CONSTRAINT payments_delivery_cost_check CHECK (delivery_cost >= 0),
+    ADD CONSTRAINT payments_goods_total_check CHECK (goods_total >= 0),
+    ADD CONSTRAINT payments_custom_fee_check CHECK (custom_fee >= 0);
+ALTER TABLE items
+    ADD CONSTRAINT items_price_check CHECK (price >= 0),
+    ADD CONSTRAINT items_sale_check CHECK (sale >= 0),
+    ADD CONSTRAINT items_total_price_check CHECK (total_price >= 0),
+    ADD CONSTRAINT items_status_check CHECK (status >= 0);
+
+-- Дочерние записи удаляются вместе с заказом
+ALTER TABLE deliveries
+    DROP CONSTRAINT deliveries_order_uid_fkey,
+    ADD CONSTRAINT deliveries_order_uid_fkey FOREIGN KEY (order_uid) REFERENCES orders(order_uid) ON DELETE CASCADE;
+ALTER TABLE payments
+    DROP CONSTRAINT payments_order_uid_fkey,
+    ADD CONSTRAINT payments_order_uid_fkey FOREIGN KEY (order_uid) REFERENCES orders(order_uid) ON DELETE CASCADE;
+ALTER TABLE items
+    DROP CONSTRAINT items_order_uid_fkey,
+    ADD CONSTRAINT items_order_uid_fkey FOREIGN KEY (order_uid) REFERENCES orders(order_uid) ON DELETE CASCADE;
+
+-- Индексы под чтение товаров заказа и фильтры листинга и выгрузки
+CREATE INDEX items_order_uid_idx ON items (order_uid);
+CREATE INDEX orders_customer_id_idx ON orders (customer_id);
+CREATE INDEX orders_track_number_idx ON orders (track_number);
+CREATE INDEX orders_date_created_idx ON orders (date_created);
+
+-- +goose Down
+DROP INDEX orders_date_created_idx;
+DROP INDEX orders_track_number_idx;
+DROP INDEX orders_customer_id_idx;
+DROP INDEX items_order_uid_idx;
+
+ALTER TABLE items
+    DROP CONSTRAINT items_order_uid_fkey,
+    ADD CONSTRAINT items_order_uid_fkey FOREIGN KEY (order_uid) REFERENCES orders(order_uid);
+ALTER TABLE payments
+    DROP CONSTRAINT payments_order_uid_fkey,
+    ADD CONSTRAINT payments_order_uid_fkey FOREIGN KEY (order_uid) REFERENCES orders(order_uid);
+ALTER TABLE deliveries
+    DROP CONSTRAINT deliveries_order_uid_fkey,
+    ADD CONSTRAINT deliveries_order_uid_fkey FOREIGN KEY (order_uid) REFERENCES orders(order_uid);
+
+ALTER TABLE items
+    DROP CONSTRAINT items_status_check,
+    DROP CONSTRAINT items_total_price_check,
+    DROP CONSTRAINT items_sale_check,
+    DROP CONSTRAINT items_price_check;
+ALTER TABLE payments
+    DROP CONSTRAINT payments_custom_fee_check,
+    DROP CONSTRAINT payments_goods_total_check,
+    DROP CONSTRAINT payments_delivery_cost_check,
+    DROP CONSTRAINT payments_amount_check;
+
+ALTER TABLE items
+    ALTER COLUMN order_uid DROP NOT NULL,
+    ALTER COLUMN chrt_id DROP NOT NULL,
+    ALTER COLUMN track_number DROP NOT NULL,
+    ALTER COLUMN price DROP NOT NULL,
+    ALTER COLUMN rid DROP NOT NULL,
+    ALTER COLUMN name DROP NOT NULL,
+    ALTER COLUMN sale DROP NOT NULL,
+    ALTER COLUMN size DROP NOT NULL,
+    ALTER COLUMN total_price DROP NOT NULL,
+    ALTER COLUMN nm_id DROP NOT NULL,
+    ALTER COLUMN brand DROP NOT NULL,
+    ALTER COLUMN status DROP NOT NULL;
+ALTER TABLE payments
+    ALTER COLUMN transaction DROP NOT NULL,
+    ALTER COLUMN request_id DROP NOT NULL,
+    ALTER COLUMN currency DROP NOT NULL,
+    ALTER COLUMN provider DROP NOT NULL,
+    ALTER COLUMN amount DROP NOT NULL,
+    ALTER COLUMN payment_dt DROP NOT NULL,
+    ALTER COLUMN bank DROP NOT NULL,
+    ALTER COLUMN delivery_cost DROP NOT NULL,
+    ALTER COLUMN goods_total DROP NOT NULL,
+    ALTER COLUMN custom_fee DROP NOT NULL;
+ALTER TABLE deliveries
+    ALTER COLUMN name DROP NOT NULL,
+    ALTER COLUMN phone DROP NOT NULL,
+    ALTER COLUMN zip DROP NOT NULL,
+    ALTER COLUMN city DROP NOT NULL,
+    ALTER COLUMN address DROP NOT NULL,
+    ALTER COLUMN region DROP NOT NULL,
+    ALTER COLUMN email DROP NOT NULL;
+ALTER TABLE orders
+    ALTER COLUMN track_number DROP NOT NULL,
+    ALTER COLUMN entry DROP NOT NULL,
+    ALTER COLUMN locale DROP NOT NULL,
+    ALTER COLUMN internal_signature DROP NOT NULL,
+    ALTER COLUMN customer_id DROP NOT NULL,
+    ALTER COLUMN delivery_service DROP NOT NULL,
+    ALTER COLUMN shardkey DROP NOT NULL,
+    ALTER COLUMN sm_id DROP NOT NULL,
+    ALTER COLUMN date_created DROP NOT NULL,
+    ALTER COLUMN oof_shard DROP NOT NULL;
+
+-- Откат длины упадет, если в БД уже есть значения длиннее прежних ограничений
+ALTER TABLE items
+    ALTER COLUMN track_number TYPE VARCHAR(50),
+    ALTER COLUMN rid TYPE VARCHAR(50),
+    ALTER COLUMN name TYPE VARCHAR(100),
+    ALTER COLUMN size TYPE VARCHAR(10),
+    ALTER COLUMN brand TYPE VARCHAR(100);
+ALTER TABLE payments
+    ALTER COLUMN transaction TYPE VARCHAR(50),
+    ALTER COLUMN request_id TYPE VARCHAR(50),
+    ALTER COLUMN currency TYPE VARCHAR(10),
+    ALTER COLUMN provider TYPE VARCHAR(50),
+    ALTER COLUMN bank TYPE VARCHAR(50);
+ALTER TABLE deliveries
+    ALTER COLUMN city TYPE VARCHAR(100),
+    ALTER COLUMN region TYPE VARCHAR(100);
+ALTER TABLE orders
+    ALTER COLUMN track_number TYPE VARCHAR(50),
+    ALTER COLUMN entry TYPE VARCHAR(50),
+    ALTER COLUMN locale TYPE VARCHAR(10),
+    ALTER COLUMN internal_signature TYPE VARCHAR(100),
+    ALTER COLUMN customer_id TYPE VARCHAR(50),
+    ALTER COLUMN delivery_service TYPE VARCHAR(50),
+    ALTER COLUMN shardkey TYPE VARCHAR(10),
+    ALTER COLUMN oof_shard TYPE VARCHAR(10);
+
+ALTER TABLE audit_events
+    ALTER COLUMN occurred_at TYPE TIMESTAMP USING occurred_at AT TIME ZONE 'UTC';
+ALTER TABLE erasures
+    ALTER COLUMN erased_at TYPE TIMESTAMP USING erased_at AT TIME ZONE 'UTC';
+ALTER TABLE deliveries
+    ALTER COLUMN erased_at TYPE TIMESTAMP USING erased_at AT TIME ZONE 'UTC';
+ALTER TABLE orders
+    ALTER COLUMN date_created TYPE TIMESTAMP USING date_created AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC',
+    ALTER COLUMN deleted_at TYPE TIMESTAMP USING deleted_at AT TIME ZONE 'UTC';
@@ -0,0 +1,286 @@
+-- +goose Up
+-- Реестр UID заказов. Секционированная таблица не может гарантировать уникальность order_uid
+-- без ключа секционирования, поэтому уникальность держит реестр. По нему же находится месяц
+-- заказа для отсечения секций и отметка о том, что заказ ушел в архив
+CREATE TABLE order_keys (
+    order_uid VARCHAR(50) PRIMARY KEY,
+    date_created TIMESTAMPTZ NOT NULL,
+    archived_at TIMESTAMPTZ,
+    archive TEXT
+);
+INSERT INTO order_keys (order_uid, date_created) SELECT order_uid, date_created FROM orders;
+
+ALTER TABLE items RENAME TO items_legacy;
+ALTER SEQUENCE items_id_seq RENAME TO items_legacy_id_seq;
+ALTER TABLE payments RENAME TO payments_legacy;
+ALTER TABLE deliveries RENAME TO deliveries_legacy;
+ALTER TABLE orders RENAME TO orders_legacy;
+
+-- Заказ и его дочерние записи секционируются по месяцу date_created (UTC). Дочерние таблицы
+-- хранят date_created заказа, чтобы строки одного заказа лежали в секциях одного месяца
+CREATE TABLE orders (
+    order_uid VARCHAR(50) NOT NULL REFERENCES order_keys(order_uid),
+    track_number TEXT NOT NULL,
+    entry TEXT NOT NULL,
+    locale TEXT NOT NULL,
+    internal_signature TEXT NOT NULL,
+    customer_id TEXT NOT NULL,
+    delivery_service TEXT NOT NULL,
+    shardkey TEXT NOT NULL,
+    sm_id INTEGER NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL,
+    oof_shard TEXT NOT NULL,
+    deleted_at TIMESTAMPTZ,
+    deleted_by VARCHAR(100),
+    version BIGINT NOT NULL DEFAULT 1,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+) PARTITION BY RANGE (date_created);
+
+CREATE TABLE deliveries (
+    order_uid VARCHAR(50) NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL,
+    name TEXT NOT NULL,
+    phone TEXT NOT NULL,
+    zip TEXT NOT NULL,
+    city TEXT NOT NULL,
+    address TEXT NOT NULL,
+    region TEXT NOT NULL,
+    email TEXT NOT NULL,
+    erased_at TIMESTAMPTZ,
+    dek TEXT,
+    email_bidx VARCHAR(64),
+    phone_bidx VARCHAR(64)
+) PARTITION BY RANGE (date_created);
+
+CREATE TABLE payments (
+    order_uid VARCHAR(50) NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL,
+    transaction TEXT NOT NULL,
+    request_id TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    amount INTEGER NOT NULL CONSTRAINT payments_amount_check CHECK (amount >= 0),
+    payment_dt BIGINT NOT NULL,
+    bank TEXT NOT NULL,
+    delivery_cost INTEGER NOT NULL CONSTRAINT payments_delivery_cost_check CHECK (delivery_cost >= 0),
+    goods_total INTEGER NOT NULL CONSTRAINT payments_goods_total_check CHECK (goods_total >= 0),
+    custom_fee INTEGER NOT NULL CONSTRAINT payments_custom_fee_check CHECK (custom_fee >= 0)
+) PARTITION BY RANGE (date_created);
+
+CREATE TABLE items (
+    id BIGSERIAL NOT NULL,
+    order_uid VARCHAR(50) NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL,
+    chrt_id INTEGER NOT NULL,
+    track_number TEXT NOT NULL,
+    price INTEGER NOT NULL CONSTRAINT items_price_check CHECK (price >= 0),
+    rid TEXT NOT NULL,
+    name TEXT NOT NULL,
+    sale INTEGER NOT NULL CONSTRAINT items_sale_check CHECK (sale >= 0),
+    size TEXT NOT NULL,
+    total_price INTEGER NOT NULL CONSTRAINT items_total_price_check CHECK (total_price >= 0),
+    nm_id INTEGER NOT NULL,
+    brand TEXT NOT NULL,
+    status INTEGER NOT NULL CONSTRAINT items_status_check CHECK (status >= 0)
+) PARTITION BY RANGE (date_created);
+
+-- Секции без месяца (слишком старые даты или даты за горизонтом заранее созданных секций)
+CREATE TABLE orders_default PARTITION OF orders DEFAULT;
+CREATE TABLE deliveries_default PARTITION OF deliveries DEFAULT;
+CREATE TABLE payments_default PARTITION OF payments DEFAULT;
+CREATE TABLE items_default PARTITION OF items DEFAULT;
+
+-- create_order_partitions создает секции <таблица>_pYYYYMM всех таблиц заказа на months
+-- месяцев начиная с start_month. Уже созданные секции пропускаются
+-- +goose StatementBegin
+CREATE FUNCTION create_order_partitions(start_month DATE, months INTEGER) RETURNS void AS $$
+DECLARE
+    month_start DATE;
+    tbl TEXT;
+BEGIN
+    FOR i IN 0 .. months - 1 LOOP
+        month_start := (date_trunc('month', start_month::timestamp) + make_interval(months => i))::date;
+        FOREACH tbl IN ARRAY ARRAY['orders', 'deliveries', 'payments', 'items'] LOOP
+            EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+                tbl || '_' || to_char(month_start, '"p"YYYYMM'), tbl,
+                month_start::timestamp AT TIME ZONE 'UTC',
+                (month_start + interval '1 month')::timestamp AT TIME ZONE 'UTC');
+        END LOOP;
+    END LOOP;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- Секции для имеющихся заказов за последние два года и на три месяца вперед
+SELECT create_order_partitions(start_month::date,
+    ((extract(year FROM age(date_trunc('month', now() AT TIME ZONE 'UTC'), start_month)) * 12
+      + extract(month FROM age(date_trunc('month', now() AT TIME ZONE 'UTC'), start_month)))::int + 4))
+FROM (
+    SELECT GREATEST(
+        COALESCE(date_trunc('month', min(date_created) AT TIME ZONE 'UTC'), date_trunc('month', now() AT TIME ZONE 'UTC')),
+        date_trunc('month', now() AT TIME ZONE 'UTC') - interval '24 months') AS start_month
+    FROM orders_legacy
+) s;
+
+INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+                    shardkey, sm_id, date_created, oof_shard, deleted_at, deleted_by, version, updated_at)
+SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+       shardkey, sm_id, date_created, oof_shard, deleted_at, deleted_by, version, updated_at
+FROM orders_legacy;
+
+INSERT INTO deliveries (order_uid, date_created, name, phone, zip, city, address, region, email,
+                        erased_at, dek, email_bidx, phone_bidx)
+SELECT d.order_uid, o.date_created, d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
+       d.erased_at, d.dek, d.email_bidx, d.phone_bidx
+FROM deliveries_legacy d JOIN orders_legacy o ON o.order_uid = d.order_uid;
+
+INSERT INTO payments (order_uid, date_created, transaction, request_id, currency, provider, amount,
+                      payment_dt, bank, delivery_cost, goods_total, custom_fee)
+SELECT p.order_uid, o.date_created, p.transaction, p.request_id, p.currency, p.provider, p.amount,
+       p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee
+FROM payments_legacy p JOIN orders_legacy o ON o.order_uid = p.order_uid;
+
+INSERT INTO items (id, order_uid, date_created, chrt_id, track_number, price, rid, name, sale, size,
+                   total_price, nm_id, brand, status)
+SELECT i.id, i.order_uid, o.date_created, i.chrt_id, i.track_number, i.price, i.rid, i.name, i.sale, i.size,
+       i.total_price, i.nm_id, i.brand, i.status
+FROM items_legacy i JOIN orders_legacy o ON o.order_uid = i.order_uid;
+SELECT setval('items_id_seq', COALESCE((SELECT max(id) FROM items), 0) + 1, false);
+
+DROP TABLE items_legacy;
+DROP TABLE payments_legacy;
+DROP TABLE deliveries_legacy;
+DROP TABLE orders_legacy;
+
+ALTER TABLE orders ADD PRIMARY KEY (order_uid, date_created);
+ALTER TABLE deliveries
+    ADD PRIMARY KEY (order_uid, date_created),
+    ADD CONSTRAINT deliveries_order_uid_fkey FOREIGN KEY (order_uid, date_created)
+        REFERENCES orders(order_uid, date_created) ON DELETE CASCADE;
+ALTER TABLE payments
+    ADD PRIMARY KEY (order_uid, date_created),
+    ADD CONSTRAINT payments_order_uid_fkey FOREIGN KEY (order_uid, date_created)
+        REFERENCES orders(order_uid, date_created) ON DELETE CASCADE;
+ALTER TABLE items
+    ADD PRIMARY KEY (id, date_created),
+    ADD CONSTRAINT items_order_uid_fkey FOREIGN KEY (order_uid, date_created)
+        REFERENCES orders(order_uid, date_created) ON DELETE CASCADE;
+
+CREATE INDEX items_order_uid_idx ON items (order_uid);
+CREATE INDEX orders_customer_id_idx ON orders (customer_id);
+CREATE INDEX orders_track_number_idx ON orders (track_number);
+CREATE INDEX orders_date_created_idx ON orders (date_created);
+CREATE INDEX deliveries_email_bidx_idx ON deliveries (email_bidx);
+CREATE INDEX deliveries_phone_bidx_idx ON deliveries (phone_bidx);
+CREATE INDEX deliveries_dek_null_idx ON deliveries (order_uid) WHERE dek IS NULL;
+CREATE INDEX order_keys_archived_idx ON order_keys (date_created) WHERE archived_at IS NOT NULL;
+
+-- +goose Down
+-- Архивированные секции не возвращаются: их данные остаются только в файлах архива
+ALTER TABLE items RENAME TO items_partitioned;
+ALTER SEQUENCE items_id_seq RENAME TO items_partitioned_id_seq;
+ALTER TABLE payments RENAME TO payments_partitioned;
+ALTER TABLE deliveries RENAME TO deliveries_partitioned;
+ALTER TABLE orders RENAME TO orders_partitioned;
+
+CREATE TABLE orders (
+    order_uid VARCHAR(50),
+    track_number TEXT NOT NULL,
+    entry TEXT NOT NULL,
+    locale TEXT NOT NULL,
+    internal_signature TEXT NOT NULL,
+    customer_id TEXT NOT NULL,
+    delivery_service TEXT NOT NULL,
+    shardkey TEXT NOT NULL,
+    sm_id INTEGER NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL,
+    oof_shard TEXT NOT NULL,
+    deleted_at TIMESTAMPTZ,
+    deleted_by VARCHAR(100),
+    version BIGINT NOT NULL DEFAULT 1,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+INSERT INTO orders SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+    shardkey, sm_id, date_created, oof_shard, deleted_at, deleted_by, version, updated_at
+FROM orders_partitioned;
+
+CREATE TABLE deliveries (
+    order_uid VARCHAR(50),
+    name TEXT NOT NULL,
+    phone TEXT NOT NULL,
+    zip TEXT NOT NULL,
+    city TEXT NOT NULL,
+    address TEXT NOT NULL,
+    region TEXT NOT NULL,
+    email TEXT NOT NULL,
+    erased_at TIMESTAMPTZ,
+    dek TEXT,
+    email_bidx VARCHAR(64),
+    phone_bidx VARCHAR(64)
+);
+INSERT INTO deliveries SELECT order_uid, name, phone, zip, city, address, region, email,
+    erased_at, dek, email_bidx, phone_bidx
+FROM deliveries_partitioned;
+
+CREATE TABLE payments (
+    order_uid VARCHAR(50),
+    transaction TEXT NOT NULL,
+    request_id TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    amount INTEGER NOT NULL CONSTRAINT payments_amount_check CHECK (amount >= 0),
+    payment_dt BIGINT NOT NULL,
+    bank TEXT NOT NULL,
+    delivery_cost INTEGER NOT NULL CONSTRAINT payments_delivery_cost_check CHECK (delivery_cost >= 0),
+    goods_total INTEGER NOT NULL CONSTRAINT payments_goods_total_check CHECK (goods_total >= 0),
+    custom_fee INTEGER NOT NULL CONSTRAINT payments_custom_fee_check CHECK (custom_fee >= 0)
+);
+INSERT INTO payments SELECT order_uid, transaction, request_id, currency, provider, amount,
+    payment_dt, bank, delivery_cost, goods_total, custom_fee
+FROM payments_partitioned;
+
+CREATE TABLE items (
+    id SERIAL,
+    order_uid VARCHAR(50) NOT NULL,
+    chrt_id INTEGER NOT NULL,
+    track_number TEXT NOT NULL,
+    price INTEGER NOT NULL CONSTRAINT items_price_check CHECK (price >= 0),
+    rid TEXT NOT NULL,
+    name TEXT NOT NULL,
+    sale INTEGER NOT NULL CONSTRAINT items_sale_check CHECK (sale >= 0),
+    size TEXT NOT NULL,
+    total_price INTEGER NOT NULL CONSTRAINT items_total_price_check CHECK (total_price >= 0),
+    nm_id INTEGER NOT NULL,
+    brand TEXT NOT NULL,
+    status INTEGER NOT NULL CONSTRAINT items_status_check CHECK (status >= 0)
+);
+INSERT INTO items SELECT id, order_uid, chrt_id, track_number, price, rid, name, sale, size,
+    total_price, nm_id, brand, status
+FROM items_partitioned;
+SELECT setval('items_id_seq', COALESCE((SELECT max(id) FROM items), 0) + 1, false);
+
+DROP TABLE items_partitioned;
+DROP TABLE payments_partitioned;
+DROP TABLE deliveries_partitioned;
+DROP TABLE orders_partitioned;
+DROP FUNCTION create_order_partitions(DATE, INTEGER);
+DROP TABLE order_keys;
+
+ALTER TABLE orders ADD PRIMARY KEY (order_uid);
+ALTER TABLE deliveries
+    ADD PRIMARY KEY (order_uid),
+    ADD CONSTRAINT deliveries_order_uid_fkey FOREIGN KEY (order_uid) REFERENCES orders(order_uid) ON DELETE CASCADE;
+ALTER TABLE payments
+    ADD PRIMARY KEY (order_uid),
+    ADD CONSTRAINT payments_order_uid_fkey FOREIGN KEY (order_uid) REFERENCES orders(order_uid) ON DELETE CASCADE;
+ALTER TABLE items
+    ADD PRIMARY KEY (id),
+    ADD CONSTRAINT items_order_uid_fkey FOREIGN KEY (order_uid) REFERENCES orders(order_uid) ON DELETE CASCADE;
+
+CREATE INDEX items_order_uid_idx ON items (order_uid);
+CREATE INDEX orders_customer_id_idx ON orders (customer_id);
+CREATE INDEX orders_track_number_idx ON orders (track_number);
+CREATE INDEX orders_date_created_idx ON orders (date_created);
+CREATE INDEX deliveries_email_bidx_idx ON deliveries (email_bidx);
+CREATE INDEX deliveries_phone_bidx_idx ON deliveries (phone_bidx);
+CREATE INDEX deliveries_dek_null_idx ON deliveries (order_uid) WHERE dek IS NULL;
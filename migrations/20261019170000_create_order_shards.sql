@@ -0,0 +1,11 @@
+-- +goose Up
+-- Каталог шардов: в каком шарде лежит заказ. Читается только в основной БД (шард 0),
+-- в остальных шардах таблица пустая. Заказ без записи в каталоге ищется в основной БД:
+-- так читаются заказы, созданные до включения шардирования
+CREATE TABLE order_shards (
+    order_uid TEXT PRIMARY KEY,
+    shard INTEGER NOT NULL CONSTRAINT order_shards_shard_check CHECK (shard >= 0)
+);
+
+-- +goose Down
+DROP TABLE order_shards;
@@ -0,0 +1,14 @@
+// Package migrations встраивает SQL-миграции схемы в бинарь.
+package migrations
+
+import "embed"
+
+// FS — файлы миграций goose.
+//
+//go:embed *.sql
+var FS embed.FS
+
+// SQLiteFS — миграции встроенного хранилища sqlite, лежат в каталоге sqlite.
+//
+//go:embed sqlite/*.sql
+var SQLiteFS embed.FS
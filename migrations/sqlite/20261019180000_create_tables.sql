@@ -0,0 +1,47 @@
+-- +goose Up
+-- Схема встроенного хранилища для локальной разработки. Заказ хранится JSON-документом,
+-- колонки рядом с ним нужны для фильтров листинга. Время — микросекунды Unix (UTC)
+CREATE TABLE orders (
+    order_uid TEXT PRIMARY KEY,
+    customer_id TEXT NOT NULL,
+    track_number TEXT NOT NULL,
+    delivery_service TEXT NOT NULL,
+    locale TEXT NOT NULL,
+    email TEXT NOT NULL, -- в нижнем регистре
+    phone TEXT NOT NULL,
+    date_created INTEGER NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1,
+    updated_at INTEGER NOT NULL,
+    deleted_at INTEGER,
+    deleted_by TEXT,
+    erased_at INTEGER,
+    data TEXT NOT NULL
+);
+CREATE INDEX orders_customer_id_idx ON orders (customer_id);
+CREATE INDEX orders_date_created_idx ON orders (date_created, order_uid);
+
+CREATE TABLE erasures (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_uid TEXT NOT NULL REFERENCES orders (order_uid),
+    customer_id TEXT NOT NULL,
+    erased_by TEXT NOT NULL,
+    fields TEXT NOT NULL,
+    erased_at INTEGER NOT NULL
+);
+
+CREATE TABLE audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    occurred_at INTEGER NOT NULL,
+    actor TEXT NOT NULL,
+    source TEXT NOT NULL,
+    action TEXT NOT NULL,
+    order_uid TEXT,
+    changes TEXT,
+    request_id TEXT
+);
+CREATE INDEX audit_events_order_uid_idx ON audit_events (order_uid, occurred_at);
+
+-- +goose Down
+DROP TABLE audit_events;
+DROP TABLE erasures;
+DROP TABLE orders;
@@ -0,0 +1,29 @@
+#!/bin/sh
+# Сравнивает бенчмарки репозитория на pgx с реализацией на database/sql и lib/pq
+# до перехода на pgx. Нужна тестовая БД в ORDER_SERVICE_TEST_POSTGRES_DSN: бенчмарки
+# работают в отдельных схемах и удаляют их. Результаты — в bench-results/.
+set -eu
+
+: "${ORDER_SERVICE_TEST_POSTGRES_DSN:?set ORDER_SERVICE_TEST_POSTGRES_DSN to a test database}"
+# Родитель коммита с переходом на pgx
+BASELINE=${BASELINE:-ffcaa3e6bd1a5caa90ae2ea404135cb321c892c9^}
+COUNT=${COUNT:-10}
+
+root=$(git rev-parse --show-toplevel)
+out="$root/bench-results"
+worktree="$(mktemp -d)/baseline"
+trap 'git -C "$root" worktree remove --force "$worktree"' EXIT
+
+mkdir -p "$out"
+git -C "$root" worktree add --detach "$worktree" "$BASELINE" >/dev/null
+cp "$root/internal/repository/testdata/baseline_bench_test.go" "$worktree/internal/repository/"
+
+bench='^BenchmarkPostgres(Create|GetByID)$'
+(cd "$worktree" && go test ./internal/repository -run '^$' -bench "$bench" -benchmem -count "$COUNT") | tee "$out/baseline.txt"
+(cd "$root" && go test ./internal/repository -run '^$' -bench "$bench" -benchmem -count "$COUNT") | tee "$out/pgx.txt"
+
+if command -v benchstat >/dev/null 2>&1; then
+	benchstat "$out/baseline.txt" "$out/pgx.txt" | tee "$out/benchstat.txt"
+else
+	echo "benchstat not found: go install golang.org/x/perf/cmd/benchstat@latest" >&2
+fi